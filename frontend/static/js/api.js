@@ -3,6 +3,17 @@
 window.api = {
     baseURL: '/api',
 
+    // CSRF token of the current session, returned with the user by
+    // /api/user, login and registration and sent with every write
+    csrfToken: null,
+
+    /**
+     * Headers that authorize a write with the current session
+     */
+    csrfHeaders() {
+        return this.csrfToken ? { 'X-CSRF-Token': this.csrfToken } : {};
+    },
+
     /**
      * Make HTTP request
      */
@@ -17,6 +28,11 @@ window.api = {
             ...options
         };
 
+        const method = (config.method || 'GET').toUpperCase();
+        if (!['GET', 'HEAD', 'OPTIONS'].includes(method)) {
+            config.headers = { ...config.headers, ...this.csrfHeaders() };
+        }
+
         try {
             console.log('📡 Making request to:', url);
             console.log('📡 Request config:', config);
@@ -28,6 +44,10 @@ window.api = {
             const data = await response.json();
             console.log('📡 Response data:', data);
 
+            if (data && data.data && data.data.csrfToken) {
+                this.csrfToken = data.data.csrfToken;
+            }
+
             if (!response.ok) {
                 const errorMessage = data.error || data.message || `HTTP error! status: ${response.status}`;
 
@@ -106,14 +126,51 @@ window.api = {
         return this.post('/login', { identifier, password });
     },
 
+    async loginTwoFactor(code) {
+        return this.post('/login/2fa', { code });
+    },
+
     async logout() {
-        return this.post('/logout');
+        try {
+            return await this.post('/logout');
+        } finally {
+            this.csrfToken = null;
+        }
     },
 
     async getCurrentUser() {
         return this.get('/user');
     },
 
+    // Session endpoints
+    async forgotPassword(email) {
+        return this.post('/password/forgot', { email });
+    },
+
+    async resetPassword(token, password) {
+        return this.post('/password/reset', { token, password });
+    },
+
+    async verifyEmail(token) {
+        return this.get('/verify-email', { token });
+    },
+
+    async resendVerificationEmail() {
+        return this.post('/verify-email/resend');
+    },
+
+    async getSessions() {
+        return this.get('/sessions');
+    },
+
+    async revokeSession(sessionId) {
+        return this.delete(`/sessions/${encodeURIComponent(sessionId)}`);
+    },
+
+    async revokeOtherSessions() {
+        return this.delete('/sessions/others');
+    },
+
     // Posts endpoints
     async getPosts(params = {}) {
         return this.get('/posts', params);
@@ -127,6 +184,14 @@ window.api = {
         return this.post('/posts', postData);
     },
 
+    async updatePost(postId, postData) {
+        return this.put(`/posts/${postId}`, postData);
+    },
+
+    async deletePost(postId) {
+        return this.delete(`/posts/${postId}`);
+    },
+
     async likePost(postId, isLike) {
         return this.post('/like', { postId, isLike });
     },
@@ -179,10 +244,46 @@ window.api = {
         return this.post('/messages', { recipientId, content, messageType });
     },
 
+    async editMessage(messageId, content) {
+        return this.put(`/messages/${messageId}`, { content });
+    },
+
+    async deleteMessage(messageId) {
+        return this.delete(`/messages/${messageId}`);
+    },
+
     async markAsRead(conversationId) {
         return this.put(`/messages/${conversationId}/read`);
     },
 
+    async createGroup(name, participantIds) {
+        return this.post('/conversations', { name, participantIds });
+    },
+
+    async renameGroup(conversationId, name) {
+        return this.put(`/conversations/${conversationId}`, { name });
+    },
+
+    async getConversationMessages(conversationId, limit = 50, offset = 0) {
+        return this.get(`/conversations/${conversationId}/messages?limit=${limit}&offset=${offset}`);
+    },
+
+    async sendConversationMessage(conversationId, content) {
+        return this.post(`/conversations/${conversationId}/messages`, { content });
+    },
+
+    async addGroupMembers(conversationId, userIds) {
+        return this.post(`/conversations/${conversationId}/members`, { userIds });
+    },
+
+    async leaveConversation(conversationId) {
+        return this.post(`/conversations/${conversationId}/leave`);
+    },
+
+    async markConversationRead(conversationId) {
+        return this.post(`/conversations/${conversationId}/read`);
+    },
+
     async searchUsers(query) {
         return this.get(`/users/search?q=${encodeURIComponent(query)}`);
     },
@@ -196,6 +297,109 @@ window.api = {
         return this.put('/profile', profileData);
     },
 
+    async changePassword(currentPassword, newPassword) {
+        return this.put('/account/password', { currentPassword, newPassword });
+    },
+
+    async changeEmail(password, email) {
+        return this.put('/account/email', { password, email });
+    },
+
+    async changeNickname(password, nickname) {
+        return this.put('/account/nickname', { password, nickname });
+    },
+
+    async getTwoFactorStatus() {
+        return this.get('/2fa');
+    },
+
+    async setupTwoFactor(password) {
+        return this.post('/2fa/setup', { password });
+    },
+
+    async enableTwoFactor(code) {
+        return this.post('/2fa/enable', { code });
+    },
+
+    async disableTwoFactor(password, code) {
+        return this.post('/2fa/disable', { password, code });
+    },
+
+    async regenerateRecoveryCodes(password, code) {
+        return this.post('/2fa/recovery-codes', { password, code });
+    },
+
+    // Admin endpoints
+    async getStaff() {
+        return this.get('/admin/staff');
+    },
+
+    async setUserRole(user, role) {
+        return this.put('/admin/roles', { user, role });
+    },
+
+    async getAuditEvents(filters = {}, cursor = null) {
+        return this.get('/admin/audit', { ...filters, cursor });
+    },
+
+    // The export is a file download, so it is linked to rather than fetched
+    auditExportURL(filters = {}, format = 'csv') {
+        const params = new URLSearchParams({ ...filters, format });
+        return `${this.baseURL}/admin/audit/export?${params}`;
+    },
+
+    // Blocked users
+    async getBlockedUsers() {
+        return this.get('/blocks');
+    },
+
+    async blockUser(userId) {
+        return this.post('/blocks', { userId });
+    },
+
+    async unblockUser(userId) {
+        return this.delete(`/blocks/${encodeURIComponent(userId)}`);
+    },
+
+    // Report and moderation endpoints
+    async createReport(targetType, targetId, reason) {
+        return this.post('/reports', { targetType, targetId: String(targetId), reason });
+    },
+
+    async getReports(status = 'pending', cursor = null) {
+        const params = new URLSearchParams({ status });
+        if (cursor) params.append('cursor', cursor);
+        return this.get(`/moderation/reports?${params}`);
+    },
+
+    async getReport(reportId) {
+        return this.get(`/moderation/reports/${reportId}`);
+    },
+
+    async claimReport(reportId) {
+        return this.post(`/moderation/reports/${reportId}/claim`, {});
+    },
+
+    async resolveReport(reportId, action, note = '', suspendDays = 0) {
+        return this.post(`/moderation/reports/${reportId}/resolve`, { action, note, suspendDays });
+    },
+
+    async dismissReport(reportId, note = '') {
+        return this.post(`/moderation/reports/${reportId}/dismiss`, { note });
+    },
+
+    async getSuspensions() {
+        return this.get('/moderation/suspensions');
+    },
+
+    async suspendUser(user, reason, days) {
+        return this.post('/moderation/suspensions', { user, reason, days });
+    },
+
+    async liftSuspension(userId) {
+        return this.delete(`/moderation/suspensions/${encodeURIComponent(userId)}`);
+    },
+
     async uploadAvatar(formData) {
         return this.request('/upload/avatar', {
             method: 'POST',
@@ -208,6 +412,19 @@ window.api = {
         return this.put('/profile/avatar', avatarData);
     },
 
+    // Notifications endpoints
+    async getNotifications(params = {}) {
+        return this.get('/notifications', params);
+    },
+
+    async markNotificationsRead(ids) {
+        return this.post('/notifications/read', ids ? { ids } : { all: true });
+    },
+
+    async getUnreadNotificationsCount() {
+        return this.get('/notifications/unread-count');
+    },
+
 
 
     // Categories endpoints
@@ -67,6 +67,11 @@ window.auth = {
             }
 
             const response = await window.api.login(identifier, password);
+
+            // Accounts with two-factor authentication need a code first
+            if (response.success && response.data && response.data.twoFactorRequired) {
+                return { success: false, twoFactorRequired: true };
+            }
             
             if (response.success && response.data) {
                 this.currentUser = response.data;
@@ -87,6 +92,29 @@ window.auth = {
         }
     },
 
+    /**
+     * Complete a login that needs a two-factor code
+     */
+    async loginTwoFactor(code) {
+        if (!code) {
+            throw new Error('Authentication code is required');
+        }
+
+        const response = await window.api.loginTwoFactor(code);
+        if (!response.success || !response.data) {
+            throw new Error(response.error || 'Login failed');
+        }
+
+        this.currentUser = response.data;
+        this.isAuthenticated = true;
+
+        if (window.forumApp && window.forumApp.notificationComponent) {
+            window.forumApp.notificationComponent.success(`Welcome back, ${this.currentUser.nickname}!`);
+        }
+
+        return { success: true, user: this.currentUser };
+    },
+
     /**
      * Logout user
      */
@@ -162,6 +190,20 @@ window.auth = {
         return this.currentUser;
     },
 
+    /**
+     * Check whether the current user's role grants a permission
+     */
+    can(permission) {
+        return this.isLoggedIn() && (this.currentUser.permissions || []).includes(permission);
+    },
+
+    /**
+     * Check whether the current user can report content by another user
+     */
+    canReport(authorId) {
+        return this.isLoggedIn() && this.currentUser.id !== authorId;
+    },
+
     /**
      * Validate registration data
      */
@@ -147,6 +147,10 @@ window.forumApp = {
             createPost: window.CreatePostPage ? window.CreatePostPage.render.bind(window.CreatePostPage) : this.defaultPageHandler('Create Post'),
             messages: window.MessagesPage ? window.MessagesPage.render.bind(window.MessagesPage) : this.defaultPageHandler('Messages'),
             profile: window.ProfilePage ? window.ProfilePage.render.bind(window.ProfilePage) : this.defaultPageHandler('Profile'),
+            forgotPassword: window.ForgotPasswordPage ? window.ForgotPasswordPage.render.bind(window.ForgotPasswordPage) : this.defaultPageHandler('Forgot Password'),
+            resetPassword: window.ResetPasswordPage ? window.ResetPasswordPage.render.bind(window.ResetPasswordPage) : this.defaultPageHandler('Reset Password'),
+            verifyEmail: window.VerifyEmailPage ? window.VerifyEmailPage.render.bind(window.VerifyEmailPage) : this.defaultPageHandler('Verify Email'),
+            admin: window.AdminPage ? window.AdminPage.render.bind(window.AdminPage) : this.defaultPageHandler('Admin'),
 
         };
 
@@ -191,6 +195,19 @@ window.forumApp = {
             redirectIfAuth: true
         });
 
+        this.router.addRoute('/forgot-password', this.pages.forgotPassword, {
+            title: 'Forum - Forgot Password',
+            redirectIfAuth: true
+        });
+
+        this.router.addRoute('/reset-password', this.pages.resetPassword, {
+            title: 'Forum - Reset Password'
+        });
+
+        this.router.addRoute('/verify-email', this.pages.verifyEmail, {
+            title: 'Forum - Verify Email'
+        });
+
         this.router.addRoute('/posts', this.pages.posts, {
             title: 'Forum - Posts',
             requiresAuth: true
@@ -216,6 +233,11 @@ window.forumApp = {
             requiresAuth: true
         });
 
+        this.router.addRoute('/admin', this.pages.admin, {
+            title: 'Forum - Admin',
+            requiresAuth: true
+        });
+
 
 
         // Error test routes
@@ -319,11 +341,21 @@ window.forumApp = {
             case 'new_post':
                 this.handleNewPost(message.data);
                 break;
+            case 'user_suspended':
+                this.handleUserSuspended(message.data);
+                break;
             case 'notification':
                 this.handleNotification(message.data);
                 break;
             case 'new_message':
             case 'message_read':
+            case 'message_delivered':
+            case 'message_sent':
+            case 'message_error':
+            case 'typing_indicator':
+            case 'message_edited':
+            case 'message_deleted':
+            case 'conversation_updated':
                 // Forward messaging-related messages to the messages page if it exists
                 console.log('📨 Main App: Forwarding message to messages page:', message.type);
                 if (window.messagesPage && window.messagesPage.handleWebSocketMessage) {
@@ -376,6 +408,24 @@ window.forumApp = {
         }
     },
 
+    /**
+     * Handle the signed-in user being suspended: the server has already
+     * ended their sessions, so sign out here too
+     */
+    handleUserSuspended(data) {
+        console.log('⛔ Account suspended:', data);
+
+        const until = data.expiresAt ? `until ${window.utils.formatDate(data.expiresAt)}` : 'permanently';
+        if (this.notificationComponent) {
+            this.notificationComponent.error(`Your account has been suspended ${until}: ${data.reason}`, 0);
+        }
+
+        this.onSessionEnded();
+        if (this.router) {
+            this.router.navigate('/login');
+        }
+    },
+
     /**
      * Update UI based on authentication state
      */
@@ -388,6 +438,7 @@ window.forumApp = {
         const userNickname = document.getElementById('user-nickname');
         const userAvatar = document.getElementById('user-avatar');
         const navigation = document.querySelector('.nav-links');
+        const adminLink = document.getElementById('admin-link');
 
         if (this.isAuthenticated && this.currentUser) {
             // Show authenticated UI - full app interface
@@ -403,6 +454,11 @@ window.forumApp = {
                 console.error('❌ Sidebar element not found!');
             }
 
+            // Only staff see the admin page
+            if (adminLink) {
+                adminLink.style.display = ['report.review', 'user.suspend', 'user.role.assign', 'audit.view'].some(perm => window.auth.can(perm)) ? 'block' : 'none';
+            }
+
             // Update user info
             if (userNickname) userNickname.textContent = this.currentUser.nickname;
             if (userAvatar) {
@@ -463,6 +519,27 @@ window.forumApp = {
         }
     },
 
+    /**
+     * Forget the signed-in user after the server ended their session, for
+     * example when a password reset signed out every device
+     */
+    onSessionEnded() {
+        window.auth.currentUser = null;
+        window.auth.isAuthenticated = false;
+        window.api.csrfToken = null;
+
+        this.currentUser = null;
+        this.isAuthenticated = false;
+
+        if (this.websocket) {
+            this.websocket.close();
+            this.websocket = null;
+            this.isWebSocketConnected = false;
+        }
+
+        this.updateAuthUI();
+    },
+
     /**
      * Handle successful login/registration
      */
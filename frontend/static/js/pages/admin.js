@@ -0,0 +1,548 @@
+// Admin Page Component, for staff to run the forum
+window.AdminPage = {
+    roles: ['user', 'moderator', 'admin'],
+    reportStatus: 'pending',
+    reportsCursor: null,
+    auditFilters: {},
+    auditCursor: null,
+
+    async render() {
+        window.forumApp.setCurrentPage('admin');
+
+        if (!window.auth.requireAuth()) {
+            return;
+        }
+
+        // The page is hidden from users without a staff permission
+        const canReview = window.auth.can('report.review');
+        const canSuspend = window.auth.can('user.suspend');
+        const canAssign = window.auth.can('user.role.assign');
+        const canAudit = window.auth.can('audit.view');
+        if (!canReview && !canSuspend && !canAssign && !canAudit) {
+            window.showErrorPage(404);
+            return;
+        }
+
+        const mainContent = document.getElementById('main-content');
+        mainContent.innerHTML = `
+            <div class="admin-container">
+                <div class="admin-header">
+                    <h1>Admin</h1>
+                </div>
+
+                ${canReview ? `
+                <!-- Reports -->
+                <div class="profile-card admin-reports">
+                    <div class="admin-reports-header">
+                        <h2>Reports</h2>
+                        <select id="admin-report-status" class="admin-staff-role">
+                            <option value="pending">Pending</option>
+                            <option value="resolved">Resolved</option>
+                            <option value="dismissed">Dismissed</option>
+                            <option value="all">All</option>
+                        </select>
+                    </div>
+
+                    <div id="admin-report-list" class="admin-report-list">
+                        <div class="loading">Loading reports...</div>
+                    </div>
+                </div>
+                ` : ''}
+
+                ${canSuspend ? `
+                <!-- Suspensions -->
+                <div class="profile-card admin-suspensions">
+                    <h2>Suspensions</h2>
+                    <p class="form-help">Suspended users are signed out everywhere and cannot sign in until the suspension ends or is lifted.${window.auth.can('user.ban') ? ' Bans never end on their own.' : ''}</p>
+
+                    <form id="admin-suspend-form" class="admin-role-form">
+                        <div class="form-group">
+                            <label for="admin-suspend-user">Nickname or Email</label>
+                            <input type="text" id="admin-suspend-user" name="user" required>
+                        </div>
+                        <div class="form-group">
+                            <label for="admin-suspend-reason">Reason</label>
+                            <input type="text" id="admin-suspend-reason" name="reason" maxlength="1000" required>
+                        </div>
+                        <div class="form-group">
+                            <label for="admin-suspend-days">Length</label>
+                            <select id="admin-suspend-days" name="days">
+                                <option value="1">1 day</option>
+                                <option value="7" selected>7 days</option>
+                                <option value="30">30 days</option>
+                                <option value="365">1 year</option>
+                                ${window.auth.can('user.ban') ? '<option value="0">Permanent ban</option>' : ''}
+                            </select>
+                        </div>
+                        <button type="submit" class="btn btn-primary btn-sm">Suspend</button>
+                    </form>
+
+                    <div id="admin-suspension-list" class="admin-staff-list">
+                        <div class="loading">Loading suspensions...</div>
+                    </div>
+                </div>
+                ` : ''}
+
+                ${canAssign ? `
+                <!-- Roles -->
+                <div class="profile-card admin-roles">
+                    <h2>Roles</h2>
+                    <p class="form-help">Moderators can edit and delete any post or comment, review reports and suspend users. Admins can also ban users, change roles and read the audit log.</p>
+
+                    <form id="admin-role-form" class="admin-role-form">
+                        <div class="form-group">
+                            <label for="admin-role-user">Nickname or Email</label>
+                            <input type="text" id="admin-role-user" name="user" required>
+                        </div>
+                        <div class="form-group">
+                            <label for="admin-role-role">Role</label>
+                            <select id="admin-role-role" name="role">
+                                ${this.roleOptions('moderator')}
+                            </select>
+                        </div>
+                        <button type="submit" class="btn btn-primary btn-sm">Set Role</button>
+                    </form>
+
+                    <div id="admin-staff-list" class="admin-staff-list">
+                        <div class="loading">Loading staff...</div>
+                    </div>
+                </div>
+                ` : ''}
+
+                ${canAudit ? `
+                <!-- Audit log -->
+                <div class="profile-card admin-audit">
+                    <h2>Audit Log</h2>
+                    <p class="form-help">Sign-ins, session and account changes, deleted content and moderator actions, newest first.</p>
+
+                    <form id="admin-audit-form" class="admin-role-form">
+                        <div class="form-group">
+                            <label for="admin-audit-actor">Actor</label>
+                            <input type="text" id="admin-audit-actor" name="actor" placeholder="Nickname or email">
+                        </div>
+                        <div class="form-group">
+                            <label for="admin-audit-action">Action</label>
+                            <select id="admin-audit-action" name="action">
+                                <option value="">All</option>
+                                <option value="auth">Authentication</option>
+                                <option value="session">Sessions</option>
+                                <option value="profile">Profile changes</option>
+                                <option value="content">Deleted content</option>
+                                <option value="moderation">Moderation</option>
+                                <option value="auth.login_failed">Failed sign-ins</option>
+                            </select>
+                        </div>
+                        <div class="form-group">
+                            <label for="admin-audit-ip">IP Address</label>
+                            <input type="text" id="admin-audit-ip" name="ip">
+                        </div>
+                        <div class="form-group">
+                            <label for="admin-audit-from">From</label>
+                            <input type="date" id="admin-audit-from" name="from">
+                        </div>
+                        <div class="form-group">
+                            <label for="admin-audit-to">To</label>
+                            <input type="date" id="admin-audit-to" name="to">
+                        </div>
+                        <button type="submit" class="btn btn-primary btn-sm">Filter</button>
+                    </form>
+
+                    <div class="admin-audit-export">
+                        <span class="form-help">Export:</span>
+                        <a id="admin-audit-csv" class="btn btn-secondary btn-sm" download>CSV</a>
+                        <a id="admin-audit-json" class="btn btn-secondary btn-sm" download>JSON</a>
+                    </div>
+
+                    <div id="admin-audit-list" class="admin-audit-list">
+                        <div class="loading">Loading audit log...</div>
+                    </div>
+                </div>
+                ` : ''}
+            </div>
+        `;
+
+        if (canReview) {
+            const statusSelect = document.getElementById('admin-report-status');
+            statusSelect.value = this.reportStatus;
+            statusSelect.addEventListener('change', () => {
+                this.reportStatus = statusSelect.value;
+                this.loadReports();
+            });
+            this.loadReports();
+        }
+
+        if (canSuspend) {
+            document.getElementById('admin-suspend-form').addEventListener('submit', (event) => this.submitSuspension(event));
+            this.loadSuspensions();
+        }
+
+        if (canAssign) {
+            document.getElementById('admin-role-form').addEventListener('submit', (event) => this.submitRole(event));
+            this.loadStaff();
+        }
+
+        if (canAudit) {
+            document.getElementById('admin-audit-form').addEventListener('submit', (event) => this.submitAuditFilters(event));
+            this.auditFilters = {};
+            this.loadAuditEvents();
+        }
+    },
+
+    async loadReports(cursor = null) {
+        const container = document.getElementById('admin-report-list');
+        if (!container) return;
+
+        try {
+            const response = await window.api.getReports(this.reportStatus, cursor);
+            const reports = response.data || [];
+            this.reportsCursor = response.nextCursor || null;
+
+            if (!cursor) {
+                container.innerHTML = '';
+            }
+            container.querySelector('.admin-report-more')?.remove();
+
+            if (reports.length === 0 && !cursor) {
+                container.innerHTML = '<p class="form-help">No reports here.</p>';
+                return;
+            }
+
+            container.insertAdjacentHTML('beforeend', reports.map(report => this.renderReport(report)).join(''));
+            if (this.reportsCursor) {
+                container.insertAdjacentHTML('beforeend',
+                    '<div class="load-more admin-report-more"><button class="btn btn-secondary btn-sm">Load more</button></div>');
+                container.querySelector('.admin-report-more button').addEventListener('click', () => this.loadReports(this.reportsCursor));
+            }
+        } catch (error) {
+            console.error('Failed to load reports:', error);
+            container.innerHTML = '<div class="error-message">Failed to load reports</div>';
+        }
+    },
+
+    renderReport(report) {
+        const escape = window.utils.escapeHtml;
+        const pending = report.status === 'open' || report.status === 'claimed';
+        const currentUser = window.auth.getCurrentUser();
+        const claimedByOther = report.status === 'claimed' && report.claimedBy !== currentUser.nickname;
+
+        return `
+            <div class="admin-report-item" data-report-id="${report.id}">
+                <div class="admin-report-meta">
+                    <span class="admin-role-badge">${escape(report.targetType)}</span>
+                    ${report.targetUser ? `<span class="admin-staff-name">${escape(report.targetUser)}</span>` : ''}
+                    <span class="form-help">reported by ${escape(report.reporter)} ${window.utils.formatDate(report.createdAt)}</span>
+                    <span class="admin-report-status admin-report-${report.status}">${escape(report.status)}</span>
+                </div>
+                <blockquote class="admin-report-snapshot">${escape(report.snapshot).replace(/\n/g, '<br>')}</blockquote>
+                <p class="admin-report-reason"><strong>Reason:</strong> ${escape(report.reason)}</p>
+                ${report.claimedBy && pending ? `<p class="form-help">Claimed by ${escape(report.claimedBy)}</p>` : ''}
+                ${!pending ? `
+                    <p class="form-help">
+                        ${report.status === 'resolved' ? `Resolved with action "${escape(report.action || 'none')}"` : 'Dismissed'}
+                        by ${escape(report.closedBy || 'a former moderator')}${report.note ? `: ${escape(report.note)}` : ''}
+                    </p>
+                ` : ''}
+                ${pending && !claimedByOther ? `
+                    <div class="admin-report-actions">
+                        ${report.status === 'open' ? `
+                            <button class="btn btn-secondary btn-sm" onclick="window.AdminPage.claimReport(${report.id})">Claim</button>
+                        ` : ''}
+                        <select class="admin-staff-role admin-report-action">
+                            <option value="none">No action</option>
+                            ${report.targetType !== 'user' ? '<option value="hide">Hide content</option>' : ''}
+                            <option value="warn">Warn user</option>
+                            <option value="suspend">Suspend user</option>
+                        </select>
+                        <input type="number" class="admin-report-days" min="1" max="365" value="7" title="Suspension days">
+                        <input type="text" class="admin-report-note" placeholder="Note (optional)" maxlength="1000">
+                        <button class="btn btn-primary btn-sm" onclick="window.AdminPage.resolveReport(${report.id})">Resolve</button>
+                        <button class="btn btn-secondary btn-sm" onclick="window.AdminPage.dismissReport(${report.id})">Dismiss</button>
+                    </div>
+                ` : ''}
+                <button class="btn-link admin-report-history" onclick="window.AdminPage.showHistory(${report.id})">History</button>
+                <ul class="admin-report-trail" style="display: none;"></ul>
+            </div>
+        `;
+    },
+
+    reportElement(reportId) {
+        return document.querySelector(`.admin-report-item[data-report-id="${reportId}"]`);
+    },
+
+    async claimReport(reportId) {
+        try {
+            await window.api.claimReport(reportId);
+            window.forumApp.notificationComponent.success('Report claimed');
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to claim report');
+        }
+        this.loadReports();
+    },
+
+    async resolveReport(reportId) {
+        const element = this.reportElement(reportId);
+        const action = element.querySelector('.admin-report-action').value;
+        const note = element.querySelector('.admin-report-note').value.trim();
+        const days = parseInt(element.querySelector('.admin-report-days').value, 10) || 0;
+
+        try {
+            await window.api.resolveReport(reportId, action, note, action === 'suspend' ? days : 0);
+            window.forumApp.notificationComponent.success('Report resolved');
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to resolve report');
+        }
+        this.loadReports();
+    },
+
+    async dismissReport(reportId) {
+        const note = this.reportElement(reportId).querySelector('.admin-report-note').value.trim();
+
+        try {
+            await window.api.dismissReport(reportId, note);
+            window.forumApp.notificationComponent.success('Report dismissed');
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to dismiss report');
+        }
+        this.loadReports();
+    },
+
+    async showHistory(reportId) {
+        const trail = this.reportElement(reportId).querySelector('.admin-report-trail');
+        if (trail.style.display !== 'none') {
+            trail.style.display = 'none';
+            return;
+        }
+
+        try {
+            const response = await window.api.getReport(reportId);
+            const actions = response.data.actions || [];
+            trail.innerHTML = actions.length === 0
+                ? '<li class="form-help">No actions yet.</li>'
+                : actions.map(action => `
+                    <li>
+                        <span class="form-help">${window.utils.formatDate(action.createdAt)}</span>
+                        ${window.utils.escapeHtml(action.moderator || 'A former moderator')}:
+                        ${window.utils.escapeHtml(action.action)} ${window.utils.escapeHtml(action.targetType)} ${window.utils.escapeHtml(action.targetId)}
+                        ${action.note ? `— ${window.utils.escapeHtml(action.note)}` : ''}
+                    </li>
+                `).join('');
+            trail.style.display = 'block';
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to load report history');
+        }
+    },
+
+    async loadSuspensions() {
+        const container = document.getElementById('admin-suspension-list');
+        if (!container) return;
+
+        try {
+            const response = await window.api.getSuspensions();
+            const suspensions = response.data || [];
+            const canBan = window.auth.can('user.ban');
+
+            if (suspensions.length === 0) {
+                container.innerHTML = '<p class="form-help">No one is suspended.</p>';
+                return;
+            }
+
+            container.innerHTML = suspensions.map(suspension => `
+                <div class="admin-staff-item">
+                    <div>
+                        <span class="admin-staff-name">${window.utils.escapeHtml(suspension.nickname)}</span>
+                        <span class="form-help">
+                            ${suspension.expiresAt ? `until ${window.utils.formatDate(suspension.expiresAt)}` : 'banned'}
+                            by ${window.utils.escapeHtml(suspension.createdBy || 'a former moderator')}:
+                            ${window.utils.escapeHtml(suspension.reason)}
+                        </span>
+                    </div>
+                    ${suspension.expiresAt || canBan ? `
+                        <button class="btn btn-secondary btn-sm" data-user-id="${window.utils.escapeHtml(suspension.userId)}">Lift</button>
+                    ` : ''}
+                </div>
+            `).join('');
+
+            container.querySelectorAll('button[data-user-id]').forEach(button => {
+                button.addEventListener('click', () => this.liftSuspension(button.dataset.userId));
+            });
+        } catch (error) {
+            console.error('Failed to load suspensions:', error);
+            container.innerHTML = '<div class="error-message">Failed to load suspensions</div>';
+        }
+    },
+
+    async submitSuspension(event) {
+        event.preventDefault();
+
+        const form = event.target;
+        const submitBtn = form.querySelector('button[type="submit"]');
+
+        try {
+            window.utils.setLoading(submitBtn, true, 'Suspending...');
+            const response = await window.api.suspendUser(form.user.value.trim(), form.reason.value.trim(), parseInt(form.days.value, 10));
+            const suspension = response.data;
+            window.forumApp.notificationComponent.success(`${suspension.nickname} is ${suspension.expiresAt ? 'suspended' : 'banned'}`);
+            form.reset();
+            this.loadSuspensions();
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to suspend user');
+        } finally {
+            window.utils.setLoading(submitBtn, false);
+        }
+    },
+
+    async liftSuspension(userId) {
+        try {
+            await window.api.liftSuspension(userId);
+            window.forumApp.notificationComponent.success('Suspension lifted');
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to lift suspension');
+        }
+        this.loadSuspensions();
+    },
+
+    submitAuditFilters(event) {
+        event.preventDefault();
+
+        // Only send the filters that were filled in
+        const filters = {};
+        new FormData(event.target).forEach((value, key) => {
+            if (value.trim()) filters[key] = value.trim();
+        });
+        if (filters.from && filters.to && filters.from > filters.to) {
+            window.forumApp.notificationComponent.error('"From" must be before "To"');
+            return;
+        }
+        this.auditFilters = filters;
+        this.loadAuditEvents();
+    },
+
+    async loadAuditEvents(cursor = null) {
+        const container = document.getElementById('admin-audit-list');
+        if (!container) return;
+
+        document.getElementById('admin-audit-csv').href = window.api.auditExportURL(this.auditFilters, 'csv');
+        document.getElementById('admin-audit-json').href = window.api.auditExportURL(this.auditFilters, 'json');
+
+        try {
+            const response = await window.api.getAuditEvents(this.auditFilters, cursor);
+            const events = response.data || [];
+            this.auditCursor = response.nextCursor || null;
+
+            if (!cursor) {
+                container.innerHTML = '';
+            }
+            container.querySelector('.admin-audit-more')?.remove();
+
+            if (events.length === 0 && !cursor) {
+                container.innerHTML = '<p class="form-help">No matching events.</p>';
+                return;
+            }
+
+            container.insertAdjacentHTML('beforeend', events.map(event => this.renderAuditEvent(event)).join(''));
+            if (this.auditCursor) {
+                container.insertAdjacentHTML('beforeend',
+                    '<div class="load-more admin-audit-more"><button class="btn btn-secondary btn-sm">Load more</button></div>');
+                container.querySelector('.admin-audit-more button').addEventListener('click', () => this.loadAuditEvents(this.auditCursor));
+            }
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to load audit log');
+            container.innerHTML = '<div class="error-message">Failed to load audit log</div>';
+        }
+    },
+
+    renderAuditEvent(event) {
+        const escape = window.utils.escapeHtml;
+        const actor = event.actor || (event.actorId ? 'a deleted user' : 'anonymous');
+        const details = Object.entries(event.metadata || {})
+            .filter(([, value]) => value !== '' && value !== null)
+            .map(([key, value]) => `${escape(key)}: ${escape(typeof value === 'object' ? JSON.stringify(value) : String(value))}`)
+            .join(', ');
+
+        return `
+            <div class="admin-audit-item">
+                <div class="admin-report-meta">
+                    <span class="admin-role-badge">${escape(event.action)}</span>
+                    <span class="admin-staff-name">${escape(actor)}</span>
+                    ${event.targetType ? `<span class="form-help">${escape(event.targetType)} ${escape(event.targetId)}</span>` : ''}
+                    <span class="admin-report-status">${window.utils.formatDate(event.createdAt)}</span>
+                </div>
+                <div class="form-help">
+                    ${event.ipAddress ? `from ${escape(event.ipAddress)}` : ''}${event.ipAddress && details ? ' · ' : ''}${details}
+                </div>
+            </div>
+        `;
+    },
+
+    roleOptions(selected) {
+        return this.roles.map(role => `
+            <option value="${role}" ${role === selected ? 'selected' : ''}>${role.charAt(0).toUpperCase() + role.slice(1)}</option>
+        `).join('');
+    },
+
+    async loadStaff() {
+        const container = document.getElementById('admin-staff-list');
+        if (!container) return;
+
+        try {
+            const response = await window.api.getStaff();
+            const staff = response.data || [];
+            const currentUser = window.auth.getCurrentUser();
+
+            if (staff.length === 0) {
+                container.innerHTML = '<p class="form-help">No moderators or admins yet.</p>';
+                return;
+            }
+
+            container.innerHTML = staff.map(member => `
+                <div class="admin-staff-item">
+                    <span class="admin-staff-name">${window.utils.escapeHtml(member.nickname)}</span>
+                    ${member.id === currentUser.id ? `
+                        <span class="admin-role-badge">${window.utils.escapeHtml(member.role)}</span>
+                    ` : `
+                        <select class="admin-staff-role" data-nickname="${window.utils.escapeHtml(member.nickname)}">
+                            ${this.roleOptions(member.role)}
+                        </select>
+                    `}
+                </div>
+            `).join('');
+
+            container.querySelectorAll('.admin-staff-role').forEach(select => {
+                select.addEventListener('change', () => this.setRole(select.dataset.nickname, select.value));
+            });
+        } catch (error) {
+            console.error('Failed to load staff:', error);
+            container.innerHTML = '<div class="error-message">Failed to load staff</div>';
+        }
+    },
+
+    async submitRole(event) {
+        event.preventDefault();
+
+        const form = event.target;
+        const submitBtn = form.querySelector('button[type="submit"]');
+
+        try {
+            window.utils.setLoading(submitBtn, true, 'Saving...');
+            if (await this.setRole(form.user.value.trim(), form.role.value)) {
+                form.reset();
+            }
+        } finally {
+            window.utils.setLoading(submitBtn, false);
+        }
+    },
+
+    async setRole(user, role) {
+        try {
+            const response = await window.api.setUserRole(user, role);
+            const member = response.data;
+            window.forumApp.notificationComponent.success(`${member.nickname} is now ${member.role === 'admin' ? 'an' : 'a'} ${member.role}`);
+            this.loadStaff();
+            return true;
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to change role');
+            this.loadStaff();
+            return false;
+        }
+    }
+};
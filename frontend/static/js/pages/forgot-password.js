@@ -0,0 +1,77 @@
+// Forgot Password Page Component
+window.ForgotPasswordPage = {
+    async render() {
+        window.forumApp.setCurrentPage('forgot-password');
+
+        // Redirect if already authenticated
+        if (window.auth.redirectIfAuthenticated()) {
+            return;
+        }
+
+        const mainContent = document.getElementById('main-content');
+        mainContent.innerHTML = `
+            <div class="auth-container">
+                <div class="auth-card">
+                    <div class="auth-header">
+                        <h1>Forgot Password</h1>
+                        <p>Enter your account email and we'll send you a reset link</p>
+                    </div>
+
+                    <form id="forgot-password-form" class="auth-form">
+                        <div id="forgot-password-error" class="error-message" style="display: none;"></div>
+                        <div id="forgot-password-success" class="auth-success-message" style="display: none;"></div>
+
+                        <div class="form-group">
+                            <label for="email">Email</label>
+                            <input
+                                type="email"
+                                id="email"
+                                name="email"
+                                required
+                                placeholder="Enter your email"
+                                autocomplete="email"
+                            >
+                        </div>
+
+                        <button type="submit" class="btn btn-primary btn-full">
+                            Send Reset Link
+                        </button>
+                    </form>
+
+                    <div class="auth-footer">
+                        <p>Remembered it? <a href="/login" data-route="/login">Sign in</a></p>
+                    </div>
+                </div>
+            </div>
+        `;
+
+        document.getElementById('forgot-password-form')
+            .addEventListener('submit', this.handleSubmit.bind(this));
+    },
+
+    async handleSubmit(event) {
+        event.preventDefault();
+
+        const form = event.target;
+        const email = new FormData(form).get('email').trim();
+        const submitBtn = form.querySelector('button[type="submit"]');
+        const errorDiv = document.getElementById('forgot-password-error');
+        const successDiv = document.getElementById('forgot-password-success');
+
+        errorDiv.style.display = 'none';
+        successDiv.style.display = 'none';
+
+        try {
+            window.utils.setLoading(submitBtn, true, 'Sending...');
+            const response = await window.api.forgotPassword(email);
+            successDiv.textContent = response.message;
+            successDiv.style.display = 'block';
+            form.reset();
+        } catch (error) {
+            errorDiv.textContent = error.message || 'Failed to send reset link';
+            errorDiv.style.display = 'block';
+        } finally {
+            window.utils.setLoading(submitBtn, false);
+        }
+    }
+};
@@ -9,6 +9,14 @@ window.LoginPage = {
         }
 
         const mainContent = document.getElementById('main-content');
+
+        // OAuth sign-ins of accounts with two-factor authentication land here
+        if (window.utils.url.getParam('twofactor')) {
+            mainContent.innerHTML = '<div class="auth-container"><div class="auth-card"></div></div>';
+            this.renderTwoFactor();
+            return;
+        }
+
         mainContent.innerHTML = `
             <div class="auth-container">
                 <div class="auth-card">
@@ -45,6 +53,10 @@ window.LoginPage = {
                             >
                         </div>
 
+                        <p class="auth-form-link">
+                            <a href="/forgot-password" data-route="/forgot-password">Forgot password?</a>
+                        </p>
+
                         <button type="submit" class="btn btn-primary btn-full">
                             Sign In
                         </button>
@@ -73,6 +85,14 @@ window.LoginPage = {
         `;
 
         this.bindEvents();
+
+        // OAuth sign-ins that cannot complete are sent back here
+        const error = window.utils.url.getParam('error');
+        if (error === 'suspended') {
+            this.showError('This account is suspended and cannot sign in.');
+        } else if (error === 'oauth_unverified') {
+            this.showError('An account with this email already exists. Sign in with your password and verify your email to link it.');
+        }
     },
 
     bindEvents() {
@@ -112,6 +132,8 @@ window.LoginPage = {
             if (result.success) {
                 // Update app state
                 window.forumApp.onAuthSuccess(result.user);
+            } else if (result.twoFactorRequired) {
+                this.renderTwoFactor();
             } else {
                 // Show error from server response
                 this.showError(result.message || 'Invalid credentials. Please check your email/nickname and password.');
@@ -134,6 +156,79 @@ window.LoginPage = {
         }
     },
 
+    /**
+     * Replace the sign-in form with a prompt for the second factor
+     */
+    renderTwoFactor() {
+        const card = document.querySelector('.auth-card');
+        if (!card) return;
+
+        card.innerHTML = `
+            <div class="auth-header">
+                <h1>Two-Factor Authentication</h1>
+                <p>Enter the 6-digit code from your authenticator app, or one of your recovery codes</p>
+            </div>
+
+            <form id="login-2fa-form" class="auth-form">
+                <div id="login-error" class="error-message" style="display: none;"></div>
+
+                <div class="form-group">
+                    <label for="code">Authentication Code</label>
+                    <input
+                        type="text"
+                        id="code"
+                        name="code"
+                        required
+                        placeholder="123456"
+                        autocomplete="one-time-code"
+                        inputmode="text"
+                        maxlength="20"
+                    >
+                </div>
+
+                <button type="submit" class="btn btn-primary btn-full">
+                    Verify
+                </button>
+            </form>
+
+            <div class="auth-footer">
+                <p><a href="/login" data-route="/login">Back to sign in</a></p>
+            </div>
+        `;
+
+        const form = document.getElementById('login-2fa-form');
+        form.addEventListener('submit', this.handleTwoFactor.bind(this));
+        form.querySelector('#code').focus();
+    },
+
+    async handleTwoFactor(event) {
+        event.preventDefault();
+
+        const form = event.target;
+        const code = form.code.value.trim();
+        const submitBtn = form.querySelector('button[type="submit"]');
+
+        this.hideError();
+
+        if (!code) {
+            this.showError('Please enter your authentication code');
+            return;
+        }
+
+        try {
+            window.utils.setLoading(submitBtn, true, 'Verifying...');
+
+            const result = await window.auth.loginTwoFactor(code);
+            window.forumApp.onAuthSuccess(result.user);
+        } catch (error) {
+            console.error('Two-factor login error:', error);
+            this.showError(error.message || 'Verification failed. Please try again.');
+            form.code.value = '';
+        } finally {
+            window.utils.setLoading(submitBtn, false);
+        }
+    },
+
     showError(message) {
         const errorDiv = document.getElementById('login-error');
         if (errorDiv) {
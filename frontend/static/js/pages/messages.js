@@ -4,13 +4,21 @@ class MessagesPage {
         this.conversations = [];
         this.onlineUsers = [];
         this.currentChatUser = null;
+        this.currentChatConversation = null; // Set instead of currentChatUser for group chats
         this.currentChatWindow = null;
         this.messageSound = null;
         this.initialized = false;
 
         // Pagination tracking for each chat
-        this.chatPagination = new Map(); // userId -> { offset, hasMore, loading }
+        this.chatPagination = new Map(); // chat key -> { offset, hasMore, loading }
         this.scrollThrottleTimeout = null;
+
+        // Messages sent over the WebSocket and waiting for their ack
+        this.pendingMessages = new Map(); // tempId -> chat key
+
+        // Outgoing typing indicator state for the open chat
+        this.lastTypingSent = 0;
+        this.typingStopTimeout = null;
     }
 
     async init() {
@@ -33,6 +41,7 @@ class MessagesPage {
                 const data = await response.json();
                 if (data.success) {
                     this.currentUser = data.data;
+                    window.api.csrfToken = data.data.csrfToken;
                     console.log('✅ Current user loaded from API:', this.currentUser.nickname);
                 } else {
                     console.error('❌ Failed to get current user');
@@ -78,8 +87,9 @@ class MessagesPage {
             this.currentChatWindow = null;
         }
 
-        // Reset current chat user
+        // Reset current chat
         this.currentChatUser = null;
+        this.currentChatConversation = null;
 
         // Clear pagination data
         this.chatPagination.clear();
@@ -93,6 +103,22 @@ class MessagesPage {
         // Note: We don't clean up the WebSocket since we're using the main app's WebSocket
     }
 
+    // Key of the open chat: the other user's ID, or the conversation ID for groups
+    currentChatKey() {
+        return this.currentChatConversation || this.currentChatUser;
+    }
+
+    // Whether a message belongs to the open chat
+    isInCurrentChat(message) {
+        if (!this.currentChatWindow) return false;
+        if (this.currentChatConversation) {
+            return message.conversationId === this.currentChatConversation;
+        }
+        // Direct messages always involve the current user, so match the other side
+        return !!message.receiverId &&
+            (message.senderId === this.currentChatUser || message.receiverId === this.currentChatUser);
+    }
+
     // Use main app's WebSocket instead of creating a new one
     getWebSocket() {
         return window.forumApp?.websocket;
@@ -120,6 +146,25 @@ class MessagesPage {
                 console.log('📖 Messages Page: Handling message read');
                 this.handleMessageRead(message.data);
                 break;
+            case 'message_delivered':
+                this.handleMessageDelivered(message.data);
+                break;
+            case 'message_sent':
+                this.handleMessageSent(message.data);
+                break;
+            case 'message_error':
+                this.handleMessageError(message.data);
+                break;
+            case 'typing_indicator':
+                this.handleTypingIndicator(message.data);
+                break;
+            case 'message_edited':
+            case 'message_deleted':
+                this.handleMessageChanged(message.data);
+                break;
+            case 'conversation_updated':
+                this.handleConversationUpdated(message.data);
+                break;
             case 'user_status':
                 console.log('👤 Messages Page: Handling user status');
                 this.handleUserStatus(message.data);
@@ -135,23 +180,194 @@ class MessagesPage {
         // Play notification sound
         this.playNotificationSound();
         
+        const inCurrentChat = this.isInCurrentChat(messageData);
+
         // Show browser notification if not focused on chat
-        if (document.hidden || this.currentChatUser !== messageData.senderId) {
+        if (document.hidden || !inCurrentChat) {
             this.showBrowserNotification(messageData);
         }
         
         // Update conversations list
         this.loadConversations();
         
-        // If chat window is open for this user, add message to chat
-        if (this.currentChatWindow && this.currentChatUser === messageData.senderId) {
+        // If chat window is open for this conversation, add message to chat
+        if (inCurrentChat) {
+            this.showTypingIndicator(false);
             this.displayMessageInChat(messageData);
+            if (this.currentChatConversation) {
+                this.markConversationAsRead(this.currentChatConversation);
+            } else if (!document.hidden) {
+                this.sendReadReceipt([messageData.id]);
+            }
+        }
+    }
+
+    handleMessageSent(ack) {
+        const message = ack.message;
+        this.pendingMessages.delete(ack.tempId);
+
+        // Also shown in this user's other sessions, unless already displayed
+        if (this.isInCurrentChat(message) &&
+            !this.currentChatWindow.querySelector(`[data-message-id="${ack.messageId}"]`)) {
+            this.displayMessageInChat(message);
         }
+
+        this.loadConversations();
     }
 
-    handleMessageRead(data) {
-        console.log('📖 Message read notification:', data);
-        // Update UI to show message as read
+    handleMessageError(ack) {
+        if (!this.pendingMessages.has(ack.tempId)) return;
+        this.pendingMessages.delete(ack.tempId);
+
+        console.error('❌ Failed to send message:', ack.error);
+        alert('Failed to send message: ' + ack.error);
+    }
+
+    handleConversationUpdated(conversation) {
+        this.loadConversations();
+
+        if (this.currentChatConversation !== conversation.id) return;
+
+        // Close the chat if this user left the group from another session
+        const isMember = (conversation.participants || []).some(p => p.userId === this.currentUser.id);
+        if (!isMember) {
+            this.closeChat();
+            return;
+        }
+
+        const name = this.currentChatWindow?.querySelector('.chat-user-name');
+        if (name) name.textContent = conversation.name;
+        const status = this.currentChatWindow?.querySelector('.chat-user-status');
+        if (status) status.textContent = this.formatMemberCount(conversation);
+    }
+
+    handleMessageChanged(message) {
+        const element = this.currentChatWindow?.querySelector(`[data-message-id="${message.id}"]`);
+        if (element) {
+            const isSameSender = element.classList.contains('same-sender');
+            const tempDiv = document.createElement('div');
+            tempDiv.innerHTML = this.createMessageHTML(message, isSameSender);
+            const replacement = tempDiv.querySelector('.message');
+            replacement.dataset.senderId = message.senderId;
+            replacement.dataset.messageId = message.id;
+            replacement.dataset.createdAt = message.createdAt;
+            element.replaceWith(replacement);
+        }
+
+        this.loadConversations();
+    }
+
+    async editMessage(messageId) {
+        const element = this.currentChatWindow?.querySelector(`[data-message-id="${messageId}"]`);
+        const current = element?.querySelector('.message-content')?.textContent || '';
+        const content = prompt('Edit message', current);
+        if (content === null || content.trim() === '' || content === current) return;
+
+        try {
+            const response = await window.api.editMessage(messageId, content.trim());
+            this.handleMessageChanged(response.data);
+        } catch (error) {
+            alert('Failed to edit message: ' + error.message);
+        }
+    }
+
+    async deleteMessage(messageId) {
+        if (!confirm('Delete this message?')) return;
+
+        try {
+            const response = await window.api.deleteMessage(messageId);
+            this.handleMessageChanged(response.data);
+        } catch (error) {
+            alert('Failed to delete message: ' + error.message);
+        }
+    }
+
+    handleTypingIndicator(data) {
+        if (this.currentChatWindow && this.currentChatUser === data.userId) {
+            this.showTypingIndicator(data.isTyping);
+        }
+    }
+
+    showTypingIndicator(isTyping) {
+        const indicator = this.currentChatWindow?.querySelector('.typing-indicator');
+        if (indicator) {
+            indicator.style.display = isTyping ? 'flex' : 'none';
+        }
+    }
+
+    // Called on every keystroke; the server also throttles and times out
+    handleTypingInput(userId) {
+        const now = Date.now();
+        if (now - this.lastTypingSent > 2000) {
+            this.lastTypingSent = now;
+            this.sendTypingIndicator(userId, true);
+        }
+
+        clearTimeout(this.typingStopTimeout);
+        this.typingStopTimeout = setTimeout(() => this.stopTyping(userId), 3000);
+    }
+
+    stopTyping(userId) {
+        clearTimeout(this.typingStopTimeout);
+        this.typingStopTimeout = null;
+        if (this.lastTypingSent) {
+            this.lastTypingSent = 0;
+            this.sendTypingIndicator(userId, false);
+        }
+    }
+
+    sendTypingIndicator(userId, isTyping) {
+        const ws = this.getWebSocket();
+        if (ws && ws.readyState === WebSocket.OPEN) {
+            ws.send(JSON.stringify({
+                type: 'typing_indicator',
+                data: { receiverId: userId, isTyping }
+            }));
+        }
+    }
+
+    handleMessageRead(receipt) {
+        console.log('📖 Message read notification:', receipt);
+        this.updateMessageStatus(receipt.messageIds, 'read');
+
+        // Read in another of this user's sessions
+        if (receipt.readerId === this.currentUser.id) {
+            this.loadConversations();
+        }
+    }
+
+    handleMessageDelivered(receipt) {
+        this.updateMessageStatus(receipt.messageIds, 'delivered');
+    }
+
+    // Moves the ticks of own messages forward; a read message never goes back to delivered
+    updateMessageStatus(messageIds, status) {
+        if (!this.currentChatWindow) return;
+
+        (messageIds || []).forEach(id => {
+            const element = this.currentChatWindow.querySelector(`[data-message-id="${id}"] .message-status`);
+            if (!element || element.dataset.status === 'read') return;
+            element.outerHTML = this.createStatusHTML(status);
+        });
+    }
+
+    createStatusHTML(status) {
+        const ticks = { sent: '✓', delivered: '✓✓', read: '✓✓' };
+        return `<span class="message-status status-${status}" data-status="${status}" title="${status.charAt(0).toUpperCase() + status.slice(1)}">${ticks[status] || ''}</span>`;
+    }
+
+    // Tells the sender over the WebSocket that messages shown in the open chat were read
+    sendReadReceipt(messageIds) {
+        const ws = this.getWebSocket();
+        if (ws && ws.readyState === WebSocket.OPEN) {
+            ws.send(JSON.stringify({ type: 'message_read', data: { messageIds } }));
+        } else {
+            fetch('/api/messages/read', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json', ...window.api.csrfHeaders() },
+                body: JSON.stringify({ messageIds })
+            }).catch(error => console.error('❌ Error sending read receipt:', error));
+        }
     }
 
     handleUserStatus(data) {
@@ -210,7 +426,7 @@ class MessagesPage {
         if (!container) return;
 
         if (this.conversations.length === 0) {
-            container.innerHTML = `
+            container.innerHTML = this.renderNewGroupButton() + `
                 <div class="no-conversations">
                     <p>No conversations yet. Start chatting with online users!</p>
                 </div>
@@ -218,7 +434,23 @@ class MessagesPage {
             return;
         }
 
-        container.innerHTML = this.conversations.map(conv => `
+        container.innerHTML = this.renderNewGroupButton() + this.conversations.map(conv => conv.isGroup ? `
+            <div class="conversation-item" onclick="window.messagesPage.openGroupChat('${conv.id}')">
+                <div class="conversation-avatar">
+                    <div class="avatar-placeholder">${this.escapeHtml(conv.name.charAt(0).toUpperCase())}</div>
+                </div>
+                <div class="conversation-info">
+                    <div class="conversation-header">
+                        <span class="conversation-name">${this.escapeHtml(conv.name)}</span>
+                        <span class="conversation-time" title="${this.formatFullTime(conv.lastMessageTime)}">${this.formatConversationTime(conv.lastMessageTime)}</span>
+                    </div>
+                    <div class="conversation-preview">
+                        <span class="last-message">${conv.lastMessage ? this.escapeHtml(conv.lastMessage) : this.formatMemberCount(conv)}</span>
+                        ${conv.unreadCount > 0 ? `<span class="unread-badge">${conv.unreadCount}</span>` : ''}
+                    </div>
+                </div>
+            </div>
+        ` : `
             <div class="conversation-item" onclick="window.messagesPage.openChat('${conv.otherUserId}', '${conv.otherUserNickname}')">
                 <div class="conversation-avatar">
                     ${conv.otherUserAvatar ?
@@ -241,6 +473,116 @@ class MessagesPage {
         `).join('');
     }
 
+    renderNewGroupButton() {
+        return `<button class="new-group-btn" onclick="window.messagesPage.createGroup()">+ New group</button>`;
+    }
+
+    formatMemberCount(conversation) {
+        const count = (conversation.participants || []).length;
+        return `${count} member${count === 1 ? '' : 's'}`;
+    }
+
+    // Users the current user can pick for a group: online users and direct chat partners
+    knownUsers() {
+        const users = new Map();
+        this.onlineUsers.forEach(user => users.set(user.userId, user.nickname));
+        this.conversations
+            .filter(conv => !conv.isGroup && conv.otherUserId)
+            .forEach(conv => users.set(conv.otherUserId, conv.otherUserNickname));
+        users.delete(this.currentUser.id);
+        return users;
+    }
+
+    // Asks for a comma-separated list of nicknames and returns the matching user IDs
+    promptForUsers(message) {
+        const users = this.knownUsers();
+        if (users.size === 0) {
+            alert('No users available. Start a conversation or wait for users to come online.');
+            return null;
+        }
+
+        const input = prompt(`${message}\nAvailable: ${[...users.values()].join(', ')}`);
+        if (input === null) return null;
+
+        const byNickname = new Map([...users].map(([id, nickname]) => [nickname.toLowerCase(), id]));
+        const ids = [];
+        for (const nickname of input.split(',').map(n => n.trim()).filter(Boolean)) {
+            const id = byNickname.get(nickname.toLowerCase());
+            if (!id) {
+                alert(`Unknown user: ${nickname}`);
+                return null;
+            }
+            ids.push(id);
+        }
+        return ids;
+    }
+
+    async createGroup() {
+        const name = prompt('Group name');
+        if (name === null || name.trim() === '') return;
+
+        const participantIds = this.promptForUsers('Members (comma-separated nicknames)');
+        if (!participantIds || participantIds.length === 0) return;
+
+        try {
+            const response = await window.api.createGroup(name.trim(), participantIds);
+            await this.loadConversations();
+            this.openGroupChat(response.data.id);
+        } catch (error) {
+            alert('Failed to create group: ' + error.message);
+        }
+    }
+
+    async renameGroup(conversationId) {
+        const conversation = this.conversations.find(conv => conv.id === conversationId);
+        const name = prompt('Group name', conversation?.name || '');
+        if (name === null || name.trim() === '') return;
+
+        try {
+            const response = await window.api.renameGroup(conversationId, name.trim());
+            this.handleConversationUpdated(response.data);
+        } catch (error) {
+            alert('Failed to rename group: ' + error.message);
+        }
+    }
+
+    async addGroupMembers(conversationId) {
+        const userIds = this.promptForUsers('Add members (comma-separated nicknames)');
+        if (!userIds || userIds.length === 0) return;
+
+        try {
+            const response = await window.api.addGroupMembers(conversationId, userIds);
+            this.handleConversationUpdated(response.data);
+        } catch (error) {
+            alert('Failed to add members: ' + error.message);
+        }
+    }
+
+    async leaveGroup(conversationId) {
+        if (!confirm('Leave this group?')) return;
+
+        try {
+            await window.api.leaveConversation(conversationId);
+            this.closeChat();
+            this.loadConversations();
+        } catch (error) {
+            alert('Failed to leave group: ' + error.message);
+        }
+    }
+
+    async blockUser(userId) {
+        if (!confirm('Block this user? Neither of you will be able to message the other, and you will no longer see their posts and comments.')) return;
+
+        try {
+            await window.api.blockUser(userId);
+            this.closeChat();
+            this.loadConversations();
+            window.forumApp.notificationComponent.success('User blocked. You can unblock them from your profile.');
+        } catch (error) {
+            alert('Failed to block user: ' + error.message);
+        }
+    }
+
     renderOnlineUsers() {
         const container = document.getElementById('onlineUsersContainer');
         if (!container) return;
@@ -296,13 +638,9 @@ class MessagesPage {
     async openChat(userId, nickname) {
         console.log(`💬 Opening chat with ${nickname} (${userId})`);
         
+        this.closeChat();
         this.currentChatUser = userId;
         
-        // Close existing chat window
-        if (this.currentChatWindow) {
-            this.currentChatWindow.remove();
-        }
-        
         // Create new chat window
         this.currentChatWindow = this.createChatWindow(userId, nickname);
         document.body.appendChild(this.currentChatWindow);
@@ -321,6 +659,55 @@ class MessagesPage {
         await this.markMessagesAsRead(userId);
     }
 
+    async openGroupChat(conversationId) {
+        const conversation = this.conversations.find(conv => conv.id === conversationId);
+        if (!conversation) return;
+
+        console.log(`💬 Opening group chat ${conversation.name} (${conversationId})`);
+
+        this.closeChat();
+        this.currentChatConversation = conversationId;
+
+        this.currentChatWindow = this.createGroupChatWindow(conversation);
+        document.body.appendChild(this.currentChatWindow);
+
+        // Loading group messages also marks the conversation as read
+        await this.loadChatMessages(conversationId);
+        this.loadConversations();
+
+        const input = this.currentChatWindow.querySelector('.message-input');
+        if (input) {
+            setTimeout(() => input.focus(), 100);
+        }
+    }
+
+    createGroupChatWindow(conversation) {
+        const chatWindow = document.createElement('div');
+        chatWindow.className = 'chat-window';
+        chatWindow.innerHTML = `
+            <div class="chat-header">
+                <div class="chat-user-info">
+                    <span class="chat-user-name">${this.escapeHtml(conversation.name)}</span>
+                    <span class="chat-user-status">${this.formatMemberCount(conversation)}</span>
+                </div>
+                <span class="chat-group-actions">
+                    <button class="message-action-btn" onclick="window.messagesPage.renameGroup('${conversation.id}')" title="Rename">✏️</button>
+                    <button class="message-action-btn" onclick="window.messagesPage.addGroupMembers('${conversation.id}')" title="Add members">➕</button>
+                    <button class="message-action-btn" onclick="window.messagesPage.leaveGroup('${conversation.id}')" title="Leave group">🚪</button>
+                </span>
+                <button class="chat-close-btn" onclick="window.messagesPage.closeChat()">×</button>
+            </div>
+            <div class="chat-messages" id="chatMessages-${conversation.id}"></div>
+            <div class="chat-input-container">
+                <input type="text" class="message-input" placeholder="Type a message..."
+                       onkeypress="window.messagesPage.handleMessageKeyPress(event, '${conversation.id}')">
+                <button class="send-btn" onclick="window.messagesPage.sendMessage('${conversation.id}')">Send</button>
+            </div>
+        `;
+
+        return chatWindow;
+    }
+
     createChatWindow(userId, nickname) {
         const chatWindow = document.createElement('div');
         chatWindow.className = 'chat-window';
@@ -330,12 +717,18 @@ class MessagesPage {
                     <span class="chat-user-name">${nickname}</span>
                     <span class="chat-user-status" id="chatUserStatus-${userId}">Online</span>
                 </div>
+                <span class="chat-group-actions">
+                    <button class="message-action-btn" onclick="window.utils.reportContent('user', '${userId}')" title="Report user">🚩</button>
+                    <button class="message-action-btn" onclick="window.messagesPage.blockUser('${userId}')" title="Block user">🚫</button>
+                </span>
                 <button class="chat-close-btn" onclick="window.messagesPage.closeChat()">×</button>
             </div>
             <div class="chat-messages" id="chatMessages-${userId}"></div>
+            <div class="typing-indicator" style="display: none;">${this.escapeHtml(nickname)} is typing…</div>
             <div class="chat-input-container">
                 <input type="text" class="message-input" placeholder="Type a message..." 
-                       onkeypress="window.messagesPage.handleMessageKeyPress(event, '${userId}')">
+                       onkeypress="window.messagesPage.handleMessageKeyPress(event, '${userId}')"
+                       oninput="window.messagesPage.handleTypingInput('${userId}')">
                 <button class="send-btn" onclick="window.messagesPage.sendMessage('${userId}')">Send</button>
             </div>
         `;
@@ -369,7 +762,10 @@ class MessagesPage {
 
             console.log(`📥 Loading messages for ${userId}: limit=${limit}, offset=${offset}, isLoadMore=${isLoadMore}`);
 
-            const response = await fetch(`/api/messages?user=${userId}&limit=${limit}&offset=${offset}`);
+            const url = userId === this.currentChatConversation
+                ? `/api/conversations/${userId}/messages?limit=${limit}&offset=${offset}`
+                : `/api/messages?user=${userId}&limit=${limit}&offset=${offset}`;
+            const response = await fetch(url);
             const data = await response.json();
 
             if (data.success) {
@@ -555,9 +951,21 @@ class MessagesPage {
             <div class="message ${isOwnMessage ? 'own-message' : 'other-message'} ${isSameSender ? 'same-sender' : ''}">
                 <div class="message-header">
                     ${!isOwnMessage && !isSameSender ? `<span class="message-sender">${message.senderNickname}</span>` : ''}
-                    <span class="message-timestamp" title="${fullTime}">${time}</span>
+                    <span class="message-timestamp" title="${fullTime}">${time}${message.editedAt && !message.isDeleted ? ' · edited' : ''}</span>
+                    ${isOwnMessage && message.receiverId && !message.isDeleted ? this.createStatusHTML(message.status || 'sent') : ''}
+                    ${isOwnMessage && !message.isDeleted ? `
+                        <span class="message-actions">
+                            <button class="message-action-btn" onclick="window.messagesPage.editMessage('${message.id}')" title="Edit">✏️</button>
+                            <button class="message-action-btn" onclick="window.messagesPage.deleteMessage('${message.id}')" title="Delete">🗑️</button>
+                        </span>` : ''}
+                    ${!isOwnMessage && !message.isDeleted ? `
+                        <span class="message-actions">
+                            <button class="message-action-btn" onclick="window.utils.reportContent('message', '${message.id}')" title="Report">🚩</button>
+                        </span>` : ''}
                 </div>
-                <div class="message-content">${this.escapeHtml(message.content)}</div>
+                ${message.isDeleted
+                    ? '<div class="message-content message-deleted">This message was deleted</div>'
+                    : `<div class="message-content">${this.escapeHtml(message.content)}</div>`}
             </div>
         `;
     }
@@ -589,12 +997,13 @@ class MessagesPage {
     }
 
     displayMessageInChat(message) {
-        const container = document.getElementById(`chatMessages-${this.currentChatUser}`);
+        const chatKey = this.currentChatKey();
+        const container = document.getElementById(`chatMessages-${chatKey}`);
         if (!container) return;
 
         // Update pagination offset to account for new message
-        if (this.chatPagination.has(this.currentChatUser)) {
-            const pagination = this.chatPagination.get(this.currentChatUser);
+        if (this.chatPagination.has(chatKey)) {
+            const pagination = this.chatPagination.get(chatKey);
             pagination.offset += 1;
         }
 
@@ -649,15 +1058,37 @@ class MessagesPage {
         const content = input.value.trim();
         
         if (!content) return;
+
+        // Group chats are keyed by conversation ID, direct chats by user ID
+        const isGroup = userId === this.currentChatConversation;
+        const target = isGroup ? { conversationId: userId } : { receiverId: userId };
+
+        if (!isGroup) {
+            this.stopTyping(userId);
+        }
+
+        // Prefer the WebSocket; the server acks with message_sent or message_error
+        const ws = this.getWebSocket();
+        if (ws && ws.readyState === WebSocket.OPEN) {
+            const tempId = `tmp-${Date.now()}-${Math.random().toString(36).slice(2)}`;
+            this.pendingMessages.set(tempId, userId);
+            ws.send(JSON.stringify({
+                type: 'private_message',
+                data: { tempId, ...target, content }
+            }));
+            input.value = '';
+            return;
+        }
         
         try {
             const response = await fetch('/api/messages/send', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
+                    ...window.api.csrfHeaders()
                 },
                 body: JSON.stringify({
-                    receiverId: userId,
+                    ...target,
                     content: content
                 })
             });
@@ -691,6 +1122,7 @@ class MessagesPage {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
+                    ...window.api.csrfHeaders()
                 },
                 body: JSON.stringify({
                     senderId: senderId
@@ -701,8 +1133,20 @@ class MessagesPage {
         }
     }
 
+    async markConversationAsRead(conversationId) {
+        try {
+            await window.api.markConversationRead(conversationId);
+        } catch (error) {
+            console.error('❌ Error marking conversation as read:', error);
+        }
+    }
+
     closeChat() {
         if (this.currentChatWindow) {
+            if (this.currentChatUser) {
+                this.stopTyping(this.currentChatUser);
+            }
+
             // Clean up scroll listener
             const container = this.currentChatWindow.querySelector('[id^="chatMessages-"]');
             if (container && container.scrollHandler) {
@@ -710,14 +1154,15 @@ class MessagesPage {
                 container.scrollHandler = null;
             }
 
-            // Reset pagination for this user
-            if (this.currentChatUser) {
-                this.chatPagination.delete(this.currentChatUser);
+            // Reset pagination for this chat
+            if (this.currentChatKey()) {
+                this.chatPagination.delete(this.currentChatKey());
             }
 
             this.currentChatWindow.remove();
             this.currentChatWindow = null;
             this.currentChatUser = null;
+            this.currentChatConversation = null;
         }
     }
 
@@ -774,12 +1219,16 @@ class MessagesPage {
             const notification = new Notification(`New message from ${message.senderNickname}`, {
                 body: message.content.length > 50 ? message.content.substring(0, 50) + '...' : message.content,
                 icon: '/static/images/message-icon.png',
-                tag: `message-${message.senderId}`
+                tag: `message-${message.receiverId ? message.senderId : message.conversationId}`
             });
             
             notification.onclick = () => {
                 window.focus();
-                this.openChat(message.senderId, message.senderNickname);
+                if (message.receiverId) {
+                    this.openChat(message.senderId, message.senderNickname);
+                } else {
+                    this.openGroupChat(message.conversationId);
+                }
                 notification.close();
             };
             
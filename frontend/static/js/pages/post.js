@@ -88,6 +88,12 @@ window.PostPage = {
                             👎 ${post.dislikeCount}
                         </button>
                         <span class="stat-item">💬 ${post.commentCount}</span>
+                        ${window.auth.canReport(post.userId) ? `
+                            <button class="stat-btn report-btn" title="Report post"
+                                    onclick="window.utils.reportContent('post', ${post.id})">
+                                🚩 Report
+                            </button>
+                        ` : ''}
                     </div>
                 </div>
             </article>
@@ -165,6 +171,12 @@ window.PostPage = {
                             Reply
                         </button>
                     ` : ''}
+                    ${window.auth.canReport(comment.userId) ? `
+                        <button class="reply-btn report-btn" title="Report comment"
+                                onclick="window.utils.reportContent('comment', ${comment.id})">
+                            🚩 Report
+                        </button>
+                    ` : ''}
                 </div>
 
                 ${comment.parentID ? '' : `
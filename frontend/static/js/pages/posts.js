@@ -102,12 +102,18 @@ window.PostsPage = {
         categoryFilter.value = currentValue;
     },
 
-    async loadPosts(category = '') {
+    async loadPosts(category = '', cursor = null) {
         try {
             const params = category ? { category } : {};
+            if (cursor) {
+                params.cursor = cursor;
+            }
             const response = await window.api.getPosts(params);
             if (response.success) {
-                this.renderPosts(response.data || []);
+                this.posts = cursor ? (this.posts || []).concat(response.data || []) : (response.data || []);
+                this.currentCategory = category;
+                this.nextCursor = response.nextCursor || null;
+                this.renderPosts(this.posts);
             }
         } catch (error) {
             console.error('Failed to load posts:', error);
@@ -174,6 +180,12 @@ window.PostsPage = {
                                 title="Toggle comments">
                             💬 ${post.commentCount}
                         </button>
+                        ${window.auth.canReport(post.userId) ? `
+                            <button class="stat-btn report-btn" title="Report post"
+                                    onclick="window.utils.reportContent('post', ${post.id})">
+                                🚩
+                            </button>
+                        ` : ''}
                     </div>
 
                 </div>
@@ -187,6 +199,15 @@ window.PostsPage = {
             </div>
         `).join('');
 
+        // Offer the next page when the server reported one
+        if (this.nextCursor) {
+            container.insertAdjacentHTML('beforeend',
+                '<div class="load-more"><button id="load-more-posts" class="btn btn-secondary">Load more</button></div>');
+            document.getElementById('load-more-posts').addEventListener('click', () => {
+                this.loadPosts(this.currentCategory, this.nextCursor);
+            });
+        }
+
         // Bind like/dislike events
         this.bindPostEvents();
     },
@@ -366,6 +387,8 @@ window.PostsPage = {
     renderComment(comment) {
         const timeAgo = window.utils.formatDate(comment.createdAt);
         const isOwnComment = window.forumApp.currentUser && comment.userId === window.forumApp.currentUser.id;
+        const canEdit = isOwnComment || window.auth.can('comment.edit.any');
+        const canDelete = isOwnComment || window.auth.can('comment.delete.any');
 
         return `
             <div class="comment-item" data-comment-id="${comment.id}">
@@ -390,16 +413,24 @@ window.PostsPage = {
                             data-comment-id="${comment.id}" data-action="dislike">
                         👎 ${comment.dislikeCount || 0}
                     </button>
-                    ${isOwnComment ? `
+                    ${canEdit ? `
                         <button class="comment-action-btn edit-comment-btn"
                                 data-comment-id="${comment.id}">
                             ✏️ Edit
                         </button>
+                    ` : ''}
+                    ${canDelete ? `
                         <button class="comment-action-btn delete-comment-btn"
                                 data-comment-id="${comment.id}">
                             🗑️ Delete
                         </button>
                     ` : ''}
+                    ${window.auth.canReport(comment.userId) ? `
+                        <button class="comment-action-btn report-btn" title="Report comment"
+                                onclick="window.utils.reportContent('comment', ${comment.id})">
+                            🚩 Report
+                        </button>
+                    ` : ''}
                 </div>
             </div>
         `;
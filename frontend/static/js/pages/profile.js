@@ -37,6 +37,10 @@ window.ProfilePage = {
                             <p class="profile-email">
                                 <i class="icon-email">📧</i>
                                 ${window.utils.escapeHtml(this.currentUser.email)}
+                                ${this.currentUser.emailVerified ? '' : `
+                                    <span class="profile-email-unverified">Not verified</span>
+                                    <button class="btn btn-link btn-sm resend-verification-btn">Resend link</button>
+                                `}
                             </p>
                             <p class="profile-joined">
                                 <i class="icon-calendar">📅</i>
@@ -53,12 +57,384 @@ window.ProfilePage = {
                     </div>
                 </div>
 
+                <!-- Account credentials -->
+                <div class="profile-card profile-account">
+                    <h2>Account</h2>
+                    <p class="form-help">Confirm each change with your current password.</p>
+
+                    <form class="profile-account-form" data-change="nickname">
+                        <div class="form-group">
+                            <label for="account-nickname">Nickname</label>
+                            <input type="text" id="account-nickname" name="nickname" required
+                                   minlength="3" maxlength="20" pattern="[a-zA-Z0-9_-]+"
+                                   value="${window.utils.escapeHtml(this.currentUser.nickname)}">
+                        </div>
+                        <div class="form-group">
+                            <label for="account-nickname-password">Current Password</label>
+                            <input type="password" id="account-nickname-password" name="password" autocomplete="current-password">
+                        </div>
+                        <button type="submit" class="btn btn-outline btn-sm">Change Nickname</button>
+                    </form>
+
+                    <form class="profile-account-form" data-change="email">
+                        <div class="form-group">
+                            <label for="account-email">Email</label>
+                            <input type="email" id="account-email" name="email" required
+                                   value="${window.utils.escapeHtml(this.currentUser.email)}">
+                            <small class="form-help">We'll send a verification link to the new address.</small>
+                        </div>
+                        <div class="form-group">
+                            <label for="account-email-password">Current Password</label>
+                            <input type="password" id="account-email-password" name="password" autocomplete="current-password">
+                        </div>
+                        <button type="submit" class="btn btn-outline btn-sm">Change Email</button>
+                    </form>
+
+                    <form class="profile-account-form" data-change="password">
+                        <div class="form-group">
+                            <label for="account-current-password">Current Password</label>
+                            <input type="password" id="account-current-password" name="currentPassword" autocomplete="current-password">
+                        </div>
+                        <div class="form-group">
+                            <label for="account-new-password">New Password</label>
+                            <input type="password" id="account-new-password" name="newPassword" required minlength="8" autocomplete="new-password">
+                        </div>
+                        <div class="form-group">
+                            <label for="account-confirm-password">Confirm New Password</label>
+                            <input type="password" id="account-confirm-password" name="confirmPassword" required autocomplete="new-password">
+                            <small class="form-help">Your other devices will be signed out.</small>
+                        </div>
+                        <button type="submit" class="btn btn-outline btn-sm">Change Password</button>
+                    </form>
+                </div>
+
+                <!-- Two-factor authentication -->
+                <div class="profile-card profile-twofactor">
+                    <h2>Two-factor authentication</h2>
+                    <div id="profile-twofactor-body">
+                        <div class="loading">Loading...</div>
+                    </div>
+                </div>
+
+                <!-- Signed-in devices -->
+                <div class="profile-card profile-sessions">
+                    <div class="profile-sessions-header">
+                        <h2>Signed-in devices</h2>
+                        <button class="btn btn-outline btn-sm revoke-other-sessions-btn">Sign out everywhere else</button>
+                    </div>
+                    <div id="profile-sessions-list" class="profile-sessions-list">
+                        <div class="loading">Loading devices...</div>
+                    </div>
+                </div>
+
+                <!-- Blocked users -->
+                <div class="profile-card profile-blocks">
+                    <h2>Blocked users</h2>
+                    <div id="profile-blocks-list" class="profile-sessions-list">
+                        <div class="loading">Loading...</div>
+                    </div>
+                </div>
+
             </div>
         `;
 
         console.log('About to bind events');
         this.bindEvents();
         console.log('Events bound');
+
+        this.loadSessions();
+        this.loadTwoFactor();
+        this.loadBlockedUsers();
+    },
+
+    async loadSessions() {
+        const container = document.getElementById('profile-sessions-list');
+        if (!container) return;
+
+        try {
+            const response = await window.api.getSessions();
+            const sessions = response.data || [];
+
+            container.innerHTML = sessions.map(session => `
+                <div class="profile-session-item">
+                    <div class="profile-session-info">
+                        <p class="profile-session-device">
+                            ${window.utils.escapeHtml(session.userAgent || 'Unknown device')}
+                            ${session.current ? '<span class="profile-session-current">This device</span>' : ''}
+                        </p>
+                        <p class="profile-session-meta">
+                            ${window.utils.escapeHtml(session.ipAddress || 'Unknown IP')} ·
+                            Last active ${window.utils.formatDate(session.lastActiveAt)} ·
+                            Signed in ${window.utils.formatDate(session.createdAt)}
+                        </p>
+                    </div>
+                    ${session.current ? '' : `
+                        <button class="btn btn-secondary btn-sm revoke-session-btn" data-session-id="${window.utils.escapeHtml(session.id)}">Sign out</button>
+                    `}
+                </div>
+            `).join('');
+
+            container.querySelectorAll('.revoke-session-btn').forEach(button => {
+                button.addEventListener('click', () => this.revokeSession(button.dataset.sessionId));
+            });
+        } catch (error) {
+            console.error('Failed to load sessions:', error);
+            container.innerHTML = '<div class="error-message">Failed to load devices</div>';
+        }
+    },
+
+    async revokeSession(sessionId) {
+        try {
+            await window.api.revokeSession(sessionId);
+            window.forumApp.notificationComponent.success('Device signed out');
+            this.loadSessions();
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to sign out device');
+        }
+    },
+
+    async loadBlockedUsers() {
+        const container = document.getElementById('profile-blocks-list');
+        if (!container) return;
+
+        try {
+            const response = await window.api.getBlockedUsers();
+            const blocked = response.data || [];
+
+            if (blocked.length === 0) {
+                container.innerHTML = '<p class="form-help">You have not blocked anyone.</p>';
+                return;
+            }
+
+            container.innerHTML = blocked.map(user => `
+                <div class="profile-session-item">
+                    <div class="profile-session-info">
+                        <p class="profile-session-device">${window.utils.escapeHtml(user.nickname)}</p>
+                        <p class="profile-session-meta">Blocked ${window.utils.formatDate(user.blockedAt)}</p>
+                    </div>
+                    <button class="btn btn-secondary btn-sm unblock-user-btn" data-user-id="${window.utils.escapeHtml(user.id)}">Unblock</button>
+                </div>
+            `).join('');
+
+            container.querySelectorAll('.unblock-user-btn').forEach(button => {
+                button.addEventListener('click', () => this.unblockUser(button.dataset.userId));
+            });
+        } catch (error) {
+            console.error('Failed to load blocked users:', error);
+            container.innerHTML = '<div class="error-message">Failed to load blocked users</div>';
+        }
+    },
+
+    async unblockUser(userId) {
+        try {
+            await window.api.unblockUser(userId);
+            window.forumApp.notificationComponent.success('User unblocked');
+            this.loadBlockedUsers();
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to unblock user');
+        }
+    },
+
+    async resendVerification(button) {
+        try {
+            window.utils.setLoading(button, true, 'Sending...');
+            await window.api.resendVerificationEmail();
+            window.forumApp.notificationComponent.success(`Verification link sent to ${this.currentUser.email}`);
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to send verification email');
+        } finally {
+            window.utils.setLoading(button, false);
+        }
+    },
+
+    async changeAccount(event) {
+        event.preventDefault();
+
+        const form = event.target;
+        const data = Object.fromEntries(new FormData(form));
+        const submitBtn = form.querySelector('button[type="submit"]');
+
+        try {
+            window.utils.setLoading(submitBtn, true, 'Saving...');
+
+            switch (form.dataset.change) {
+                case 'nickname': {
+                    const response = await window.api.changeNickname(data.password, data.nickname.trim());
+                    Object.assign(this.currentUser, response.data);
+                    window.forumApp.notificationComponent.success('Nickname changed');
+                    break;
+                }
+                case 'email': {
+                    const response = await window.api.changeEmail(data.password, data.email.trim());
+                    Object.assign(this.currentUser, response.data);
+                    window.forumApp.notificationComponent.success(`Email changed. We sent a verification link to ${this.currentUser.email}`);
+                    break;
+                }
+                case 'password': {
+                    if (data.newPassword !== data.confirmPassword) {
+                        throw new Error('Passwords do not match');
+                    }
+                    const response = await window.api.changePassword(data.currentPassword, data.newPassword);
+                    const revoked = response.data ? response.data.revoked : 0;
+                    window.forumApp.notificationComponent.success(
+                        `Password changed${revoked ? `, signed out of ${revoked} other device${revoked === 1 ? '' : 's'}` : ''}`
+                    );
+                    break;
+                }
+            }
+
+            window.forumApp.updateAuthUI();
+            this.render();
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to update account');
+            window.utils.setLoading(submitBtn, false);
+        }
+    },
+
+    async loadTwoFactor() {
+        const container = document.getElementById('profile-twofactor-body');
+        if (!container) return;
+
+        try {
+            const response = await window.api.getTwoFactorStatus();
+            const status = response.data || {};
+
+            if (!status.enabled) {
+                container.innerHTML = `
+                    <p class="form-help">Sign-ins will also ask for a code from an authenticator app.</p>
+                    <form class="profile-twofactor-form" data-step="setup">
+                        <div class="form-group">
+                            <label for="twofactor-setup-password">Current Password</label>
+                            <input type="password" id="twofactor-setup-password" name="password" autocomplete="current-password">
+                        </div>
+                        <button type="submit" class="btn btn-outline btn-sm">Set Up</button>
+                    </form>
+                `;
+            } else {
+                const left = status.recoveryCodesLeft;
+                container.innerHTML = `
+                    <p class="profile-twofactor-status">
+                        <span class="profile-twofactor-enabled">Enabled</span>
+                        ${left} recovery code${left === 1 ? '' : 's'} left
+                    </p>
+                    <form class="profile-twofactor-form" data-step="manage">
+                        <div class="form-group">
+                            <label for="twofactor-manage-password">Current Password</label>
+                            <input type="password" id="twofactor-manage-password" name="password" autocomplete="current-password">
+                        </div>
+                        <div class="form-group">
+                            <label for="twofactor-manage-code">Authentication Code</label>
+                            <input type="text" id="twofactor-manage-code" name="code" required autocomplete="one-time-code">
+                        </div>
+                        <button type="submit" class="btn btn-outline btn-sm" value="regenerate">New Recovery Codes</button>
+                        <button type="submit" class="btn btn-secondary btn-sm" value="disable">Turn Off</button>
+                    </form>
+                `;
+            }
+
+            this.bindTwoFactorForm(container);
+        } catch (error) {
+            console.error('Failed to load two-factor status:', error);
+            container.innerHTML = '<div class="error-message">Failed to load two-factor status</div>';
+        }
+    },
+
+    bindTwoFactorForm(container) {
+        const form = container.querySelector('.profile-twofactor-form');
+        if (form) {
+            form.addEventListener('submit', (event) => this.changeTwoFactor(event));
+        }
+    },
+
+    async changeTwoFactor(event) {
+        event.preventDefault();
+
+        const form = event.target;
+        const container = document.getElementById('profile-twofactor-body');
+        const data = Object.fromEntries(new FormData(form));
+        const submitBtn = event.submitter || form.querySelector('button[type="submit"]');
+
+        try {
+            window.utils.setLoading(submitBtn, true, 'Saving...');
+
+            switch (form.dataset.step) {
+                case 'setup': {
+                    const response = await window.api.setupTwoFactor(data.password);
+                    const setup = response.data;
+                    container.innerHTML = `
+                        <p class="form-help">
+                            Add this account to your authenticator app by
+                            <a href="${window.utils.escapeHtml(setup.uri)}">opening the setup link</a>
+                            or entering the key below, then confirm with the code it shows.
+                        </p>
+                        <code class="profile-twofactor-secret">${window.utils.escapeHtml(setup.secret)}</code>
+                        <form class="profile-twofactor-form" data-step="enable">
+                            <div class="form-group">
+                                <label for="twofactor-enable-code">Authentication Code</label>
+                                <input type="text" id="twofactor-enable-code" name="code" required
+                                       inputmode="numeric" maxlength="6" autocomplete="one-time-code">
+                            </div>
+                            <button type="submit" class="btn btn-primary btn-sm">Turn On</button>
+                        </form>
+                    `;
+                    this.bindTwoFactorForm(container);
+                    return;
+                }
+                case 'enable': {
+                    const response = await window.api.enableTwoFactor(data.code.trim());
+                    window.forumApp.notificationComponent.success('Two-factor authentication enabled');
+                    this.showRecoveryCodes(container, response.data.recoveryCodes);
+                    return;
+                }
+                case 'manage': {
+                    if (submitBtn.value === 'disable') {
+                        if (!confirm('Turn off two-factor authentication?')) return;
+                        await window.api.disableTwoFactor(data.password, data.code.trim());
+                        window.forumApp.notificationComponent.success('Two-factor authentication disabled');
+                        this.loadTwoFactor();
+                    } else {
+                        const response = await window.api.regenerateRecoveryCodes(data.password, data.code.trim());
+                        window.forumApp.notificationComponent.success('New recovery codes generated');
+                        this.showRecoveryCodes(container, response.data.recoveryCodes);
+                    }
+                    return;
+                }
+            }
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to update two-factor authentication');
+        } finally {
+            window.utils.setLoading(submitBtn, false);
+        }
+    },
+
+    showRecoveryCodes(container, codes) {
+        container.innerHTML = `
+            <p class="form-help">
+                Save these recovery codes somewhere safe. Each one signs you in once
+                if you lose your authenticator app, and they won't be shown again.
+            </p>
+            <ul class="profile-recovery-codes">
+                ${codes.map(code => `<li><code>${window.utils.escapeHtml(code)}</code></li>`).join('')}
+            </ul>
+            <button class="btn btn-outline btn-sm recovery-codes-done-btn">Done</button>
+        `;
+
+        container.querySelector('.recovery-codes-done-btn').addEventListener('click', () => {
+            this.loadTwoFactor();
+        });
+    },
+
+    async revokeOtherSessions() {
+        if (!confirm('Sign out of every other device?')) return;
+
+        try {
+            const response = await window.api.revokeOtherSessions();
+            const revoked = response.data ? response.data.revoked : 0;
+            window.forumApp.notificationComponent.success(`Signed out of ${revoked} other device${revoked === 1 ? '' : 's'}`);
+            this.loadSessions();
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to sign out other devices');
+        }
     },
 
     handleTabSwitch(event) {
@@ -269,6 +645,27 @@ window.ProfilePage = {
                 this.showEditProfileModal();
             });
         }
+
+        // Sign out of every other device
+        const revokeOthersBtn = document.querySelector('.revoke-other-sessions-btn');
+        if (revokeOthersBtn) {
+            revokeOthersBtn.addEventListener('click', () => {
+                this.revokeOtherSessions();
+            });
+        }
+
+        // Change nickname, email or password
+        document.querySelectorAll('.profile-account-form').forEach(form => {
+            form.addEventListener('submit', (event) => this.changeAccount(event));
+        });
+
+        // Email a new verification link
+        const resendBtn = document.querySelector('.resend-verification-btn');
+        if (resendBtn) {
+            resendBtn.addEventListener('click', () => {
+                this.resendVerification(resendBtn);
+            });
+        }
     },
 
     showEditProfileModal() {
@@ -337,7 +734,7 @@ window.ProfilePage = {
                         <label for="edit-email">Email</label>
                         <input type="email" id="edit-email" name="email" disabled
                                value="${window.utils.escapeHtml(this.currentUser.email)}">
-                        <small class="form-help">Email cannot be changed</small>
+                        <small class="form-help">Change your email in the Account section</small>
                     </div>
 
                     <div class="modal-actions">
@@ -137,6 +137,36 @@ window.RegisterPage = {
         `;
 
         this.bindEvents();
+
+        // Returning from an OAuth provider with a new account to complete
+        this.oauthSignup = false;
+        if (new URLSearchParams(window.location.search).get('oauth')) {
+            await this.prefillOAuthSignup();
+        }
+    },
+
+    async prefillOAuthSignup() {
+        try {
+            const response = await window.api.get('/oauth/signup');
+            const pending = response.data;
+
+            document.getElementById('firstName').value = pending.firstName || '';
+            document.getElementById('lastName').value = pending.lastName || '';
+            document.getElementById('nickname').value = pending.nickname || '';
+
+            const emailInput = document.getElementById('email');
+            emailInput.value = pending.email;
+            emailInput.readOnly = true;
+
+            // The provider account replaces the password
+            const passwordInput = document.getElementById('password');
+            passwordInput.required = false;
+            passwordInput.closest('.form-group').style.display = 'none';
+
+            this.oauthSignup = true;
+        } catch (error) {
+            this.showError(error.message || 'Your sign-in session expired. Please try again.');
+        }
     },
 
     bindEvents() {
@@ -201,7 +231,7 @@ window.RegisterPage = {
         this.hideError();
 
         // Basic validation
-        if (!userData.firstName || !userData.lastName || !userData.email || !userData.nickname || !userData.password) {
+        if (!userData.firstName || !userData.lastName || !userData.email || !userData.nickname || (!userData.password && !this.oauthSignup)) {
             this.showError('Please fill in all required fields');
             return;
         }
@@ -216,7 +246,15 @@ window.RegisterPage = {
             window.utils.setLoading(submitBtn, true, 'Creating Account...');
 
             // Attempt registration
-            const result = await window.auth.register(userData);
+            let result;
+            if (this.oauthSignup) {
+                const response = await window.api.post('/oauth/signup', userData);
+                window.auth.currentUser = response.data;
+                window.auth.isAuthenticated = true;
+                result = { success: response.success, user: response.data, message: response.message };
+            } else {
+                result = await window.auth.register(userData);
+            }
 
             if (result.success) {
                 // Update app state
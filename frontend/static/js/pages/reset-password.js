@@ -0,0 +1,102 @@
+// Reset Password Page Component, reached from the link in a reset email
+window.ResetPasswordPage = {
+    async render() {
+        window.forumApp.setCurrentPage('reset-password');
+
+        const token = window.utils.url.getParam('token');
+        const mainContent = document.getElementById('main-content');
+        mainContent.innerHTML = `
+            <div class="auth-container">
+                <div class="auth-card">
+                    <div class="auth-header">
+                        <h1>Choose a New Password</h1>
+                        <p>You will be signed out of every device</p>
+                    </div>
+
+                    <form id="reset-password-form" class="auth-form">
+                        <div id="reset-password-error" class="error-message" style="display: none;"></div>
+
+                        <div class="form-group">
+                            <label for="password">New Password</label>
+                            <input
+                                type="password"
+                                id="password"
+                                name="password"
+                                required
+                                minlength="8"
+                                placeholder="At least 8 characters"
+                                autocomplete="new-password"
+                            >
+                        </div>
+
+                        <div class="form-group">
+                            <label for="confirmPassword">Confirm Password</label>
+                            <input
+                                type="password"
+                                id="confirmPassword"
+                                name="confirmPassword"
+                                required
+                                placeholder="Repeat the new password"
+                                autocomplete="new-password"
+                            >
+                        </div>
+
+                        <button type="submit" class="btn btn-primary btn-full" ${token ? '' : 'disabled'}>
+                            Update Password
+                        </button>
+                    </form>
+
+                    <div class="auth-footer">
+                        <p>Link expired? <a href="/forgot-password" data-route="/forgot-password">Request a new one</a></p>
+                    </div>
+                </div>
+            </div>
+        `;
+
+        if (!token) {
+            this.showError('This reset link is missing its token. Please use the link from your email.');
+            return;
+        }
+
+        document.getElementById('reset-password-form')
+            .addEventListener('submit', (event) => this.handleSubmit(event, token));
+    },
+
+    async handleSubmit(event, token) {
+        event.preventDefault();
+
+        const form = event.target;
+        const formData = new FormData(form);
+        const password = formData.get('password');
+        const submitBtn = form.querySelector('button[type="submit"]');
+
+        document.getElementById('reset-password-error').style.display = 'none';
+
+        if (password !== formData.get('confirmPassword')) {
+            this.showError('Passwords do not match');
+            return;
+        }
+
+        try {
+            window.utils.setLoading(submitBtn, true, 'Updating...');
+            await window.api.resetPassword(token, password);
+
+            // Every session was ended, including this browser's
+            window.forumApp.onSessionEnded();
+            window.forumApp.notificationComponent.success('Password updated, please sign in');
+            window.forumApp.router.navigate('/login');
+        } catch (error) {
+            this.showError(error.message || 'Failed to reset password');
+        } finally {
+            window.utils.setLoading(submitBtn, false);
+        }
+    },
+
+    showError(message) {
+        const errorDiv = document.getElementById('reset-password-error');
+        if (errorDiv) {
+            errorDiv.textContent = message;
+            errorDiv.style.display = 'block';
+        }
+    }
+};
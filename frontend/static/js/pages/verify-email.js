@@ -0,0 +1,42 @@
+// Verify Email Page Component, reached from the link in a verification email
+window.VerifyEmailPage = {
+    async render() {
+        window.forumApp.setCurrentPage('verify-email');
+
+        const mainContent = document.getElementById('main-content');
+        mainContent.innerHTML = `
+            <div class="auth-container">
+                <div class="auth-card">
+                    <div class="auth-header">
+                        <h1>Verify Email</h1>
+                    </div>
+                    <div id="verify-email-status">
+                        <div class="loading">Verifying your email...</div>
+                    </div>
+                    <div class="auth-footer">
+                        <p><a href="/" data-route="/">Go to the forum</a></p>
+                    </div>
+                </div>
+            </div>
+        `;
+
+        const status = document.getElementById('verify-email-status');
+        const token = window.utils.url.getParam('token');
+        if (!token) {
+            status.innerHTML = '<div class="error-message">This verification link is missing its token. Please use the link from your email.</div>';
+            return;
+        }
+
+        try {
+            await window.api.verifyEmail(token);
+            status.innerHTML = '<div class="auth-success-message">Thanks, your email address is verified.</div>';
+
+            const user = window.auth.getCurrentUser();
+            if (user) {
+                user.emailVerified = true;
+            }
+        } catch (error) {
+            status.innerHTML = `<div class="error-message">${window.utils.escapeHtml(error.message || 'Failed to verify email')}</div>`;
+        }
+    }
+};
@@ -191,6 +191,21 @@ window.utils = {
         );
     },
 
+    /**
+     * Ask for a reason and report a post, comment, message or user to the moderators
+     */
+    async reportContent(targetType, targetId) {
+        const reason = prompt(`Why are you reporting this ${targetType}?`);
+        if (reason === null || reason.trim() === '') return;
+
+        try {
+            await window.api.createReport(targetType, targetId, reason.trim());
+            window.forumApp.notificationComponent.success('Thanks, the moderators will take a look');
+        } catch (error) {
+            window.handleAPIError(error, 'Failed to send report');
+        }
+    },
+
     /**
      * Local storage helpers
      */
@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// Actions recorded in the audit log. The part before the dot is the
+// category, which List also accepts as a filter.
+const (
+	ActionRegister                 = "auth.register"
+	ActionLogin                    = "auth.login"
+	ActionLoginFailed              = "auth.login_failed"
+	ActionLogout                   = "auth.logout"
+	ActionPasswordReset            = "auth.password_reset"
+	ActionTwoFactorEnabled         = "auth.two_factor_enabled"
+	ActionTwoFactorDisabled        = "auth.two_factor_disabled"
+	ActionRecoveryCodesRegenerated = "auth.recovery_codes_regenerated"
+
+	ActionSessionRevoked = "session.revoked"
+
+	ActionProfileUpdated  = "profile.updated"
+	ActionAvatarChanged   = "profile.avatar_changed"
+	ActionPasswordChanged = "profile.password_changed"
+	ActionEmailChanged    = "profile.email_changed"
+	ActionNicknameChanged = "profile.nickname_changed"
+
+	ActionPostDeleted    = "content.post_deleted"
+	ActionCommentDeleted = "content.comment_deleted"
+	ActionMessageDeleted = "content.message_deleted"
+
+	ActionPostEdited       = "moderation.post_edited"
+	ActionCommentEdited    = "moderation.comment_edited"
+	ActionReportClaimed    = "moderation.report_claimed"
+	ActionReportResolved   = "moderation.report_resolved"
+	ActionReportDismissed  = "moderation.report_dismissed"
+	ActionUserSuspended    = "moderation.user_suspended"
+	ActionSuspensionLifted = "moderation.suspension_lifted"
+	ActionRoleChanged      = "moderation.role_changed"
+)
+
+// Types of the thing an event was done to
+const (
+	TargetUser    = "user"
+	TargetSession = "session"
+	TargetPost    = "post"
+	TargetComment = "comment"
+	TargetMessage = "message"
+	TargetReport  = "report"
+)
+
+// ErrInvalidRange is returned when a filter's Since is after its Until
+var ErrInvalidRange = errors.New("since must be before until")
+
+// Event is an entry to record. ActorID is empty for anonymous events, such
+// as a failed sign-in.
+type Event struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	IPAddress  string
+	Metadata   map[string]interface{}
+}
+
+// Filter narrows the events List returns. Empty fields match everything.
+// Action matches an action exactly or, without a dot, a whole category.
+type Filter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	IPAddress  string
+	Since      time.Time
+	Until      time.Time
+}
+
+// Record adds an event to the audit log, timestamped in UTC so that List can
+// compare times however the server's zone changes. Failures are logged
+// rather than returned so that auditing never fails the action being
+// audited.
+func Record(event Event) {
+	var actorID *string
+	if event.ActorID != "" {
+		actorID = &event.ActorID
+	}
+
+	var metadata *string
+	if len(event.Metadata) > 0 {
+		encoded, err := json.Marshal(event.Metadata)
+		if err != nil {
+			log.Printf("❌ Failed to encode audit metadata for %s: %v", event.Action, err)
+			return
+		}
+		s := string(encoded)
+		metadata = &s
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO audit_events (actor_id, action, target_type, target_id, ip_address, metadata, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, actorID, event.Action, event.TargetType, event.TargetID, event.IPAddress, metadata, time.Now().UTC())
+	if err != nil {
+		log.Printf("❌ Failed to record audit event %s: %v", event.Action, err)
+	}
+}
+
+// List returns the events matching filter, newest first. When beforeID is
+// set, only older events are returned.
+func List(filter Filter, limit int, beforeID int64) ([]models.AuditEvent, error) {
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Since.After(filter.Until) {
+		return nil, ErrInvalidRange
+	}
+
+	query := `
+		SELECT e.id, e.actor_id, u.nickname, e.action, e.target_type, e.target_id,
+			e.ip_address, e.metadata, e.created_at
+		FROM audit_events e
+		LEFT JOIN users u ON u.id = e.actor_id
+		WHERE 1 = 1`
+	args := []interface{}{}
+
+	if filter.ActorID != "" {
+		query += ` AND e.actor_id = ?`
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		category := filter.Action + "."
+		query += ` AND (e.action = ? OR substr(e.action, 1, ?) = ?)`
+		args = append(args, filter.Action, len(category), category)
+	}
+	if filter.TargetType != "" {
+		query += ` AND e.target_type = ?`
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query += ` AND e.target_id = ?`
+		args = append(args, filter.TargetID)
+	}
+	if filter.IPAddress != "" {
+		query += ` AND e.ip_address = ?`
+		args = append(args, filter.IPAddress)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND e.created_at >= ?`
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND e.created_at < ?`
+		args = append(args, filter.Until.UTC())
+	}
+	if beforeID > 0 {
+		query += ` AND e.id < ?`
+		args = append(args, beforeID)
+	}
+
+	query += ` ORDER BY e.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var actorID, actor, metadata sql.NullString
+		if err := rows.Scan(&event.ID, &actorID, &actor, &event.Action, &event.TargetType, &event.TargetID,
+			&event.IPAddress, &metadata, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %v", err)
+		}
+		if actorID.Valid {
+			event.ActorID = &actorID.String
+		}
+		if actor.Valid {
+			event.Actor = &actor.String
+		}
+		if metadata.Valid {
+			event.Metadata = json.RawMessage(metadata.String)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"forum/internal/database"
+)
+
+// Errors returned when changing account credentials
+var (
+	ErrWrongPassword   = errors.New("current password is incorrect")
+	ErrInvalidEmail    = errors.New("invalid email address")
+	ErrInvalidNickname = errors.New("nickname must be 3-20 letters, numbers, underscores or hyphens")
+	ErrEmailTaken      = errors.New("email already in use")
+	ErrEmailUnchanged  = errors.New("email is already in use by this account")
+	ErrNicknameTaken   = errors.New("nickname already in use")
+	ErrReauthRequired  = errors.New("sign in with your provider again to confirm this change")
+)
+
+// reauthWindow is how recent a provider sign-in must be to stand in for the
+// password of an account that has none
+const reauthWindow = 10 * time.Minute
+
+var nicknamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,20}$`)
+
+// confirmPassword checks the current password of the user before a change
+// to their credentials. Wrong passwords count towards the login lockout.
+// Accounts created through OAuth have no password, so they must have signed
+// in with their provider within reauthWindow instead; a session alone is not
+// enough. As with AuthenticateUser, a correct password does not clear failed
+// codes of users with two-factor authentication.
+func confirmPassword(userID, password string) error {
+	var hash string
+	var twoFactor bool
+	var oauthLoginAt sql.NullTime
+	err := database.DB.QueryRow(`
+		SELECT password, totp_enabled_at IS NOT NULL, oauth_login_at FROM users WHERE id = ?
+	`, userID).Scan(&hash, &twoFactor, &oauthLoginAt)
+	if err != nil {
+		return fmt.Errorf("failed to look up user: %v", err)
+	}
+	if hash == "" {
+		if !oauthLoginAt.Valid || time.Since(oauthLoginAt.Time) > reauthWindow {
+			return ErrReauthRequired
+		}
+		return nil
+	}
+
+	if err := checkLockout(userID); err != nil {
+		return err
+	}
+	if !CheckPasswordHash(password, hash) {
+		if err := recordFailedLogin(userID); err != nil {
+			return err
+		}
+		return ErrWrongPassword
+	}
+	if twoFactor {
+		return nil
+	}
+	return resetFailedLogins(userID)
+}
+
+// ChangePassword replaces the user's password after confirming the current
+// one. Callers should end the user's other sessions.
+func ChangePassword(userID, currentPassword, newPassword string) error {
+	if len(newPassword) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	if err := confirmPassword(userID, currentPassword); err != nil {
+		return err
+	}
+
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	_, err = database.DB.Exec("UPDATE users SET password = ?, updated_at = ? WHERE id = ?", hash, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %v", err)
+	}
+	return nil
+}
+
+// ChangeEmail replaces the user's email after confirming their password. The
+// new address starts unverified, and links already emailed to the old
+// address stop working.
+func ChangeEmail(userID, password, email string) error {
+	email = strings.TrimSpace(email)
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return ErrInvalidEmail
+	}
+
+	var current string
+	if err := database.DB.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&current); err != nil {
+		return fmt.Errorf("failed to look up user: %v", err)
+	}
+	if email == current {
+		return ErrEmailUnchanged
+	}
+
+	if err := confirmPassword(userID, password); err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users SET email = ?, email_verified_at = NULL, updated_at = ? WHERE id = ?
+	`, email, time.Now(), userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("failed to update email: %v", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_tokens WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to invalidate account tokens: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update email: %v", err)
+	}
+	return nil
+}
+
+// ChangeNickname replaces the user's nickname after confirming their password
+func ChangeNickname(userID, password, nickname string) error {
+	if !nicknamePattern.MatchString(nickname) {
+		return ErrInvalidNickname
+	}
+	if err := confirmPassword(userID, password); err != nil {
+		return err
+	}
+
+	_, err := database.DB.Exec("UPDATE users SET nickname = ?, updated_at = ? WHERE id = ?", nickname, time.Now(), userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrNicknameTaken
+		}
+		return fmt.Errorf("failed to update nickname: %v", err)
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err comes from a UNIQUE constraint
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"time"
 
+	"forum/internal/config"
 	"forum/internal/database"
 	"forum/internal/models"
 
@@ -15,10 +16,20 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-const (
-	SessionCookieName = "forum_session"
-	SessionDuration   = 24 * time.Hour * 7 // 7 days
-)
+const SessionCookieName = "forum_session"
+
+// settings holds the session configuration passed to Initialize
+var settings = config.Default().Auth
+
+// Initialize applies the session and cookie settings
+func Initialize(cfg config.AuthConfig) {
+	settings = cfg
+}
+
+// SecureCookies reports whether cookies must only be sent over HTTPS
+func SecureCookies() bool {
+	return settings.CookieSecure
+}
 
 // HashPassword hashes a password using bcrypt
 func HashPassword(password string) (string, error) {
@@ -39,22 +50,43 @@ func GenerateSessionID() string {
 	return hex.EncodeToString(bytes)
 }
 
-// CreateSession creates a new session for a user
-func CreateSession(userID string) (*models.Session, error) {
+// CreateSession creates a new session for a user signing in from the
+// device described by userAgent and ipAddress. The session expires after
+// the idle timeout unless it is used, and after the session duration at the
+// latest. Suspended users get a SuspendedError instead.
+func CreateSession(userID, userAgent, ipAddress string) (*models.Session, error) {
+	if err := checkSuspension(userID); err != nil {
+		return nil, err
+	}
+
 	sessionID := GenerateSessionID()
-	expiresAt := time.Now().Add(SessionDuration)
+	now := time.Now()
+	expiresAt := sessionExpiry(now, now)
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	publicID := make([]byte, 16)
+	rand.Read(publicID)
 
 	session := &models.Session{
-		ID:        sessionID,
-		UserID:    userID,
-		ExpiresAt: expiresAt,
-		CreatedAt: time.Now(),
+		ID:           sessionID,
+		UserID:       userID,
+		ExpiresAt:    expiresAt,
+		CreatedAt:    now,
+		CSRFToken:    GenerateSessionID(),
+		PublicID:     hex.EncodeToString(publicID),
+		UserAgent:    userAgent,
+		IPAddress:    ipAddress,
+		LastActiveAt: now,
 	}
 
 	_, err := database.DB.Exec(`
-		INSERT INTO sessions (id, user_id, expires_at, created_at)
-		VALUES (?, ?, ?, ?)
-	`, session.ID, session.UserID, session.ExpiresAt, session.CreatedAt)
+		INSERT INTO sessions (id, user_id, expires_at, created_at, csrf_token, public_id, user_agent, ip_address, last_active_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, session.ID, session.UserID, session.ExpiresAt, session.CreatedAt, session.CSRFToken,
+		session.PublicID, session.UserAgent, session.IPAddress, session.LastActiveAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %v", err)
@@ -67,7 +99,7 @@ func CreateSession(userID string) (*models.Session, error) {
 func GetSessionByID(sessionID string) (*models.Session, error) {
 	session := &models.Session{}
 	err := database.DB.QueryRow(`
-		SELECT id, user_id, expires_at, created_at
+		SELECT id, user_id, expires_at, created_at, csrf_token, public_id, user_agent, ip_address, last_active_at
 		FROM sessions
 		WHERE id = ? AND expires_at > ?
 	`, sessionID, time.Now()).Scan(
@@ -75,6 +107,11 @@ func GetSessionByID(sessionID string) (*models.Session, error) {
 		&session.UserID,
 		&session.ExpiresAt,
 		&session.CreatedAt,
+		&session.CSRFToken,
+		&session.PublicID,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.LastActiveAt,
 	)
 
 	if err != nil {
@@ -96,24 +133,15 @@ func DeleteSession(sessionID string) error {
 	return nil
 }
 
-// CleanupExpiredSessions removes expired sessions from the database
-func CleanupExpiredSessions() error {
-	_, err := database.DB.Exec("DELETE FROM sessions WHERE expires_at <= ?", time.Now())
-	if err != nil {
-		return fmt.Errorf("failed to cleanup expired sessions: %v", err)
-	}
-	return nil
-}
-
 // SetSessionCookie sets the session cookie on the response
 func SetSessionCookie(w http.ResponseWriter, sessionID string) {
 	cookie := &http.Cookie{
 		Name:     SessionCookieName,
 		Value:    sessionID,
 		Path:     "/",
-		MaxAge:   int(SessionDuration.Seconds()),
+		MaxAge:   int(settings.SessionDuration.Seconds()),
 		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
+		Secure:   settings.CookieSecure,
 		SameSite: http.SameSiteLaxMode,
 	}
 	http.SetCookie(w, cookie)
@@ -127,7 +155,7 @@ func ClearSessionCookie(w http.ResponseWriter) {
 		Path:     "/",
 		MaxAge:   -1,
 		HttpOnly: true,
-		Secure:   false,
+		Secure:   settings.CookieSecure,
 		SameSite: http.SameSiteLaxMode,
 	}
 	http.SetCookie(w, cookie)
@@ -151,19 +179,33 @@ func GetSessionFromRequest(r *http.Request) (*models.Session, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate session: %v", err)
 	}
+	if session != nil {
+		touchSession(session)
+	}
 
 	return session, nil
 }
 
-// GetUserFromSession gets the user associated with the session in the request
+// GetUserFromSession gets the user associated with the session in the
+// request. Suspended users are treated as signed out.
 func GetUserFromSession(r *http.Request) *models.User {
 	session, err := GetSessionFromRequest(r)
 	if err != nil || session == nil {
 		return nil
 	}
 
+	return sessionOwner(session)
+}
+
+// sessionOwner returns the user a session belongs to, or nil if they no
+// longer exist or are suspended
+func sessionOwner(session *models.Session) *models.User {
 	user, err := GetUserByID(session.UserID)
-	if err != nil {
+	if err != nil || user == nil {
+		return nil
+	}
+
+	if err := checkSuspension(user.ID); err != nil {
 		return nil
 	}
 
@@ -185,6 +227,7 @@ func CreateUser(req *models.RegisterRequest) (*models.User, error) {
 		ID:        userID,
 		Email:     req.Email,
 		Nickname:  req.Nickname,
+		Role:      RoleUser,
 		Password:  hashedPassword,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
@@ -212,13 +255,16 @@ func CreateUser(req *models.RegisterRequest) (*models.User, error) {
 func GetUserByID(userID string) (*models.User, error) {
 	user := &models.User{}
 	err := database.DB.QueryRow(`
-		SELECT id, email, nickname, first_name, last_name, age, gender, google_id, github_id, avatar_url, created_at, updated_at
+		SELECT id, email, email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL, nickname, role, first_name, last_name, age, gender, google_id, github_id, avatar_url, created_at, updated_at
 		FROM users
 		WHERE id = ?
 	`, userID).Scan(
 		&user.ID,
 		&user.Email,
+		&user.EmailVerified,
+		&user.TwoFactorEnabled,
 		&user.Nickname,
+		&user.Role,
 		&user.FirstName,
 		&user.LastName,
 		&user.Age,
@@ -244,13 +290,16 @@ func GetUserByID(userID string) (*models.User, error) {
 func GetUserByEmailOrNickname(identifier string) (*models.User, error) {
 	user := &models.User{}
 	err := database.DB.QueryRow(`
-		SELECT id, email, nickname, password, first_name, last_name, age, gender, google_id, github_id, avatar_url, created_at, updated_at
+		SELECT id, email, email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL, nickname, role, password, first_name, last_name, age, gender, google_id, github_id, avatar_url, created_at, updated_at
 		FROM users
 		WHERE email = ? OR nickname = ?
 	`, identifier, identifier).Scan(
 		&user.ID,
 		&user.Email,
+		&user.EmailVerified,
+		&user.TwoFactorEnabled,
 		&user.Nickname,
+		&user.Role,
 		&user.Password,
 		&user.FirstName,
 		&user.LastName,
@@ -284,10 +333,31 @@ func AuthenticateUser(identifier, password string) (*models.User, error) {
 		return nil, fmt.Errorf("user not found")
 	}
 
+	// A locked account is refused before the password is checked
+	if err := checkLockout(user.ID); err != nil {
+		return nil, err
+	}
+
 	if !CheckPasswordHash(password, user.Password) {
+		if err := recordFailedLogin(user.ID); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("invalid password")
 	}
 
+	// Suspensions are only revealed to someone who knows the password
+	if err := checkSuspension(user.ID); err != nil {
+		return nil, err
+	}
+
+	// With two-factor authentication the count is reset once the second
+	// factor is accepted, so a known password cannot clear failed codes
+	if !user.TwoFactorEnabled {
+		if err := resetFailedLogins(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Don't return the password
 	user.Password = ""
 	return user, nil
@@ -304,9 +374,3 @@ func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
-
-// InitializeOAuthProviders initializes OAuth providers (placeholder)
-func InitializeOAuthProviders() {
-	// OAuth initialization would go here
-	// For now, this is a placeholder to satisfy the main.go import
-}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"forum/internal/models"
+)
+
+// CSRFHeaderName is the request header that carries the session's CSRF token
+const CSRFHeaderName = "X-CSRF-Token"
+
+// ValidCSRFToken reports whether token matches the one issued with session
+func ValidCSRFToken(session *models.Session, token string) bool {
+	if session == nil || session.CSRFToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(session.CSRFToken), []byte(token)) == 1
+}
+
+// GetSessionUser gets the signed-in user for the request together with the
+// session's CSRF token, or nil when there is no valid session
+func GetSessionUser(r *http.Request) *models.SessionUser {
+	session, err := GetSessionFromRequest(r)
+	if err != nil || session == nil {
+		return nil
+	}
+
+	user := sessionOwner(session)
+	if user == nil {
+		return nil
+	}
+
+	return NewSessionUser(user, session.CSRFToken)
+}
+
+// OriginAllowed reports whether the request's Origin header, if any, is the
+// server itself or one of the configured allowed origins. Browsers send
+// Origin with every cross-site write and websocket handshake; requests
+// without one come from same-origin navigation or non-browser clients.
+func OriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false // includes the opaque "null" origin
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, allowed := range settings.AllowedOrigins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"forum/internal/database"
+)
+
+// LockedError is returned by AuthenticateUser for an account locked after
+// repeated failed logins
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("account locked until %s", e.Until.Format(time.RFC3339))
+}
+
+// RetryAfter returns how long until the account unlocks
+func (e *LockedError) RetryAfter() time.Duration {
+	return time.Until(e.Until)
+}
+
+// checkLockout returns a LockedError if the user's account is locked
+func checkLockout(userID string) error {
+	var lockedUntil sql.NullTime
+	err := database.DB.QueryRow("SELECT locked_until FROM users WHERE id = ?", userID).Scan(&lockedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to check account lockout: %v", err)
+	}
+
+	if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+		return &LockedError{Until: lockedUntil.Time}
+	}
+	return nil
+}
+
+// recordFailedLogin counts a failed login and locks the account once the
+// threshold is reached. Each failure past the threshold doubles the lockout,
+// up to the configured maximum.
+func recordFailedLogin(userID string) error {
+	var failures int
+	err := database.DB.QueryRow(`
+		UPDATE users SET failed_logins = failed_logins + 1 WHERE id = ? RETURNING failed_logins
+	`, userID).Scan(&failures)
+	if err != nil {
+		return fmt.Errorf("failed to record failed login: %v", err)
+	}
+
+	if settings.LockoutThreshold <= 0 || failures < settings.LockoutThreshold {
+		return nil
+	}
+
+	duration := lockoutDuration(failures - settings.LockoutThreshold)
+	until := time.Now().Add(duration)
+	if _, err := database.DB.Exec("UPDATE users SET locked_until = ? WHERE id = ?", until, userID); err != nil {
+		return fmt.Errorf("failed to lock account: %v", err)
+	}
+
+	log.Printf("🔒 Account %s locked for %s after %d failed logins", userID, duration, failures)
+	return &LockedError{Until: until}
+}
+
+// lockoutDuration returns the lockout after extra failures past the threshold
+func lockoutDuration(extra int) time.Duration {
+	duration := settings.LockoutDuration.Duration
+	for i := 0; i < extra && duration < settings.MaxLockoutDuration.Duration; i++ {
+		duration *= 2
+	}
+	if duration > settings.MaxLockoutDuration.Duration {
+		duration = settings.MaxLockoutDuration.Duration
+	}
+	return duration
+}
+
+// resetFailedLogins clears the failure count after a successful login
+func resetFailedLogins(userID string) error {
+	_, err := database.DB.Exec(`
+		UPDATE users SET failed_logins = 0, locked_until = NULL
+		WHERE id = ? AND (failed_logins > 0 OR locked_until IS NOT NULL)
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed logins: %v", err)
+	}
+	return nil
+}
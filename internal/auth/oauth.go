@@ -0,0 +1,581 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/config"
+	"forum/internal/database"
+	"forum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// OAuthStateCookieName binds an authorization request to the browser that started it
+	OAuthStateCookieName = "forum_oauth_state"
+	// OAuthSignupCookieName carries a pending OAuth signup to the profile completion step
+	OAuthSignupCookieName = "forum_oauth_signup"
+
+	OAuthStateDuration  = 10 * time.Minute
+	OAuthSignupDuration = 30 * time.Minute
+)
+
+// OAuthProvider describes an OAuth 2.0 authorization-code provider.
+// Endpoints are configurable so the flow can run against a local stub.
+type OAuthProvider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	EmailsURL    string // GitHub only: lists the user's emails with verification state
+	Scopes       []string
+
+	userIDColumn string // users column holding the provider's user ID
+	tokenTable   string // table holding the provider's tokens
+}
+
+// OAuthToken is the token endpoint response
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+// OAuthProfile is the provider's user profile normalized across providers
+type OAuthProfile struct {
+	ProviderUserID string
+	Email          string
+	EmailVerified  bool
+	Login          string
+	FirstName      string
+	LastName       string
+	AvatarURL      string
+}
+
+var (
+	oauthProviders  = map[string]*OAuthProvider{}
+	oauthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+	nicknameCleaner = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+)
+
+// InitializeOAuthProviders configures the Google and GitHub providers.
+// A provider is only enabled when its client ID and secret are set.
+func InitializeOAuthProviders(cfg config.OAuthConfig) {
+	RegisterOAuthProvider(&OAuthProvider{
+		Name:         "google",
+		ClientID:     cfg.Google.ClientID,
+		ClientSecret: cfg.Google.ClientSecret,
+		RedirectURL:  cfg.Google.RedirectURL,
+		AuthURL:      cfg.Google.AuthURL,
+		TokenURL:     cfg.Google.TokenURL,
+		UserInfoURL:  cfg.Google.UserInfoURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+
+	RegisterOAuthProvider(&OAuthProvider{
+		Name:         "github",
+		ClientID:     cfg.GitHub.ClientID,
+		ClientSecret: cfg.GitHub.ClientSecret,
+		RedirectURL:  cfg.GitHub.RedirectURL,
+		AuthURL:      cfg.GitHub.AuthURL,
+		TokenURL:     cfg.GitHub.TokenURL,
+		UserInfoURL:  cfg.GitHub.UserInfoURL,
+		EmailsURL:    cfg.GitHub.EmailsURL,
+		Scopes:       []string{"read:user", "user:email"},
+	})
+}
+
+// RegisterOAuthProvider enables a provider if it has credentials.
+// Only "google" and "github" are supported since they map to dedicated columns.
+func RegisterOAuthProvider(p *OAuthProvider) {
+	switch p.Name {
+	case "google":
+		p.userIDColumn, p.tokenTable = "google_id", "google_auth"
+	case "github":
+		p.userIDColumn, p.tokenTable = "github_id", "github_auth"
+	default:
+		log.Printf("⚠️ Unsupported OAuth provider: %s", p.Name)
+		return
+	}
+
+	if p.ClientID == "" || p.ClientSecret == "" {
+		delete(oauthProviders, p.Name)
+		log.Printf("OAuth provider %s disabled (no client credentials)", p.Name)
+		return
+	}
+
+	oauthProviders[p.Name] = p
+	log.Printf("✅ OAuth provider %s enabled", p.Name)
+}
+
+// GetOAuthProvider returns an enabled provider by name, or nil
+func GetOAuthProvider(name string) *OAuthProvider {
+	return oauthProviders[name]
+}
+
+// EnabledOAuthProviders returns the names of the configured providers
+func EnabledOAuthProviders() []string {
+	names := []string{}
+	for _, name := range []string{"google", "github"} {
+		if oauthProviders[name] != nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GeneratePKCE returns a PKCE code verifier and its S256 code challenge
+func GeneratePKCE() (verifier, challenge string) {
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+	verifier = base64.RawURLEncoding.EncodeToString(bytes)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	return verifier, challenge
+}
+
+// AuthCodeURL builds the URL the browser is sent to for authorization
+func (p *OAuthProvider) AuthCodeURL(state, codeChallenge string) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		separator = "&"
+	}
+	return p.AuthURL + separator + params.Encode()
+}
+
+// Exchange trades an authorization code for tokens
+func (p *OAuthProvider) Exchange(code, codeVerifier string) (*OAuthToken, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json") // GitHub answers form-encoded otherwise
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var token OAuthToken
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %v", err)
+	}
+
+	if token.Error != "" {
+		return nil, fmt.Errorf("token endpoint error: %s %s", token.Error, token.ErrorDesc)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned status %d without an access token", resp.StatusCode)
+	}
+
+	return &token, nil
+}
+
+// FetchProfile loads the authenticated user's profile from the provider
+func (p *OAuthProvider) FetchProfile(accessToken string) (*OAuthProfile, error) {
+	switch p.Name {
+	case "google":
+		return p.fetchGoogleProfile(accessToken)
+	case "github":
+		return p.fetchGithubProfile(accessToken)
+	}
+	return nil, fmt.Errorf("unsupported provider: %s", p.Name)
+}
+
+func (p *OAuthProvider) fetchGoogleProfile(accessToken string) (*OAuthProfile, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+		Picture       string `json:"picture"`
+	}
+	if err := oauthGetJSON(p.UserInfoURL, accessToken, &info); err != nil {
+		return nil, err
+	}
+	if info.Sub == "" {
+		return nil, fmt.Errorf("userinfo response has no subject")
+	}
+
+	login := info.Email
+	if at := strings.Index(login, "@"); at > 0 {
+		login = login[:at]
+	}
+
+	return &OAuthProfile{
+		ProviderUserID: info.Sub,
+		Email:          info.Email,
+		EmailVerified:  info.EmailVerified,
+		Login:          login,
+		FirstName:      info.GivenName,
+		LastName:       info.FamilyName,
+		AvatarURL:      info.Picture,
+	}, nil
+}
+
+func (p *OAuthProvider) fetchGithubProfile(accessToken string) (*OAuthProfile, error) {
+	var info struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := oauthGetJSON(p.UserInfoURL, accessToken, &info); err != nil {
+		return nil, err
+	}
+	if info.ID == 0 {
+		return nil, fmt.Errorf("user response has no id")
+	}
+
+	profile := &OAuthProfile{
+		ProviderUserID: strconv.FormatInt(info.ID, 10),
+		Login:          info.Login,
+		AvatarURL:      info.AvatarURL,
+	}
+	profile.FirstName, profile.LastName, _ = strings.Cut(strings.TrimSpace(info.Name), " ")
+
+	// The public profile email may be unverified or hidden, so use the primary verified one
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := oauthGetJSON(p.EmailsURL, accessToken, &emails); err != nil {
+		return nil, err
+	}
+	for _, e := range emails {
+		if e.Primary {
+			profile.Email = e.Email
+			profile.EmailVerified = e.Verified
+			break
+		}
+	}
+
+	return profile, nil
+}
+
+// oauthGetJSON performs an authenticated GET and decodes the JSON response
+func oauthGetJSON(endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %v", endpoint, err)
+	}
+	return nil
+}
+
+// SaveOAuthState stores the state and PKCE verifier of an authorization request
+func SaveOAuthState(state, provider, codeVerifier string) error {
+	_, err := database.DB.Exec(`
+		INSERT INTO oauth_states (state, provider, code_verifier, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, state, provider, codeVerifier, time.Now().Add(OAuthStateDuration))
+	if err != nil {
+		return fmt.Errorf("failed to save oauth state: %v", err)
+	}
+	return nil
+}
+
+// ConsumeOAuthState validates a callback state and returns its PKCE verifier.
+// States are single use: the row is deleted whether or not it is still valid.
+func ConsumeOAuthState(state, provider string) (string, error) {
+	var verifier string
+	var expiresAt time.Time
+	err := database.DB.QueryRow(`
+		SELECT code_verifier, expires_at FROM oauth_states WHERE state = ? AND provider = ?
+	`, state, provider).Scan(&verifier, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("unknown oauth state")
+		}
+		return "", fmt.Errorf("failed to load oauth state: %v", err)
+	}
+
+	database.DB.Exec("DELETE FROM oauth_states WHERE state = ? OR expires_at <= ?", state, time.Now())
+
+	if time.Now().After(expiresAt) {
+		return "", fmt.Errorf("oauth state expired")
+	}
+	return verifier, nil
+}
+
+// ErrOAuthEmailUnverified is returned when a provider sign-in matches a local
+// account whose email has not been verified yet
+var ErrOAuthEmailUnverified = errors.New("sign in with your password and verify your email before linking this account")
+
+// CompleteOAuthLogin resolves a provider profile to a local user. Known
+// provider accounts log straight in, a verified email matching an existing
+// user whose own email is verified links the accounts, and anything else becomes a pending signup whose
+// token is returned so the user can fill in the remaining profile fields.
+func CompleteOAuthLogin(p *OAuthProvider, profile *OAuthProfile, token *OAuthToken) (*models.User, string, error) {
+	// Already linked
+	var userID string
+	err := database.DB.QueryRow(
+		fmt.Sprintf("SELECT id FROM users WHERE %s = ?", p.userIDColumn), profile.ProviderUserID,
+	).Scan(&userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, "", fmt.Errorf("failed to look up linked user: %v", err)
+	}
+
+	if err == sql.ErrNoRows {
+		if profile.Email == "" || !profile.EmailVerified {
+			return nil, "", fmt.Errorf("%s account has no verified email", p.Name)
+		}
+
+		// Link to an existing account with the same email, but only once the
+		// account owner has proven they hold that address. Otherwise whoever
+		// registered the address first could be handed the provider sign-in.
+		var emailVerified bool
+		err = database.DB.QueryRow(
+			"SELECT id, email_verified_at IS NOT NULL FROM users WHERE email = ?", profile.Email,
+		).Scan(&userID, &emailVerified)
+		if err == sql.ErrNoRows {
+			pendingToken, err := createPendingOAuthSignup(p, profile, token)
+			return nil, pendingToken, err
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to look up user by email: %v", err)
+		}
+		if !emailVerified {
+			return nil, "", ErrOAuthEmailUnverified
+		}
+
+		_, err = database.DB.Exec(
+			fmt.Sprintf("UPDATE users SET %s = ?, updated_at = ? WHERE id = ?", p.userIDColumn),
+			profile.ProviderUserID, time.Now(), userID,
+		)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to link %s account: %v", p.Name, err)
+		}
+		log.Printf("🔗 Linked %s account to user %s", p.Name, userID)
+	}
+
+	if err := saveOAuthToken(database.DB, p, userID, token.AccessToken, token.RefreshToken, tokenExpiry(token)); err != nil {
+		return nil, "", err
+	}
+
+	// A fresh provider sign-in confirms credential changes of accounts
+	// without a password
+	if _, err := database.DB.Exec("UPDATE users SET oauth_login_at = ? WHERE id = ?", time.Now(), userID); err != nil {
+		return nil, "", fmt.Errorf("failed to record %s sign-in: %v", p.Name, err)
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, "", nil
+}
+
+// GetPendingOAuthSignup returns the prefilled profile of a pending signup
+func GetPendingOAuthSignup(token string) (*models.OAuthPendingSignup, error) {
+	pending := &models.OAuthPendingSignup{}
+	err := database.DB.QueryRow(`
+		SELECT provider, email, nickname, first_name, last_name, avatar_url, expires_at
+		FROM oauth_pending_signups
+		WHERE token = ? AND expires_at > ?
+	`, token, time.Now()).Scan(
+		&pending.Provider, &pending.Email, &pending.Nickname,
+		&pending.FirstName, &pending.LastName, &pending.AvatarURL, &pending.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get pending signup: %v", err)
+	}
+	return pending, nil
+}
+
+// CompleteOAuthSignup creates the user for a pending signup, links the
+// provider account and stores its tokens
+func CompleteOAuthSignup(token string, req *models.OAuthSignupRequest) (*models.User, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var providerName, providerUserID, email, accessToken string
+	var avatarURL, refreshToken sql.NullString
+	var tokenExpiresAt time.Time
+	err = tx.QueryRow(`
+		SELECT provider, provider_user_id, email, avatar_url, access_token, refresh_token, token_expires_at
+		FROM oauth_pending_signups
+		WHERE token = ? AND expires_at > ?
+	`, token, time.Now()).Scan(&providerName, &providerUserID, &email, &avatarURL, &accessToken, &refreshToken, &tokenExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("signup expired")
+		}
+		return nil, fmt.Errorf("failed to load pending signup: %v", err)
+	}
+
+	p := GetOAuthProvider(providerName)
+	if p == nil {
+		return nil, fmt.Errorf("provider %s is not enabled", providerName)
+	}
+
+	userID := uuid.New().String()
+	now := time.Now()
+
+	// OAuth-only accounts have no password; the empty hash never matches
+	_, err = tx.Exec(fmt.Sprintf(`
+		INSERT INTO users (id, email, email_verified_at, nickname, password, first_name, last_name, age, gender, %s, avatar_url, oauth_login_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, '', ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.userIDColumn), userID, email, now, req.Nickname, req.FirstName, req.LastName, req.Age, req.Gender,
+		providerUserID, nullIfEmpty(avatarURL.String), now, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	if err := saveOAuthToken(tx, p, userID, accessToken, refreshToken.String, tokenExpiresAt); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM oauth_pending_signups WHERE token = ?", token); err != nil {
+		return nil, fmt.Errorf("failed to clear pending signup: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit signup: %v", err)
+	}
+
+	log.Printf("✅ Provisioned user %s from %s", req.Nickname, providerName)
+	return GetUserByID(userID)
+}
+
+// createPendingOAuthSignup stores the provider profile and tokens until the
+// user supplies the profile fields the provider does not know (age, gender)
+func createPendingOAuthSignup(p *OAuthProvider, profile *OAuthProfile, token *OAuthToken) (string, error) {
+	pendingToken := GenerateSessionID()
+
+	_, err := database.DB.Exec(`
+		INSERT INTO oauth_pending_signups (
+			token, provider, provider_user_id, email, nickname, first_name, last_name, avatar_url,
+			access_token, refresh_token, token_expires_at, expires_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, pendingToken, p.Name, profile.ProviderUserID, profile.Email, suggestNickname(profile.Login),
+		profile.FirstName, profile.LastName, nullIfEmpty(profile.AvatarURL),
+		token.AccessToken, token.RefreshToken, tokenExpiry(token), time.Now().Add(OAuthSignupDuration))
+	if err != nil {
+		return "", fmt.Errorf("failed to store pending signup: %v", err)
+	}
+
+	// Opportunistically clear abandoned signups
+	database.DB.Exec("DELETE FROM oauth_pending_signups WHERE expires_at <= ?", time.Now())
+
+	return pendingToken, nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// saveOAuthToken upserts the provider tokens for a user
+func saveOAuthToken(db execer, p *OAuthProvider, userID, accessToken, refreshToken string, expiresAt time.Time) error {
+	_, err := db.Exec(fmt.Sprintf(`
+		INSERT INTO %s (user_id, access_token, refresh_token, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			access_token = excluded.access_token,
+			refresh_token = COALESCE(excluded.refresh_token, refresh_token),
+			expires_at = excluded.expires_at
+	`, p.tokenTable), userID, accessToken, nullIfEmpty(refreshToken), expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save %s token: %v", p.Name, err)
+	}
+	return nil
+}
+
+// tokenExpiry converts expires_in into an absolute time. GitHub OAuth app
+// tokens never expire and omit expires_in, so they get a far-future expiry.
+func tokenExpiry(token *OAuthToken) time.Time {
+	if token.ExpiresIn <= 0 {
+		return time.Now().AddDate(10, 0, 0)
+	}
+	return time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+}
+
+// suggestNickname derives a free nickname from the provider login
+func suggestNickname(login string) string {
+	base := nicknameCleaner.ReplaceAllString(login, "")
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 1; i < 100; i++ {
+		var exists bool
+		if err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE nickname = ?)", candidate).Scan(&exists); err != nil || !exists {
+			return candidate
+		}
+		candidate = base + strconv.Itoa(i)
+	}
+	return candidate
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"forum/internal/database"
+	"forum/internal/mail"
+	"forum/internal/models"
+)
+
+// MinPasswordLength is the shortest password accepted when one is set
+const MinPasswordLength = 8
+
+// ErrPasswordTooShort is returned for new passwords under MinPasswordLength
+var ErrPasswordTooShort = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+
+// RequireVerifiedEmail reports whether users must verify their email before
+// posting and commenting
+func RequireVerifiedEmail() bool {
+	return settings.RequireVerifiedEmail
+}
+
+// RequestPasswordReset emails a password reset link to the account
+// registered with email. Unknown emails succeed without sending anything so
+// callers cannot tell which emails have accounts.
+func RequestPasswordReset(email string) error {
+	var userID, nickname string
+	err := database.DB.QueryRow("SELECT id, nickname FROM users WHERE email = ?", email).Scan(&userID, &nickname)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to look up user: %v", err)
+	}
+
+	token, err := createUserToken(userID, TokenPasswordReset, settings.PasswordResetTTL.Duration)
+	if err != nil {
+		return err
+	}
+
+	link := mail.URL("/reset-password?token=" + url.QueryEscape(token))
+	return mail.Send(mail.Message{
+		To:      email,
+		Subject: "Reset your Forum password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your Forum account. "+
+			"Choose a new password here:\n\n%s\n\nThe link works once and expires in %s. "+
+			"If you did not ask for this, you can ignore this email.\n",
+			nickname, link, settings.PasswordResetTTL.Duration),
+	})
+}
+
+// ResetPassword sets a new password using a password reset token. Every
+// session of the user is ended and any login lockout is lifted. Returns the
+// user ID and the public IDs of the ended sessions.
+func ResetPassword(token, password string) (string, []string, error) {
+	if len(password) < MinPasswordLength {
+		return "", nil, ErrPasswordTooShort
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeUserToken(tx, token, TokenPasswordReset)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Receiving the reset email proves the user owns the address
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE users
+		SET password = ?, failed_logins = 0, locked_until = NULL,
+			email_verified_at = COALESCE(email_verified_at, ?), updated_at = ?
+		WHERE id = ?
+	`, hash, now, now, userID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to update password: %v", err)
+	}
+
+	revoked, err := deleteUserSessions(tx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", nil, fmt.Errorf("failed to reset password: %v", err)
+	}
+
+	log.Printf("🔑 Password reset for user %s, ended %d sessions", userID, len(revoked))
+	return userID, revoked, nil
+}
+
+// SendEmailVerification emails user a link that confirms their address
+func SendEmailVerification(user *models.User) error {
+	token, err := createUserToken(user.ID, TokenEmailVerification, settings.EmailVerificationTTL.Duration)
+	if err != nil {
+		return err
+	}
+
+	link := mail.URL("/verify-email?token=" + url.QueryEscape(token))
+	return mail.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your Forum email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm that this is your email address:\n\n%s\n\n"+
+			"The link expires in %s. If you did not create a Forum account, you can ignore this email.\n",
+			user.Nickname, link, settings.EmailVerificationTTL.Duration),
+	})
+}
+
+// VerifyEmail marks the email of the user an email verification token was
+// issued to as verified, and returns that user's ID
+func VerifyEmail(token string) (string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeUserToken(tx, token, TokenEmailVerification)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?), updated_at = ? WHERE id = ?
+	`, now, now, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify email: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to verify email: %v", err)
+	}
+	return userID, nil
+}
+
+// deleteUserSessions deletes every session of the user and returns their
+// public IDs
+func deleteUserSessions(tx *sql.Tx, userID string) ([]string, error) {
+	rows, err := tx.Query("DELETE FROM sessions WHERE user_id = ? RETURNING public_id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to end sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var publicIDs []string
+	for rows.Next() {
+		var publicID string
+		if err := rows.Scan(&publicID); err != nil {
+			return nil, fmt.Errorf("failed to scan ended session: %v", err)
+		}
+		publicIDs = append(publicIDs, publicID)
+	}
+	return publicIDs, rows.Err()
+}
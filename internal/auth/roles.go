@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// Roles a user can hold. Every account starts as RoleUser.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permission names an action that some roles may take on content or
+// accounts they do not own
+type Permission string
+
+// Permissions granted by roles
+const (
+	PermPostEditAny      Permission = "post.edit.any"
+	PermPostDeleteAny    Permission = "post.delete.any"
+	PermCommentEditAny   Permission = "comment.edit.any"
+	PermCommentDeleteAny Permission = "comment.delete.any"
+	PermReportReview     Permission = "report.review"
+	PermUserSuspend      Permission = "user.suspend"
+	PermUserBan          Permission = "user.ban"
+	PermUserRoleAssign   Permission = "user.role.assign"
+	PermAuditView        Permission = "audit.view"
+)
+
+// Errors returned when assigning roles
+var (
+	ErrInvalidRole  = errors.New("role must be user, moderator or admin")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// moderatorPermissions let moderators look after content
+var moderatorPermissions = []Permission{
+	PermPostEditAny,
+	PermPostDeleteAny,
+	PermCommentEditAny,
+	PermCommentDeleteAny,
+	PermReportReview,
+	PermUserSuspend,
+}
+
+// rolePermissions lists what each role may do beyond its own content.
+// Regular users have no extra permissions.
+var rolePermissions = map[string][]Permission{
+	RoleUser:      nil,
+	RoleModerator: moderatorPermissions,
+	RoleAdmin:     append([]Permission{PermUserBan, PermUserRoleAssign, PermAuditView}, moderatorPermissions...),
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether the user's role grants permission. A nil
+// user has no permissions.
+func HasPermission(user *models.User, permission Permission) bool {
+	if user == nil {
+		return false
+	}
+	for _, p := range rolePermissions[user.Role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanModify reports whether the user may change something owned by ownerID:
+// their own content always, anyone else's only with permission
+func CanModify(user *models.User, ownerID string, permission Permission) bool {
+	return user != nil && (user.ID == ownerID || HasPermission(user, permission))
+}
+
+// Permissions lists the permissions of the user's role
+func Permissions(user *models.User) []string {
+	permissions := []string{}
+	for _, p := range rolePermissions[user.Role] {
+		permissions = append(permissions, string(p))
+	}
+	return permissions
+}
+
+// NewSessionUser pairs the signed-in user with their permissions and the
+// session's CSRF token, as returned to the client
+func NewSessionUser(user *models.User, csrfToken string) *models.SessionUser {
+	return &models.SessionUser{User: user, Permissions: Permissions(user), CSRFToken: csrfToken}
+}
+
+// SetUserRole gives the user a new role. Their sessions pick it up on the
+// next request.
+func SetUserRole(userID, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	result, err := database.DB.Exec("UPDATE users SET role = ?, updated_at = ? WHERE id = ?", role, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set role: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	log.Printf("👮 User %s is now %s", userID, role)
+	return nil
+}
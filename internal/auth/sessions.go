@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// ErrSessionNotFound is returned when a session does not exist or belongs to
+// another user
+var ErrSessionNotFound = errors.New("session not found")
+
+const (
+	// activityInterval is how stale a session's last activity may get before
+	// a request records it again and renews the session, so requests do not
+	// write on every request
+	activityInterval = time.Minute
+
+	// maxUserAgentLength bounds the user agent stored with a session
+	maxUserAgentLength = 512
+)
+
+// sessionExpiry is when a session created at createdAt and last used at
+// lastActive expires: after the idle timeout, capped by the absolute lifetime
+func sessionExpiry(createdAt, lastActive time.Time) time.Time {
+	idle := lastActive.Add(settings.SessionIdleTimeout.Duration)
+	absolute := createdAt.Add(settings.SessionDuration.Duration)
+	if idle.After(absolute) {
+		return absolute
+	}
+	return idle
+}
+
+// renewInterval is how often activity renews a session. Short idle timeouts
+// renew more often so an active session never lapses between renewals.
+func renewInterval() time.Duration {
+	if interval := settings.SessionIdleTimeout.Duration / 10; interval < activityInterval {
+		return interval
+	}
+	return activityInterval
+}
+
+// touchSession records activity on a session and slides its expiry forward,
+// at most once per renewInterval
+func touchSession(session *models.Session) {
+	now := time.Now()
+	if now.Sub(session.LastActiveAt) < renewInterval() {
+		return
+	}
+
+	expiresAt := sessionExpiry(session.CreatedAt, now)
+	_, err := database.DB.Exec(`
+		UPDATE sessions SET last_active_at = ?, expires_at = ? WHERE id = ?
+	`, now, expiresAt, session.ID)
+	if err != nil {
+		log.Printf("❌ Failed to renew session: %v", err)
+		return
+	}
+	session.LastActiveAt = now
+	session.ExpiresAt = expiresAt
+}
+
+// RotateSession gives a session a new ID and sets it on the response cookie,
+// so an ID captured before a privilege change stops working. The session
+// keeps its public ID, CSRF token and expiry.
+func RotateSession(w http.ResponseWriter, session *models.Session) error {
+	newID := GenerateSessionID()
+	_, err := database.DB.Exec("UPDATE sessions SET id = ? WHERE id = ?", newID, session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to rotate session: %v", err)
+	}
+
+	session.ID = newID
+	SetSessionCookie(w, newID)
+	return nil
+}
+
+// ListSessions returns the user's unexpired sessions, most recently active
+// first. The session identified by currentID is marked as current.
+func ListSessions(userID, currentID string) ([]models.SessionInfo, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, public_id, user_agent, ip_address, created_at, last_active_at, expires_at
+		FROM sessions
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY last_active_at DESC
+	`, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.SessionInfo{}
+	for rows.Next() {
+		var id string
+		var info models.SessionInfo
+		if err := rows.Scan(&id, &info.ID, &info.UserAgent, &info.IPAddress,
+			&info.CreatedAt, &info.LastActiveAt, &info.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %v", err)
+		}
+		info.Current = id == currentID
+		sessions = append(sessions, info)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession deletes the user's session with the given public ID
+func RevokeSession(userID, publicID string) error {
+	result, err := database.DB.Exec(`
+		DELETE FROM sessions WHERE user_id = ? AND public_id = ?
+	`, userID, publicID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions deletes every session of the user except keepID and
+// returns the public IDs of the deleted sessions
+func RevokeOtherSessions(userID, keepID string) ([]string, error) {
+	rows, err := database.DB.Query(`
+		DELETE FROM sessions WHERE user_id = ? AND id != ?
+		RETURNING public_id
+	`, userID, keepID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var publicIDs []string
+	for rows.Next() {
+		var publicID string
+		if err := rows.Scan(&publicID); err != nil {
+			return nil, fmt.Errorf("failed to scan revoked session: %v", err)
+		}
+		publicIDs = append(publicIDs, publicID)
+	}
+
+	return publicIDs, rows.Err()
+}
+
+// CleanupExpiredSessions removes expired sessions from the database and
+// returns how many were removed
+func CleanupExpiredSessions() (int64, error) {
+	result, err := database.DB.Exec("DELETE FROM sessions WHERE expires_at <= ?", time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired sessions: %v", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// MaxSuspensionReasonLength bounds the reason shown to a suspended user
+const MaxSuspensionReasonLength = 1000
+
+// Errors returned when suspending users
+var (
+	ErrCannotSuspendStaff     = errors.New("moderators and admins cannot be suspended")
+	ErrSuspensionReason       = errors.New("a reason is required")
+	ErrSuspensionReasonLength = fmt.Errorf("reason cannot exceed %d characters", MaxSuspensionReasonLength)
+	ErrNotSuspended           = errors.New("user is not suspended")
+)
+
+// SuspendedError is returned when a suspended or banned user signs in
+type SuspendedError struct {
+	Suspension *models.Suspension
+}
+
+func (e *SuspendedError) Error() string {
+	if e.Suspension.ExpiresAt == nil {
+		return "account banned: " + e.Suspension.Reason
+	}
+	return fmt.Sprintf("account suspended until %s: %s",
+		e.Suspension.ExpiresAt.Format(time.RFC3339), e.Suspension.Reason)
+}
+
+// suspensionSelect loads suspensions with the nicknames of the suspended
+// user and the moderator who suspended them
+const suspensionSelect = `
+	SELECT s.id, s.user_id, u.nickname, s.reason, s.expires_at, m.nickname, s.created_at
+	FROM user_suspensions s
+	JOIN users u ON u.id = s.user_id
+	LEFT JOIN users m ON m.id = s.created_by
+`
+
+// activeSuspension is the condition for a suspension still in force
+const activeSuspension = "s.lifted_at IS NULL AND (s.expires_at IS NULL OR s.expires_at > ?)"
+
+// GetActiveSuspension returns the suspension keeping the user out, or nil.
+// When several overlap, a ban wins over the suspension lasting longest.
+func GetActiveSuspension(userID string) (*models.Suspension, error) {
+	row := database.DB.QueryRow(suspensionSelect+`
+		WHERE s.user_id = ? AND `+activeSuspension+`
+		ORDER BY s.expires_at IS NULL DESC, s.expires_at DESC
+		LIMIT 1
+	`, userID, time.Now())
+
+	suspension, err := scanSuspension(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check suspension: %v", err)
+	}
+	return suspension, nil
+}
+
+// checkSuspension returns a SuspendedError if the user is suspended
+func checkSuspension(userID string) error {
+	suspension, err := GetActiveSuspension(userID)
+	if err != nil {
+		return err
+	}
+	if suspension != nil {
+		return &SuspendedError{Suspension: suspension}
+	}
+	return nil
+}
+
+// ListSuspensions returns the suspensions in force, newest first
+func ListSuspensions() ([]models.Suspension, error) {
+	rows, err := database.DB.Query(suspensionSelect+`
+		WHERE `+activeSuspension+`
+		ORDER BY s.created_at DESC, s.id DESC
+	`, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suspensions: %v", err)
+	}
+	defer rows.Close()
+
+	suspensions := []models.Suspension{}
+	for rows.Next() {
+		suspension, err := scanSuspension(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan suspension: %v", err)
+		}
+		suspensions = append(suspensions, *suspension)
+	}
+	return suspensions, rows.Err()
+}
+
+// SuspendUser keeps the user out for duration, or for good when duration is
+// zero, and ends all their sessions. Overlapping suspensions are kept, so a
+// shorter one never cuts a longer one or a ban short.
+func SuspendUser(userID, byID, reason string, duration time.Duration) (*models.Suspension, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	suspension, err := SuspendUserTx(tx, userID, byID, reason, duration)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit suspension: %v", err)
+	}
+	return suspension, nil
+}
+
+// SuspendUserTx is SuspendUser within the caller's transaction
+func SuspendUserTx(tx *sql.Tx, userID, byID, reason string, duration time.Duration) (*models.Suspension, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrSuspensionReason
+	}
+	if utf8.RuneCountInString(reason) > MaxSuspensionReasonLength {
+		return nil, ErrSuspensionReasonLength
+	}
+
+	var role string
+	err := tx.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user: %v", err)
+	}
+	if role != RoleUser {
+		return nil, ErrCannotSuspendStaff
+	}
+
+	now := time.Now()
+	var expiresAt *time.Time
+	if duration > 0 {
+		until := now.Add(duration)
+		expiresAt = &until
+	}
+
+	var id int64
+	err = tx.QueryRow(`
+		INSERT INTO user_suspensions (user_id, reason, expires_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?) RETURNING id
+	`, userID, reason, expiresAt, byID, now).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to suspend user: %v", err)
+	}
+
+	if _, err := deleteUserSessions(tx, userID); err != nil {
+		return nil, err
+	}
+
+	suspension, err := scanSuspension(tx.QueryRow(suspensionSelect+"WHERE s.id = ?", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load suspension: %v", err)
+	}
+
+	if expiresAt == nil {
+		log.Printf("⛔ User %s banned by %s", userID, byID)
+	} else {
+		log.Printf("⛔ User %s suspended by %s until %s", userID, byID, expiresAt.Format(time.RFC3339))
+	}
+	return suspension, nil
+}
+
+// LiftSuspension lifts every suspension and ban in force on the user
+func LiftSuspension(userID, byID string) error {
+	result, err := database.DB.Exec(`
+		UPDATE user_suspensions AS s SET lifted_by = ?, lifted_at = ?
+		WHERE s.user_id = ? AND `+activeSuspension,
+		byID, time.Now(), userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to lift suspension: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotSuspended
+	}
+
+	log.Printf("✅ Suspension of user %s lifted by %s", userID, byID)
+	return nil
+}
+
+// scanSuspension scans a row selected with suspensionSelect
+func scanSuspension(row interface{ Scan(...interface{}) error }) (*models.Suspension, error) {
+	var s models.Suspension
+	var expiresAt sql.NullTime
+	var createdBy sql.NullString
+	if err := row.Scan(&s.ID, &s.UserID, &s.Nickname, &s.Reason, &expiresAt, &createdBy, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		s.ExpiresAt = &expiresAt.Time
+	}
+	if createdBy.Valid {
+		s.CreatedBy = &createdBy.String
+	}
+	return &s, nil
+}
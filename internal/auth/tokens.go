@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"forum/internal/database"
+)
+
+// Purposes of account tokens. A token only works for the purpose it was
+// issued for.
+const (
+	TokenPasswordReset     = "password_reset"
+	TokenEmailVerification = "email_verification"
+	TokenLoginChallenge    = "login_challenge" // a login waiting for its second factor
+)
+
+// ErrInvalidToken is returned for account tokens that are unknown, expired,
+// already used or issued for another purpose
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// hashToken is how account tokens are stored, so the database never holds a
+// usable token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createUserToken issues a single-use token for purpose that expires after
+// ttl. Unused tokens issued earlier for the same purpose stop working.
+func createUserToken(userID, purpose string, ttl time.Duration) (string, error) {
+	token := GenerateSessionID()
+	now := time.Now()
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM user_tokens WHERE user_id = ? AND purpose = ?", userID, purpose); err != nil {
+		return "", fmt.Errorf("failed to replace earlier tokens: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, hashToken(token), userID, purpose, now.Add(ttl), now)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to create token: %v", err)
+	}
+	return token, nil
+}
+
+// lookupUserToken returns the user a valid token was issued to without
+// using it up
+func lookupUserToken(token, purpose string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidToken
+	}
+
+	var userID string
+	err := database.DB.QueryRow(`
+		SELECT user_id FROM user_tokens
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?
+	`, hashToken(token), purpose, time.Now()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrInvalidToken
+		}
+		return "", fmt.Errorf("failed to look up token: %v", err)
+	}
+	return userID, nil
+}
+
+// consumeUserToken marks a valid token as used and returns the user it was
+// issued to. A token can be consumed once.
+func consumeUserToken(tx *sql.Tx, token, purpose string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidToken
+	}
+
+	now := time.Now()
+	var userID string
+	err := tx.QueryRow(`
+		UPDATE user_tokens SET used_at = ?
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?
+		RETURNING user_id
+	`, now, hashToken(token), purpose, now).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrInvalidToken
+		}
+		return "", fmt.Errorf("failed to consume token: %v", err)
+	}
+	return userID, nil
+}
+
+// CleanupUserTokens removes used and expired account tokens and returns how
+// many were removed
+func CleanupUserTokens() (int64, error) {
+	result, err := database.DB.Exec(
+		"DELETE FROM user_tokens WHERE used_at IS NOT NULL OR expires_at <= ?", time.Now(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup account tokens: %v", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,386 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// TOTP parameters. These are the RFC 6238 defaults, which every
+// authenticator app supports.
+const (
+	totpDigits = 6
+	totpPeriod = 30 // seconds per code
+	totpSkew   = 1  // codes accepted either side of the current one, for clock drift
+
+	recoveryCodeCount = 10
+)
+
+const (
+	// TwoFactorCookieName carries a login waiting for its second factor
+	TwoFactorCookieName = "forum_2fa_challenge"
+	// TwoFactorChallengeDuration is how long the second factor may take
+	TwoFactorChallengeDuration = 5 * time.Minute
+)
+
+// Errors returned by two-factor authentication
+var (
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotStarted = errors.New("two-factor setup has not been started")
+	ErrInvalidCode         = errors.New("invalid authentication code")
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpCode returns the code for a time step, as described in RFC 4226
+func totpCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// totpStep returns the time step t falls in
+func totpStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// TOTPCode returns the code an authenticator app shows for a base32 secret
+// at time t
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid secret: %v", err)
+	}
+	return totpCode(key, totpStep(t)), nil
+}
+
+// checkTOTP accepts a code for the user's secret. Each code works once: the
+// accepted time step is recorded and earlier steps are refused afterwards.
+func checkTOTP(userID, secret, code string) (bool, error) {
+	key, err := secretEncoding.DecodeString(secret)
+	if err != nil {
+		return false, fmt.Errorf("invalid stored secret: %v", err)
+	}
+
+	now := totpStep(time.Now())
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if !hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			continue
+		}
+
+		result, err := database.DB.Exec(`
+			UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?
+		`, step, userID, step)
+		if err != nil {
+			return false, fmt.Errorf("failed to record code use: %v", err)
+		}
+		used, err := result.RowsAffected()
+		return used == 1, err
+	}
+	return false, nil
+}
+
+// generateRecoveryCodes replaces the user's recovery codes and returns the
+// new ones. Only their hashes are stored.
+func generateRecoveryCodes(tx *sql.Tx, userID string) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return nil, fmt.Errorf("failed to remove old recovery codes: %v", err)
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %v", err)
+		}
+		code := strings.ToLower(secretEncoding.EncodeToString(raw))
+		codes[i] = code[:4] + "-" + code[4:]
+
+		if _, err := tx.Exec(
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hashToken(code),
+		); err != nil {
+			return nil, fmt.Errorf("failed to store recovery code: %v", err)
+		}
+	}
+	return codes, nil
+}
+
+// useRecoveryCode spends one of the user's unused recovery codes
+func useRecoveryCode(userID, code string) (bool, error) {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	result, err := database.DB.Exec(`
+		UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, time.Now(), userID, hashToken(normalized))
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %v", err)
+	}
+	used, err := result.RowsAffected()
+	return used == 1, err
+}
+
+// verifySecondFactor checks a code from the user's authenticator app or one
+// of their recovery codes. Wrong codes count towards the login lockout, and
+// a right one clears it.
+func verifySecondFactor(userID, code string) error {
+	var secret sql.NullString
+	var enabled bool
+	err := database.DB.QueryRow(`
+		SELECT totp_secret, totp_enabled_at IS NOT NULL FROM users WHERE id = ?
+	`, userID).Scan(&secret, &enabled)
+	if err != nil {
+		return fmt.Errorf("failed to look up two-factor settings: %v", err)
+	}
+	if !enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err := checkLockout(userID); err != nil {
+		return err
+	}
+
+	code = strings.TrimSpace(code)
+	var ok bool
+	if len(code) == totpDigits {
+		ok, err = checkTOTP(userID, secret.String, code)
+	} else {
+		ok, err = useRecoveryCode(userID, code)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		if err := recordFailedLogin(userID); err != nil {
+			return err
+		}
+		return ErrInvalidCode
+	}
+	return resetFailedLogins(userID)
+}
+
+// BeginTwoFactorSetup gives the user a new secret for their authenticator
+// app. Two-factor authentication is only enabled once EnableTwoFactor
+// confirms a code generated from it.
+func BeginTwoFactorSetup(user *models.User, password string) (*models.TwoFactorSetup, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if err := confirmPassword(user.ID, password); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %v", err)
+	}
+	secret := secretEncoding.EncodeToString(raw)
+
+	_, err := database.DB.Exec(`
+		UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ? AND totp_enabled_at IS NULL
+	`, secret, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store secret: %v", err)
+	}
+
+	issuer := settings.TwoFactorIssuer
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + user.Nickname,
+		RawQuery: query.Encode(),
+	}
+
+	return &models.TwoFactorSetup{Secret: secret, URI: uri.String()}, nil
+}
+
+// EnableTwoFactor turns on two-factor authentication once code shows the
+// user's authenticator app has the secret from BeginTwoFactorSetup. Returns
+// the user's recovery codes.
+func EnableTwoFactor(userID, code string) ([]string, error) {
+	var secret sql.NullString
+	var enabled bool
+	err := database.DB.QueryRow(`
+		SELECT totp_secret, totp_enabled_at IS NOT NULL FROM users WHERE id = ?
+	`, userID).Scan(&secret, &enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up two-factor settings: %v", err)
+	}
+	if enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if !secret.Valid {
+		return nil, ErrTwoFactorNotStarted
+	}
+
+	ok, err := checkTOTP(userID, secret.String, strings.TrimSpace(code))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET totp_enabled_at = ? WHERE id = ?", time.Now(), userID); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %v", err)
+	}
+
+	codes, err := generateRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %v", err)
+	}
+
+	log.Printf("🔐 User %s enabled two-factor authentication", userID)
+	return codes, nil
+}
+
+// DisableTwoFactor turns off two-factor authentication after confirming the
+// user's password and a code
+func DisableTwoFactor(userID, password, code string) error {
+	if err := confirmPassword(userID, password); err != nil {
+		return err
+	}
+	if err := verifySecondFactor(userID, code); err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = ?
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to remove recovery codes: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %v", err)
+	}
+
+	log.Printf("🔓 User %s disabled two-factor authentication", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after
+// confirming their password and a code
+func RegenerateRecoveryCodes(userID, password, code string) ([]string, error) {
+	if err := confirmPassword(userID, password); err != nil {
+		return nil, err
+	}
+	if err := verifySecondFactor(userID, code); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	codes, err := generateRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %v", err)
+	}
+	return codes, nil
+}
+
+// GetTwoFactorStatus describes the user's two-factor settings
+func GetTwoFactorStatus(userID string) (*models.TwoFactorStatus, error) {
+	status := &models.TwoFactorStatus{}
+	err := database.DB.QueryRow(`
+		SELECT u.totp_enabled_at IS NOT NULL,
+			(SELECT COUNT(*) FROM recovery_codes r WHERE r.user_id = u.id AND r.used_at IS NULL)
+		FROM users u WHERE u.id = ?
+	`, userID).Scan(&status.Enabled, &status.RecoveryCodesLeft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor status: %v", err)
+	}
+	return status, nil
+}
+
+// BeginTwoFactorLogin starts the second login step for a user whose
+// password was accepted. Returns the challenge that CompleteTwoFactorLogin
+// takes.
+func BeginTwoFactorLogin(userID string) (string, error) {
+	return createUserToken(userID, TokenLoginChallenge, TwoFactorChallengeDuration)
+}
+
+// CompleteTwoFactorLogin finishes a login started by BeginTwoFactorLogin
+// once the user gives a valid code. The challenge survives wrong codes until
+// it expires or the account locks.
+func CompleteTwoFactorLogin(challenge, code string) (*models.User, error) {
+	userID, err := lookupUserToken(challenge, TokenLoginChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySecondFactor(userID, code); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := consumeUserToken(tx, challenge, TokenLoginChallenge); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to complete login: %v", err)
+	}
+	return GetUserByID(userID)
+}
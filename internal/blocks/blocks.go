@@ -0,0 +1,117 @@
+package blocks
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// BlockedIDs selects the IDs of the users blocked by the user bound to its
+// parameter, for queries to leave out their content
+const BlockedIDs = "SELECT blocked_id FROM user_blocks WHERE blocker_id = ?"
+
+// Errors returned when blocking users
+var (
+	ErrCannotBlockSelf = errors.New("you cannot block yourself")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrNotBlocked      = errors.New("user is not blocked")
+)
+
+// Block stops blockedID from messaging blockerID and hides their content
+// from blockerID. Blocking someone already blocked does nothing.
+func Block(blockerID, blockedID string) error {
+	if blockerID == blockedID {
+		return ErrCannotBlockSelf
+	}
+
+	var exists bool
+	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", blockedID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to look up user: %v", err)
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	_, err = database.DB.Exec(`
+		INSERT INTO user_blocks (blocker_id, blocked_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`, blockerID, blockedID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to block user: %v", err)
+	}
+
+	log.Printf("🚫 User %s blocked %s", blockerID, blockedID)
+	return nil
+}
+
+// Unblock lifts a block set by blockerID
+func Unblock(blockerID, blockedID string) error {
+	result, err := database.DB.Exec(
+		"DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?", blockerID, blockedID)
+	if err != nil {
+		return fmt.Errorf("failed to unblock user: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotBlocked
+	}
+
+	log.Printf("🚫 User %s unblocked %s", blockerID, blockedID)
+	return nil
+}
+
+// List returns the users blockerID has blocked, most recent first
+func List(blockerID string) ([]models.BlockedUser, error) {
+	rows, err := database.DB.Query(`
+		SELECT u.id, u.nickname, u.avatar_url, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = ?
+		ORDER BY b.created_at DESC
+	`, blockerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocked users: %v", err)
+	}
+	defer rows.Close()
+
+	blocked := []models.BlockedUser{}
+	for rows.Next() {
+		var user models.BlockedUser
+		if err := rows.Scan(&user.ID, &user.Nickname, &user.AvatarURL, &user.BlockedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan blocked user: %v", err)
+		}
+		blocked = append(blocked, user)
+	}
+	return blocked, rows.Err()
+}
+
+// HasBlocked reports whether blockerID has blocked blockedID
+func HasBlocked(blockerID, blockedID string) (bool, error) {
+	var blocked bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?)
+	`, blockerID, blockedID).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %v", err)
+	}
+	return blocked, nil
+}
+
+// Between reports whether either user has blocked the other
+func Between(userID, otherID string) (bool, error) {
+	var blocked bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+		)
+	`, userID, otherID, otherID, userID).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %v", err)
+	}
+	return blocked, nil
+}
@@ -0,0 +1,532 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every setting the server reads at startup. It is loaded once
+// in main and each package receives the section it needs.
+type Config struct {
+	Server    ServerConfig    `json:"server"`
+	Database  DatabaseConfig  `json:"database"`
+	Auth      AuthConfig      `json:"auth"`
+	OAuth     OAuthConfig     `json:"oauth"`
+	Uploads   UploadsConfig   `json:"uploads"`
+	WebSocket WebSocketConfig `json:"websocket"`
+	Messaging MessagingConfig `json:"messaging"`
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	Mail      MailConfig      `json:"mail"`
+}
+
+// ServerConfig configures the HTTP listener and the frontend files
+type ServerConfig struct {
+	Port            string   `json:"port"`
+	PublicURL       string   `json:"publicUrl"`       // where users reach the forum, used in emailed links; defaults to http://localhost:<port>
+	StaticDir       string   `json:"staticDir"`       // served at /static/, must contain index.html
+	ShutdownTimeout Duration `json:"shutdownTimeout"` // time allowed on shutdown for in-flight requests, then again for websocket clients
+}
+
+// DatabaseConfig configures the SQLite database
+type DatabaseConfig struct {
+	Path string `json:"path"`
+}
+
+// AuthConfig configures sessions, their cookies, request origins and login lockout
+type AuthConfig struct {
+	SessionDuration    Duration `json:"sessionDuration"`    // absolute lifetime of a session, however active
+	SessionIdleTimeout Duration `json:"sessionIdleTimeout"` // a session unused for this long expires; no effect when longer than sessionDuration
+	CleanupInterval    Duration `json:"cleanupInterval"`    // how often expired sessions and stale online users are purged
+	CookieSecure       bool     `json:"cookieSecure"`       // send cookies over HTTPS only
+
+	// AllowedOrigins lists the origins other than the server's own, such as
+	// "https://forum.example.com", that may send API writes and open websockets
+	AllowedOrigins []string `json:"allowedOrigins"`
+
+	// After LockoutThreshold failed logins in a row an account is locked for
+	// LockoutDuration, doubling with every further failure up to MaxLockoutDuration
+	LockoutThreshold   int      `json:"lockoutThreshold"`
+	LockoutDuration    Duration `json:"lockoutDuration"`
+	MaxLockoutDuration Duration `json:"maxLockoutDuration"`
+
+	PasswordResetTTL     Duration `json:"passwordResetTtl"`     // how long a password reset link works
+	EmailVerificationTTL Duration `json:"emailVerificationTtl"` // how long an email verification link works
+	RequireVerifiedEmail bool     `json:"requireVerifiedEmail"` // only users with a verified email may post and comment
+
+	TwoFactorIssuer string `json:"twoFactorIssuer"` // names the forum in authenticator apps
+}
+
+// OAuthConfig configures the OAuth providers. A provider is enabled when
+// both its client ID and secret are set.
+type OAuthConfig struct {
+	RedirectBaseURL string              `json:"redirectBaseUrl"` // defaults to server.publicUrl
+	Google          OAuthProviderConfig `json:"google"`
+	GitHub          OAuthProviderConfig `json:"github"`
+}
+
+// OAuthProviderConfig holds one provider's credentials and endpoints.
+// Endpoints only need changing to point at a stub provider.
+type OAuthProviderConfig struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RedirectURL  string `json:"redirectUrl"` // defaults to <redirectBaseUrl>/auth/<provider>/callback
+	AuthURL      string `json:"authUrl"`
+	TokenURL     string `json:"tokenUrl"`
+	UserInfoURL  string `json:"userInfoUrl"`
+	EmailsURL    string `json:"emailsUrl,omitempty"` // GitHub only
+}
+
+// UploadsConfig configures user uploads
+type UploadsConfig struct {
+	Dir           string `json:"dir"`           // served at /static/uploads/
+	MaxAvatarSize int64  `json:"maxAvatarSize"` // bytes
+}
+
+// WebSocketConfig configures WebSocket connection timeouts and limits
+type WebSocketConfig struct {
+	WriteWait    Duration `json:"writeWait"`    // time allowed to write a frame
+	PongWait     Duration `json:"pongWait"`     // time allowed between pongs before the connection is dropped
+	PingPeriod   Duration `json:"pingPeriod"`   // interval between pings, must be shorter than pongWait
+	MaxFrameSize int64    `json:"maxFrameSize"` // bytes
+
+	TypingThrottle Duration `json:"typingThrottle"` // minimum interval between relayed typing indicators to one receiver
+	TypingTimeout  Duration `json:"typingTimeout"`  // typing without a refresh for this long sends isTyping:false; must be longer than typingThrottle
+}
+
+// MessagingConfig configures private messaging
+type MessagingConfig struct {
+	EditWindow Duration `json:"editWindow"` // zero removes the limit
+}
+
+// RateLimitConfig configures the per-route request limits. Authenticated
+// requests are limited per user, anonymous ones per client IP.
+type RateLimitConfig struct {
+	Enabled           bool `json:"enabled"`
+	TrustForwardedFor bool `json:"trustForwardedFor"` // take the client IP from X-Forwarded-For; only behind a proxy that sets it
+
+	Login     RateLimitPolicy `json:"login"`
+	Register  RateLimitPolicy `json:"register"`
+	Post      RateLimitPolicy `json:"post"`
+	Comment   RateLimitPolicy `json:"comment"`
+	Like      RateLimitPolicy `json:"like"`
+	Message   RateLimitPolicy `json:"message"`   // HTTP sends and private_message frames
+	WebSocket RateLimitPolicy `json:"websocket"` // every inbound frame
+	Email     RateLimitPolicy `json:"email"`     // requests that send an email
+	Report    RateLimitPolicy `json:"report"`    // content and user reports
+}
+
+// RateLimitPolicy allows Requests per Per on average, in bursts of up to Burst
+type RateLimitPolicy struct {
+	Requests int      `json:"requests"`
+	Per      Duration `json:"per"`
+	Burst    int      `json:"burst"` // defaults to requests
+}
+
+// Policies returns the policies by name
+func (c RateLimitConfig) Policies() map[string]RateLimitPolicy {
+	return map[string]RateLimitPolicy{
+		"login":     c.Login,
+		"register":  c.Register,
+		"post":      c.Post,
+		"comment":   c.Comment,
+		"like":      c.Like,
+		"message":   c.Message,
+		"websocket": c.WebSocket,
+		"email":     c.Email,
+		"report":    c.Report,
+	}
+}
+
+// MailConfig configures delivery of account emails such as password resets
+type MailConfig struct {
+	Driver string     `json:"driver"` // "log" writes emails to the server log, "smtp" sends them
+	From   string     `json:"from"`
+	File   string     `json:"file"` // log driver: also append emails to this file
+	SMTP   SMTPConfig `json:"smtp"`
+}
+
+// SMTPConfig is the server the smtp mail driver sends through. STARTTLS is
+// used when the server offers it.
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	Username string `json:"username"` // leave both empty for servers without authentication
+	Password string `json:"password"`
+}
+
+// Duration is a time.Duration written as a Go duration string such as "15m"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"15m\"")
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Default returns the settings used when nothing is configured
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            "8080",
+			StaticDir:       "frontend/static",
+			ShutdownTimeout: Duration{15 * time.Second},
+		},
+		Database: DatabaseConfig{
+			Path: "forum.db",
+		},
+		Auth: AuthConfig{
+			SessionDuration:    Duration{7 * 24 * time.Hour},
+			SessionIdleTimeout: Duration{24 * time.Hour},
+			CleanupInterval:    Duration{10 * time.Minute},
+			LockoutThreshold:   5,
+			LockoutDuration:    Duration{time.Minute},
+			MaxLockoutDuration: Duration{time.Hour},
+
+			PasswordResetTTL:     Duration{time.Hour},
+			EmailVerificationTTL: Duration{48 * time.Hour},
+
+			TwoFactorIssuer: "Forum",
+		},
+		OAuth: OAuthConfig{
+			Google: OAuthProviderConfig{
+				AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+				TokenURL:    "https://oauth2.googleapis.com/token",
+				UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+			},
+			GitHub: OAuthProviderConfig{
+				AuthURL:     "https://github.com/login/oauth/authorize",
+				TokenURL:    "https://github.com/login/oauth/access_token",
+				UserInfoURL: "https://api.github.com/user",
+				EmailsURL:   "https://api.github.com/user/emails",
+			},
+		},
+		Uploads: UploadsConfig{
+			Dir:           "frontend/static/uploads",
+			MaxAvatarSize: 5 << 20,
+		},
+		WebSocket: WebSocketConfig{
+			WriteWait:    Duration{10 * time.Second},
+			PongWait:     Duration{60 * time.Second},
+			PingPeriod:   Duration{54 * time.Second},
+			MaxFrameSize: 4*2000 + 1024, // a 2000 character message of multi-byte characters plus the JSON envelope
+
+			TypingThrottle: Duration{2 * time.Second},
+			TypingTimeout:  Duration{6 * time.Second},
+		},
+		Messaging: MessagingConfig{
+			EditWindow: Duration{15 * time.Minute},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:   true,
+			Login:     RateLimitPolicy{Requests: 10, Per: Duration{time.Minute}, Burst: 5},
+			Register:  RateLimitPolicy{Requests: 5, Per: Duration{time.Hour}, Burst: 3},
+			Post:      RateLimitPolicy{Requests: 5, Per: Duration{time.Minute}, Burst: 5},
+			Comment:   RateLimitPolicy{Requests: 20, Per: Duration{time.Minute}, Burst: 10},
+			Like:      RateLimitPolicy{Requests: 60, Per: Duration{time.Minute}, Burst: 30},
+			Message:   RateLimitPolicy{Requests: 60, Per: Duration{time.Minute}, Burst: 20},
+			WebSocket: RateLimitPolicy{Requests: 300, Per: Duration{time.Minute}, Burst: 60},
+			Email:     RateLimitPolicy{Requests: 5, Per: Duration{time.Hour}, Burst: 3},
+			Report:    RateLimitPolicy{Requests: 10, Per: Duration{time.Hour}, Burst: 5},
+		},
+		Mail: MailConfig{
+			Driver: "log",
+			From:   "Forum <no-reply@localhost>",
+			SMTP:   SMTPConfig{Port: "587"},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the JSON file at path (if
+// path is not empty) and environment variable overrides, in that order, and
+// validates the result
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	cfg.fillDerived()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings from environment variables that are set
+func (c *Config) applyEnv() error {
+	texts := []struct {
+		key    string
+		target *string
+	}{
+		{"PORT", &c.Server.Port},
+		{"PUBLIC_URL", &c.Server.PublicURL},
+		{"STATIC_DIR", &c.Server.StaticDir},
+		{"DATABASE_PATH", &c.Database.Path},
+		{"UPLOADS_DIR", &c.Uploads.Dir},
+		{"TWO_FACTOR_ISSUER", &c.Auth.TwoFactorIssuer},
+		{"OAUTH_REDIRECT_BASE_URL", &c.OAuth.RedirectBaseURL},
+		{"GOOGLE_CLIENT_ID", &c.OAuth.Google.ClientID},
+		{"GOOGLE_CLIENT_SECRET", &c.OAuth.Google.ClientSecret},
+		{"GOOGLE_REDIRECT_URL", &c.OAuth.Google.RedirectURL},
+		{"GOOGLE_AUTH_URL", &c.OAuth.Google.AuthURL},
+		{"GOOGLE_TOKEN_URL", &c.OAuth.Google.TokenURL},
+		{"GOOGLE_USERINFO_URL", &c.OAuth.Google.UserInfoURL},
+		{"GITHUB_CLIENT_ID", &c.OAuth.GitHub.ClientID},
+		{"GITHUB_CLIENT_SECRET", &c.OAuth.GitHub.ClientSecret},
+		{"GITHUB_REDIRECT_URL", &c.OAuth.GitHub.RedirectURL},
+		{"GITHUB_AUTH_URL", &c.OAuth.GitHub.AuthURL},
+		{"GITHUB_TOKEN_URL", &c.OAuth.GitHub.TokenURL},
+		{"GITHUB_USERINFO_URL", &c.OAuth.GitHub.UserInfoURL},
+		{"GITHUB_EMAILS_URL", &c.OAuth.GitHub.EmailsURL},
+		{"MAIL_DRIVER", &c.Mail.Driver},
+		{"MAIL_FROM", &c.Mail.From},
+		{"MAIL_FILE", &c.Mail.File},
+		{"SMTP_HOST", &c.Mail.SMTP.Host},
+		{"SMTP_PORT", &c.Mail.SMTP.Port},
+		{"SMTP_USERNAME", &c.Mail.SMTP.Username},
+		{"SMTP_PASSWORD", &c.Mail.SMTP.Password},
+	}
+	for _, t := range texts {
+		if value := os.Getenv(t.key); value != "" {
+			*t.target = value
+		}
+	}
+
+	durations := []struct {
+		key    string
+		target *Duration
+	}{
+		{"SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout},
+		{"SESSION_DURATION", &c.Auth.SessionDuration},
+		{"SESSION_IDLE_TIMEOUT", &c.Auth.SessionIdleTimeout},
+		{"SESSION_CLEANUP_INTERVAL", &c.Auth.CleanupInterval},
+		{"LOGIN_LOCKOUT_DURATION", &c.Auth.LockoutDuration},
+		{"LOGIN_MAX_LOCKOUT_DURATION", &c.Auth.MaxLockoutDuration},
+		{"PASSWORD_RESET_TTL", &c.Auth.PasswordResetTTL},
+		{"EMAIL_VERIFICATION_TTL", &c.Auth.EmailVerificationTTL},
+		{"WS_WRITE_WAIT", &c.WebSocket.WriteWait},
+		{"WS_PONG_WAIT", &c.WebSocket.PongWait},
+		{"WS_PING_PERIOD", &c.WebSocket.PingPeriod},
+		{"WS_TYPING_THROTTLE", &c.WebSocket.TypingThrottle},
+		{"WS_TYPING_TIMEOUT", &c.WebSocket.TypingTimeout},
+		{"MESSAGE_EDIT_WINDOW", &c.Messaging.EditWindow},
+	}
+	for _, d := range durations {
+		if value := os.Getenv(d.key); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", d.key, value, err)
+			}
+			d.target.Duration = parsed
+		}
+	}
+
+	sizes := []struct {
+		key    string
+		target *int64
+	}{
+		{"MAX_AVATAR_SIZE", &c.Uploads.MaxAvatarSize},
+		{"WS_MAX_FRAME_SIZE", &c.WebSocket.MaxFrameSize},
+	}
+	for _, s := range sizes {
+		if value := os.Getenv(s.key); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: must be a number of bytes", s.key, value)
+			}
+			*s.target = parsed
+		}
+	}
+
+	if value := os.Getenv("ALLOWED_ORIGINS"); value != "" {
+		c.Auth.AllowedOrigins = nil
+		for _, origin := range strings.Split(value, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.Auth.AllowedOrigins = append(c.Auth.AllowedOrigins, origin)
+			}
+		}
+	}
+
+	if value := os.Getenv("LOGIN_LOCKOUT_THRESHOLD"); value != "" {
+		threshold, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid LOGIN_LOCKOUT_THRESHOLD %q: must be a number", value)
+		}
+		c.Auth.LockoutThreshold = threshold
+	}
+
+	flags := []struct {
+		key    string
+		target *bool
+	}{
+		{"COOKIE_SECURE", &c.Auth.CookieSecure},
+		{"REQUIRE_VERIFIED_EMAIL", &c.Auth.RequireVerifiedEmail},
+		{"RATE_LIMIT_ENABLED", &c.RateLimit.Enabled},
+		{"TRUST_FORWARDED_FOR", &c.RateLimit.TrustForwardedFor},
+	}
+	for _, f := range flags {
+		if value := os.Getenv(f.key); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: must be true or false", f.key, value)
+			}
+			*f.target = parsed
+		}
+	}
+
+	return nil
+}
+
+// fillDerived sets the public and OAuth callback URLs that default to values
+// built from other settings
+func (c *Config) fillDerived() {
+	c.Server.PublicURL = strings.TrimSuffix(c.Server.PublicURL, "/")
+	if c.Server.PublicURL == "" {
+		c.Server.PublicURL = "http://localhost:" + c.Server.Port
+	}
+	c.OAuth.RedirectBaseURL = strings.TrimSuffix(c.OAuth.RedirectBaseURL, "/")
+	if c.OAuth.RedirectBaseURL == "" {
+		c.OAuth.RedirectBaseURL = c.Server.PublicURL
+	}
+	if c.OAuth.Google.RedirectURL == "" {
+		c.OAuth.Google.RedirectURL = c.OAuth.RedirectBaseURL + "/auth/google/callback"
+	}
+	if c.OAuth.GitHub.RedirectURL == "" {
+		c.OAuth.GitHub.RedirectURL = c.OAuth.RedirectBaseURL + "/auth/github/callback"
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port < 65536, "server.port must be a TCP port number, got %q", c.Server.Port)
+	check(isHTTPURL(c.Server.PublicURL), "server.publicUrl must be an http(s) URL")
+	check(c.Server.StaticDir != "", "server.staticDir is required")
+	check(c.Server.ShutdownTimeout.Duration > 0, "server.shutdownTimeout must be positive")
+	check(c.Database.Path != "", "database.path is required")
+
+	check(c.Auth.SessionDuration.Duration >= time.Minute, "auth.sessionDuration must be at least 1m")
+	check(c.Auth.SessionIdleTimeout.Duration >= time.Minute, "auth.sessionIdleTimeout must be at least 1m")
+	check(c.Auth.CleanupInterval.Duration >= time.Second, "auth.cleanupInterval must be at least 1s")
+	for _, origin := range c.Auth.AllowedOrigins {
+		check(isOrigin(origin), "auth.allowedOrigins entries must be a scheme and host such as https://forum.example.com, got %q", origin)
+	}
+	check(c.Auth.LockoutThreshold >= 0, "auth.lockoutThreshold must not be negative (0 disables lockout)")
+	check(c.Auth.LockoutDuration.Duration > 0, "auth.lockoutDuration must be positive")
+	check(c.Auth.MaxLockoutDuration.Duration >= c.Auth.LockoutDuration.Duration,
+		"auth.maxLockoutDuration must not be shorter than auth.lockoutDuration")
+	check(c.Auth.PasswordResetTTL.Duration >= time.Minute, "auth.passwordResetTtl must be at least 1m")
+	check(c.Auth.EmailVerificationTTL.Duration >= time.Minute, "auth.emailVerificationTtl must be at least 1m")
+	check(c.Auth.TwoFactorIssuer != "" && !strings.Contains(c.Auth.TwoFactorIssuer, ":"),
+		"auth.twoFactorIssuer must be set and must not contain a colon")
+
+	check(isHTTPURL(c.OAuth.RedirectBaseURL), "oauth.redirectBaseUrl must be an http(s) URL")
+	c.OAuth.Google.validate("google", check)
+	c.OAuth.GitHub.validate("github", check)
+	check(isHTTPURL(c.OAuth.GitHub.EmailsURL), "oauth.github.emailsUrl must be an http(s) URL")
+
+	check(c.Uploads.Dir != "", "uploads.dir is required")
+	check(c.Uploads.MaxAvatarSize > 0, "uploads.maxAvatarSize must be positive")
+
+	check(c.WebSocket.WriteWait.Duration > 0, "websocket.writeWait must be positive")
+	check(c.WebSocket.PongWait.Duration > 0, "websocket.pongWait must be positive")
+	check(c.WebSocket.PingPeriod.Duration > 0 && c.WebSocket.PingPeriod.Duration < c.WebSocket.PongWait.Duration,
+		"websocket.pingPeriod must be positive and shorter than websocket.pongWait")
+	check(c.WebSocket.MaxFrameSize >= 1024, "websocket.maxFrameSize must be at least 1024 bytes")
+	check(c.WebSocket.TypingThrottle.Duration > 0 && c.WebSocket.TypingThrottle.Duration < c.WebSocket.TypingTimeout.Duration,
+		"websocket.typingThrottle must be positive and shorter than websocket.typingTimeout")
+
+	check(c.Messaging.EditWindow.Duration >= 0, "messaging.editWindow must not be negative")
+
+	for _, name := range []string{"login", "register", "post", "comment", "like", "message", "websocket", "email", "report"} {
+		policy := c.RateLimit.Policies()[name]
+		check(policy.Requests > 0 && policy.Per.Duration > 0 && policy.Burst >= 0,
+			"rateLimit.%s needs positive requests and per, and a burst that is not negative", name)
+	}
+
+	_, err = mail.ParseAddress(c.Mail.From)
+	check(err == nil, "mail.from must be an email address such as \"Forum <no-reply@example.com>\"")
+	switch c.Mail.Driver {
+	case "log":
+	case "smtp":
+		check(c.Mail.SMTP.Host != "", "mail.smtp.host is required by the smtp driver")
+		smtpPort, err := strconv.Atoi(c.Mail.SMTP.Port)
+		check(err == nil && smtpPort > 0 && smtpPort < 65536, "mail.smtp.port must be a TCP port number, got %q", c.Mail.SMTP.Port)
+		check((c.Mail.SMTP.Username == "") == (c.Mail.SMTP.Password == ""),
+			"mail.smtp needs both username and password, or neither")
+	default:
+		check(false, "mail.driver must be \"log\" or \"smtp\", got %q", c.Mail.Driver)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// validate checks a provider's credentials and endpoints
+func (p OAuthProviderConfig) validate(name string, check func(bool, string, ...interface{})) {
+	check((p.ClientID == "") == (p.ClientSecret == ""),
+		"oauth.%s needs both clientId and clientSecret, or neither", name)
+	check(isHTTPURL(p.RedirectURL), "oauth.%s.redirectUrl must be an http(s) URL", name)
+	check(isHTTPURL(p.AuthURL), "oauth.%s.authUrl must be an http(s) URL", name)
+	check(isHTTPURL(p.TokenURL), "oauth.%s.tokenUrl must be an http(s) URL", name)
+	check(isHTTPURL(p.UserInfoURL), "oauth.%s.userInfoUrl must be an http(s) URL", name)
+}
+
+// isHTTPURL reports whether value is an absolute http or https URL
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// isOrigin reports whether value is an http(s) origin: a scheme, a host and
+// an optional port with nothing after them
+func isOrigin(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && isHTTPURL(value) && u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
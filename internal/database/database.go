@@ -4,16 +4,51 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
+
+	"forum/internal/config"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var DB *sql.DB
 
-// Initialize sets up the database connection and creates tables
-func Initialize() error {
+// SearchEnabled reports whether the FTS5 search indexes are available.
+// FTS5 is only compiled into go-sqlite3 when building with -tags sqlite_fts5.
+var SearchEnabled bool
+
+// Initialize sets up the database connection and brings the schema up to date
+func Initialize(cfg config.DatabaseConfig) error {
+	if err := Open(cfg.Path); err != nil {
+		return err
+	}
+
+	applied, err := MigrateUp()
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if applied > 0 {
+		log.Printf("✅ Applied %d database migrations (schema version %d)", applied, LatestVersion())
+	}
+
+	// Full-text search indexes are optional so that builds without FTS5 still run
+	if err := createSearchTables(); err != nil {
+		log.Printf("⚠️ Full-text search disabled: %v", err)
+	}
+
+	log.Println("✅ Database initialized successfully")
+	return nil
+}
+
+// Open connects to the database at path without changing its schema
+func Open(path string) error {
 	var err error
-	DB, err = sql.Open("sqlite3", "forum.db")
+	// Concurrent writers, such as the websocket hub recording presence while
+	// a handler stores a message, wait for the lock instead of failing.
+	// Transactions take the write lock when they begin: a transaction that
+	// reads first and upgrades later gets SQLITE_BUSY at once rather than
+	// waiting, since waiting could deadlock.
+	DB, err = sql.Open("sqlite3", path+"?_busy_timeout=5000&_txlock=immediate")
 	if err != nil {
 		return fmt.Errorf("failed to open database: %v", err)
 	}
@@ -28,14 +63,6 @@ func Initialize() error {
 		return fmt.Errorf("failed to enable foreign keys: %v", err)
 	}
 
-	// Create tables
-	if err = createTables(); err != nil {
-		return fmt.Errorf("failed to create tables: %v", err)
-	}
-
-	// Messaging tables removed - will be recreated
-
-	log.Println("✅ Database initialized successfully")
 	return nil
 }
 
@@ -47,249 +74,118 @@ func Close() error {
 	return nil
 }
 
-// createTables creates all necessary database tables
-func createTables() error {
-	// Users table with comprehensive profile information
-	usersTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id TEXT PRIMARY KEY,
-		email TEXT UNIQUE NOT NULL,
-		nickname TEXT UNIQUE NOT NULL,
-		password TEXT NOT NULL,
-		first_name TEXT NOT NULL,
-		last_name TEXT NOT NULL,
-		age INTEGER NOT NULL CHECK (age >= 13),
-		gender TEXT NOT NULL CHECK (gender IN ('male', 'female')),
-		google_id TEXT UNIQUE,
-		github_id TEXT UNIQUE,
-		avatar_url TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// OAuth tokens table for Google authentication
-	googleAuthTable := `
-	CREATE TABLE IF NOT EXISTS google_auth (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id TEXT NOT NULL UNIQUE,
-		access_token TEXT NOT NULL,
-		refresh_token TEXT,
-		expires_at TIMESTAMP NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);`
-
-	// OAuth tokens table for GitHub authentication
-	githubAuthTable := `
-	CREATE TABLE IF NOT EXISTS github_auth (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id TEXT NOT NULL UNIQUE,
-		access_token TEXT NOT NULL,
-		refresh_token TEXT,
-		expires_at TIMESTAMP NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);`
-
-	// Sessions table for user authentication
-	sessionsTable := `
-	CREATE TABLE IF NOT EXISTS sessions (
-		id TEXT PRIMARY KEY,
-		user_id TEXT NOT NULL,
-		expires_at TIMESTAMP NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);`
-
-	// Posts table
-	postsTable := `
-	CREATE TABLE IF NOT EXISTS posts (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id TEXT NOT NULL,
-		title TEXT NOT NULL,
-		content TEXT NOT NULL,
-		image_path TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);`
-
-	// Post categories table
-	postCategoriesTable := `
-	CREATE TABLE IF NOT EXISTS post_categories (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		post_id INTEGER NOT NULL,
-		category TEXT NOT NULL,
-		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
-	);`
-
-	// Comments table with support for nested comments
-	commentsTable := `
-	CREATE TABLE IF NOT EXISTS comments (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		post_id INTEGER NOT NULL,
-		user_id TEXT NOT NULL,
-		parent_id INTEGER,
-		content TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-		FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
-	);`
-
-	// Likes table for posts and comments
-	likesTable := `
-	CREATE TABLE IF NOT EXISTS likes (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id TEXT NOT NULL,
-		post_id INTEGER,
-		comment_id INTEGER,
-		is_like BOOLEAN NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
-		FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
-		UNIQUE(user_id, post_id),
-		UNIQUE(user_id, comment_id)
-	);`
-
-	// Online users table for tracking active users (supports multiple sessions per user)
-	onlineUsersTable := `
-	CREATE TABLE IF NOT EXISTS online_users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id TEXT NOT NULL,
-		session_id TEXT NOT NULL UNIQUE,
-		last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);`
-
-	// Messages table for private messaging
-	messagesTable := `
-	CREATE TABLE IF NOT EXISTS messages (
-		id TEXT PRIMARY KEY,
-		sender_id TEXT NOT NULL,
-		receiver_id TEXT NOT NULL,
-		content TEXT NOT NULL,
-		is_read BOOLEAN DEFAULT FALSE,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
-		FOREIGN KEY (receiver_id) REFERENCES users(id) ON DELETE CASCADE
-	);`
-
-	// Conversations table for tracking conversation metadata
-	conversationsTable := `
-	CREATE TABLE IF NOT EXISTS conversations (
-		id TEXT PRIMARY KEY,
-		user1_id TEXT NOT NULL,
-		user2_id TEXT NOT NULL,
-		last_message_id TEXT,
-		last_message_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user1_id) REFERENCES users(id) ON DELETE CASCADE,
-		FOREIGN KEY (user2_id) REFERENCES users(id) ON DELETE CASCADE,
-		FOREIGN KEY (last_message_id) REFERENCES messages(id) ON DELETE SET NULL,
-		UNIQUE(user1_id, user2_id)
-	);`
-
-	tables := []string{
-		usersTable,
-		googleAuthTable,
-		githubAuthTable,
-		sessionsTable,
-		postsTable,
-		postCategoriesTable,
-		commentsTable,
-		likesTable,
-		onlineUsersTable,
-		messagesTable,
-		conversationsTable,
+// createSearchTables creates the FTS5 indexes over posts, comments and user
+// nicknames, plus the triggers that keep them in sync with their source tables
+func createSearchTables() error {
+	// Probe for the fts5 module first: IF NOT EXISTS statements succeed on a
+	// database indexed by an FTS5-enabled build even when the module is missing
+	if _, err := DB.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS temp.fts5_probe USING fts5(x)"); err != nil {
+		dropSearchTriggers()
+		return fmt.Errorf("fts5 module unavailable (build with -tags sqlite_fts5): %v", err)
 	}
-
-	for _, table := range tables {
-		if _, err := DB.Exec(table); err != nil {
-			return fmt.Errorf("failed to create table: %v", err)
+	DB.Exec("DROP TABLE IF EXISTS temp.fts5_probe")
+
+	// users_fts used to take its rowids from the implicit rowid of users,
+	// which VACUUM may renumber since users has a TEXT primary key. Drop the
+	// old index so it is rebuilt keyed by user ID.
+	var usersIndex string
+	DB.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'users_fts'").Scan(&usersIndex)
+	if strings.Contains(usersIndex, "content='users'") {
+		for _, event := range []string{"insert", "delete", "update"} {
+			DB.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS users_fts_%s", event))
 		}
-	}
-
-	// Create indexes for better performance
-	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts(user_id);",
-		"CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at DESC);",
-		"CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);",
-		"CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);",
-		"CREATE INDEX IF NOT EXISTS idx_likes_post_id ON likes(post_id);",
-		"CREATE INDEX IF NOT EXISTS idx_likes_comment_id ON likes(comment_id);",
-		"CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);",
-		"CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);",
-		"CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages(sender_id);",
-		"CREATE INDEX IF NOT EXISTS idx_messages_receiver_id ON messages(receiver_id);",
-		"CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at DESC);",
-		"CREATE INDEX IF NOT EXISTS idx_messages_is_read ON messages(is_read);",
-		"CREATE INDEX IF NOT EXISTS idx_conversations_user1_id ON conversations(user1_id);",
-		"CREATE INDEX IF NOT EXISTS idx_conversations_user2_id ON conversations(user2_id);",
-		"CREATE INDEX IF NOT EXISTS idx_conversations_last_message_time ON conversations(last_message_time DESC);",
-	}
-
-	for _, index := range indexes {
-		if _, err := DB.Exec(index); err != nil {
-			return fmt.Errorf("failed to create index: %v", err)
+		if _, err := DB.Exec("DROP TABLE users_fts"); err != nil {
+			return fmt.Errorf("failed to drop old user search index: %v", err)
 		}
 	}
 
-	// Migrate online_users table to new schema if needed
-	if err := migrateOnlineUsersTable(); err != nil {
-		return fmt.Errorf("failed to migrate online_users table: %v", err)
+	// Missing triggers mean the indexes are new or were not maintained by the
+	// previous run, so they have to be rebuilt from the source tables
+	var triggerCount int
+	if err := DB.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE '%_fts_%'
+	`).Scan(&triggerCount); err != nil {
+		return fmt.Errorf("failed to check search tables: %v", err)
 	}
 
-	return nil
-}
-
-// migrateOnlineUsersTable migrates the online_users table to support multiple sessions per user
-func migrateOnlineUsersTable() error {
-	// Check if session_id column exists
-	var columnExists bool
-	err := DB.QueryRow(`
-		SELECT COUNT(*) > 0
-		FROM pragma_table_info('online_users')
-		WHERE name = 'session_id'
-	`).Scan(&columnExists)
-
-	if err != nil {
-		return fmt.Errorf("failed to check table schema: %v", err)
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+			title, content, content='posts', content_rowid='id'
+		);`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(
+			content, content='comments', content_rowid='id'
+		);`,
+		// Users have no integer key to share with an external content
+		// index, so this one stores the nicknames itself
+		`CREATE VIRTUAL TABLE IF NOT EXISTS users_fts USING fts5(
+			nickname, user_id UNINDEXED
+		);`,
+
+		`CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+			INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+			INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+			INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+			INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+		END;`,
+
+		`CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments BEGIN
+			INSERT INTO comments_fts(rowid, content) VALUES (new.id, new.content);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments BEGIN
+			INSERT INTO comments_fts(comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+			INSERT INTO comments_fts(comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+			INSERT INTO comments_fts(rowid, content) VALUES (new.id, new.content);
+		END;`,
+
+		`CREATE TRIGGER IF NOT EXISTS users_fts_insert AFTER INSERT ON users BEGIN
+			INSERT INTO users_fts(nickname, user_id) VALUES (new.nickname, new.id);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS users_fts_delete AFTER DELETE ON users BEGIN
+			DELETE FROM users_fts WHERE user_id = old.id;
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS users_fts_update AFTER UPDATE OF nickname ON users BEGIN
+			UPDATE users_fts SET nickname = new.nickname WHERE user_id = old.id;
+		END;`,
 	}
 
-	// If session_id column doesn't exist, we need to recreate the table
-	if !columnExists {
-		log.Println("🔄 Migrating online_users table to support multiple sessions...")
-
-		// Drop the old table
-		_, err := DB.Exec("DROP TABLE IF EXISTS online_users")
-		if err != nil {
-			return fmt.Errorf("failed to drop old online_users table: %v", err)
+	for _, statement := range statements {
+		if _, err := DB.Exec(statement); err != nil {
+			dropSearchTriggers()
+			return fmt.Errorf("failed to create search index: %v", err)
 		}
+	}
 
-		// Create the new table with the updated schema
-		newOnlineUsersTable := `
-		CREATE TABLE IF NOT EXISTS online_users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			session_id TEXT NOT NULL UNIQUE,
-			last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		);`
-
-		_, err = DB.Exec(newOnlineUsersTable)
-		if err != nil {
-			return fmt.Errorf("failed to create new online_users table: %v", err)
+	if triggerCount < 9 {
+		log.Println("🔄 Building full-text search indexes...")
+		for _, table := range []string{"posts_fts", "comments_fts"} {
+			if _, err := DB.Exec(fmt.Sprintf("INSERT INTO %s(%s) VALUES ('rebuild')", table, table)); err != nil {
+				return fmt.Errorf("failed to rebuild %s: %v", table, err)
+			}
+		}
+		if _, err := DB.Exec("DELETE FROM users_fts"); err != nil {
+			return fmt.Errorf("failed to rebuild users_fts: %v", err)
+		}
+		if _, err := DB.Exec("INSERT INTO users_fts(nickname, user_id) SELECT nickname, id FROM users"); err != nil {
+			return fmt.Errorf("failed to rebuild users_fts: %v", err)
 		}
-
-		log.Println("✅ Successfully migrated online_users table")
 	}
 
+	SearchEnabled = true
 	return nil
 }
 
-// Old messaging tables function removed - will be recreated
+// dropSearchTriggers removes the FTS sync triggers. Without the fts5 module
+// they would make every write to posts, comments and users fail.
+func dropSearchTriggers() {
+	for _, table := range []string{"posts", "comments", "users"} {
+		for _, event := range []string{"insert", "delete", "update"} {
+			DB.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s_fts_%s", table, event))
+		}
+	}
+}
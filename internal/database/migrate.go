@@ -0,0 +1,340 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Migration is one numbered schema change. Up applies it and Down reverts
+// it; each runs in its own transaction together with the schema_migrations
+// bookkeeping, so a failed migration leaves the schema untouched.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+	Down        func(tx *sql.Tx) error
+}
+
+// MigrationStatus describes a known migration and when it was applied
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   *time.Time // nil if the migration is pending
+}
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this build does not know about, for example after a downgrade
+var ErrSchemaTooNew = errors.New("database schema is newer than this build supports")
+
+// LatestVersion returns the version of the newest known migration
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the version of the newest applied migration, or 0
+// for an empty database
+func SchemaVersion() (int, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := DB.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return version, nil
+}
+
+// CheckSchemaVersion refuses to continue if the database schema is newer
+// than the migrations compiled into this build
+func CheckSchemaVersion() error {
+	version, err := SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > LatestVersion() {
+		return fmt.Errorf("%w: database is at version %d, this build supports up to %d",
+			ErrSchemaTooNew, version, LatestVersion())
+	}
+	return nil
+}
+
+// MigrateUp applies all pending migrations in order and returns how many were applied
+func MigrateUp() (int, error) {
+	if err := adoptExistingSchema(); err != nil {
+		return 0, err
+	}
+	if err := CheckSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	current, err := SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+
+		log.Printf("🔄 Applying migration %d: %s", migration.Version, migration.Description)
+		if err := runMigration(migration, true); err != nil {
+			return applied, fmt.Errorf("migration %d failed: %v", migration.Version, err)
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the newest steps applied migrations, newest first, and
+// returns how many were reverted
+func MigrateDown(steps int) (int, error) {
+	if err := CheckSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	current, err := SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+		migration := migrations[i]
+		if migration.Version > current {
+			continue
+		}
+
+		log.Printf("🔄 Reverting migration %d: %s", migration.Version, migration.Description)
+		if err := runMigration(migration, false); err != nil {
+			return reverted, fmt.Errorf("reverting migration %d failed: %v", migration.Version, err)
+		}
+		reverted++
+	}
+
+	return reverted, nil
+}
+
+// MigrationStatuses lists every known migration with the time it was applied
+func MigrationStatuses() ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema migrations: %v", err)
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan schema migration: %v", err)
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Description: migration.Description}
+		if at, ok := appliedAt[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// runMigration applies or reverts one migration in a transaction and records it
+func runMigration(migration Migration, up bool) error {
+	// Table rebuilds must not cascade or null out references while the old
+	// table is dropped. The pragma is per connection and ignored inside a
+	// transaction, so use a dedicated connection.
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return fmt.Errorf("failed to disable foreign keys: %v", err)
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if up {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Description, time.Now(),
+		)
+	} else {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// ensureMigrationsTable creates the table that records applied migrations
+func ensureMigrationsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	return nil
+}
+
+// adoptExistingSchema records the migrations already reflected in a database
+// created before schema versioning, so they are not applied a second time.
+// The version is inferred from the newest schema change present.
+func adoptExistingSchema() error {
+	version, err := SchemaVersion()
+	if err != nil || version > 0 {
+		return err
+	}
+
+	if exists, err := tableExists("users"); err != nil || !exists {
+		return err
+	}
+
+	// Each check identifies the schema change made by the migration of the
+	// same version; the first one found is the newest applied
+	checks := []struct {
+		version int
+		exists  func() (bool, error)
+	}{
+		{6, func() (bool, error) { return columnExists("messages", "read_at") }},
+		{5, func() (bool, error) { return columnExists("messages", "conversation_id") }},
+		{4, func() (bool, error) { return columnExists("messages", "edited_at") }},
+		{3, func() (bool, error) { return tableExists("notifications") }},
+		{2, func() (bool, error) { return tableExists("oauth_states") }},
+	}
+
+	version = 1
+	for _, check := range checks {
+		exists, err := check.exists()
+		if err != nil {
+			return err
+		}
+		if exists {
+			version = check.version
+			break
+		}
+	}
+
+	// Very old databases tracked one online_users row per user. The table
+	// only holds live presence, so it can be recreated without losing data.
+	hasSessions, err := columnExists("online_users", "session_id")
+	if err != nil {
+		return err
+	}
+	if !hasSessions {
+		if _, err := DB.Exec("DROP TABLE IF EXISTS online_users"); err != nil {
+			return fmt.Errorf("failed to drop old online_users table: %v", err)
+		}
+		if err := runMigrationStatements(migrations[0]); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, migration := range migrations {
+		if migration.Version > version {
+			break
+		}
+		_, err := DB.Exec(
+			"INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Description, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record existing schema: %v", err)
+		}
+	}
+
+	log.Printf("✅ Recorded existing database schema as version %d", version)
+	return nil
+}
+
+// runMigrationStatements runs a migration's Up without recording it. Only
+// valid for migrations whose statements are all idempotent.
+func runMigrationStatements(migration Migration) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := migration.Up(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execAll runs schema statements in order, stopping at the first error
+func execAll(tx *sql.Tx, statements ...string) error {
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableExists reports whether a table exists in the database
+func tableExists(name string) (bool, error) {
+	var exists bool
+	err := DB.QueryRow(
+		"SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?", name,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check table %s: %v", name, err)
+	}
+	return exists, nil
+}
+
+// columnExists reports whether a table has a column
+func columnExists(table, column string) (bool, error) {
+	var exists bool
+	err := DB.QueryRow(
+		"SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?", table, column,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check table schema: %v", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,704 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// migrations is the ordered list of schema changes. Append new migrations
+// with the next version number; never edit one that has been released.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create users, posts, comments, likes, sessions and messaging tables",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS users (
+					id TEXT PRIMARY KEY,
+					email TEXT UNIQUE NOT NULL,
+					nickname TEXT UNIQUE NOT NULL,
+					password TEXT NOT NULL,
+					first_name TEXT NOT NULL,
+					last_name TEXT NOT NULL,
+					age INTEGER NOT NULL CHECK (age >= 13),
+					gender TEXT NOT NULL CHECK (gender IN ('male', 'female')),
+					google_id TEXT UNIQUE,
+					github_id TEXT UNIQUE,
+					avatar_url TEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE TABLE IF NOT EXISTS google_auth (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id TEXT NOT NULL UNIQUE,
+					access_token TEXT NOT NULL,
+					refresh_token TEXT,
+					expires_at TIMESTAMP NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS github_auth (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id TEXT NOT NULL UNIQUE,
+					access_token TEXT NOT NULL,
+					refresh_token TEXT,
+					expires_at TIMESTAMP NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS sessions (
+					id TEXT PRIMARY KEY,
+					user_id TEXT NOT NULL,
+					expires_at TIMESTAMP NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS posts (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id TEXT NOT NULL,
+					title TEXT NOT NULL,
+					content TEXT NOT NULL,
+					image_path TEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS post_categories (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					post_id INTEGER NOT NULL,
+					category TEXT NOT NULL,
+					FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS comments (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					post_id INTEGER NOT NULL,
+					user_id TEXT NOT NULL,
+					parent_id INTEGER,
+					content TEXT NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS likes (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id TEXT NOT NULL,
+					post_id INTEGER,
+					comment_id INTEGER,
+					is_like BOOLEAN NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+					FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+					UNIQUE(user_id, post_id),
+					UNIQUE(user_id, comment_id)
+				)`,
+				// Tracks active users (supports multiple sessions per user)
+				`CREATE TABLE IF NOT EXISTS online_users (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id TEXT NOT NULL,
+					session_id TEXT NOT NULL UNIQUE,
+					last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS messages (
+					id TEXT PRIMARY KEY,
+					sender_id TEXT NOT NULL,
+					receiver_id TEXT NOT NULL,
+					content TEXT NOT NULL,
+					is_read BOOLEAN DEFAULT FALSE,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (receiver_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS conversations (
+					id TEXT PRIMARY KEY,
+					user1_id TEXT NOT NULL,
+					user2_id TEXT NOT NULL,
+					last_message_id TEXT,
+					last_message_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user1_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (user2_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (last_message_id) REFERENCES messages(id) ON DELETE SET NULL,
+					UNIQUE(user1_id, user2_id)
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts(user_id)",
+				"CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at DESC)",
+				"CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id)",
+				"CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id)",
+				"CREATE INDEX IF NOT EXISTS idx_likes_post_id ON likes(post_id)",
+				"CREATE INDEX IF NOT EXISTS idx_likes_comment_id ON likes(comment_id)",
+				"CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)",
+				"CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at)",
+				messagesIndexes,
+				conversationsIndexes,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			// The search indexes are created at startup outside of the
+			// migrations, but depend on these tables
+			return execAll(tx,
+				"DROP TABLE IF EXISTS posts_fts",
+				"DROP TABLE IF EXISTS comments_fts",
+				"DROP TABLE IF EXISTS users_fts",
+				"DROP TABLE conversations",
+				"DROP TABLE messages",
+				"DROP TABLE online_users",
+				"DROP TABLE likes",
+				"DROP TABLE comments",
+				"DROP TABLE post_categories",
+				"DROP TABLE posts",
+				"DROP TABLE sessions",
+				"DROP TABLE github_auth",
+				"DROP TABLE google_auth",
+				"DROP TABLE users",
+			)
+		},
+	},
+	{
+		Version:     2,
+		Description: "add OAuth state and pending signup tables",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				// Pending OAuth authorization requests (state and PKCE verifier)
+				`CREATE TABLE oauth_states (
+					state TEXT PRIMARY KEY,
+					provider TEXT NOT NULL,
+					code_verifier TEXT NOT NULL,
+					expires_at TIMESTAMP NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				// OAuth logins awaiting profile completion before the user is created
+				`CREATE TABLE oauth_pending_signups (
+					token TEXT PRIMARY KEY,
+					provider TEXT NOT NULL,
+					provider_user_id TEXT NOT NULL,
+					email TEXT NOT NULL,
+					nickname TEXT NOT NULL,
+					first_name TEXT NOT NULL,
+					last_name TEXT NOT NULL,
+					avatar_url TEXT,
+					access_token TEXT NOT NULL,
+					refresh_token TEXT,
+					token_expires_at TIMESTAMP NOT NULL,
+					expires_at TIMESTAMP NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"DROP TABLE oauth_pending_signups",
+				"DROP TABLE oauth_states",
+			)
+		},
+	},
+	{
+		Version:     3,
+		Description: "add notifications table",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE notifications (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id TEXT NOT NULL,
+					actor_id TEXT,
+					type TEXT NOT NULL,
+					message TEXT NOT NULL,
+					post_id INTEGER,
+					comment_id INTEGER,
+					is_read BOOLEAN DEFAULT FALSE,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL,
+					FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+					FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+				)`,
+				"CREATE INDEX idx_notifications_user_id ON notifications(user_id, id DESC)",
+				"CREATE INDEX idx_notifications_unread ON notifications(user_id, is_read)",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, "DROP TABLE notifications")
+		},
+	},
+	{
+		Version:     4,
+		Description: "add message edit and soft delete columns",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"ALTER TABLE messages ADD COLUMN edited_at TIMESTAMP",
+				"ALTER TABLE messages ADD COLUMN deleted_at TIMESTAMP",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"ALTER TABLE messages DROP COLUMN deleted_at",
+				"ALTER TABLE messages DROP COLUMN edited_at",
+			)
+		},
+	},
+	{
+		// user1_id/user2_id and receiver_id become optional, conversations get
+		// a name and group flag, messages get a conversation_id, and every
+		// existing one-to-one conversation gets its two participants
+		Version:     5,
+		Description: "add group conversations and participants",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE conversations_new (
+					id TEXT PRIMARY KEY,
+					user1_id TEXT,
+					user2_id TEXT,
+					is_group BOOLEAN NOT NULL DEFAULT FALSE,
+					name TEXT,
+					created_by TEXT,
+					last_message_id TEXT,
+					last_message_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user1_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (user2_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+					FOREIGN KEY (last_message_id) REFERENCES messages(id) ON DELETE SET NULL,
+					UNIQUE(user1_id, user2_id)
+				)`,
+				`INSERT INTO conversations_new (id, user1_id, user2_id, last_message_id, last_message_time, created_at, updated_at)
+				 SELECT id, user1_id, user2_id, last_message_id, last_message_time, created_at, updated_at FROM conversations`,
+				"DROP TABLE conversations",
+				"ALTER TABLE conversations_new RENAME TO conversations",
+
+				`CREATE TABLE messages_new (
+					id TEXT PRIMARY KEY,
+					conversation_id TEXT,
+					sender_id TEXT NOT NULL,
+					receiver_id TEXT,
+					content TEXT NOT NULL,
+					is_read BOOLEAN DEFAULT FALSE,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					edited_at TIMESTAMP,
+					deleted_at TIMESTAMP,
+					FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+					FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (receiver_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				`INSERT INTO messages_new (id, conversation_id, sender_id, receiver_id, content, is_read, created_at, updated_at, edited_at, deleted_at)
+				 SELECT m.id, c.id, m.sender_id, m.receiver_id, m.content, m.is_read, m.created_at, m.updated_at, m.edited_at, m.deleted_at
+				 FROM messages m
+				 LEFT JOIN conversations c ON
+					(c.user1_id = m.sender_id AND c.user2_id = m.receiver_id) OR
+					(c.user1_id = m.receiver_id AND c.user2_id = m.sender_id)`,
+				"DROP TABLE messages",
+				"ALTER TABLE messages_new RENAME TO messages",
+
+				// Conversation members with their read position. Direct conversations
+				// also keep their two users in user1_id/user2_id for lookups.
+				`CREATE TABLE conversation_participants (
+					conversation_id TEXT NOT NULL,
+					user_id TEXT NOT NULL,
+					joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					last_read_at TIMESTAMP,
+					PRIMARY KEY (conversation_id, user_id),
+					FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				// Each user has read up to the newest message they received that is marked read
+				`INSERT INTO conversation_participants (conversation_id, user_id, joined_at, last_read_at)
+				 SELECT c.id, u.user_id, c.created_at,
+					(SELECT MAX(m.created_at) FROM messages m
+					 WHERE m.conversation_id = c.id AND m.receiver_id = u.user_id AND m.is_read = 1)
+				 FROM conversations c
+				 JOIN (SELECT id, user1_id AS user_id FROM conversations
+				       UNION ALL
+				       SELECT id, user2_id FROM conversations) u ON u.id = c.id`,
+
+				// Dropping the old tables dropped their indexes
+				messagesIndexes,
+				conversationsIndexes,
+				"CREATE INDEX idx_messages_conversation_id ON messages(conversation_id, created_at DESC)",
+				"CREATE INDEX idx_conversation_participants_user_id ON conversation_participants(user_id)",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				// Group conversations have no place in the old schema
+				"DELETE FROM messages WHERE receiver_id IS NULL",
+				"DELETE FROM conversations WHERE is_group = 1",
+				"DROP TABLE conversation_participants",
+
+				`CREATE TABLE conversations_old (
+					id TEXT PRIMARY KEY,
+					user1_id TEXT NOT NULL,
+					user2_id TEXT NOT NULL,
+					last_message_id TEXT,
+					last_message_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (user1_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (user2_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (last_message_id) REFERENCES messages(id) ON DELETE SET NULL,
+					UNIQUE(user1_id, user2_id)
+				)`,
+				`INSERT INTO conversations_old (id, user1_id, user2_id, last_message_id, last_message_time, created_at, updated_at)
+				 SELECT id, user1_id, user2_id, last_message_id, last_message_time, created_at, updated_at FROM conversations`,
+				"DROP TABLE conversations",
+				"ALTER TABLE conversations_old RENAME TO conversations",
+
+				`CREATE TABLE messages_old (
+					id TEXT PRIMARY KEY,
+					sender_id TEXT NOT NULL,
+					receiver_id TEXT NOT NULL,
+					content TEXT NOT NULL,
+					is_read BOOLEAN DEFAULT FALSE,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					edited_at TIMESTAMP,
+					deleted_at TIMESTAMP,
+					FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (receiver_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				`INSERT INTO messages_old (id, sender_id, receiver_id, content, is_read, created_at, updated_at, edited_at, deleted_at)
+				 SELECT id, sender_id, receiver_id, content, is_read, created_at, updated_at, edited_at, deleted_at FROM messages`,
+				"DROP TABLE messages",
+				"ALTER TABLE messages_old RENAME TO messages",
+
+				messagesIndexes,
+				conversationsIndexes,
+			)
+		},
+	},
+	{
+		Version:     6,
+		Description: "add message delivery and read timestamps",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"ALTER TABLE messages ADD COLUMN delivered_at TIMESTAMP",
+				"ALTER TABLE messages ADD COLUMN read_at TIMESTAMP",
+				// Messages already marked read were last updated when they were
+				// read, which is the best available estimate of both times
+				"UPDATE messages SET delivered_at = updated_at, read_at = updated_at WHERE is_read = 1",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"ALTER TABLE messages DROP COLUMN read_at",
+				"ALTER TABLE messages DROP COLUMN delivered_at",
+			)
+		},
+	},
+	{
+		Version:     7,
+		Description: "add failed login tracking for account lockout",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"ALTER TABLE users ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0",
+				"ALTER TABLE users ADD COLUMN locked_until TIMESTAMP",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"ALTER TABLE users DROP COLUMN locked_until",
+				"ALTER TABLE users DROP COLUMN failed_logins",
+			)
+		},
+	},
+	{
+		Version:     8,
+		Description: "add CSRF tokens to sessions",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"ALTER TABLE sessions ADD COLUMN csrf_token TEXT NOT NULL DEFAULT ''",
+				"UPDATE sessions SET csrf_token = lower(hex(randomblob(32)))",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, "ALTER TABLE sessions DROP COLUMN csrf_token")
+		},
+	},
+	{
+		Version:     9,
+		Description: "record the device and last activity of sessions",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"ALTER TABLE sessions ADD COLUMN public_id TEXT NOT NULL DEFAULT ''",
+				"ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT ''",
+				"ALTER TABLE sessions ADD COLUMN ip_address TEXT NOT NULL DEFAULT ''",
+				"ALTER TABLE sessions ADD COLUMN last_active_at TIMESTAMP",
+				"UPDATE sessions SET public_id = lower(hex(randomblob(16))), last_active_at = created_at",
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_public_id ON sessions(public_id)",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"DROP INDEX IF EXISTS idx_sessions_public_id",
+				"ALTER TABLE sessions DROP COLUMN last_active_at",
+				"ALTER TABLE sessions DROP COLUMN ip_address",
+				"ALTER TABLE sessions DROP COLUMN user_agent",
+				"ALTER TABLE sessions DROP COLUMN public_id",
+			)
+		},
+	},
+	{
+		Version:     10,
+		Description: "add email verification and single-use account tokens",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP",
+				// Provider accounts are only created or linked for verified emails
+				"UPDATE users SET email_verified_at = created_at WHERE google_id IS NOT NULL OR github_id IS NOT NULL",
+				// Tokens emailed for password resets and email verification.
+				// Only a hash is stored so a database leak reveals no usable links.
+				`CREATE TABLE IF NOT EXISTS user_tokens (
+					token_hash TEXT PRIMARY KEY,
+					user_id TEXT NOT NULL,
+					purpose TEXT NOT NULL,
+					expires_at TIMESTAMP NOT NULL,
+					created_at TIMESTAMP NOT NULL,
+					used_at TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_user_tokens_user_purpose ON user_tokens(user_id, purpose)",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"DROP TABLE user_tokens",
+				"ALTER TABLE users DROP COLUMN email_verified_at",
+			)
+		},
+	},
+	{
+		Version:     11,
+		Description: "add two-factor authentication and recovery codes",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				// The secret is set at enrollment and only used once
+				// totp_enabled_at confirms the user's app produces codes
+				"ALTER TABLE users ADD COLUMN totp_secret TEXT",
+				"ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP",
+				// Highest time step accepted, so a code cannot be replayed
+				"ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0",
+				`CREATE TABLE IF NOT EXISTS recovery_codes (
+					user_id TEXT NOT NULL,
+					code_hash TEXT NOT NULL,
+					used_at TIMESTAMP,
+					PRIMARY KEY (user_id, code_hash),
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"DROP TABLE recovery_codes",
+				"ALTER TABLE users DROP COLUMN totp_last_step",
+				"ALTER TABLE users DROP COLUMN totp_enabled_at",
+				"ALTER TABLE users DROP COLUMN totp_secret",
+			)
+		},
+	},
+	{
+		Version:     12,
+		Description: "add user roles",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+					CHECK (role IN ('user', 'moderator', 'admin'))`,
+				"CREATE INDEX IF NOT EXISTS idx_users_role ON users(role) WHERE role != 'user'",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"DROP INDEX idx_users_role",
+				"ALTER TABLE users DROP COLUMN role",
+			)
+		},
+	},
+	{
+		Version:     13,
+		Description: "add reports, moderation actions and user suspensions",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				// Hidden posts and comments are kept but no longer shown
+				"ALTER TABLE posts ADD COLUMN hidden_at TIMESTAMP",
+				"ALTER TABLE comments ADD COLUMN hidden_at TIMESTAMP",
+				`CREATE TABLE reports (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					reporter_id TEXT NOT NULL,
+					target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message', 'user')),
+					target_id TEXT NOT NULL,
+					target_user_id TEXT,
+					target_snapshot TEXT NOT NULL,
+					reason TEXT NOT NULL,
+					status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved', 'dismissed')),
+					claimed_by TEXT,
+					claimed_at TIMESTAMP,
+					closed_by TEXT,
+					closed_at TIMESTAMP,
+					action TEXT,
+					note TEXT,
+					created_at TIMESTAMP NOT NULL,
+					FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (target_user_id) REFERENCES users(id) ON DELETE SET NULL,
+					FOREIGN KEY (claimed_by) REFERENCES users(id) ON DELETE SET NULL,
+					FOREIGN KEY (closed_by) REFERENCES users(id) ON DELETE SET NULL
+				)`,
+				// A user can have one pending report per target
+				`CREATE UNIQUE INDEX idx_reports_pending ON reports(reporter_id, target_type, target_id)
+					WHERE status IN ('open', 'claimed')`,
+				"CREATE INDEX idx_reports_status ON reports(status, id)",
+				"CREATE INDEX idx_reports_target ON reports(target_type, target_id)",
+				`CREATE TABLE moderation_actions (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					report_id INTEGER,
+					moderator_id TEXT,
+					action TEXT NOT NULL,
+					target_type TEXT NOT NULL,
+					target_id TEXT NOT NULL,
+					note TEXT,
+					created_at TIMESTAMP NOT NULL,
+					FOREIGN KEY (report_id) REFERENCES reports(id) ON DELETE SET NULL,
+					FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL
+				)`,
+				"CREATE INDEX idx_moderation_actions_report ON moderation_actions(report_id)",
+				"CREATE INDEX idx_moderation_actions_target ON moderation_actions(target_type, target_id)",
+				// expires_at is NULL for a permanent ban
+				`CREATE TABLE user_suspensions (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id TEXT NOT NULL,
+					reason TEXT NOT NULL,
+					expires_at TIMESTAMP,
+					created_by TEXT,
+					created_at TIMESTAMP NOT NULL,
+					lifted_by TEXT,
+					lifted_at TIMESTAMP,
+					FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+					FOREIGN KEY (lifted_by) REFERENCES users(id) ON DELETE SET NULL
+				)`,
+				"CREATE INDEX idx_user_suspensions_user ON user_suspensions(user_id)",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				"DROP TABLE user_suspensions",
+				"DROP TABLE moderation_actions",
+				"DROP TABLE reports",
+				"ALTER TABLE comments DROP COLUMN hidden_at",
+				"ALTER TABLE posts DROP COLUMN hidden_at",
+			)
+		},
+	},
+	{
+		Version:     14,
+		Description: "add user blocks",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE user_blocks (
+					blocker_id TEXT NOT NULL,
+					blocked_id TEXT NOT NULL,
+					created_at TIMESTAMP NOT NULL,
+					PRIMARY KEY (blocker_id, blocked_id),
+					FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+					FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+				)`,
+				"CREATE INDEX idx_user_blocks_blocked ON user_blocks(blocked_id)",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, "DROP TABLE user_blocks")
+		},
+	},
+	{
+		Version:     15,
+		Description: "add audit events",
+		Up: func(tx *sql.Tx) error {
+			// actor_id has no foreign key so events outlive the accounts
+			// that caused them
+			return execAll(tx,
+				`CREATE TABLE audit_events (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					actor_id TEXT,
+					action TEXT NOT NULL,
+					target_type TEXT NOT NULL DEFAULT '',
+					target_id TEXT NOT NULL DEFAULT '',
+					ip_address TEXT NOT NULL DEFAULT '',
+					metadata TEXT,
+					created_at TIMESTAMP NOT NULL
+				)`,
+				"CREATE INDEX idx_audit_events_actor ON audit_events(actor_id)",
+				"CREATE INDEX idx_audit_events_action ON audit_events(action)",
+				"CREATE INDEX idx_audit_events_target ON audit_events(target_type, target_id)",
+				"CREATE INDEX idx_audit_events_created_at ON audit_events(created_at)",
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, "DROP TABLE audit_events")
+		},
+	},
+	{
+		Version:     16,
+		Description: "record provider sign-ins for re-authentication",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx, "ALTER TABLE users ADD COLUMN oauth_login_at TIMESTAMP")
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, "ALTER TABLE users DROP COLUMN oauth_login_at")
+		},
+	},
+	{
+		Version:     17,
+		Description: "store post creation times in UTC",
+		Up: func(tx *sql.Tx) error {
+			// The feed pages by comparing created_at as stored, which only
+			// orders correctly when every row is written in the same zone
+			rows, err := tx.Query("SELECT id, created_at FROM posts")
+			if err != nil {
+				return err
+			}
+			createdAt := map[int]time.Time{}
+			for rows.Next() {
+				var id int
+				var t time.Time
+				if err := rows.Scan(&id, &t); err != nil {
+					rows.Close()
+					return err
+				}
+				createdAt[id] = t
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for id, t := range createdAt {
+				if _, err := tx.Exec("UPDATE posts SET created_at = ? WHERE id = ?", t.UTC(), id); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *sql.Tx) error {
+			// UTC times read back the same, so there is nothing to undo
+			return nil
+		},
+	},
+}
+
+// Indexes on columns the messages and conversations tables have had since
+// the first version; recreated whenever those tables are rebuilt
+const (
+	messagesIndexes = `
+		CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages(sender_id);
+		CREATE INDEX IF NOT EXISTS idx_messages_receiver_id ON messages(receiver_id);
+		CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_messages_is_read ON messages(is_read);`
+
+	conversationsIndexes = `
+		CREATE INDEX IF NOT EXISTS idx_conversations_user1_id ON conversations(user1_id);
+		CREATE INDEX IF NOT EXISTS idx_conversations_user2_id ON conversations(user2_id);
+		CREATE INDEX IF NOT EXISTS idx_conversations_last_message_time ON conversations(last_message_time DESC);`
+)
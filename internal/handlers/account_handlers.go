@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+	"forum/internal/models"
+	"forum/internal/websocket"
+)
+
+// ForgotPasswordHandler emails a password reset link. It answers the same
+// whether or not an account uses the email, so it cannot be used to find
+// out who is registered.
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		RenderError(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	// Send in the background so response times do not reveal whether an
+	// email went out
+	go func() {
+		if err := auth.RequestPasswordReset(email); err != nil {
+			log.Printf("❌ Password reset email failed: %v", err)
+		}
+	}()
+
+	RenderSuccess(w, "If an account uses that email, a password reset link is on its way", nil)
+}
+
+// ResetPasswordHandler sets a new password with an emailed reset token. All
+// of the user's sessions are ended and their websockets closed, so they sign
+// in again with the new password.
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, revoked, err := auth.ResetPassword(req.Token, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrPasswordTooShort):
+			RenderError(w, fmt.Sprintf("Password must be at least %d characters", auth.MinPasswordLength), http.StatusBadRequest)
+		case errors.Is(err, auth.ErrInvalidToken):
+			RenderError(w, "This reset link is invalid or has expired", http.StatusBadRequest)
+		default:
+			log.Printf("❌ Password reset failed: %v", err)
+			RenderError(w, "Failed to reset password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	recordAudit(r, userID, audit.ActionPasswordReset, audit.TargetUser, userID,
+		map[string]interface{}{"revokedSessions": len(revoked)})
+
+	websocket.GetHub().CloseSessions(revoked...)
+	auth.ClearSessionCookie(w)
+	RenderSuccess(w, "Password updated, please sign in", nil)
+}
+
+// VerifyEmailHandler confirms the email address an emailed verification
+// token was sent to
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := auth.VerifyEmail(r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			RenderError(w, "This verification link is invalid or has expired", http.StatusBadRequest)
+			return
+		}
+		log.Printf("❌ Email verification failed: %v", err)
+		RenderError(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("✅ User %s verified their email", userID)
+	RenderSuccess(w, "Email verified", nil)
+}
+
+// ResendVerificationHandler emails the current user a new verification link
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if user.EmailVerified {
+		RenderError(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+
+	if err := auth.SendEmailVerification(user); err != nil {
+		log.Printf("❌ Verification email for %s failed: %v", user.ID, err)
+		RenderError(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	RenderSuccess(w, "Verification email sent", nil)
+}
+
+// ChangePasswordHandler replaces the current user's password. Every other
+// session is signed out and this one gets a new ID.
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := requireSession(w, r)
+	if session == nil {
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := auth.ChangePassword(session.UserID, req.CurrentPassword, req.NewPassword); err != nil {
+		renderAccountError(w, err, "Failed to change password")
+		return
+	}
+
+	revoked, err := auth.RevokeOtherSessions(session.UserID, session.ID)
+	if err != nil {
+		log.Printf("❌ Failed to end other sessions of %s after a password change: %v", session.UserID, err)
+	}
+	websocket.GetHub().CloseSessions(revoked...)
+
+	if err := auth.RotateSession(w, session); err != nil {
+		log.Printf("⚠️ Failed to rotate session of %s: %v", session.UserID, err)
+	}
+
+	log.Printf("🔑 User %s changed their password, ended %d other sessions", session.UserID, len(revoked))
+	recordAudit(r, session.UserID, audit.ActionPasswordChanged, audit.TargetUser, session.UserID,
+		map[string]interface{}{"revokedSessions": len(revoked)})
+	RenderSuccess(w, "Password changed", map[string]int{"revoked": len(revoked)})
+}
+
+// ChangeEmailHandler replaces the current user's email and sends a
+// verification link to the new address
+func ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := requireSession(w, r)
+	if session == nil {
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := auth.GetUserByID(session.UserID)
+	if err != nil {
+		RenderError(w, "Failed to retrieve profile", http.StatusInternalServerError)
+		return
+	}
+
+	if err := auth.ChangeEmail(session.UserID, req.Password, req.Email); err != nil {
+		renderAccountError(w, err, "Failed to change email")
+		return
+	}
+
+	if err := auth.RotateSession(w, session); err != nil {
+		log.Printf("⚠️ Failed to rotate session of %s: %v", session.UserID, err)
+	}
+
+	user, err := auth.GetUserByID(session.UserID)
+	if err != nil {
+		RenderError(w, "Failed to retrieve updated profile", http.StatusInternalServerError)
+		return
+	}
+	sendVerificationEmail(user)
+
+	log.Printf("📧 User %s changed their email", user.ID)
+	recordAudit(r, user.ID, audit.ActionEmailChanged, audit.TargetUser, user.ID,
+		map[string]interface{}{"from": previous.Email, "to": user.Email})
+	RenderSuccess(w, "Email changed, check your inbox to verify it", user)
+}
+
+// ChangeNicknameHandler replaces the current user's nickname
+func ChangeNicknameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := requireSession(w, r)
+	if session == nil {
+		return
+	}
+
+	var req models.ChangeNicknameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := auth.GetUserByID(session.UserID)
+	if err != nil {
+		RenderError(w, "Failed to retrieve profile", http.StatusInternalServerError)
+		return
+	}
+
+	if err := auth.ChangeNickname(session.UserID, req.Password, req.Nickname); err != nil {
+		renderAccountError(w, err, "Failed to change nickname")
+		return
+	}
+
+	user, err := auth.GetUserByID(session.UserID)
+	if err != nil {
+		RenderError(w, "Failed to retrieve updated profile", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(r, user.ID, audit.ActionNicknameChanged, audit.TargetUser, user.ID,
+		map[string]interface{}{"from": previous.Nickname, "to": user.Nickname})
+
+	RenderSuccess(w, "Nickname changed", user)
+}
+
+// renderAccountError renders an error from changing account credentials,
+// falling back to a 500 with message
+func renderAccountError(w http.ResponseWriter, err error, message string) {
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &locked):
+		renderTooManyRequests(w, "Too many wrong passwords, please try again later", locked.RetryAfter())
+	case errors.Is(err, auth.ErrWrongPassword):
+		RenderError(w, "Current password is incorrect", http.StatusForbidden)
+	case errors.Is(err, auth.ErrReauthRequired):
+		RenderError(w, "Sign in again with your provider to confirm this change", http.StatusForbidden)
+	case errors.Is(err, auth.ErrPasswordTooShort):
+		RenderError(w, fmt.Sprintf("Password must be at least %d characters", auth.MinPasswordLength), http.StatusBadRequest)
+	case errors.Is(err, auth.ErrInvalidEmail):
+		RenderError(w, "Please enter a valid email address", http.StatusBadRequest)
+	case errors.Is(err, auth.ErrInvalidNickname):
+		RenderError(w, "Nickname must be 3-20 letters, numbers, underscores or hyphens", http.StatusBadRequest)
+	case errors.Is(err, auth.ErrEmailUnchanged):
+		RenderError(w, "That is already your email", http.StatusBadRequest)
+	case errors.Is(err, auth.ErrEmailTaken):
+		RenderError(w, "Email already exists", http.StatusConflict)
+	case errors.Is(err, auth.ErrNicknameTaken):
+		RenderError(w, "Nickname already exists", http.StatusConflict)
+	default:
+		log.Printf("❌ %s: %v", message, err)
+		RenderError(w, message, http.StatusInternalServerError)
+	}
+}
+
+// sendVerificationEmail emails a new user their verification link without
+// holding up the response
+func sendVerificationEmail(user *models.User) {
+	go func() {
+		if err := auth.SendEmailVerification(user); err != nil {
+			log.Printf("❌ Verification email for %s failed: %v", user.ID, err)
+		}
+	}()
+}
+
+// requireVerifiedEmail renders 403 and returns false when unverified users
+// may not post and user has not verified their email
+func requireVerifiedEmail(w http.ResponseWriter, user *models.User) bool {
+	if auth.RequireVerifiedEmail() && !user.EmailVerified {
+		RenderError(w, "Please verify your email address first", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// RequirePermission lets a request through only when the signed-in user's
+// role grants permission
+func RequirePermission(permission auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.GetUserFromSession(r)
+		if user == nil {
+			RenderError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		if !auth.HasPermission(user, permission) {
+			RenderError(w, "You do not have permission to do that", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// StaffHandler lists the moderators and admins
+func StaffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, nickname, role FROM users WHERE role != ? ORDER BY role, nickname COLLATE NOCASE
+	`, auth.RoleUser)
+	if err != nil {
+		log.Printf("❌ Failed to list staff: %v", err)
+		RenderError(w, "Failed to list staff", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	staff := []models.StaffMember{}
+	for rows.Next() {
+		var member models.StaffMember
+		if err := rows.Scan(&member.ID, &member.Nickname, &member.Role); err != nil {
+			RenderError(w, "Failed to list staff", http.StatusInternalServerError)
+			return
+		}
+		staff = append(staff, member)
+	}
+	if err := rows.Err(); err != nil {
+		RenderError(w, "Failed to list staff", http.StatusInternalServerError)
+		return
+	}
+
+	RenderSuccess(w, "Staff retrieved", staff)
+}
+
+// UserRoleHandler gives a user, named by nickname or email, a new role.
+// Admins cannot change their own role, so there is always one left.
+func UserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin := auth.GetUserFromSession(r)
+	if admin == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.UserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	target, err := auth.GetUserByEmailOrNickname(strings.TrimSpace(req.User))
+	if err != nil {
+		RenderError(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+	if target == nil {
+		RenderError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if target.ID == admin.ID {
+		RenderError(w, "You cannot change your own role", http.StatusConflict)
+		return
+	}
+
+	if err := auth.SetUserRole(target.ID, req.Role); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidRole):
+			RenderError(w, "Role must be user, moderator or admin", http.StatusBadRequest)
+		case errors.Is(err, auth.ErrUserNotFound):
+			RenderError(w, "User not found", http.StatusNotFound)
+		default:
+			log.Printf("❌ %v", err)
+			RenderError(w, "Failed to change role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("👮 %s made %s %s", admin.Nickname, target.Nickname, req.Role)
+	recordAudit(r, admin.ID, audit.ActionRoleChanged, audit.TargetUser, target.ID,
+		map[string]interface{}{"from": target.Role, "to": req.Role})
+	RenderSuccess(w, "Role changed", &models.StaffMember{ID: target.ID, Nickname: target.Nickname, Role: req.Role})
+}
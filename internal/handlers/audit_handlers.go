@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+
+	// maxAuditExport bounds an export to the newest events matching it
+	maxAuditExport = 10000
+)
+
+// recordAudit adds an event to the audit log with the request's client address
+func recordAudit(r *http.Request, actorID, action, targetType, targetID string, metadata map[string]interface{}) {
+	audit.Record(audit.Event{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  clientIP(r),
+		Metadata:   metadata,
+	})
+}
+
+// recordLoginFailure records a failed sign-in. When the identifier names an
+// account, that account is the target; there is no actor. Second-factor
+// failures have no identifier.
+func recordLoginFailure(r *http.Request, identifier string, err error) {
+	metadata := map[string]interface{}{"reason": "invalid_credentials"}
+	var locked *auth.LockedError
+	var suspended *auth.SuspendedError
+	switch {
+	case errors.As(err, &locked):
+		metadata["reason"] = "locked"
+	case errors.As(err, &suspended):
+		metadata["reason"] = "suspended"
+	case errors.Is(err, auth.ErrInvalidCode):
+		metadata["reason"] = "invalid_code"
+	}
+
+	targetType, targetID := "", ""
+	if identifier != "" {
+		metadata["identifier"] = identifier
+		if user, _ := auth.GetUserByEmailOrNickname(identifier); user != nil {
+			targetType, targetID = audit.TargetUser, user.ID
+		}
+	}
+
+	recordAudit(r, "", audit.ActionLoginFailed, targetType, targetID, metadata)
+}
+
+// AuditEventsHandler lists audit events for admins, newest first, filtered
+// by the query parameters parsed in parseAuditFilter. Older pages are
+// requested with ?cursor=<nextCursor>.
+func AuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := defaultAuditPageSize
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			RenderError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if l > maxAuditPageSize {
+			l = maxAuditPageSize
+		}
+		limit = l
+	}
+
+	var beforeID int64
+	if cursor := query.Get("cursor"); cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || id <= 0 {
+			RenderError(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		beforeID = id
+	}
+
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := audit.List(filter, limit+1, beforeID)
+	if err != nil {
+		renderAuditError(w, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(events) > limit {
+		events = events[:limit]
+		nextCursor = strconv.FormatInt(events[limit-1].ID, 10)
+	}
+
+	RenderPage(w, "Audit events retrieved", events, nextCursor)
+}
+
+// AuditExportHandler downloads the newest events matching the same filters
+// as AuditEventsHandler, as CSV (?format=csv, the default) or a JSON array
+// (?format=json)
+func AuditExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		RenderError(w, "Format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := audit.List(filter, maxAuditExport, 0)
+	if err != nil {
+		renderAuditError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "created_at", "actor_id", "actor", "action", "target_type", "target_id", "ip_address", "metadata"})
+	for _, event := range events {
+		writer.Write([]string{
+			strconv.FormatInt(event.ID, 10),
+			event.CreatedAt.UTC().Format(time.RFC3339),
+			csvCell(derefString(event.ActorID)),
+			csvCell(derefString(event.Actor)),
+			event.Action,
+			event.TargetType,
+			csvCell(event.TargetID),
+			event.IPAddress,
+			csvCell(string(event.Metadata)),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("❌ Failed to write audit export: %v", err)
+	}
+}
+
+// parseAuditFilter reads the audit filters from the query: actor (nickname
+// or email) or actorId, action (an action or a category such as "auth"),
+// targetType, targetId, ip, and from and to (YYYY-MM-DD or RFC 3339). It
+// renders an error and returns false when a filter is invalid.
+func parseAuditFilter(w http.ResponseWriter, r *http.Request) (audit.Filter, bool) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		ActorID:    query.Get("actorId"),
+		Action:     strings.TrimSpace(query.Get("action")),
+		TargetType: query.Get("targetType"),
+		TargetID:   query.Get("targetId"),
+		IPAddress:  strings.TrimSpace(query.Get("ip")),
+	}
+
+	if actor := strings.TrimSpace(query.Get("actor")); actor != "" {
+		user, err := auth.GetUserByEmailOrNickname(actor)
+		if err != nil {
+			RenderError(w, "Failed to look up user", http.StatusInternalServerError)
+			return filter, false
+		}
+		if user == nil {
+			RenderError(w, "User not found", http.StatusNotFound)
+			return filter, false
+		}
+		filter.ActorID = user.ID
+	}
+
+	if fromStr := query.Get("from"); fromStr != "" {
+		from, _, err := parseSearchDate(fromStr)
+		if err != nil {
+			RenderError(w, "Invalid 'from' date, use YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.Since = from
+	}
+
+	if toStr := query.Get("to"); toStr != "" {
+		to, dateOnly, err := parseSearchDate(toStr)
+		if err != nil {
+			RenderError(w, "Invalid 'to' date, use YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+			return filter, false
+		}
+		// A bare date includes the whole day
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.Until = to
+	}
+
+	return filter, true
+}
+
+// renderAuditError maps audit log errors to HTTP responses
+func renderAuditError(w http.ResponseWriter, err error) {
+	if errors.Is(err, audit.ErrInvalidRange) {
+		RenderError(w, "'from' must be before 'to'", http.StatusBadRequest)
+		return
+	}
+	log.Printf("❌ %v", err)
+	RenderError(w, "Failed to retrieve audit events", http.StatusInternalServerError)
+}
+
+// csvCell keeps spreadsheet apps from running user-supplied values, such
+// as nicknames, as formulas
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// derefString returns the string s points to, or "" for nil
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"forum/internal/auth"
+	"forum/internal/blocks"
+	"forum/internal/models"
+)
+
+// BlocksHandler lists the users the current user has blocked (GET) and
+// blocks another user (POST)
+func BlocksHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		blocked, err := blocks.List(user.ID)
+		if err != nil {
+			log.Printf("❌ %v", err)
+			RenderError(w, "Failed to list blocked users", http.StatusInternalServerError)
+			return
+		}
+		RenderSuccess(w, "Blocked users retrieved", blocked)
+	case http.MethodPost:
+		var req models.BlockRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RenderError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := blocks.Block(user.ID, req.UserID); err != nil {
+			renderBlockError(w, err)
+			return
+		}
+		RenderSuccess(w, "User blocked", nil)
+	default:
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// BlockHandler unblocks a user (DELETE /{userID})
+func BlockHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	blockedID := strings.TrimPrefix(r.URL.Path, "/api/blocks/")
+	if blockedID == "" || strings.Contains(blockedID, "/") {
+		RenderError(w, "Invalid block path", http.StatusBadRequest)
+		return
+	}
+
+	if err := blocks.Unblock(user.ID, blockedID); err != nil {
+		renderBlockError(w, err)
+		return
+	}
+	RenderSuccess(w, "User unblocked", nil)
+}
+
+// renderBlockError maps blocking errors to HTTP responses
+func renderBlockError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, blocks.ErrUserNotFound):
+		RenderError(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, blocks.ErrNotBlocked):
+		RenderError(w, "User is not blocked", http.StatusNotFound)
+	case errors.Is(err, blocks.ErrCannotBlockSelf):
+		RenderError(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("❌ %v", err)
+		RenderError(w, "Failed to update blocked users", http.StatusInternalServerError)
+	}
+}
+
+// viewerID returns the ID of the signed-in user, or "" for anonymous
+// visitors, who have blocked no one
+func viewerID(user *models.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.ID
+}
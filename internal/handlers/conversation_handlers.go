@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/internal/auth"
+	"forum/internal/messaging"
+	"forum/internal/models"
+	"forum/internal/ratelimit"
+	"forum/internal/websocket"
+)
+
+// createGroupHandler creates a group conversation with the current user as a member
+func createGroupHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.GroupConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	conversation, err := messaging.CreateGroup(user.ID, req.Name, req.ParticipantIDs)
+	if err != nil {
+		renderConversationError(w, err)
+		return
+	}
+
+	websocket.BroadcastConversationUpdated(conversation)
+	RenderSuccess(w, "Group created successfully", conversation)
+}
+
+// ConversationHandler handles a single conversation:
+//
+//	PUT  /api/conversations/{id}          rename a group
+//	GET  /api/conversations/{id}/messages list messages
+//	POST /api/conversations/{id}/messages send a message
+//	POST /api/conversations/{id}/members  add members to a group
+//	POST /api/conversations/{id}/leave    leave a group
+//	POST /api/conversations/{id}/read     mark the conversation as read
+func ConversationHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/conversations/"), "/")
+	conversationID := parts[0]
+	if conversationID == "" || len(parts) > 2 {
+		RenderError(w, "Invalid conversation path", http.StatusBadRequest)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodPut:
+		renameGroupHandler(w, r, user, conversationID)
+	case action == "messages" && r.Method == http.MethodGet:
+		conversationMessagesHandler(w, r, user, conversationID)
+	case action == "messages" && r.Method == http.MethodPost:
+		RateLimit(ratelimit.Message, func(w http.ResponseWriter, r *http.Request) {
+			sendConversationMessageHandler(w, r, user, conversationID)
+		})(w, r)
+	case action == "members" && r.Method == http.MethodPost:
+		addMembersHandler(w, r, user, conversationID)
+	case action == "leave" && r.Method == http.MethodPost:
+		leaveConversationHandler(w, user, conversationID)
+	case action == "read" && r.Method == http.MethodPost:
+		markConversationReadHandler(w, user, conversationID)
+	case action == "" || action == "messages" || action == "members" || action == "leave" || action == "read":
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		RenderError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// renameGroupHandler changes a group's name
+func renameGroupHandler(w http.ResponseWriter, r *http.Request, user *models.User, conversationID string) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	conversation, err := messaging.RenameGroup(user.ID, conversationID, req.Name)
+	if err != nil {
+		renderConversationError(w, err)
+		return
+	}
+
+	websocket.BroadcastConversationUpdated(conversation)
+	RenderSuccess(w, "Group renamed successfully", conversation)
+}
+
+// conversationMessagesHandler lists messages of a conversation and marks it read
+func conversationMessagesHandler(w http.ResponseWriter, r *http.Request, user *models.User, conversationID string) {
+	limit := 50 // Default limit
+	offset := 0 // Default offset
+
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	messages, err := messaging.GetMessagesInConversation(user.ID, conversationID, limit, offset)
+	if err != nil {
+		renderConversationError(w, err)
+		return
+	}
+
+	receipts, err := messaging.MarkConversationRead(user.ID, conversationID)
+	if err != nil {
+		log.Printf("Error marking conversation %s as read: %v", conversationID, err)
+		// Don't fail the request, just log the error
+	}
+	websocket.BroadcastMessagesRead(receipts)
+
+	RenderSuccess(w, "Messages retrieved successfully", messages)
+}
+
+// sendConversationMessageHandler sends a message to a conversation
+func sendConversationMessageHandler(w http.ResponseWriter, r *http.Request, user *models.User, conversationID string) {
+	var req models.MessageUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	message, err := messaging.SendToConversation(user.ID, conversationID, req.Content)
+	if err != nil {
+		renderConversationError(w, err)
+		return
+	}
+
+	websocket.BroadcastNewMessage(message)
+
+	// Reload to include the delivery status set while broadcasting
+	if delivered, err := messaging.GetMessage(message.ID); err == nil {
+		message = delivered
+	}
+
+	RenderSuccess(w, "Message sent successfully", message)
+}
+
+// addMembersHandler adds users to a group
+func addMembersHandler(w http.ResponseWriter, r *http.Request, user *models.User, conversationID string) {
+	var req models.ConversationMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.UserIDs) == 0 {
+		RenderError(w, "userIds is required", http.StatusBadRequest)
+		return
+	}
+
+	conversation, err := messaging.AddMembers(user.ID, conversationID, req.UserIDs)
+	if err != nil {
+		renderConversationError(w, err)
+		return
+	}
+
+	websocket.BroadcastConversationUpdated(conversation)
+	RenderSuccess(w, "Members added successfully", conversation)
+}
+
+// leaveConversationHandler removes the current user from a group
+func leaveConversationHandler(w http.ResponseWriter, user *models.User, conversationID string) {
+	conversation, err := messaging.LeaveConversation(user.ID, conversationID)
+	if err != nil {
+		renderConversationError(w, err)
+		return
+	}
+
+	// Nothing to broadcast when the last member left and the group was deleted
+	if conversation != nil {
+		websocket.BroadcastConversationUpdated(conversation, user.ID)
+	}
+
+	RenderSuccess(w, "Left conversation successfully", nil)
+}
+
+// markConversationReadHandler marks a conversation as read by the current user
+func markConversationReadHandler(w http.ResponseWriter, user *models.User, conversationID string) {
+	receipts, err := messaging.MarkConversationRead(user.ID, conversationID)
+	if err != nil {
+		renderConversationError(w, err)
+		return
+	}
+
+	websocket.BroadcastMessagesRead(receipts)
+
+	RenderSuccess(w, "Conversation marked as read", nil)
+}
+
+// renderConversationError maps messaging conversation errors to HTTP responses
+func renderConversationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, messaging.ErrConversationNotFound):
+		RenderError(w, "Conversation not found", http.StatusNotFound)
+	case errors.Is(err, messaging.ErrReceiverNotFound):
+		RenderError(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, messaging.ErrNotGroup):
+		RenderError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, messaging.ErrBlocked):
+		RenderError(w, err.Error(), http.StatusForbidden)
+	case messaging.IsValidationError(err):
+		RenderError(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error handling conversation: %v", err)
+		RenderError(w, "Failed to process conversation request", http.StatusInternalServerError)
+	}
+}
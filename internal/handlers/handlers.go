@@ -2,14 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"forum/internal/audit"
 	"forum/internal/auth"
+	"forum/internal/blocks"
 	"forum/internal/database"
 	"forum/internal/models"
+	"forum/internal/notifications"
 	"forum/internal/websocket"
 )
 
@@ -35,6 +40,18 @@ func RenderError(w http.ResponseWriter, message string, statusCode int) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// RenderPage renders a successful JSON response for one page of a paginated list
+func RenderPage(w http.ResponseWriter, message string, data interface{}, nextCursor string) {
+	w.Header().Set("Content-Type", "application/json")
+	response := models.APIResponse{
+		Success:    true,
+		Message:    message,
+		Data:       data,
+		NextCursor: nextCursor,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
 // RegisterHandler handles user registration
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -86,17 +103,17 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create session
-	session, err := auth.CreateSession(user.ID)
+	sendVerificationEmail(user)
+	recordAudit(r, user.ID, audit.ActionRegister, audit.TargetUser, user.ID, nil)
+
+	// Create session and set its cookie
+	session, err := startSession(w, r, user.ID)
 	if err != nil {
 		RenderError(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
 
-	// Set session cookie
-	auth.SetSessionCookie(w, session.ID)
-
-	RenderSuccess(w, "User registered successfully", user)
+	RenderSuccess(w, "User registered successfully", auth.NewSessionUser(user, session.CSRFToken))
 }
 
 // LoginHandler handles user login
@@ -126,27 +143,50 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.AuthenticateUser(req.Identifier, req.Password)
 	if err != nil {
 		log.Printf("Login error - Authentication failed: %v", err)
+		recordLoginFailure(r, req.Identifier, err)
+		var locked *auth.LockedError
+		if errors.As(err, &locked) {
+			renderTooManyRequests(w, "Too many failed login attempts, please try again later", locked.RetryAfter())
+			return
+		}
+		if renderSuspended(w, err) {
+			return
+		}
 		RenderError(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
 	log.Printf("User authenticated successfully: %s", user.ID)
 
-	// Create session
-	session, err := auth.CreateSession(user.ID)
+	// Accounts with two-factor authentication sign in once the code checks out
+	if user.TwoFactorEnabled {
+		challenge, err := beginTwoFactorLogin(w, user.ID)
+		if err != nil {
+			log.Printf("Login error - Failed to start two-factor login: %v", err)
+			RenderError(w, "Failed to sign in", http.StatusInternalServerError)
+			return
+		}
+		RenderSuccess(w, "Enter the code from your authenticator app", challenge)
+		return
+	}
+
+	// Create session and set its cookie
+	session, err := startSession(w, r, user.ID)
 	if err != nil {
 		log.Printf("Login error - Failed to create session: %v", err)
+		if renderSuspended(w, err) {
+			return
+		}
 		RenderError(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Session created successfully: %s", session.ID)
-
-	// Set session cookie
-	auth.SetSessionCookie(w, session.ID)
+	log.Printf("Session created successfully: %s", session.PublicID)
+	recordAudit(r, user.ID, audit.ActionLogin, audit.TargetSession, session.PublicID,
+		map[string]interface{}{"method": "password"})
 
 	log.Printf("Login successful for user: %s", user.Nickname)
-	RenderSuccess(w, "Login successful", user)
+	RenderSuccess(w, "Login successful", auth.NewSessionUser(user, session.CSRFToken))
 }
 
 // LogoutHandler handles user logout
@@ -177,6 +217,7 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("🔒 Deleting session: %s for user: %s", session.ID, session.UserID)
+	recordAudit(r, session.UserID, audit.ActionLogout, audit.TargetSession, session.PublicID, nil)
 
 	// Delete session from database
 	if err := auth.DeleteSession(session.ID); err != nil {
@@ -187,6 +228,9 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Disconnect the websockets opened with this session
+	websocket.GetHub().CloseSessions(session.PublicID)
+
 	// Remove user from online_users table for this specific session
 	_, err = database.DB.Exec(`
 		DELETE FROM online_users WHERE user_id = ? AND session_id = ?
@@ -228,7 +272,7 @@ func CurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := auth.GetUserFromSession(r)
+	user := auth.GetSessionUser(r)
 	if user == nil {
 		RenderError(w, "Not authenticated", http.StatusUnauthorized)
 		return
@@ -249,48 +293,73 @@ func PostsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getPostsHandler handles getting posts
+// getPostsHandler handles getting posts, newest first, one page at a time
 func getPostsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get query parameters
 	category := r.URL.Query().Get("category")
 
-	// Build query
+	limit := defaultPostsPageSize
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			RenderError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if l > maxPostsPageSize {
+			l = maxPostsPageSize
+		}
+		limit = l
+	}
+
+	var cursor *postCursor
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		c, err := decodePostCursor(cursorStr)
+		if err != nil {
+			RenderError(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		cursor = c
+	}
+
+	// Build query. Counts are correlated subqueries so that only the rows
+	// on the requested page are aggregated.
 	query := `
-		SELECT p.id, p.user_id, p.title, p.content, p.image_path, p.created_at,
+		SELECT p.id, p.user_id, p.title, p.content, p.image_path, p.created_at, p.updated_at,
 		       u.nickname, u.avatar_url,
-		       COALESCE(like_counts.like_count, 0) as like_count,
-		       COALESCE(like_counts.dislike_count, 0) as dislike_count,
-		       COALESCE(comment_counts.comment_count, 0) as comment_count
+		       (SELECT COUNT(*) FROM likes l WHERE l.post_id = p.id AND l.is_like = 1) as like_count,
+		       (SELECT COUNT(*) FROM likes l WHERE l.post_id = p.id AND l.is_like = 0) as dislike_count,
+		       (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.hidden_at IS NULL) as comment_count
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		LEFT JOIN (
-			SELECT post_id,
-			       SUM(CASE WHEN is_like = 1 THEN 1 ELSE 0 END) as like_count,
-			       SUM(CASE WHEN is_like = 0 THEN 1 ELSE 0 END) as dislike_count
-			FROM likes
-			WHERE post_id IS NOT NULL
-			GROUP BY post_id
-		) like_counts ON p.id = like_counts.post_id
-		LEFT JOIN (
-			SELECT post_id, COUNT(*) as comment_count
-			FROM comments
-			GROUP BY post_id
-		) comment_counts ON p.id = comment_counts.post_id
 	`
 
-	args := []interface{}{}
+	// Posts hidden by a moderator, or by users the viewer blocked, are left out
+	user := auth.GetUserFromSession(r)
+	conditions := []string{`p.hidden_at IS NULL`, `p.user_id NOT IN (` + blocks.BlockedIDs + `)`}
+	args := []interface{}{viewerID(user)}
 
 	if category != "" {
-		query += ` WHERE p.id IN (
+		conditions = append(conditions, `p.id IN (
 			SELECT post_id FROM post_categories WHERE category = ?
-		)`
+		)`)
 		args = append(args, category)
 	}
 
-	query += ` ORDER BY p.created_at DESC`
+	// Keyset pagination: continue strictly after the last row of the previous page
+	if cursor != nil {
+		conditions = append(conditions, `(p.created_at < ? OR (p.created_at = ? AND p.id < ?))`)
+		args = append(args, cursor.CreatedAt.UTC(), cursor.CreatedAt.UTC(), cursor.ID)
+	}
+
+	query += ` WHERE ` + strings.Join(conditions, " AND ")
+
+	// Fetch one extra row to find out whether another page exists
+	query += ` ORDER BY p.created_at DESC, p.id DESC LIMIT ?`
+	args = append(args, limit+1)
 
 	rows, err := database.DB.Query(query, args...)
 	if err != nil {
+		log.Printf("Error fetching posts: %v", err)
 		RenderError(w, "Failed to fetch posts", http.StatusInternalServerError)
 		return
 	}
@@ -300,7 +369,7 @@ func getPostsHandler(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var post models.Post
 		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImagePath, &post.CreatedAt,
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImagePath, &post.CreatedAt, &post.UpdatedAt,
 			&post.Author, &post.AuthorAvatar,
 			&post.LikeCount, &post.DislikeCount, &post.CommentCount,
 		)
@@ -309,32 +378,55 @@ func getPostsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Load categories for this post
-		categories, err := getPostCategories(post.ID)
-		if err != nil {
-			RenderError(w, "Failed to load post categories", http.StatusInternalServerError)
-			return
-		}
-		post.Categories = categories
-
-		// Check if current user liked/disliked this post
-		user := auth.GetUserFromSession(r)
-		if user != nil {
-			userLike, err := getUserLikeStatus(user.ID, &post.ID, nil)
-			if err == nil && userLike != nil {
-				post.UserLiked = userLike.IsLike
-				post.UserDisliked = !userLike.IsLike
-			}
-		}
-
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		RenderError(w, "Failed to fetch posts", http.StatusInternalServerError)
+		return
+	}
+
+	nextCursor := ""
+	if len(posts) > limit {
+		posts = posts[:limit]
+		last := posts[limit-1]
+		nextCursor = encodePostCursor(postCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
 
 	if posts == nil {
 		posts = []models.Post{}
 	}
 
-	RenderSuccess(w, "Posts retrieved successfully", posts)
+	postIDs := make([]int, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+
+	// Load categories for the whole page in one query
+	categories, err := getCategoriesForPosts(postIDs)
+	if err != nil {
+		RenderError(w, "Failed to load post categories", http.StatusInternalServerError)
+		return
+	}
+
+	// Check if current user liked/disliked the posts on this page
+	var likes map[int]bool
+	if user != nil {
+		likes, err = getUserPostLikes(user.ID, postIDs)
+		if err != nil {
+			RenderError(w, "Failed to load like status", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for i := range posts {
+		posts[i].Categories = categories[posts[i].ID]
+		if isLike, ok := likes[posts[i].ID]; ok {
+			posts[i].UserLiked = isLike
+			posts[i].UserDisliked = !isLike
+		}
+	}
+
+	RenderPage(w, "Posts retrieved successfully", posts, nextCursor)
 }
 
 // createPostHandler handles post creation
@@ -345,6 +437,9 @@ func createPostHandler(w http.ResponseWriter, r *http.Request) {
 		RenderError(w, "Authentication required", http.StatusUnauthorized)
 		return
 	}
+	if !requireVerifiedEmail(w, user) {
+		return
+	}
 
 	log.Printf("Creating post for user: %s", user.ID)
 
@@ -368,7 +463,7 @@ func createPostHandler(w http.ResponseWriter, r *http.Request) {
 	result, err := database.DB.Exec(`
 		INSERT INTO posts (user_id, title, content, image_path, created_at)
 		VALUES (?, ?, ?, ?, ?)
-	`, user.ID, req.Title, req.Content, req.ImagePath, time.Now())
+	`, user.ID, req.Title, req.Content, req.ImagePath, time.Now().UTC())
 
 	if err != nil {
 		log.Printf("Post creation error - Failed to insert post: %v", err)
@@ -404,6 +499,7 @@ func createPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Broadcast new post to all users
 	websocket.BroadcastNewPost(post)
+	notifications.NotifyPostCreated(user, post)
 
 	log.Printf("Post creation successful for user: %s", user.Nickname)
 	RenderSuccess(w, "Post created successfully", post)
@@ -431,6 +527,77 @@ func getPostCategories(postID int) ([]string, error) {
 	return categories, nil
 }
 
+// getCategoriesForPosts loads the categories of several posts in one query
+func getCategoriesForPosts(postIDs []int) (map[int][]string, error) {
+	categories := make(map[int][]string, len(postIDs))
+	if len(postIDs) == 0 {
+		return categories, nil
+	}
+
+	placeholders, args := intPlaceholders(postIDs)
+	rows, err := database.DB.Query(`
+		SELECT post_id, category FROM post_categories
+		WHERE post_id IN (`+placeholders+`)
+		ORDER BY id ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var category string
+		if err := rows.Scan(&postID, &category); err != nil {
+			return nil, err
+		}
+		categories[postID] = append(categories[postID], category)
+	}
+
+	return categories, rows.Err()
+}
+
+// getUserPostLikes loads a user's like (true) or dislike (false) for several posts in one query
+func getUserPostLikes(userID string, postIDs []int) (map[int]bool, error) {
+	likes := make(map[int]bool, len(postIDs))
+	if len(postIDs) == 0 {
+		return likes, nil
+	}
+
+	placeholders, args := intPlaceholders(postIDs)
+	args = append([]interface{}{userID}, args...)
+	rows, err := database.DB.Query(`
+		SELECT post_id, is_like FROM likes
+		WHERE user_id = ? AND post_id IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var isLike bool
+		if err := rows.Scan(&postID, &isLike); err != nil {
+			return nil, err
+		}
+		likes[postID] = isLike
+	}
+
+	return likes, rows.Err()
+}
+
+// intPlaceholders builds a "?, ?, ?" list and matching arguments for an IN clause
+func intPlaceholders(ids []int) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(placeholders, ", "), args
+}
+
 // getUserLikeStatus gets user's like status for a post or comment
 func getUserLikeStatus(userID string, postID *int, commentID *int) (*models.Like, error) {
 	var like models.Like
@@ -464,11 +631,11 @@ func getUserLikeStatus(userID string, postID *int, commentID *int) (*models.Like
 	return &like, nil
 }
 
-// getPostByID gets a post by ID
+// getPostByID gets a post by ID. Posts hidden by a moderator are not found.
 func getPostByID(postID int) (*models.Post, error) {
 	var post models.Post
 	err := database.DB.QueryRow(`
-		SELECT p.id, p.user_id, p.title, p.content, p.image_path, p.created_at,
+		SELECT p.id, p.user_id, p.title, p.content, p.image_path, p.created_at, p.updated_at,
 		       u.nickname, u.avatar_url,
 		       COALESCE(like_counts.like_count, 0) as like_count,
 		       COALESCE(like_counts.dislike_count, 0) as dislike_count,
@@ -486,11 +653,12 @@ func getPostByID(postID int) (*models.Post, error) {
 		LEFT JOIN (
 			SELECT post_id, COUNT(*) as comment_count
 			FROM comments
+			WHERE hidden_at IS NULL
 			GROUP BY post_id
 		) comment_counts ON p.id = comment_counts.post_id
-		WHERE p.id = ?
+		WHERE p.id = ? AND p.hidden_at IS NULL
 	`, postID).Scan(
-		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImagePath, &post.CreatedAt,
+		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImagePath, &post.CreatedAt, &post.UpdatedAt,
 		&post.Author, &post.AuthorAvatar,
 		&post.LikeCount, &post.DislikeCount, &post.CommentCount,
 	)
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+	"forum/internal/models"
+	"forum/internal/moderation"
+	"forum/internal/notifications"
+	"forum/internal/websocket"
+)
+
+const (
+	defaultReportsPageSize = 20
+	maxReportsPageSize     = 100
+)
+
+// ReportHandler files a report about a post, comment, message or user
+func ReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	report, err := moderation.CreateReport(user.ID, req)
+	if err != nil {
+		renderModerationError(w, err)
+		return
+	}
+
+	RenderSuccess(w, "Report submitted", report)
+}
+
+// ModerationReportsHandler lists reports for moderators, newest first. The
+// status query parameter defaults to pending reports; older pages are
+// requested with ?cursor=<nextCursor>.
+func ModerationReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := defaultReportsPageSize
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			RenderError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if l > maxReportsPageSize {
+			l = maxReportsPageSize
+		}
+		limit = l
+	}
+
+	var beforeID int64
+	if cursor := query.Get("cursor"); cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || id <= 0 {
+			RenderError(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		beforeID = id
+	}
+
+	reports, err := moderation.ListReports(query.Get("status"), limit+1, beforeID)
+	if err != nil {
+		renderModerationError(w, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(reports) > limit {
+		reports = reports[:limit]
+		nextCursor = strconv.FormatInt(reports[limit-1].ID, 10)
+	}
+
+	RenderPage(w, "Reports retrieved", reports, nextCursor)
+}
+
+// ModerationReportHandler serves a single report (GET) and the claim,
+// resolve and dismiss actions on it (POST /{id}/{action})
+func ModerationReportHandler(w http.ResponseWriter, r *http.Request) {
+	moderator := auth.GetUserFromSession(r)
+	if moderator == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/moderation/reports/"), "/")
+	reportID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || reportID <= 0 || len(parts) > 2 {
+		RenderError(w, "Invalid report path", http.StatusBadRequest)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		report, err := moderation.GetReport(reportID)
+		if err != nil {
+			renderModerationError(w, err)
+			return
+		}
+		RenderSuccess(w, "Report retrieved", report)
+	case action == "claim" && r.Method == http.MethodPost:
+		report, err := moderation.ClaimReport(reportID, moderator.ID)
+		if err != nil {
+			renderModerationError(w, err)
+			return
+		}
+		recordAudit(r, moderator.ID, audit.ActionReportClaimed, audit.TargetReport, strconv.FormatInt(reportID, 10), nil)
+		RenderSuccess(w, "Report claimed", report)
+	case action == "resolve" && r.Method == http.MethodPost:
+		resolveReportHandler(w, r, moderator, reportID)
+	case action == "dismiss" && r.Method == http.MethodPost:
+		dismissReportHandler(w, r, moderator, reportID)
+	case action == "" || action == "claim" || action == "resolve" || action == "dismiss":
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		RenderError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// resolveReportHandler closes a report with an action, takes hidden content
+// off connected clients and notifies the warned user and the reporters
+func resolveReportHandler(w http.ResponseWriter, r *http.Request, moderator *models.User, reportID int64) {
+	var req models.ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resolution, err := moderation.ResolveReport(reportID, moderator.ID, req)
+	if err != nil {
+		renderModerationError(w, err)
+		return
+	}
+	report := resolution.Report
+
+	switch {
+	case resolution.RemovedMessage != nil:
+		websocket.BroadcastMessageDeleted(resolution.RemovedMessage)
+	case req.Action == moderation.ActionHide && report.TargetType == moderation.TargetPost:
+		if postID, err := strconv.Atoi(report.TargetID); err == nil {
+			websocket.BroadcastPostDeleted(postID)
+		}
+	case req.Action == moderation.ActionWarn:
+		notifications.NotifyWarning(*report.TargetUserID, strings.TrimSpace(req.Note))
+	case resolution.Suspension != nil:
+		websocket.DisconnectSuspendedUser(resolution.Suspension)
+	}
+
+	notifications.NotifyReportClosed(resolution.ReporterIDs, report.TargetType, false)
+
+	log.Printf("🚩 %s resolved report %d (%s)", moderator.Nickname, reportID, *report.Action)
+	recordAudit(r, moderator.ID, audit.ActionReportResolved, audit.TargetReport, strconv.FormatInt(reportID, 10),
+		map[string]interface{}{"action": req.Action, "targetType": report.TargetType, "targetId": report.TargetID, "note": req.Note})
+	if resolution.Suspension != nil {
+		recordAudit(r, moderator.ID, audit.ActionUserSuspended, audit.TargetUser, resolution.Suspension.UserID,
+			map[string]interface{}{"suspensionId": resolution.Suspension.ID, "reportId": reportID, "reason": resolution.Suspension.Reason})
+	}
+	RenderSuccess(w, "Report resolved", report)
+}
+
+// dismissReportHandler closes a report without action and notifies the reporter
+func dismissReportHandler(w http.ResponseWriter, r *http.Request, moderator *models.User, reportID int64) {
+	var req models.DismissReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resolution, err := moderation.DismissReport(reportID, moderator.ID, req.Note)
+	if err != nil {
+		renderModerationError(w, err)
+		return
+	}
+
+	notifications.NotifyReportClosed(resolution.ReporterIDs, resolution.Report.TargetType, true)
+
+	log.Printf("🚩 %s dismissed report %d", moderator.Nickname, reportID)
+	recordAudit(r, moderator.ID, audit.ActionReportDismissed, audit.TargetReport, strconv.FormatInt(reportID, 10),
+		map[string]interface{}{"note": req.Note})
+	RenderSuccess(w, "Report dismissed", resolution.Report)
+}
+
+// renderModerationError maps moderation errors to HTTP responses
+func renderModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, moderation.ErrTargetNotFound):
+		RenderError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, moderation.ErrReportNotFound):
+		RenderError(w, "Report not found", http.StatusNotFound)
+	case errors.Is(err, moderation.ErrCannotReportSelf), errors.Is(err, auth.ErrCannotSuspendStaff):
+		RenderError(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, moderation.ErrAlreadyReported),
+		errors.Is(err, moderation.ErrReportClosed),
+		errors.Is(err, moderation.ErrClaimedByOther):
+		RenderError(w, err.Error(), http.StatusConflict)
+	case moderation.IsValidationError(err):
+		RenderError(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("❌ Moderation error: %v", err)
+		RenderError(w, "Failed to process report", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"forum/internal/auth"
+	"forum/internal/notifications"
+)
+
+const (
+	defaultNotificationsPageSize = 20
+	maxNotificationsPageSize     = 100
+)
+
+// NotificationsHandler lists the current user's notifications, newest first.
+// Older pages are requested with ?cursor=<nextCursor>.
+func NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := defaultNotificationsPageSize
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			RenderError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if l > maxNotificationsPageSize {
+			l = maxNotificationsPageSize
+		}
+		limit = l
+	}
+
+	var beforeID int64
+	if cursor := query.Get("cursor"); cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || id <= 0 {
+			RenderError(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		beforeID = id
+	}
+
+	list, err := notifications.List(user.ID, limit+1, beforeID, query.Get("unread") == "true")
+	if err != nil {
+		log.Printf("❌ Failed to list notifications for %s: %v", user.ID, err)
+		RenderError(w, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	nextCursor := ""
+	if len(list) > limit {
+		list = list[:limit]
+		nextCursor = strconv.FormatInt(list[limit-1].ID, 10)
+	}
+
+	RenderPage(w, "Notifications retrieved successfully", list, nextCursor)
+}
+
+// MarkNotificationsReadHandler marks notifications as read. The body is either
+// {"ids": [1, 2]} or {"all": true}.
+func MarkNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		IDs []int64 `json:"ids"`
+		All bool    `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.IDs) == 0 && !req.All {
+		RenderError(w, "Either ids or all is required", http.StatusBadRequest)
+		return
+	}
+
+	ids := req.IDs
+	if req.All {
+		ids = nil
+	}
+	err := notifications.MarkRead(user.ID, ids)
+	if errors.Is(err, notifications.ErrTooManyIDs) {
+		RenderError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to mark notifications read for %s: %v", user.ID, err)
+		RenderError(w, "Failed to mark notifications as read", http.StatusInternalServerError)
+		return
+	}
+
+	count, err := notifications.UnreadCount(user.ID)
+	if err != nil {
+		RenderError(w, "Failed to count unread notifications", http.StatusInternalServerError)
+		return
+	}
+
+	RenderSuccess(w, "Notifications marked as read", map[string]int{"unreadCount": count})
+}
+
+// UnreadNotificationsCountHandler returns the number of unread notifications
+func UnreadNotificationsCountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := notifications.UnreadCount(user.ID)
+	if err != nil {
+		log.Printf("❌ Failed to count notifications for %s: %v", user.ID, err)
+		RenderError(w, "Failed to count unread notifications", http.StatusInternalServerError)
+		return
+	}
+
+	RenderSuccess(w, "Unread count retrieved successfully", map[string]int{"unreadCount": count})
+}
@@ -1,33 +1,268 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+	"forum/internal/models"
 )
 
 // GoogleLoginHandler handles Google OAuth login
 func GoogleLoginHandler(w http.ResponseWriter, r *http.Request) {
-	// OAuth implementation would go here
-	// For now, redirect to regular login
-	http.Redirect(w, r, "/login", http.StatusTemporaryRedirect)
+	oauthLogin(w, r, "google")
 }
 
 // GoogleCallbackHandler handles Google OAuth callback
 func GoogleCallbackHandler(w http.ResponseWriter, r *http.Request) {
-	// OAuth callback implementation would go here
-	// For now, redirect to regular login
-	http.Redirect(w, r, "/login", http.StatusTemporaryRedirect)
+	oauthCallback(w, r, "google")
 }
 
 // GithubLoginHandler handles GitHub OAuth login
 func GithubLoginHandler(w http.ResponseWriter, r *http.Request) {
-	// OAuth implementation would go here
-	// For now, redirect to regular login
-	http.Redirect(w, r, "/login", http.StatusTemporaryRedirect)
+	oauthLogin(w, r, "github")
 }
 
 // GithubCallbackHandler handles GitHub OAuth callback
 func GithubCallbackHandler(w http.ResponseWriter, r *http.Request) {
-	// OAuth callback implementation would go here
-	// For now, redirect to regular login
-	http.Redirect(w, r, "/login", http.StatusTemporaryRedirect)
+	oauthCallback(w, r, "github")
+}
+
+// OAuthProvidersHandler lists the OAuth providers enabled on this server
+func OAuthProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	RenderSuccess(w, "OAuth providers retrieved", auth.EnabledOAuthProviders())
+}
+
+// OAuthSignupHandler returns (GET) or completes (POST) a pending OAuth signup
+func OAuthSignupHandler(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(auth.OAuthSignupCookieName)
+	if err != nil || cookie.Value == "" {
+		RenderError(w, "No pending signup", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		pending, err := auth.GetPendingOAuthSignup(cookie.Value)
+		if err != nil {
+			RenderError(w, "Failed to load pending signup", http.StatusInternalServerError)
+			return
+		}
+		if pending == nil {
+			clearOAuthCookie(w, auth.OAuthSignupCookieName, "/")
+			RenderError(w, "Signup expired, please sign in again", http.StatusNotFound)
+			return
+		}
+		RenderSuccess(w, "Pending signup retrieved", pending)
+
+	case http.MethodPost:
+		var req models.OAuthSignupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RenderError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Validate input
+		if req.Nickname == "" || req.FirstName == "" || req.LastName == "" {
+			RenderError(w, "Nickname, first name and last name are required", http.StatusBadRequest)
+			return
+		}
+
+		if req.Age < 13 {
+			RenderError(w, "Age must be at least 13", http.StatusBadRequest)
+			return
+		}
+
+		if req.Gender != "male" && req.Gender != "female" {
+			RenderError(w, "Gender must be 'male' or 'female'", http.StatusBadRequest)
+			return
+		}
+
+		user, err := auth.CompleteOAuthSignup(cookie.Value, &req)
+		if err != nil {
+			log.Printf("❌ OAuth signup failed: %v", err)
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				if strings.Contains(err.Error(), "nickname") {
+					RenderError(w, "Nickname already exists", http.StatusConflict)
+				} else {
+					RenderError(w, "User already exists", http.StatusConflict)
+				}
+			} else if strings.Contains(err.Error(), "signup expired") {
+				clearOAuthCookie(w, auth.OAuthSignupCookieName, "/")
+				RenderError(w, "Signup expired, please sign in again", http.StatusGone)
+			} else {
+				RenderError(w, "Failed to create user", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		recordAudit(r, user.ID, audit.ActionRegister, audit.TargetUser, user.ID,
+			map[string]interface{}{"method": "oauth"})
+
+		session, err := startSession(w, r, user.ID)
+		if err != nil {
+			RenderError(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+
+		clearOAuthCookie(w, auth.OAuthSignupCookieName, "/")
+		RenderSuccess(w, "User registered successfully", auth.NewSessionUser(user, session.CSRFToken))
+
+	default:
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// oauthLogin starts the authorization-code flow with state and PKCE
+func oauthLogin(w http.ResponseWriter, r *http.Request, providerName string) {
+	provider := auth.GetOAuthProvider(providerName)
+	if provider == nil {
+		log.Printf("⚠️ OAuth login attempted with disabled provider %s", providerName)
+		http.Redirect(w, r, "/login?error=oauth_unavailable", http.StatusTemporaryRedirect)
+		return
+	}
+
+	state := auth.GenerateSessionID()
+	verifier, challenge := auth.GeneratePKCE()
+
+	if err := auth.SaveOAuthState(state, providerName, verifier); err != nil {
+		log.Printf("❌ OAuth login error: %v", err)
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusTemporaryRedirect)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.OAuthStateCookieName,
+		Value:    state,
+		Path:     "/auth/",
+		MaxAge:   int(auth.OAuthStateDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   auth.SecureCookies(),
+		SameSite: http.SameSiteLaxMode, // Must survive the top-level redirect back from the provider
+	})
+
+	http.Redirect(w, r, provider.AuthCodeURL(state, challenge), http.StatusTemporaryRedirect)
+}
+
+// oauthCallback finishes the authorization-code flow and signs the user in
+func oauthCallback(w http.ResponseWriter, r *http.Request, providerName string) {
+	provider := auth.GetOAuthProvider(providerName)
+	if provider == nil {
+		http.Redirect(w, r, "/login?error=oauth_unavailable", http.StatusTemporaryRedirect)
+		return
+	}
+
+	query := r.URL.Query()
+	if errParam := query.Get("error"); errParam != "" {
+		log.Printf("⚠️ OAuth %s authorization denied: %s", providerName, errParam)
+		http.Redirect(w, r, "/login?error=oauth_denied", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// The state must match the one issued to this browser
+	state := query.Get("state")
+	cookie, err := r.Cookie(auth.OAuthStateCookieName)
+	clearOAuthCookie(w, auth.OAuthStateCookieName, "/auth/")
+	if err != nil || state == "" || cookie.Value != state {
+		log.Printf("❌ OAuth %s callback with mismatched state", providerName)
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusTemporaryRedirect)
+		return
+	}
+
+	verifier, err := auth.ConsumeOAuthState(state, providerName)
+	if err != nil {
+		log.Printf("❌ OAuth %s state error: %v", providerName, err)
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusTemporaryRedirect)
+		return
+	}
+
+	token, err := provider.Exchange(query.Get("code"), verifier)
+	if err != nil {
+		log.Printf("❌ OAuth %s token exchange failed: %v", providerName, err)
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusTemporaryRedirect)
+		return
+	}
+
+	profile, err := provider.FetchProfile(token.AccessToken)
+	if err != nil {
+		log.Printf("❌ OAuth %s profile fetch failed: %v", providerName, err)
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusTemporaryRedirect)
+		return
+	}
+
+	user, pendingToken, err := auth.CompleteOAuthLogin(provider, profile, token)
+	if err != nil {
+		log.Printf("❌ OAuth %s login failed: %v", providerName, err)
+		if errors.Is(err, auth.ErrOAuthEmailUnverified) {
+			http.Redirect(w, r, "/login?error=oauth_unverified", http.StatusTemporaryRedirect)
+			return
+		}
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// New user: collect the remaining profile fields on the register page
+	if pendingToken != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     auth.OAuthSignupCookieName,
+			Value:    pendingToken,
+			Path:     "/",
+			MaxAge:   int(auth.OAuthSignupDuration.Seconds()),
+			HttpOnly: true,
+			Secure:   auth.SecureCookies(),
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, "/register?oauth="+providerName, http.StatusTemporaryRedirect)
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		if _, err := beginTwoFactorLogin(w, user.ID); err != nil {
+			log.Printf("❌ OAuth %s two-factor login failed: %v", providerName, err)
+			http.Redirect(w, r, "/login?error=oauth_failed", http.StatusTemporaryRedirect)
+			return
+		}
+		http.Redirect(w, r, "/login?twofactor=1", http.StatusTemporaryRedirect)
+		return
+	}
+
+	session, err := startSession(w, r, user.ID)
+	if err != nil {
+		log.Printf("❌ OAuth %s session creation failed: %v", providerName, err)
+		var suspended *auth.SuspendedError
+		if errors.As(err, &suspended) {
+			recordLoginFailure(r, user.Nickname, err)
+			http.Redirect(w, r, "/login?error=suspended", http.StatusTemporaryRedirect)
+			return
+		}
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusTemporaryRedirect)
+		return
+	}
+
+	log.Printf("✅ OAuth %s login successful for user: %s", providerName, user.Nickname)
+	recordAudit(r, user.ID, audit.ActionLogin, audit.TargetSession, session.PublicID,
+		map[string]interface{}{"method": providerName})
+	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+}
+
+// clearOAuthCookie expires one of the short-lived OAuth cookies
+func clearOAuthCookie(w http.ResponseWriter, name, path string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   auth.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
 }
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultPostsPageSize = 20
+	maxPostsPageSize     = 100
+)
+
+// postCursor identifies the last post of a feed page. posts.created_at is
+// stored in UTC, so binding CreatedAt in UTC reproduces the stored value and
+// keyset comparisons in SQL match the column exactly; ID breaks ties between
+// posts created at the same instant.
+type postCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+}
+
+// encodePostCursor turns a cursor into the opaque string handed to clients
+func encodePostCursor(c postCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePostCursor parses a cursor previously produced by encodePostCursor
+func decodePostCursor(s string) (*postCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor encoding: %v", err)
+	}
+
+	var c postCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor payload: %v", err)
+	}
+	if c.CreatedAt.IsZero() || c.ID <= 0 {
+		return nil, fmt.Errorf("incomplete cursor")
+	}
+
+	return &c, nil
+}
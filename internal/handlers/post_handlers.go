@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,22 +10,18 @@ import (
 	"strings"
 	"time"
 
+	"forum/internal/audit"
 	"forum/internal/auth"
+	"forum/internal/blocks"
 	"forum/internal/database"
 	"forum/internal/models"
+	"forum/internal/notifications"
+	"forum/internal/websocket"
 )
 
 // PostHandler handles individual post operations
 func PostHandler(w http.ResponseWriter, r *http.Request) {
-	log.Printf("🚀 PostHandler START - URL: %s", r.URL.Path)
-
-	if r.Method != http.MethodGet {
-		log.Printf("❌ Method not allowed: %s", r.Method)
-		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	log.Printf("📝 PostHandler called with URL: %s", r.URL.Path)
+	log.Printf("🚀 PostHandler START - Method: %s, URL: %s", r.Method, r.URL.Path)
 
 	// Extract path after /api/posts/
 	path := strings.TrimPrefix(r.URL.Path, "/api/posts/")
@@ -32,6 +29,11 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Check if this is a comments request
 	if strings.Contains(path, "/comments") {
+		if r.Method != http.MethodGet {
+			log.Printf("❌ Method not allowed: %s", r.Method)
+			RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 		log.Printf("📝 Detected comments request, delegating to handleCommentsRequest")
 		handleCommentsRequest(w, r, path)
 		return
@@ -44,15 +46,32 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch r.Method {
+	case http.MethodGet:
+		handleGetPost(w, r, postID)
+	case http.MethodPut, http.MethodPatch:
+		// PUT replaces title/content, PATCH only touches the fields sent
+		handleUpdatePost(w, r, postID)
+	case http.MethodDelete:
+		handleDeletePost(w, r, postID)
+	default:
+		log.Printf("❌ Method not allowed: %s", r.Method)
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleGetPost returns a single post with its comments
+func handleGetPost(w http.ResponseWriter, r *http.Request, postID int) {
+	user := auth.GetUserFromSession(r)
+
 	// Get post with comments
-	post, err := getPostWithComments(postID)
+	post, err := getPostWithComments(postID, viewerID(user))
 	if err != nil {
 		RenderError(w, "Post not found", http.StatusNotFound)
 		return
 	}
 
 	// Check if current user liked/disliked this post
-	user := auth.GetUserFromSession(r)
 	if user != nil {
 		userLike, err := getUserLikeStatus(user.ID, &post.ID, nil)
 		if err == nil && userLike != nil {
@@ -73,6 +92,161 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
 	RenderSuccess(w, "Post retrieved successfully", post)
 }
 
+// handleUpdatePost handles post edits by the post author or a moderator
+func handleUpdatePost(w http.ResponseWriter, r *http.Request, postID int) {
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		log.Printf("❌ No user in session for post update")
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	// Get existing post to verify ownership
+	existingPost, err := getPostByID(postID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			RenderError(w, "Post not found", http.StatusNotFound)
+		} else {
+			RenderError(w, "Failed to retrieve post", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !auth.CanModify(user, existingPost.UserID, auth.PermPostEditAny) {
+		RenderError(w, "You can only edit your own posts", http.StatusForbidden)
+		return
+	}
+
+	var req models.PostUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// A full replacement must carry both title and content
+	if r.Method == http.MethodPut && (req.Title == nil || req.Content == nil) {
+		RenderError(w, "Title and content are required", http.StatusBadRequest)
+		return
+	}
+
+	title := existingPost.Title
+	if req.Title != nil {
+		title = strings.TrimSpace(*req.Title)
+	}
+	content := existingPost.Content
+	if req.Content != nil {
+		content = strings.TrimSpace(*req.Content)
+	}
+	imagePath := existingPost.ImagePath
+	if req.ImagePath != nil {
+		imagePath = req.ImagePath
+	}
+
+	// Validate input
+	if title == "" || content == "" {
+		RenderError(w, "Title and content cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		RenderError(w, "Failed to update post", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE posts SET title = ?, content = ?, image_path = ?, updated_at = ? WHERE id = ?
+	`, title, content, imagePath, time.Now(), postID)
+	if err != nil {
+		log.Printf("❌ Failed to update post %d: %v", postID, err)
+		RenderError(w, "Failed to update post", http.StatusInternalServerError)
+		return
+	}
+
+	// Rewrite categories only when the client sent a new set
+	if req.Categories != nil {
+		if _, err := tx.Exec(`DELETE FROM post_categories WHERE post_id = ?`, postID); err != nil {
+			RenderError(w, "Failed to update post categories", http.StatusInternalServerError)
+			return
+		}
+		for _, category := range *req.Categories {
+			if category == "" {
+				continue
+			}
+			_, err := tx.Exec(`
+				INSERT INTO post_categories (post_id, category)
+				VALUES (?, ?)
+			`, postID, category)
+			if err != nil {
+				RenderError(w, "Failed to update post categories", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		RenderError(w, "Failed to update post", http.StatusInternalServerError)
+		return
+	}
+
+	// Get updated post
+	updatedPost, err := getPostByID(postID)
+	if err != nil {
+		RenderError(w, "Failed to retrieve updated post", http.StatusInternalServerError)
+		return
+	}
+
+	// Let open clients refresh the post in place
+	websocket.BroadcastPostUpdated(updatedPost)
+
+	log.Printf("✅ Post %d updated by %s", postID, user.Nickname)
+	if user.ID != existingPost.UserID {
+		recordAudit(r, user.ID, audit.ActionPostEdited, audit.TargetPost, strconv.Itoa(postID),
+			map[string]interface{}{"authorId": existingPost.UserID})
+	}
+	RenderSuccess(w, "Post updated successfully", updatedPost)
+}
+
+// handleDeletePost handles post deletion by the post author or a moderator
+func handleDeletePost(w http.ResponseWriter, r *http.Request, postID int) {
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		log.Printf("❌ No user in session for post deletion")
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	// Get existing post to verify ownership
+	existingPost, err := getPostByID(postID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			RenderError(w, "Post not found", http.StatusNotFound)
+		} else {
+			RenderError(w, "Failed to retrieve post", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !auth.CanModify(user, existingPost.UserID, auth.PermPostDeleteAny) {
+		RenderError(w, "You can only delete your own posts", http.StatusForbidden)
+		return
+	}
+
+	if err := deletePost(postID); err != nil {
+		log.Printf("❌ Failed to delete post %d: %v", postID, err)
+		RenderError(w, "Failed to delete post", http.StatusInternalServerError)
+		return
+	}
+
+	websocket.BroadcastPostDeleted(postID)
+
+	log.Printf("✅ Post %d deleted by %s", postID, user.Nickname)
+	recordAudit(r, user.ID, audit.ActionPostDeleted, audit.TargetPost, strconv.Itoa(postID),
+		map[string]interface{}{"authorId": existingPost.UserID, "title": existingPost.Title})
+	RenderSuccess(w, "Post deleted successfully", nil)
+}
+
 // CommentHandler handles comment operations (create, update, delete)
 func CommentHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🚀 CommentHandler - Method: %s, URL: %s", r.Method, r.URL.Path)
@@ -112,6 +286,9 @@ func handleCreateComment(w http.ResponseWriter, r *http.Request) {
 		RenderError(w, "Authentication required", http.StatusUnauthorized)
 		return
 	}
+	if !requireVerifiedEmail(w, user) {
+		return
+	}
 
 	var req models.CommentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -125,6 +302,16 @@ func handleCreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hidden posts and comments take no new replies
+	if err := findVisibleTarget(&req.PostID, req.ParentID); err != nil {
+		if err == sql.ErrNoRows {
+			RenderError(w, "Post or comment not found", http.StatusNotFound)
+		} else {
+			RenderError(w, "Failed to retrieve post", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	// Insert comment
 	result, err := database.DB.Exec(`
 		INSERT INTO comments (post_id, user_id, parent_id, content, created_at)
@@ -145,10 +332,12 @@ func handleCreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	notifications.NotifyCommentCreated(user, comment)
+
 	RenderSuccess(w, "Comment created successfully", comment)
 }
 
-// handleUpdateComment handles comment updates
+// handleUpdateComment handles comment edits by the comment author or a moderator
 func handleUpdateComment(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🔄 handleUpdateComment - URL: %s", r.URL.Path)
 
@@ -186,8 +375,7 @@ func handleUpdateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user owns the comment
-	if existingComment.UserID != user.ID {
+	if !auth.CanModify(user, existingComment.UserID, auth.PermCommentEditAny) {
 		RenderError(w, "You can only edit your own comments", http.StatusForbidden)
 		return
 	}
@@ -224,10 +412,15 @@ func handleUpdateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.ID != existingComment.UserID {
+		recordAudit(r, user.ID, audit.ActionCommentEdited, audit.TargetComment, strconv.Itoa(commentID),
+			map[string]interface{}{"authorId": existingComment.UserID, "postId": existingComment.PostID})
+	}
+
 	RenderSuccess(w, "Comment updated successfully", updatedComment)
 }
 
-// handleDeleteComment handles comment deletion
+// handleDeleteComment handles comment deletion by the comment author or a moderator
 func handleDeleteComment(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🗑️ handleDeleteComment - URL: %s", r.URL.Path)
 
@@ -262,26 +455,20 @@ func handleDeleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user owns the comment
-	if existingComment.UserID != user.ID {
+	if !auth.CanModify(user, existingComment.UserID, auth.PermCommentDeleteAny) {
 		RenderError(w, "You can only delete your own comments", http.StatusForbidden)
 		return
 	}
 
-	// Delete comment likes first (foreign key constraint)
-	_, err = database.DB.Exec(`DELETE FROM likes WHERE comment_id = ?`, commentID)
-	if err != nil {
-		RenderError(w, "Failed to delete comment likes", http.StatusInternalServerError)
-		return
-	}
-
-	// Delete comment
-	_, err = database.DB.Exec(`DELETE FROM comments WHERE id = ?`, commentID)
-	if err != nil {
+	if err := deleteComment(commentID); err != nil {
+		log.Printf("❌ Failed to delete comment %d: %v", commentID, err)
 		RenderError(w, "Failed to delete comment", http.StatusInternalServerError)
 		return
 	}
 
+	recordAudit(r, user.ID, audit.ActionCommentDeleted, audit.TargetComment, strconv.Itoa(commentID),
+		map[string]interface{}{"authorId": existingComment.UserID, "postId": existingComment.PostID})
+
 	RenderSuccess(w, "Comment deleted successfully", nil)
 }
 
@@ -309,7 +496,8 @@ func handleCommentsRequest(w http.ResponseWriter, r *http.Request, path string)
 	log.Printf("📝 Getting comments for post ID: %d", postID)
 
 	// Get comments for the post
-	comments, err := getPostComments(postID)
+	user := auth.GetUserFromSession(r)
+	comments, err := getPostComments(postID, viewerID(user))
 	if err != nil {
 		log.Printf("❌ Failed to get comments for post %d: %v", postID, err)
 		RenderError(w, "Failed to retrieve comments", http.StatusInternalServerError)
@@ -319,7 +507,6 @@ func handleCommentsRequest(w http.ResponseWriter, r *http.Request, path string)
 	log.Printf("✅ Found %d comments for post %d", len(comments), postID)
 
 	// Check if current user liked/disliked comments
-	user := auth.GetUserFromSession(r)
 	if user != nil {
 		for i := range comments {
 			commentLike, err := getUserLikeStatus(user.ID, nil, &comments[i].ID)
@@ -358,7 +545,8 @@ func CommentsHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("📝 Getting comments for post ID: %d", postID)
 
 	// Get comments for the post
-	comments, err := getPostComments(postID)
+	user := auth.GetUserFromSession(r)
+	comments, err := getPostComments(postID, viewerID(user))
 	if err != nil {
 		log.Printf("❌ Failed to get comments for post %d: %v", postID, err)
 		RenderError(w, "Failed to retrieve comments", http.StatusInternalServerError)
@@ -368,7 +556,6 @@ func CommentsHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("✅ Found %d comments for post %d", len(comments), postID)
 
 	// Check if current user liked/disliked comments
-	user := auth.GetUserFromSession(r)
 	if user != nil {
 		for i := range comments {
 			commentLike, err := getUserLikeStatus(user.ID, nil, &comments[i].ID)
@@ -414,6 +601,16 @@ func LikeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hidden posts and comments cannot be liked
+	if err := findVisibleTarget(req.PostID, req.CommentID); err != nil {
+		if err == sql.ErrNoRows {
+			RenderError(w, "Post or comment not found", http.StatusNotFound)
+		} else {
+			RenderError(w, "Failed to retrieve post", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	// Check if user already liked/disliked this item
 	var existingLike *models.Like
 	var err error
@@ -429,6 +626,9 @@ func LikeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only a new like (not a dislike or an undo) notifies the author
+	notifyAuthor := req.IsLike && (existingLike == nil || !existingLike.IsLike)
+
 	if existingLike != nil {
 		// If clicking the same action (like->like or dislike->dislike), remove the like
 		if existingLike.IsLike == req.IsLike {
@@ -452,6 +652,10 @@ func LikeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notifyAuthor {
+		notifications.NotifyLiked(user, req.PostID, req.CommentID)
+	}
+
 	// Return updated counts
 	var likeCount, dislikeCount int
 	if req.PostID != nil {
@@ -518,8 +722,8 @@ func CategoriesHandler(w http.ResponseWriter, r *http.Request) {
 
 // Helper functions for post handlers
 
-// getPostWithComments gets a post with its comments
-func getPostWithComments(postID int) (*models.Post, error) {
+// getPostWithComments gets a post with the comments viewerID can see
+func getPostWithComments(postID int, viewerID string) (*models.Post, error) {
 	// Get the post
 	post, err := getPostByID(postID)
 	if err != nil {
@@ -527,7 +731,7 @@ func getPostWithComments(postID int) (*models.Post, error) {
 	}
 
 	// Get comments for the post
-	comments, err := getPostComments(postID)
+	comments, err := getPostComments(postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -536,8 +740,9 @@ func getPostWithComments(postID int) (*models.Post, error) {
 	return post, nil
 }
 
-// getPostComments gets all comments for a post
-func getPostComments(postID int) ([]models.Comment, error) {
+// getPostComments gets the comments of a post that are not hidden, leaving
+// out those by users viewerID has blocked
+func getPostComments(postID int, viewerID string) ([]models.Comment, error) {
 	rows, err := database.DB.Query(`
 		SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.created_at,
 		       u.nickname, u.avatar_url,
@@ -553,9 +758,10 @@ func getPostComments(postID int) ([]models.Comment, error) {
 			WHERE comment_id IS NOT NULL
 			GROUP BY comment_id
 		) like_counts ON c.id = like_counts.comment_id
-		WHERE c.post_id = ?
+		WHERE c.post_id = ? AND c.hidden_at IS NULL
+		  AND c.user_id NOT IN (`+blocks.BlockedIDs+`)
 		ORDER BY c.created_at ASC
-	`, postID)
+	`, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -578,7 +784,90 @@ func getPostComments(postID int) ([]models.Comment, error) {
 	return comments, nil
 }
 
-// getCommentByID gets a comment by ID
+// deletePost removes a post together with its comments, likes and categories.
+// Dependent rows are deleted explicitly because foreign key enforcement is
+// per connection in SQLite and cannot be relied on for cascades.
+func deletePost(postID int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DELETE FROM notifications WHERE post_id = ?`,
+		`DELETE FROM likes WHERE comment_id IN (SELECT id FROM comments WHERE post_id = ?)`,
+		`DELETE FROM likes WHERE post_id = ?`,
+		`DELETE FROM comments WHERE post_id = ?`,
+		`DELETE FROM post_categories WHERE post_id = ?`,
+		`DELETE FROM posts WHERE id = ?`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, postID); err != nil {
+			return fmt.Errorf("failed to delete post: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// commentSubtree selects the IDs of the comment bound to its parameter and
+// of every reply below it
+const commentSubtree = `
+	WITH RECURSIVE subtree(id) AS (
+		SELECT ?
+		UNION ALL
+		SELECT c.id FROM comments c JOIN subtree s ON c.parent_id = s.id
+	)`
+
+// deleteComment deletes a comment with its replies and their likes and
+// notifications in one transaction. Dependent rows are deleted explicitly
+// since foreign key cascades are not enabled on every pooled connection.
+func deleteComment(commentID int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		commentSubtree + ` DELETE FROM notifications WHERE comment_id IN (SELECT id FROM subtree)`,
+		commentSubtree + ` DELETE FROM likes WHERE comment_id IN (SELECT id FROM subtree)`,
+		commentSubtree + ` DELETE FROM comments WHERE id IN (SELECT id FROM subtree)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, commentID); err != nil {
+			return fmt.Errorf("failed to delete comment: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// findVisibleTarget checks that a post and a comment, whichever are set,
+// exist and are not hidden by a moderator, nor is the comment's post. It
+// returns sql.ErrNoRows otherwise.
+func findVisibleTarget(postID, commentID *int) error {
+	if commentID != nil {
+		comment, err := getCommentByID(*commentID)
+		if err != nil {
+			return err
+		}
+		if postID != nil && *postID != comment.PostID {
+			return sql.ErrNoRows
+		}
+		postID = &comment.PostID
+	}
+	if postID == nil {
+		return nil
+	}
+
+	_, err := getPostByID(*postID)
+	return err
+}
+
+// getCommentByID gets a comment by ID. Comments hidden by a moderator are
+// not found.
 func getCommentByID(commentID int) (*models.Comment, error) {
 	var comment models.Comment
 	err := database.DB.QueryRow(`
@@ -596,7 +885,7 @@ func getCommentByID(commentID int) (*models.Comment, error) {
 			WHERE comment_id IS NOT NULL
 			GROUP BY comment_id
 		) like_counts ON c.id = like_counts.comment_id
-		WHERE c.id = ?
+		WHERE c.id = ? AND c.hidden_at IS NULL
 	`, commentID).Scan(
 		&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentID, &comment.Content, &comment.CreatedAt,
 		&comment.Author, &comment.AuthorAvatar,
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/config"
+	"forum/internal/ratelimit"
+)
+
+// trustForwardedFor is set from config.RateLimitConfig by InitializeRateLimits
+var trustForwardedFor bool
+
+// InitializeRateLimits sets up the limiters used by RateLimit
+func InitializeRateLimits(cfg config.RateLimitConfig) {
+	trustForwardedFor = cfg.TrustForwardedFor
+	ratelimit.Initialize(cfg)
+}
+
+// RateLimit applies the named rate limit policy to requests that change
+// state. Reads pass through unthrottled. Every request is limited per client
+// IP, and authenticated requests per user as well, so neither signing in
+// nor spreading requests over accounts gets around the limit.
+func RateLimit(policy string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnly(r.Method) {
+			next(w, r)
+			return
+		}
+
+		for _, key := range rateLimitKeys(r) {
+			if allowed, retryAfter := ratelimit.Allow(policy, key); !allowed {
+				renderTooManyRequests(w, "Too many requests, please slow down", retryAfter)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// renderTooManyRequests renders a 429 response telling the client when to retry
+func renderTooManyRequests(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter)))
+	RenderError(w, message, http.StatusTooManyRequests)
+}
+
+// rateLimitKeys returns the limiter keys a request counts against: its
+// client IP, plus its user when it has a session
+func rateLimitKeys(r *http.Request) []string {
+	keys := []string{ratelimit.IPKey(clientIP(r))}
+	if session, err := auth.GetSessionFromRequest(r); err == nil && session != nil {
+		keys = append(keys, ratelimit.UserKey(session.UserID))
+	}
+	return keys
+}
+
+// clientIP returns the IP address of the client that sent the request
+func clientIP(r *http.Request) string {
+	if trustForwardedFor {
+		// The proxy appends the address it received the request from
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
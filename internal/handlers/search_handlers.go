@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/blocks"
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// snippet() wraps matches in these control characters so the surrounding
+// text can be HTML-escaped before the <mark> tags are put in place
+const (
+	snippetMatchStart = "\x01"
+	snippetMatchEnd   = "\x02"
+)
+
+// searchFilters holds the optional filters accepted by the search endpoint
+type searchFilters struct {
+	Category string
+	Author   string
+	From     string
+	To       string
+	Limit    int
+
+	// Content by users this viewer blocked is left out
+	ViewerID string
+}
+
+// SearchHandler handles full-text search across posts, comments and users
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !database.SearchEnabled {
+		RenderError(w, "Search is not available on this server", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		RenderError(w, "Search query is required", http.StatusBadRequest)
+		return
+	}
+
+	match := buildMatchQuery(q)
+	if match == "" {
+		RenderError(w, "Search query must contain at least one word", http.StatusBadRequest)
+		return
+	}
+
+	filters := searchFilters{
+		Category: query.Get("category"),
+		Author:   query.Get("author"),
+		Limit:    defaultSearchLimit,
+		ViewerID: viewerID(auth.GetUserFromSession(r)),
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			RenderError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if l > maxSearchLimit {
+			l = maxSearchLimit
+		}
+		filters.Limit = l
+	}
+
+	if fromStr := query.Get("from"); fromStr != "" {
+		from, _, err := parseSearchDate(fromStr)
+		if err != nil {
+			RenderError(w, "Invalid 'from' date, use YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+			return
+		}
+		filters.From = from.UTC().Format("2006-01-02 15:04:05")
+	}
+
+	if toStr := query.Get("to"); toStr != "" {
+		to, dateOnly, err := parseSearchDate(toStr)
+		if err != nil {
+			RenderError(w, "Invalid 'to' date, use YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+			return
+		}
+		// A bare date includes the whole day
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filters.To = to.UTC().Format("2006-01-02 15:04:05")
+	}
+
+	// Restrict to specific result types, e.g. ?type=posts,users
+	types := map[string]bool{"posts": true, "comments": true, "users": true}
+	if typeStr := query.Get("type"); typeStr != "" {
+		types = map[string]bool{}
+		for _, t := range strings.Split(typeStr, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	}
+
+	results := models.SearchResults{
+		Query:    q,
+		Posts:    []models.PostSearchResult{},
+		Comments: []models.CommentSearchResult{},
+		Users:    []models.UserSearchResult{},
+	}
+
+	var err error
+	if types["posts"] {
+		if results.Posts, err = searchPosts(match, filters); err != nil {
+			log.Printf("❌ Post search failed for %q: %v", q, err)
+			RenderError(w, "Failed to search posts", http.StatusInternalServerError)
+			return
+		}
+	}
+	if types["comments"] {
+		if results.Comments, err = searchComments(match, filters); err != nil {
+			log.Printf("❌ Comment search failed for %q: %v", q, err)
+			RenderError(w, "Failed to search comments", http.StatusInternalServerError)
+			return
+		}
+	}
+	// Category, author and date filters only apply to content, not to users
+	if types["users"] && filters.Category == "" && filters.Author == "" {
+		if results.Users, err = searchUsers(match, filters); err != nil {
+			log.Printf("❌ User search failed for %q: %v", q, err)
+			RenderError(w, "Failed to search users", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	RenderSuccess(w, "Search completed successfully", results)
+}
+
+// searchPosts runs the FTS query against post titles and content
+func searchPosts(match string, filters searchFilters) ([]models.PostSearchResult, error) {
+	query := `
+		SELECT p.id, p.title,
+		       snippet(posts_fts, 0, char(1), char(2), '…', 12),
+		       snippet(posts_fts, 1, char(1), char(2), '…', 24),
+		       p.user_id, u.nickname, p.created_at,
+		       bm25(posts_fts, 10.0, 1.0) as score
+		FROM posts_fts
+		JOIN posts p ON p.id = posts_fts.rowid
+		JOIN users u ON p.user_id = u.id
+		WHERE posts_fts MATCH ? AND p.hidden_at IS NULL
+		  AND p.user_id NOT IN (` + blocks.BlockedIDs + `)
+	`
+	args := []interface{}{match, filters.ViewerID}
+
+	if filters.Category != "" {
+		query += ` AND p.id IN (SELECT post_id FROM post_categories WHERE category = ?)`
+		args = append(args, filters.Category)
+	}
+	if filters.Author != "" {
+		query += ` AND u.nickname = ?`
+		args = append(args, filters.Author)
+	}
+	if filters.From != "" {
+		query += ` AND datetime(p.created_at) >= datetime(?)`
+		args = append(args, filters.From)
+	}
+	if filters.To != "" {
+		query += ` AND datetime(p.created_at) < datetime(?)`
+		args = append(args, filters.To)
+	}
+
+	query += ` ORDER BY score ASC LIMIT ?`
+	args = append(args, filters.Limit)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts: %v", err)
+	}
+	defer rows.Close()
+
+	results := []models.PostSearchResult{}
+	for rows.Next() {
+		var result models.PostSearchResult
+		var score float64
+		if err := rows.Scan(
+			&result.ID, &result.Title, &result.TitleSnippet, &result.ContentSnippet,
+			&result.UserID, &result.Author, &result.CreatedAt, &score,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan post: %v", err)
+		}
+		result.TitleSnippet = highlightSnippet(result.TitleSnippet)
+		result.ContentSnippet = highlightSnippet(result.ContentSnippet)
+		result.Rank = -score
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	postIDs := make([]int, len(results))
+	for i := range results {
+		postIDs[i] = results[i].ID
+	}
+	categories, err := getCategoriesForPosts(postIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load categories: %v", err)
+	}
+	for i := range results {
+		results[i].Categories = categories[results[i].ID]
+	}
+
+	return results, nil
+}
+
+// searchComments runs the FTS query against comment content
+func searchComments(match string, filters searchFilters) ([]models.CommentSearchResult, error) {
+	query := `
+		SELECT c.id, c.post_id, p.title,
+		       snippet(comments_fts, 0, char(1), char(2), '…', 24),
+		       c.user_id, u.nickname, c.created_at,
+		       bm25(comments_fts) as score
+		FROM comments_fts
+		JOIN comments c ON c.id = comments_fts.rowid
+		JOIN posts p ON c.post_id = p.id
+		JOIN users u ON c.user_id = u.id
+		WHERE comments_fts MATCH ? AND c.hidden_at IS NULL AND p.hidden_at IS NULL
+		  AND c.user_id NOT IN (` + blocks.BlockedIDs + `)
+	`
+	args := []interface{}{match, filters.ViewerID}
+
+	if filters.Category != "" {
+		query += ` AND c.post_id IN (SELECT post_id FROM post_categories WHERE category = ?)`
+		args = append(args, filters.Category)
+	}
+	if filters.Author != "" {
+		query += ` AND u.nickname = ?`
+		args = append(args, filters.Author)
+	}
+	if filters.From != "" {
+		query += ` AND datetime(c.created_at) >= datetime(?)`
+		args = append(args, filters.From)
+	}
+	if filters.To != "" {
+		query += ` AND datetime(c.created_at) < datetime(?)`
+		args = append(args, filters.To)
+	}
+
+	query += ` ORDER BY score ASC LIMIT ?`
+	args = append(args, filters.Limit)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %v", err)
+	}
+	defer rows.Close()
+
+	results := []models.CommentSearchResult{}
+	for rows.Next() {
+		var result models.CommentSearchResult
+		var score float64
+		if err := rows.Scan(
+			&result.ID, &result.PostID, &result.PostTitle, &result.Snippet,
+			&result.UserID, &result.Author, &result.CreatedAt, &score,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %v", err)
+		}
+		result.Snippet = highlightSnippet(result.Snippet)
+		result.Rank = -score
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// searchUsers runs the FTS query against user nicknames
+func searchUsers(match string, filters searchFilters) ([]models.UserSearchResult, error) {
+	rows, err := database.DB.Query(`
+		SELECT u.id, u.nickname,
+		       highlight(users_fts, 0, char(1), char(2)),
+		       u.avatar_url,
+		       bm25(users_fts) as score
+		FROM users_fts
+		JOIN users u ON u.id = users_fts.user_id
+		WHERE users_fts MATCH ?
+		  AND u.id NOT IN (`+blocks.BlockedIDs+`)
+		ORDER BY score ASC
+		LIMIT ?
+	`, match, filters.ViewerID, filters.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %v", err)
+	}
+	defer rows.Close()
+
+	results := []models.UserSearchResult{}
+	for rows.Next() {
+		var result models.UserSearchResult
+		var score float64
+		if err := rows.Scan(&result.ID, &result.Nickname, &result.NicknameSnippet, &result.AvatarURL, &score); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %v", err)
+		}
+		result.NicknameSnippet = highlightSnippet(result.NicknameSnippet)
+		result.Rank = -score
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// buildMatchQuery turns free-form user input into a safe FTS5 MATCH
+// expression: every word is quoted (so FTS operators in the input are taken
+// literally) and prefix-matched, and all words must be present
+func buildMatchQuery(input string) string {
+	var terms []string
+	for _, word := range strings.Fields(input) {
+		word = strings.ReplaceAll(word, `"`, `""`)
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// highlightSnippet HTML-escapes a snippet and turns the match markers into <mark> tags
+func highlightSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, snippetMatchStart, "<mark>")
+	return strings.ReplaceAll(escaped, snippetMatchEnd, "</mark>")
+}
+
+// parseSearchDate accepts either a date (YYYY-MM-DD) or an RFC 3339 timestamp
+// and reports whether the input was a bare date
+func parseSearchDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
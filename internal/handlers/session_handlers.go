@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+	"forum/internal/models"
+	"forum/internal/websocket"
+)
+
+// SessionsHandler lists the current user's sessions, one per signed-in device
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := requireSession(w, r)
+	if session == nil {
+		return
+	}
+
+	sessions, err := auth.ListSessions(session.UserID, session.ID)
+	if err != nil {
+		log.Printf("❌ Failed to list sessions for %s: %v", session.UserID, err)
+		RenderError(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+
+	RenderSuccess(w, "Sessions retrieved successfully", sessions)
+}
+
+// SessionHandler revokes sessions. DELETE /api/sessions/{id} signs out one
+// device; DELETE /api/sessions/others signs out every device but this one.
+// Websockets opened with a revoked session are closed right away.
+func SessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := requireSession(w, r)
+	if session == nil {
+		return
+	}
+
+	publicID := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
+	if publicID == "" || strings.Contains(publicID, "/") {
+		RenderError(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	if publicID == "others" {
+		revoked, err := auth.RevokeOtherSessions(session.UserID, session.ID)
+		if err != nil {
+			log.Printf("❌ Failed to revoke sessions for %s: %v", session.UserID, err)
+			RenderError(w, "Failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+
+		websocket.GetHub().CloseSessions(revoked...)
+		log.Printf("🔒 User %s signed out %d other sessions", session.UserID, len(revoked))
+		if len(revoked) > 0 {
+			recordAudit(r, session.UserID, audit.ActionSessionRevoked, audit.TargetUser, session.UserID,
+				map[string]interface{}{"sessions": revoked})
+		}
+		RenderSuccess(w, "Signed out of all other sessions", map[string]int{"revoked": len(revoked)})
+		return
+	}
+
+	if err := auth.RevokeSession(session.UserID, publicID); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			RenderError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("❌ Failed to revoke session for %s: %v", session.UserID, err)
+		RenderError(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(r, session.UserID, audit.ActionSessionRevoked, audit.TargetSession, publicID, nil)
+
+	websocket.GetHub().CloseSessions(publicID)
+	if publicID == session.PublicID {
+		// Revoking the current session signs this device out
+		auth.ClearSessionCookie(w)
+	}
+
+	RenderSuccess(w, "Session revoked", nil)
+}
+
+// startSession signs the user in on a new session and sets its cookie. A
+// session the request already carried is ended first, so a session ID known
+// to someone else before sign-in never gains the user's privileges.
+func startSession(w http.ResponseWriter, r *http.Request, userID string) (*models.Session, error) {
+	if previous, err := auth.GetSessionFromRequest(r); err == nil && previous != nil {
+		if err := auth.DeleteSession(previous.ID); err != nil {
+			log.Printf("⚠️ Failed to end previous session: %v", err)
+		} else {
+			websocket.GetHub().CloseSessions(previous.PublicID)
+		}
+	}
+
+	session, err := auth.CreateSession(userID, r.UserAgent(), clientIP(r))
+	if err != nil {
+		return nil, err
+	}
+
+	auth.SetSessionCookie(w, session.ID)
+	return session, nil
+}
+
+// requireSession returns the request's session, or renders 401 and returns
+// nil when there is none
+func requireSession(w http.ResponseWriter, r *http.Request) *models.Session {
+	session, err := auth.GetSessionFromRequest(r)
+	if err != nil || session == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return nil
+	}
+	return session
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+	"forum/internal/models"
+	"forum/internal/moderation"
+	"forum/internal/websocket"
+)
+
+// SuspensionsHandler lists the suspensions in force (GET) and suspends or
+// bans a user named by nickname or email (POST). Bans need the user.ban
+// permission.
+func SuspensionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		suspensions, err := auth.ListSuspensions()
+		if err != nil {
+			log.Printf("❌ %v", err)
+			RenderError(w, "Failed to list suspensions", http.StatusInternalServerError)
+			return
+		}
+		RenderSuccess(w, "Suspensions retrieved", suspensions)
+	case http.MethodPost:
+		suspendUserHandler(w, r)
+	default:
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// suspendUserHandler suspends a user, then tells their clients and
+// disconnects them
+func suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	moderator := auth.GetUserFromSession(r)
+	if moderator == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.SuspendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Days < 0 || req.Days > moderation.MaxSuspendDays {
+		RenderError(w, fmt.Sprintf("Days must be between 1 and %d, or 0 for a ban", moderation.MaxSuspendDays), http.StatusBadRequest)
+		return
+	}
+	if req.Days == 0 && !auth.HasPermission(moderator, auth.PermUserBan) {
+		RenderError(w, "You do not have permission to ban users", http.StatusForbidden)
+		return
+	}
+
+	target, err := auth.GetUserByEmailOrNickname(strings.TrimSpace(req.User))
+	if err != nil {
+		RenderError(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+	if target == nil {
+		RenderError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	suspension, err := auth.SuspendUser(target.ID, moderator.ID, req.Reason, time.Duration(req.Days)*24*time.Hour)
+	if err != nil {
+		renderSuspensionError(w, err)
+		return
+	}
+
+	websocket.DisconnectSuspendedUser(suspension)
+
+	log.Printf("⛔ %s suspended %s for %d days (0 is a ban)", moderator.Nickname, target.Nickname, req.Days)
+	recordAudit(r, moderator.ID, audit.ActionUserSuspended, audit.TargetUser, target.ID,
+		map[string]interface{}{"suspensionId": suspension.ID, "days": req.Days, "reason": suspension.Reason})
+	RenderSuccess(w, "User suspended", suspension)
+}
+
+// SuspensionHandler lifts the suspensions of a user (DELETE /{userID}).
+// Lifting a ban needs the user.ban permission.
+func SuspensionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	moderator := auth.GetUserFromSession(r)
+	if moderator == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	userID := strings.TrimPrefix(r.URL.Path, "/api/moderation/suspensions/")
+	if userID == "" || strings.Contains(userID, "/") {
+		RenderError(w, "Invalid suspension path", http.StatusBadRequest)
+		return
+	}
+
+	suspension, err := auth.GetActiveSuspension(userID)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		RenderError(w, "Failed to lift suspension", http.StatusInternalServerError)
+		return
+	}
+	if suspension == nil {
+		RenderError(w, "User is not suspended", http.StatusNotFound)
+		return
+	}
+	if suspension.ExpiresAt == nil && !auth.HasPermission(moderator, auth.PermUserBan) {
+		RenderError(w, "You do not have permission to lift bans", http.StatusForbidden)
+		return
+	}
+
+	if err := auth.LiftSuspension(userID, moderator.ID); err != nil {
+		renderSuspensionError(w, err)
+		return
+	}
+
+	log.Printf("✅ %s lifted the suspension of %s", moderator.Nickname, suspension.Nickname)
+	recordAudit(r, moderator.ID, audit.ActionSuspensionLifted, audit.TargetUser, userID,
+		map[string]interface{}{"ban": suspension.ExpiresAt == nil})
+	RenderSuccess(w, "Suspension lifted", nil)
+}
+
+// renderSuspensionError maps errors from suspending users to HTTP responses
+func renderSuspensionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		RenderError(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, auth.ErrNotSuspended):
+		RenderError(w, "User is not suspended", http.StatusNotFound)
+	case errors.Is(err, auth.ErrCannotSuspendStaff):
+		RenderError(w, "Moderators and admins cannot be suspended", http.StatusForbidden)
+	case errors.Is(err, auth.ErrSuspensionReason), errors.Is(err, auth.ErrSuspensionReasonLength):
+		RenderError(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("❌ %v", err)
+		RenderError(w, "Failed to suspend user", http.StatusInternalServerError)
+	}
+}
+
+// renderSuspended refuses a sign-in by a suspended user, telling them why
+// and for how long. It reports whether err was a SuspendedError.
+func renderSuspended(w http.ResponseWriter, err error) bool {
+	var suspended *auth.SuspendedError
+	if !errors.As(err, &suspended) {
+		return false
+	}
+
+	suspension := suspended.Suspension
+	if suspension.ExpiresAt == nil {
+		RenderError(w, "Your account has been banned: "+suspension.Reason, http.StatusForbidden)
+	} else {
+		RenderError(w, fmt.Sprintf("Your account is suspended until %s: %s",
+			suspension.ExpiresAt.Format("January 2, 2006 15:04 MST"), suspension.Reason), http.StatusForbidden)
+	}
+	return true
+}
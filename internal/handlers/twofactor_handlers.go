@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+	"forum/internal/models"
+)
+
+// LoginTwoFactorHandler completes a login that needs a second factor. The
+// challenge set by LoginHandler or the OAuth callback is read from its
+// cookie, and the code may be from an authenticator app or a recovery code.
+func LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(auth.TwoFactorCookieName)
+	if err != nil {
+		RenderError(w, "Sign-in expired, please sign in again", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := auth.CompleteTwoFactorLogin(cookie.Value, req.Code)
+	if err != nil {
+		var locked *auth.LockedError
+		switch {
+		case errors.As(err, &locked):
+			recordLoginFailure(r, "", err)
+			clearTwoFactorCookie(w)
+			renderTooManyRequests(w, "Too many failed login attempts, please try again later", locked.RetryAfter())
+		case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTwoFactorNotEnabled):
+			clearTwoFactorCookie(w)
+			RenderError(w, "Sign-in expired, please sign in again", http.StatusUnauthorized)
+		case errors.Is(err, auth.ErrInvalidCode):
+			recordLoginFailure(r, "", err)
+			RenderError(w, "Invalid authentication code", http.StatusUnauthorized)
+		default:
+			log.Printf("❌ Two-factor login failed: %v", err)
+			RenderError(w, "Failed to sign in", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	clearTwoFactorCookie(w)
+	session, err := startSession(w, r, user.ID)
+	if err != nil {
+		if renderSuspended(w, err) {
+			return
+		}
+		RenderError(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Login successful for user: %s (two-factor)", user.Nickname)
+	recordAudit(r, user.ID, audit.ActionLogin, audit.TargetSession, session.PublicID,
+		map[string]interface{}{"twoFactor": true})
+	RenderSuccess(w, "Login successful", auth.NewSessionUser(user, session.CSRFToken))
+}
+
+// TwoFactorStatusHandler reports whether the current user has two-factor
+// authentication enabled and how many recovery codes they have left
+func TwoFactorStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := requireSession(w, r)
+	if session == nil {
+		return
+	}
+
+	status, err := auth.GetTwoFactorStatus(session.UserID)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		RenderError(w, "Failed to get two-factor status", http.StatusInternalServerError)
+		return
+	}
+
+	RenderSuccess(w, "Two-factor status retrieved", status)
+}
+
+// TwoFactorSetupHandler starts enrollment, returning the secret and otpauth
+// URI for the user's authenticator app. Requires the current password.
+func TwoFactorSetupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := auth.GetUserFromSession(r)
+	if user == nil {
+		RenderError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.TwoFactorConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	setup, err := auth.BeginTwoFactorSetup(user, req.Password)
+	if err != nil {
+		renderTwoFactorError(w, err, "Failed to start two-factor setup")
+		return
+	}
+
+	RenderSuccess(w, "Scan the code with your authenticator app, then confirm a code", setup)
+}
+
+// TwoFactorEnableHandler confirms enrollment with a code from the
+// authenticator app and returns the user's recovery codes
+func TwoFactorEnableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := requireSession(w, r)
+	if session == nil {
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := auth.EnableTwoFactor(session.UserID, req.Code)
+	if err != nil {
+		renderTwoFactorError(w, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	if err := auth.RotateSession(w, session); err != nil {
+		log.Printf("⚠️ Failed to rotate session of %s: %v", session.UserID, err)
+	}
+
+	recordAudit(r, session.UserID, audit.ActionTwoFactorEnabled, audit.TargetUser, session.UserID, nil)
+	RenderSuccess(w, "Two-factor authentication enabled", &models.RecoveryCodes{Codes: codes})
+}
+
+// TwoFactorDisableHandler turns off two-factor authentication. Requires the
+// current password and a code.
+func TwoFactorDisableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := requireSession(w, r)
+	if session == nil {
+		return
+	}
+
+	var req models.TwoFactorConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := auth.DisableTwoFactor(session.UserID, req.Password, req.Code); err != nil {
+		renderTwoFactorError(w, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	recordAudit(r, session.UserID, audit.ActionTwoFactorDisabled, audit.TargetUser, session.UserID, nil)
+	RenderSuccess(w, "Two-factor authentication disabled", nil)
+}
+
+// RecoveryCodesHandler replaces the user's recovery codes. Requires the
+// current password and a code.
+func RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RenderError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := requireSession(w, r)
+	if session == nil {
+		return
+	}
+
+	var req models.TwoFactorConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RenderError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := auth.RegenerateRecoveryCodes(session.UserID, req.Password, req.Code)
+	if err != nil {
+		renderTwoFactorError(w, err, "Failed to generate recovery codes")
+		return
+	}
+
+	recordAudit(r, session.UserID, audit.ActionRecoveryCodesRegenerated, audit.TargetUser, session.UserID, nil)
+	RenderSuccess(w, "New recovery codes generated; the old ones no longer work", &models.RecoveryCodes{Codes: codes})
+}
+
+// beginTwoFactorLogin asks for the second factor of a user whose first
+// factor was accepted, instead of starting a session. The challenge is kept
+// in an HttpOnly cookie for LoginTwoFactorHandler.
+func beginTwoFactorLogin(w http.ResponseWriter, userID string) (*models.TwoFactorChallenge, error) {
+	challenge, err := auth.BeginTwoFactorLogin(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.TwoFactorCookieName,
+		Value:    challenge,
+		Path:     "/api/login",
+		MaxAge:   int(auth.TwoFactorChallengeDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   auth.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return &models.TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ExpiresAt:         time.Now().Add(auth.TwoFactorChallengeDuration),
+	}, nil
+}
+
+// clearTwoFactorCookie expires the login challenge cookie
+func clearTwoFactorCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.TwoFactorCookieName,
+		Value:    "",
+		Path:     "/api/login",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   auth.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// renderTwoFactorError renders an error from changing two-factor settings,
+// falling back to a 500 with message
+func renderTwoFactorError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, auth.ErrTwoFactorEnabled):
+		RenderError(w, "Two-factor authentication is already enabled", http.StatusConflict)
+	case errors.Is(err, auth.ErrTwoFactorNotEnabled):
+		RenderError(w, "Two-factor authentication is not enabled", http.StatusConflict)
+	case errors.Is(err, auth.ErrTwoFactorNotStarted):
+		RenderError(w, "Start two-factor setup first", http.StatusConflict)
+	case errors.Is(err, auth.ErrInvalidCode):
+		RenderError(w, "Invalid authentication code", http.StatusForbidden)
+	default:
+		renderAccountError(w, err, message)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -10,11 +11,21 @@ import (
 	"strings"
 	"time"
 
+	"forum/internal/audit"
 	"forum/internal/auth"
+	"forum/internal/config"
 	"forum/internal/database"
 	"forum/internal/models"
 )
 
+// uploads holds the upload settings passed to Initialize
+var uploads = config.Default().Uploads
+
+// Initialize applies the upload settings used by the avatar handlers
+func Initialize(cfg config.UploadsConfig) {
+	uploads = cfg
+}
+
 // ProfileHandler handles profile operations
 func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -93,9 +104,32 @@ func updateProfileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if changed := profileChanges(user, updatedUser); len(changed) > 0 {
+		recordAudit(r, user.ID, audit.ActionProfileUpdated, audit.TargetUser, user.ID,
+			map[string]interface{}{"fields": changed})
+	}
+
 	RenderSuccess(w, "Profile updated successfully", updatedUser)
 }
 
+// profileChanges lists the profile fields that differ between before and after
+func profileChanges(before, after *models.User) []string {
+	changed := []string{}
+	if before.FirstName != after.FirstName {
+		changed = append(changed, "firstName")
+	}
+	if before.LastName != after.LastName {
+		changed = append(changed, "lastName")
+	}
+	if before.Age != after.Age {
+		changed = append(changed, "age")
+	}
+	if before.Gender != after.Gender {
+		changed = append(changed, "gender")
+	}
+	return changed
+}
+
 // OnlineUsersHandler handles online users listing
 func OnlineUsersHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -190,7 +224,7 @@ func AvatarUploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse multipart form
-	err := r.ParseMultipartForm(5 << 20) // 5MB limit
+	err := r.ParseMultipartForm(uploads.MaxAvatarSize)
 	if err != nil {
 		RenderError(w, "Failed to parse form", http.StatusBadRequest)
 		return
@@ -210,9 +244,9 @@ func AvatarUploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate file size (5MB limit)
-	if header.Size > 5<<20 {
-		RenderError(w, "File size must be less than 5MB", http.StatusBadRequest)
+	// Validate file size
+	if header.Size > uploads.MaxAvatarSize {
+		RenderError(w, "File size must be at most "+formatSize(uploads.MaxAvatarSize), http.StatusBadRequest)
 		return
 	}
 
@@ -221,7 +255,7 @@ func AvatarUploadHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("📂 Current working directory: %s", wd)
 
 	// Create uploads directory if it doesn't exist
-	uploadsDir := "frontend/static/uploads/avatars"
+	uploadsDir := filepath.Join(uploads.Dir, "avatars")
 	log.Printf("📁 Creating uploads directory: %s", uploadsDir)
 	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
 		log.Printf("❌ Failed to create upload directory: %v", err)
@@ -280,11 +314,25 @@ func AvatarUploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("✅ Avatar uploaded successfully for user %s: %s", user.ID, avatarURL)
+	recordAudit(r, user.ID, audit.ActionAvatarChanged, audit.TargetUser, user.ID,
+		map[string]interface{}{"avatarUrl": avatarURL, "uploaded": true})
 	RenderSuccess(w, "Avatar uploaded successfully", map[string]string{
 		"avatarURL": avatarURL,
 	})
 }
 
+// formatSize renders a byte count for error messages
+func formatSize(bytes int64) string {
+	switch {
+	case bytes%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", bytes>>20)
+	case bytes%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", bytes>>10)
+	default:
+		return fmt.Sprintf("%d bytes", bytes)
+	}
+}
+
 // AvatarUpdateHandler handles avatar URL updates (for default avatars)
 func AvatarUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
@@ -323,6 +371,9 @@ func AvatarUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(r, user.ID, audit.ActionAvatarChanged, audit.TargetUser, user.ID,
+		map[string]interface{}{"avatarUrl": req.AvatarURL})
+
 	// Get updated user data
 	updatedUser, err := auth.GetUserByID(user.ID)
 	if err != nil {
@@ -0,0 +1,81 @@
+package janitor
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/websocket"
+)
+
+// staleFactor is how many missed cleanup runs mark an online_users row as
+// stale. Each run refreshes the rows of this instance's live connections.
+const staleFactor = 3
+
+// Janitor periodically purges expired sessions, used or expired account
+// tokens and online_users rows that no running instance has refreshed
+type Janitor struct {
+	interval time.Duration
+	hub      *websocket.Hub
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a janitor that runs every interval once started
+func New(interval time.Duration, hub *websocket.Hub) *Janitor {
+	return &Janitor{
+		interval: interval,
+		hub:      hub,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run cleans up immediately and then on every interval until Stop is called
+func (j *Janitor) Run() {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.cleanup()
+
+		select {
+		case <-ticker.C:
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// Stop ends Run and waits for a cleanup in progress to finish. Run must
+// have been started.
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() { close(j.stop) })
+	<-j.done
+}
+
+// cleanup runs one pass of every cleanup task
+func (j *Janitor) cleanup() {
+	if removed, err := auth.CleanupExpiredSessions(); err != nil {
+		log.Printf("❌ Janitor: %v", err)
+	} else if removed > 0 {
+		log.Printf("🧹 Janitor: removed %d expired sessions", removed)
+	}
+
+	if removed, err := auth.CleanupUserTokens(); err != nil {
+		log.Printf("❌ Janitor: %v", err)
+	} else if removed > 0 {
+		log.Printf("🧹 Janitor: removed %d used or expired account tokens", removed)
+	}
+
+	if removed, err := j.hub.PurgeStaleOnline(staleFactor * j.interval); err != nil {
+		log.Printf("❌ Janitor: %v", err)
+	} else if removed > 0 {
+		log.Printf("🧹 Janitor: removed %d stale online sessions", removed)
+	}
+}
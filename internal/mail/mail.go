@@ -0,0 +1,159 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"forum/internal/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var (
+	mailer    Mailer = &LogMailer{}
+	from             = config.Default().Mail.From
+	publicURL        = "http://localhost:" + config.Default().Server.Port
+	mutex     sync.RWMutex
+)
+
+// Initialize selects the mailer for the configured driver. Links in emails
+// point at baseURL, the address users reach the forum on.
+func Initialize(cfg config.MailConfig, baseURL string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	from = cfg.From
+	publicURL = baseURL
+
+	switch cfg.Driver {
+	case "smtp":
+		mailer = NewSMTPMailer(cfg.SMTP, cfg.From)
+		log.Printf("✅ Sending email through %s", net.JoinHostPort(cfg.SMTP.Host, cfg.SMTP.Port))
+	default:
+		mailer = &LogMailer{File: cfg.File}
+		log.Println("⚠️ Emails are written to the log, not sent")
+	}
+}
+
+// SetMailer replaces the mailer, for example with one that records emails in tests
+func SetMailer(m Mailer) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	mailer = m
+}
+
+// Send delivers msg with the configured mailer
+func Send(msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return errors.New("email headers must not contain line breaks")
+	}
+
+	mutex.RLock()
+	m := mailer
+	mutex.RUnlock()
+
+	if err := m.Send(msg); err != nil {
+		return fmt.Errorf("failed to send email to %s: %v", msg.To, err)
+	}
+	return nil
+}
+
+// URL returns the absolute address of path on the forum, for links in emails
+func URL(path string) string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return publicURL + path
+}
+
+// LogMailer writes emails to the server log instead of sending them, and
+// appends them to File when it is set. Meant for local development.
+type LogMailer struct {
+	File string
+
+	mutex sync.Mutex
+}
+
+// Send logs msg
+func (m *LogMailer) Send(msg Message) error {
+	text := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	log.Printf("📧 Email (not sent):\n%s", text)
+
+	if m.File == "" {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	f, err := os.OpenFile(m.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\n%s\n", time.Now().Format(time.RFC1123Z), text)
+	return err
+}
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer sending as from through the configured server
+func NewSMTPMailer(cfg config.SMTPConfig, from string) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(cfg.Host, cfg.Port),
+		from: from,
+	}
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return m
+}
+
+// Send delivers msg through the SMTP server
+func (m *SMTPMailer) Send(msg Message) error {
+	sender, err := netmail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %v", err)
+	}
+	recipient, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %v", err)
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", sender.String())
+	fmt.Fprintf(&body, "To: %s\r\n", recipient.String())
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(m.addr, m.auth, sender.Address, []string{recipient.Address}, body.Bytes())
+}
@@ -0,0 +1,447 @@
+package messaging
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"forum/internal/database"
+	"forum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxGroupNameLength is the maximum length of a group name in characters
+	MaxGroupNameLength = 100
+
+	// MaxGroupSize is the maximum number of members in a group, creator included
+	MaxGroupSize = 50
+)
+
+// Errors returned by the conversation functions
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrNotGroup             = errors.New("conversation is not a group")
+	ErrGroupNameRequired    = errors.New("group name is required")
+	ErrGroupNameTooLong     = fmt.Errorf("group name cannot exceed %d characters", MaxGroupNameLength)
+	ErrNoParticipants       = errors.New("a group needs at least one other member")
+	ErrGroupTooLarge        = fmt.Errorf("a group cannot have more than %d members", MaxGroupSize)
+)
+
+// CreateGroup creates a group conversation owned by creatorID with the given
+// members. The creator is always a member.
+func CreateGroup(creatorID, name string, participantIDs []string) (*models.Conversation, error) {
+	name, err := validateGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	memberIDs := uniqueIDs(participantIDs, map[string]bool{creatorID: true})
+	if len(memberIDs) == 0 {
+		return nil, ErrNoParticipants
+	}
+	if len(memberIDs)+1 > MaxGroupSize {
+		return nil, ErrGroupTooLarge
+	}
+	for _, memberID := range memberIDs {
+		if err := CanMessage(creatorID, memberID); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	conversationID := uuid.New().String()
+	_, err = tx.Exec(`
+		INSERT INTO conversations (id, is_group, name, created_by, last_message_time, created_at, updated_at)
+		VALUES (?, 1, ?, ?, ?, ?, ?)
+	`, conversationID, name, creatorID, now, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group: %v", err)
+	}
+
+	if err := addParticipants(tx, conversationID, append([]string{creatorID}, memberIDs...), now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit group: %v", err)
+	}
+
+	return GetConversation(creatorID, conversationID)
+}
+
+// GetConversation returns a conversation with its participants. Users who
+// are not members get ErrConversationNotFound.
+func GetConversation(userID, conversationID string) (*models.Conversation, error) {
+	var isMember bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM conversation_participants WHERE conversation_id = ? AND user_id = ?)
+	`, conversationID, userID).Scan(&isMember)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check conversation membership: %v", err)
+	}
+	if !isMember {
+		return nil, ErrConversationNotFound
+	}
+
+	return loadConversation(conversationID)
+}
+
+// GetMessagesInConversation retrieves messages of a conversation userID belongs to
+func GetMessagesInConversation(userID, conversationID string, limit, offset int) ([]models.Message, error) {
+	if _, err := GetConversation(userID, conversationID); err != nil {
+		return nil, err
+	}
+
+	query := messageSelect + `
+		WHERE m.conversation_id = ?
+		ORDER BY m.created_at DESC
+		LIMIT ? OFFSET ?
+	`
+
+	return queryMessages(query, conversationID, limit, offset)
+}
+
+// RenameGroup changes the name of a group userID belongs to
+func RenameGroup(userID, conversationID, name string) (*models.Conversation, error) {
+	name, err := validateGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := getGroup(userID, conversationID); err != nil {
+		return nil, err
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE conversations SET name = ?, updated_at = ? WHERE id = ?",
+		name, time.Now(), conversationID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename group: %v", err)
+	}
+
+	return GetConversation(userID, conversationID)
+}
+
+// AddMembers adds users to a group userID belongs to. Users who are already
+// members are ignored.
+func AddMembers(userID, conversationID string, userIDs []string) (*models.Conversation, error) {
+	conv, err := getGroup(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(conv.Participants))
+	for _, participant := range conv.Participants {
+		existing[participant.UserID] = true
+	}
+
+	newIDs := uniqueIDs(userIDs, existing)
+	if len(newIDs) == 0 {
+		return conv, nil
+	}
+	if len(existing)+len(newIDs) > MaxGroupSize {
+		return nil, ErrGroupTooLarge
+	}
+	for _, memberID := range newIDs {
+		if err := CanMessage(userID, memberID); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if err := addParticipants(tx, conversationID, newIDs, now); err != nil {
+		return nil, err
+	}
+	// Earlier history is visible to new members but not counted as unread
+	for _, memberID := range newIDs {
+		_, err := tx.Exec(`
+			UPDATE conversation_participants SET last_read_at = ?
+			WHERE conversation_id = ? AND user_id = ?
+		`, now, conversationID, memberID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update read position: %v", err)
+		}
+	}
+	if _, err := tx.Exec("UPDATE conversations SET updated_at = ? WHERE id = ?", now, conversationID); err != nil {
+		return nil, fmt.Errorf("failed to update conversation: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit new members: %v", err)
+	}
+
+	return GetConversation(userID, conversationID)
+}
+
+// LeaveConversation removes userID from a group and returns the group as
+// the remaining members see it. The group is deleted along with its messages
+// when its last member leaves, in which case nil is returned.
+func LeaveConversation(userID, conversationID string) (*models.Conversation, error) {
+	conv, err := getGroup(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"DELETE FROM conversation_participants WHERE conversation_id = ? AND user_id = ?",
+		conversationID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to leave conversation: %v", err)
+	}
+
+	if len(conv.Participants) <= 1 {
+		// Foreign keys are not enforced on every connection, so remove
+		// dependent rows explicitly
+		if _, err := tx.Exec("UPDATE conversations SET last_message_id = NULL WHERE id = ?", conversationID); err != nil {
+			return nil, fmt.Errorf("failed to delete conversation: %v", err)
+		}
+		if _, err := tx.Exec("DELETE FROM messages WHERE conversation_id = ?", conversationID); err != nil {
+			return nil, fmt.Errorf("failed to delete conversation messages: %v", err)
+		}
+		if _, err := tx.Exec("DELETE FROM conversations WHERE id = ?", conversationID); err != nil {
+			return nil, fmt.Errorf("failed to delete conversation: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit leaving conversation: %v", err)
+	}
+
+	if len(conv.Participants) <= 1 {
+		return nil, nil
+	}
+	return loadConversation(conversationID)
+}
+
+// MarkConversationRead records that userID has read a conversation up to
+// now. For one-to-one conversations it returns the read receipts to send to
+// the other user.
+func MarkConversationRead(userID, conversationID string) ([]models.MessageReceiptData, error) {
+	conv, err := GetConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE conversation_participants SET last_read_at = ?
+		WHERE conversation_id = ? AND user_id = ?
+	`, time.Now(), conversationID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark conversation as read: %v", err)
+	}
+
+	// One-to-one messages also carry their own read status
+	if conv.IsGroup {
+		return nil, nil
+	}
+
+	receipts, err := markReceipts("read_at", userID, "conversation_id = ?", conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark messages as read: %v", err)
+	}
+
+	return receipts, nil
+}
+
+// ParticipantIDs returns the IDs of every member of a conversation
+func ParticipantIDs(conversationID string) ([]string, error) {
+	rows, err := database.DB.Query(
+		"SELECT user_id FROM conversation_participants WHERE conversation_id = ?",
+		conversationID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query participants: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan participant: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// getGroup loads a conversation userID belongs to and checks that it is a group
+func getGroup(userID, conversationID string) (*models.Conversation, error) {
+	conv, err := GetConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !conv.IsGroup {
+		return nil, ErrNotGroup
+	}
+	return conv, nil
+}
+
+// loadConversation loads a conversation and its participants by ID
+func loadConversation(conversationID string) (*models.Conversation, error) {
+	var conv models.Conversation
+	var lastMessageID sql.NullString
+
+	err := database.DB.QueryRow(`
+		SELECT id, COALESCE(user1_id, ''), COALESCE(user2_id, ''), is_group,
+		       COALESCE(name, ''), created_by, last_message_id, last_message_time,
+		       created_at, updated_at
+		FROM conversations
+		WHERE id = ?
+	`, conversationID).Scan(
+		&conv.ID,
+		&conv.User1ID,
+		&conv.User2ID,
+		&conv.IsGroup,
+		&conv.Name,
+		&conv.CreatedBy,
+		&lastMessageID,
+		&conv.LastMessageTime,
+		&conv.CreatedAt,
+		&conv.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %v", err)
+	}
+
+	if lastMessageID.Valid {
+		conv.LastMessageID = &lastMessageID.String
+	}
+
+	conversations := []models.Conversation{conv}
+	if err := loadParticipants(conversations); err != nil {
+		return nil, err
+	}
+
+	return &conversations[0], nil
+}
+
+// loadParticipants fills in the participants of each conversation
+func loadParticipants(conversations []models.Conversation) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(conversations))
+	placeholders := make([]string, 0, len(conversations))
+	args := make([]interface{}, 0, len(conversations))
+	for i, conv := range conversations {
+		index[conv.ID] = i
+		placeholders = append(placeholders, "?")
+		args = append(args, conv.ID)
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT p.conversation_id, p.user_id, u.nickname, u.avatar_url, p.joined_at, p.last_read_at
+		FROM conversation_participants p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.conversation_id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY p.joined_at ASC, u.nickname ASC
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query participants: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var conversationID string
+		var participant models.ConversationParticipant
+		var avatarURL sql.NullString
+		var lastReadAt sql.NullTime
+
+		err := rows.Scan(
+			&conversationID,
+			&participant.UserID,
+			&participant.Nickname,
+			&avatarURL,
+			&participant.JoinedAt,
+			&lastReadAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan participant: %v", err)
+		}
+
+		if avatarURL.Valid {
+			participant.AvatarURL = &avatarURL.String
+		}
+		if lastReadAt.Valid {
+			participant.LastReadAt = &lastReadAt.Time
+		}
+
+		i := index[conversationID]
+		conversations[i].Participants = append(conversations[i].Participants, participant)
+	}
+
+	return rows.Err()
+}
+
+// addParticipants inserts conversation members, ignoring existing ones
+func addParticipants(tx *sql.Tx, conversationID string, userIDs []string, now time.Time) error {
+	for _, userID := range userIDs {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO conversation_participants (conversation_id, user_id, joined_at)
+			VALUES (?, ?, ?)
+		`, conversationID, userID, now)
+		if err != nil {
+			return fmt.Errorf("failed to add participant: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateGroupName trims a group name and checks its length
+func validateGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrGroupNameRequired
+	}
+	if utf8.RuneCountInString(name) > MaxGroupNameLength {
+		return "", ErrGroupNameTooLong
+	}
+	return name, nil
+}
+
+// uniqueIDs returns the non-empty IDs in order without duplicates or any
+// ID in exclude
+func uniqueIDs(ids []string, exclude map[string]bool) []string {
+	seen := make(map[string]bool, len(ids))
+	var result []string
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || exclude[id] || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
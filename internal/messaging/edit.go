@@ -0,0 +1,179 @@
+package messaging
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"forum/internal/config"
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// EditWindow is how long after sending a message its sender may edit or
+// delete it. Zero or a negative value removes the limit.
+var EditWindow = 15 * time.Minute
+
+// Errors returned by Edit and Delete
+var (
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrNotMessageSender  = errors.New("you can only change your own messages")
+	ErrEditWindowExpired = errors.New("message can no longer be changed")
+	ErrMessageDeleted    = errors.New("message has been deleted")
+)
+
+// Initialize applies the messaging settings
+func Initialize(cfg config.MessagingConfig) {
+	EditWindow = cfg.EditWindow.Duration
+}
+
+// Edit replaces the content of a message sent by userID
+func Edit(userID, messageID, content string) (*models.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(content) > MaxMessageLength {
+		return nil, ErrMessageTooLong
+	}
+
+	if _, err := getChangeableMessage(userID, messageID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err := database.DB.Exec(`
+		UPDATE messages SET content = ?, edited_at = ?, updated_at = ? WHERE id = ?
+	`, content, now, now, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message: %v", err)
+	}
+
+	return GetMessage(messageID)
+}
+
+// Delete soft-deletes a message sent by userID, leaving a tombstone without
+// content, and points the conversation at the latest remaining message
+func Delete(userID, messageID string) (*models.Message, error) {
+	message, err := getChangeableMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return remove(message)
+}
+
+// Remove soft-deletes any message, regardless of sender or age. It is used
+// by moderators to take down reported messages.
+func Remove(messageID string) (*models.Message, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := RemoveTx(tx, messageID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit message deletion: %v", err)
+	}
+
+	return GetMessage(messageID)
+}
+
+// RemoveTx is Remove within the caller's transaction. The tombstone can be
+// loaded with GetMessage once the transaction commits.
+func RemoveTx(tx *sql.Tx, messageID string) error {
+	var conversationID string
+	var deletedAt sql.NullTime
+	err := tx.QueryRow(
+		"SELECT COALESCE(conversation_id, ''), deleted_at FROM messages WHERE id = ?", messageID,
+	).Scan(&conversationID, &deletedAt)
+	if err == sql.ErrNoRows {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get message: %v", err)
+	}
+	if deletedAt.Valid {
+		return ErrMessageDeleted
+	}
+
+	return markDeleted(tx, messageID, conversationID, time.Now())
+}
+
+// remove soft-deletes a message and fixes up its conversation
+func remove(message *models.Message) (*models.Message, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := markDeleted(tx, message.ID, message.ConversationID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit message deletion: %v", err)
+	}
+
+	return GetMessage(message.ID)
+}
+
+// markDeleted empties a message, marks it deleted and moves its
+// conversation's last message off it
+func markDeleted(tx *sql.Tx, messageID, conversationID string, now time.Time) error {
+	_, err := tx.Exec(`
+		UPDATE messages SET content = '', deleted_at = ?, updated_at = ? WHERE id = ?
+	`, now, now, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete message: %v", err)
+	}
+
+	return fixLastMessage(tx, conversationID, messageID, now)
+}
+
+// getChangeableMessage loads a message and checks that userID may still edit or delete it
+func getChangeableMessage(userID, messageID string) (*models.Message, error) {
+	message, err := GetMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.SenderID != userID {
+		return nil, ErrNotMessageSender
+	}
+	if message.IsDeleted {
+		return nil, ErrMessageDeleted
+	}
+	if EditWindow > 0 && time.Since(message.CreatedAt) > EditWindow {
+		return nil, ErrEditWindowExpired
+	}
+
+	return message, nil
+}
+
+// fixLastMessage moves a conversation's last_message_id off a deleted message
+// to the newest message that is still visible, or clears it
+func fixLastMessage(tx *sql.Tx, conversationID, deletedID string, now time.Time) error {
+	_, err := tx.Exec(`
+		UPDATE conversations
+		SET last_message_id = (
+			SELECT id FROM messages
+			WHERE conversation_id = ? AND deleted_at IS NULL
+			ORDER BY created_at DESC
+			LIMIT 1
+		), updated_at = ?
+		WHERE id = ? AND last_message_id = ?
+	`, conversationID, now, conversationID, deletedID)
+	if err != nil {
+		return fmt.Errorf("failed to update conversation: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,519 @@
+package messaging
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"forum/internal/blocks"
+	"forum/internal/database"
+	"forum/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// MaxMessageLength is the maximum length of a private message in characters
+const MaxMessageLength = 2000
+
+// Validation errors returned by Send and CanMessage
+var (
+	ErrReceiverRequired = errors.New("receiver ID is required")
+	ErrReceiverNotFound = errors.New("receiver not found")
+	ErrSelfMessage      = errors.New("cannot send a message to yourself")
+	ErrEmptyMessage     = errors.New("message content cannot be empty")
+	ErrMessageTooLong   = fmt.Errorf("message content cannot exceed %d characters", MaxMessageLength)
+	ErrBlocked          = errors.New("you cannot message this user")
+)
+
+// IsValidationError reports whether err was caused by invalid input rather
+// than a storage failure
+func IsValidationError(err error) bool {
+	return errors.Is(err, ErrReceiverRequired) ||
+		errors.Is(err, ErrSelfMessage) ||
+		errors.Is(err, ErrEmptyMessage) ||
+		errors.Is(err, ErrMessageTooLong) ||
+		errors.Is(err, ErrGroupNameRequired) ||
+		errors.Is(err, ErrGroupNameTooLong) ||
+		errors.Is(err, ErrNoParticipants) ||
+		errors.Is(err, ErrGroupTooLarge) ||
+		errors.Is(err, ErrTooManyMessages)
+}
+
+// Send validates and stores a private message and updates the conversation
+// between sender and receiver. It returns the stored message with user info.
+func Send(senderID, receiverID, content string) (*models.Message, error) {
+	content, err := validateContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CanMessage(senderID, receiverID); err != nil {
+		return nil, err
+	}
+
+	return storeMessage(&models.Message{
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		Content:    content,
+	})
+}
+
+// SendToConversation validates and stores a message addressed to a
+// conversation the sender belongs to, either one-to-one or group
+func SendToConversation(senderID, conversationID, content string) (*models.Message, error) {
+	content, err := validateContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	conversation, err := GetConversation(senderID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &models.Message{
+		ConversationID: conversation.ID,
+		SenderID:       senderID,
+		Content:        content,
+	}
+
+	// One-to-one messages keep their receiver so both APIs can read them
+	if !conversation.IsGroup {
+		message.ReceiverID = conversation.User1ID
+		if message.ReceiverID == senderID {
+			message.ReceiverID = conversation.User2ID
+		}
+		if err := CanMessage(senderID, message.ReceiverID); err != nil {
+			return nil, err
+		}
+	}
+
+	return storeMessage(message)
+}
+
+// validateContent trims message content and checks its length
+func validateContent(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(content) > MaxMessageLength {
+		return "", ErrMessageTooLong
+	}
+	return content, nil
+}
+
+// storeMessage inserts a validated message, creating the one-to-one
+// conversation if needed, and makes it the conversation's last message
+func storeMessage(message *models.Message) (*models.Message, error) {
+	now := time.Now()
+	message.ID = uuid.New().String()
+	message.IsRead = false
+	message.CreatedAt = now
+	message.UpdatedAt = now
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if message.ConversationID == "" {
+		message.ConversationID, err = getOrCreateDirectConversation(tx, message.SenderID, message.ReceiverID, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := createMessage(tx, message); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE conversations
+		SET last_message_id = ?, last_message_time = ?, updated_at = ?
+		WHERE id = ?
+	`, message.ID, now, now, message.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update conversation: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit message: %v", err)
+	}
+
+	// Get the complete message with sender info
+	completeMessage, err := GetMessage(message.ID)
+	if err != nil {
+		// Return the basic message if we can't get the complete one
+		return message, nil
+	}
+
+	return completeMessage, nil
+}
+
+// CanMessage reports whether sender is allowed to message receiver. It
+// returns nil when allowed, or the reason (such as ErrReceiverNotFound or
+// ErrBlocked when either has blocked the other) otherwise.
+func CanMessage(senderID, receiverID string) error {
+	if receiverID == "" {
+		return ErrReceiverRequired
+	}
+	if receiverID == senderID {
+		return ErrSelfMessage
+	}
+
+	// Check if receiver exists
+	var receiverExists bool
+	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", receiverID).Scan(&receiverExists)
+	if err != nil {
+		return fmt.Errorf("failed to validate receiver: %v", err)
+	}
+	if !receiverExists {
+		return ErrReceiverNotFound
+	}
+
+	blocked, err := blocks.Between(senderID, receiverID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+
+	return nil
+}
+
+// GetUserConversations retrieves all conversations for a user, one-to-one
+// and group, with their participants
+func GetUserConversations(userID string) ([]models.Conversation, error) {
+	query := `
+		SELECT
+			c.id,
+			COALESCE(c.user1_id, ''),
+			COALESCE(c.user2_id, ''),
+			c.is_group,
+			COALESCE(c.name, ''),
+			c.created_by,
+			c.last_message_id,
+			c.last_message_time,
+			c.created_at,
+			c.updated_at,
+			COALESCE(other.id, '') as other_user_id,
+			COALESCE(other.nickname, '') as other_user_nickname,
+			other.avatar_url as other_user_avatar,
+			COALESCE(m.content, '') as last_message,
+			(
+				SELECT COUNT(*) FROM messages um
+				WHERE um.conversation_id = c.id
+				  AND um.sender_id != p.user_id
+				  AND um.deleted_at IS NULL
+				  AND (p.last_read_at IS NULL OR julianday(um.created_at) > julianday(p.last_read_at))
+			) as unread_count,
+			EXISTS(SELECT 1 FROM online_users ou WHERE ou.user_id = other.id) as is_online
+		FROM conversation_participants p
+		JOIN conversations c ON c.id = p.conversation_id
+		LEFT JOIN users other ON c.is_group = 0 AND other.id = CASE
+			WHEN c.user1_id = p.user_id THEN c.user2_id
+			ELSE c.user1_id
+		END
+		LEFT JOIN messages m ON c.last_message_id = m.id
+		WHERE p.user_id = ?
+		ORDER BY c.last_message_time DESC
+	`
+
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversations: %v", err)
+	}
+	defer rows.Close()
+
+	var conversations []models.Conversation
+	for rows.Next() {
+		var conv models.Conversation
+		var lastMessageID sql.NullString
+		var otherUserAvatar sql.NullString
+
+		err := rows.Scan(
+			&conv.ID,
+			&conv.User1ID,
+			&conv.User2ID,
+			&conv.IsGroup,
+			&conv.Name,
+			&conv.CreatedBy,
+			&lastMessageID,
+			&conv.LastMessageTime,
+			&conv.CreatedAt,
+			&conv.UpdatedAt,
+			&conv.OtherUserID,
+			&conv.OtherUserNickname,
+			&otherUserAvatar,
+			&conv.LastMessage,
+			&conv.UnreadCount,
+			&conv.IsOnline,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %v", err)
+		}
+
+		if lastMessageID.Valid {
+			conv.LastMessageID = &lastMessageID.String
+		}
+		if otherUserAvatar.Valid {
+			conv.OtherUserAvatar = &otherUserAvatar.String
+		}
+
+		conversations = append(conversations, conv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadParticipants(conversations); err != nil {
+		return nil, err
+	}
+
+	return conversations, nil
+}
+
+// GetConversationMessages retrieves messages between two users
+func GetConversationMessages(userID, otherUserID string, limit, offset int) ([]models.Message, error) {
+	query := messageSelect + `
+		WHERE
+			(m.sender_id = ? AND m.receiver_id = ?) OR
+			(m.sender_id = ? AND m.receiver_id = ?)
+		ORDER BY m.created_at DESC
+		LIMIT ? OFFSET ?
+	`
+
+	return queryMessages(query, userID, otherUserID, otherUserID, userID, limit, offset)
+}
+
+// queryMessages runs a messageSelect query ordered newest first and returns
+// the messages in chronological order (oldest first)
+func queryMessages(query string, args ...interface{}) ([]models.Message, error) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %v", err)
+		}
+		messages = append(messages, *msg)
+	}
+
+	// Reverse the slice to get chronological order (oldest first)
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
+
+// MarkAsRead marks all unread messages from a sender as read and returns
+// the read receipts to send to the sender
+func MarkAsRead(receiverID, senderID string) ([]models.MessageReceiptData, error) {
+	receipts, err := markReceipts("read_at", receiverID, "sender_id = ?", senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark messages as read: %v", err)
+	}
+
+	// Keep the reader's position in the conversation in step
+	user1ID, user2ID := receiverID, senderID
+	if user1ID > user2ID {
+		user1ID, user2ID = user2ID, user1ID
+	}
+	_, err = database.DB.Exec(`
+		UPDATE conversation_participants SET last_read_at = ?
+		WHERE user_id = ? AND conversation_id = (
+			SELECT id FROM conversations WHERE user1_id = ? AND user2_id = ?
+		)
+	`, time.Now(), receiverID, user1ID, user2ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update read position: %v", err)
+	}
+
+	return receipts, nil
+}
+
+// GetMessageForUser retrieves a message with its delivery status for a
+// member of its conversation. Other users get ErrMessageNotFound.
+func GetMessageForUser(userID, messageID string) (*models.Message, error) {
+	message, err := GetMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := GetConversation(userID, message.ConversationID); err != nil {
+		if errors.Is(err, ErrConversationNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// GetMessage retrieves a message with complete user information
+func GetMessage(messageID string) (*models.Message, error) {
+	msg, err := scanMessage(database.DB.QueryRow(messageSelect+` WHERE m.id = ?`, messageID))
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message with user info: %v", err)
+	}
+
+	return msg, nil
+}
+
+// messageSelect selects the columns read by scanMessage
+const messageSelect = `
+	SELECT
+		m.id,
+		COALESCE(m.conversation_id, ''),
+		m.sender_id,
+		COALESCE(m.receiver_id, ''),
+		m.content,
+		m.is_read,
+		m.created_at,
+		m.updated_at,
+		m.edited_at,
+		m.deleted_at,
+		m.delivered_at,
+		m.read_at,
+		sender.nickname as sender_nickname,
+		sender.avatar_url as sender_avatar_url,
+		receiver.nickname as receiver_nickname
+	FROM messages m
+	JOIN users sender ON m.sender_id = sender.id
+	LEFT JOIN users receiver ON m.receiver_id = receiver.id
+`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMessage scans a row selected with messageSelect
+func scanMessage(row rowScanner) (*models.Message, error) {
+	var msg models.Message
+	var senderAvatarURL, receiverNickname sql.NullString
+
+	err := row.Scan(
+		&msg.ID,
+		&msg.ConversationID,
+		&msg.SenderID,
+		&msg.ReceiverID,
+		&msg.Content,
+		&msg.IsRead,
+		&msg.CreatedAt,
+		&msg.UpdatedAt,
+		&msg.EditedAt,
+		&msg.DeletedAt,
+		&msg.DeliveredAt,
+		&msg.ReadAt,
+		&msg.SenderNickname,
+		&senderAvatarURL,
+		&receiverNickname,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if senderAvatarURL.Valid {
+		msg.SenderAvatarURL = &senderAvatarURL.String
+	}
+	if receiverNickname.Valid {
+		msg.ReceiverNickname = receiverNickname.String
+	}
+	msg.IsDeleted = msg.DeletedAt != nil
+
+	switch {
+	case msg.ReadAt != nil:
+		msg.Status = models.MessageStatusRead
+	case msg.DeliveredAt != nil:
+		msg.Status = models.MessageStatusDelivered
+	default:
+		msg.Status = models.MessageStatusSent
+	}
+
+	return &msg, nil
+}
+
+// createMessage inserts a new message into the database
+func createMessage(tx *sql.Tx, message *models.Message) error {
+	query := `
+		INSERT INTO messages (id, conversation_id, sender_id, receiver_id, content, is_read, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	var receiverID interface{}
+	if message.ReceiverID != "" {
+		receiverID = message.ReceiverID
+	}
+
+	_, err := tx.Exec(query,
+		message.ID,
+		message.ConversationID,
+		message.SenderID,
+		receiverID,
+		message.Content,
+		message.IsRead,
+		message.CreatedAt,
+		message.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert message: %v", err)
+	}
+
+	return nil
+}
+
+// getOrCreateDirectConversation returns the one-to-one conversation between
+// two users, creating it with both participants if it does not exist
+func getOrCreateDirectConversation(tx *sql.Tx, user1ID, user2ID string, now time.Time) (string, error) {
+	// Ensure consistent ordering of user IDs for conversation lookup
+	if user1ID > user2ID {
+		user1ID, user2ID = user2ID, user1ID
+	}
+
+	// Check if conversation exists
+	var conversationID string
+	err := tx.QueryRow(
+		"SELECT id FROM conversations WHERE user1_id = ? AND user2_id = ?",
+		user1ID, user2ID,
+	).Scan(&conversationID)
+
+	if err == nil {
+		return conversationID, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to check conversation existence: %v", err)
+	}
+
+	// Create new conversation
+	conversationID = uuid.New().String()
+	_, err = tx.Exec(`
+		INSERT INTO conversations (id, user1_id, user2_id, is_group, last_message_time, created_at, updated_at)
+		VALUES (?, ?, ?, 0, ?, ?, ?)
+	`, conversationID, user1ID, user2ID, now, now, now)
+	if err != nil {
+		return "", fmt.Errorf("failed to create conversation: %v", err)
+	}
+
+	if err := addParticipants(tx, conversationID, []string{user1ID, user2ID}, now); err != nil {
+		return "", err
+	}
+
+	return conversationID, nil
+}
@@ -0,0 +1,188 @@
+package messaging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// MaxReceiptBatch is the most messages one MarkDelivered or MarkRead call
+// accepts, keeping each query well under SQLite's bound variable limit
+const MaxReceiptBatch = 500
+
+// ErrTooManyMessages is returned when more than MaxReceiptBatch message IDs
+// are marked at once
+var ErrTooManyMessages = fmt.Errorf("cannot mark more than %d messages at once", MaxReceiptBatch)
+
+// MarkDelivered records that a session of receiverID got the given messages.
+// It returns one receipt per sender and conversation for the messages that
+// were not already delivered.
+func MarkDelivered(receiverID string, messageIDs []string) ([]models.MessageReceiptData, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	if len(messageIDs) > MaxReceiptBatch {
+		return nil, ErrTooManyMessages
+	}
+	placeholders, args := inClause(messageIDs)
+	return markReceipts("delivered_at", receiverID, "id IN ("+placeholders+")", args...)
+}
+
+// MarkRead records that readerID has read the given messages. It returns one
+// receipt per sender and conversation for the one-to-one messages that were
+// not already read. Group messages get no receipts; reading them only moves
+// the reader's last_read_at forward.
+func MarkRead(readerID string, messageIDs []string) ([]models.MessageReceiptData, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	if len(messageIDs) > MaxReceiptBatch {
+		return nil, ErrTooManyMessages
+	}
+	placeholders, args := inClause(messageIDs)
+	receipts, err := markReceipts("read_at", readerID, "id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Move the reader's position forward to the newest message just read in
+	// each conversation. Only conversations the reader belongs to have a row.
+	rows, err := database.DB.Query(`
+		SELECT conversation_id, MAX(created_at)
+		FROM messages
+		WHERE id IN (`+placeholders+`) AND sender_id != ? AND conversation_id IS NOT NULL
+		GROUP BY conversation_id
+	`, append(args, readerID)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query read position: %v", err)
+	}
+	positions := make(map[string]string)
+	for rows.Next() {
+		var conversationID, newest string
+		if err := rows.Scan(&conversationID, &newest); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan read position: %v", err)
+		}
+		positions[conversationID] = newest
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for conversationID, newest := range positions {
+		_, err := database.DB.Exec(`
+			UPDATE conversation_participants SET last_read_at = ?
+			WHERE conversation_id = ? AND user_id = ?
+			  AND (last_read_at IS NULL OR julianday(last_read_at) < julianday(?))
+		`, newest, conversationID, readerID, newest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update read position: %v", err)
+		}
+	}
+
+	return receipts, nil
+}
+
+// markReceipts sets column (delivered_at or read_at) to now on the messages
+// received by receiverID that match filter and don't have it set yet, and
+// groups them into receipts. Reading a message also delivers it. Only
+// one-to-one messages have a receiver_id, so group messages never match.
+func markReceipts(column, receiverID, filter string, args ...interface{}) ([]models.MessageReceiptData, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, sender_id, COALESCE(conversation_id, '')
+		FROM messages
+		WHERE receiver_id = ? AND `+column+` IS NULL AND (`+filter+`)
+		ORDER BY created_at ASC
+	`, append([]interface{}{receiverID}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %v", err)
+	}
+
+	now := time.Now()
+	var receipts []models.MessageReceiptData
+	index := make(map[string]int)
+	for rows.Next() {
+		var id, senderID, conversationID string
+		if err := rows.Scan(&id, &senderID, &conversationID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan message: %v", err)
+		}
+
+		key := senderID + "\x00" + conversationID
+		i, ok := index[key]
+		if !ok {
+			receipt := models.MessageReceiptData{
+				SenderID:       senderID,
+				ConversationID: conversationID,
+			}
+			if column == "read_at" {
+				receipt.ReaderID = receiverID
+				receipt.ReadAt = &now
+			} else {
+				receipt.ReceiverID = receiverID
+				receipt.DeliveredAt = &now
+			}
+			receipts = append(receipts, receipt)
+			i = len(receipts) - 1
+			index[key] = i
+		}
+		receipts[i].MessageIDs = append(receipts[i].MessageIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(receipts) == 0 {
+		return nil, nil
+	}
+
+	// Update by the same predicate rather than by ID, so marking a long
+	// backlog never builds an unbounded IN list. The transaction keeps the
+	// matching rows the same as the ones just selected.
+	if column == "read_at" {
+		_, err = tx.Exec(`
+			UPDATE messages
+			SET is_read = 1, read_at = ?, delivered_at = COALESCE(delivered_at, ?), updated_at = ?
+			WHERE receiver_id = ? AND read_at IS NULL AND (`+filter+`)
+		`, append([]interface{}{now, now, now, receiverID}, args...)...)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE messages SET delivered_at = ?
+			WHERE receiver_id = ? AND delivered_at IS NULL AND (`+filter+`)
+		`, append([]interface{}{now, receiverID}, args...)...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message status: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit message status: %v", err)
+	}
+
+	return receipts, nil
+}
+
+// inClause returns placeholders and arguments for an IN (...) list
+func inClause(values []string) (string, []interface{}) {
+	return strings.TrimSuffix(strings.Repeat("?,", len(values)), ","), stringArgs(values)
+}
+
+// stringArgs converts strings to query arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
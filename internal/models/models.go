@@ -1,22 +1,28 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // User represents a user in the system with comprehensive profile information
 type User struct {
-	ID        string    `json:"id" db:"id"`
-	Email     string    `json:"email" db:"email"`
-	Nickname  string    `json:"nickname" db:"nickname"`
-	Password  string    `json:"-" db:"password"` // Never expose password in JSON
-	FirstName string    `json:"firstName" db:"first_name"`
-	LastName  string    `json:"lastName" db:"last_name"`
-	Age       int       `json:"age" db:"age"`
-	Gender    string    `json:"gender" db:"gender"`
-	GoogleID  *string   `json:"googleId,omitempty" db:"google_id"`
-	GithubID  *string   `json:"githubId,omitempty" db:"github_id"`
-	AvatarURL *string   `json:"avatarUrl,omitempty" db:"avatar_url"`
-	CreatedAt time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	ID               string    `json:"id" db:"id"`
+	Email            string    `json:"email" db:"email"`
+	EmailVerified    bool      `json:"emailVerified" db:"-"`    // email_verified_at is set
+	TwoFactorEnabled bool      `json:"twoFactorEnabled" db:"-"` // totp_enabled_at is set
+	Nickname         string    `json:"nickname" db:"nickname"`
+	Role             string    `json:"role" db:"role"`
+	Password         string    `json:"-" db:"password"` // Never expose password in JSON
+	FirstName        string    `json:"firstName" db:"first_name"`
+	LastName         string    `json:"lastName" db:"last_name"`
+	Age              int       `json:"age" db:"age"`
+	Gender           string    `json:"gender" db:"gender"`
+	GoogleID         *string   `json:"googleId,omitempty" db:"google_id"`
+	GithubID         *string   `json:"githubId,omitempty" db:"github_id"`
+	AvatarURL        *string   `json:"avatarUrl,omitempty" db:"avatar_url"`
+	CreatedAt        time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt        time.Time `json:"updatedAt" db:"updated_at"`
 }
 
 // Post represents a forum post with enhanced features
@@ -72,6 +78,47 @@ type Session struct {
 	UserID    string    `json:"userId" db:"user_id"`
 	ExpiresAt time.Time `json:"expiresAt" db:"expires_at"`
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	CSRFToken string    `json:"-" db:"csrf_token"` // must accompany every write made with this session
+
+	// Device details shown when the user reviews their sessions
+	PublicID     string    `json:"-" db:"public_id"` // identifies the session in the API; the ID itself is a secret
+	UserAgent    string    `json:"-" db:"user_agent"`
+	IPAddress    string    `json:"-" db:"ip_address"`
+	LastActiveAt time.Time `json:"-" db:"last_active_at"`
+}
+
+// SessionInfo describes one of the user's sessions without exposing its ID
+type SessionInfo struct {
+	ID           string    `json:"id"` // the session's public ID
+	UserAgent    string    `json:"userAgent"`
+	IPAddress    string    `json:"ipAddress"`
+	CreatedAt    time.Time `json:"createdAt"`
+	LastActiveAt time.Time `json:"lastActiveAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Current      bool      `json:"current"` // the session that made the request
+}
+
+// SessionUser is the signed-in user together with the CSRF token the client
+// must send in the X-CSRF-Token header of every write
+type SessionUser struct {
+	*User
+	Permissions []string `json:"permissions"`
+	CSRFToken   string   `json:"csrfToken"`
+}
+
+// Notification represents a persisted notification for a user
+type Notification struct {
+	ID            int64     `json:"id" db:"id"`
+	UserID        string    `json:"userId" db:"user_id"`
+	ActorID       *string   `json:"actorId,omitempty" db:"actor_id"`
+	Type          string    `json:"type" db:"type"` // "comment_reply", "post_comment", "post_like", "comment_like", "mention"
+	Message       string    `json:"message" db:"message"`
+	PostID        *int      `json:"postId,omitempty" db:"post_id"`
+	CommentID     *int      `json:"commentId,omitempty" db:"comment_id"`
+	IsRead        bool      `json:"isRead" db:"is_read"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+	ActorNickname string    `json:"actorNickname,omitempty" db:"actor_nickname"`
+	ActorAvatar   *string   `json:"actorAvatar,omitempty" db:"actor_avatar"`
 }
 
 // OnlineUser represents an online user
@@ -86,35 +133,63 @@ type OnlineUser struct {
 
 // Message represents a private message between users
 type Message struct {
-	ID         string    `json:"id" db:"id"`
-	SenderID   string    `json:"senderId" db:"sender_id"`
-	ReceiverID string    `json:"receiverId" db:"receiver_id"`
-	Content    string    `json:"content" db:"content"`
-	IsRead     bool      `json:"isRead" db:"is_read"`
-	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
+	ID             string     `json:"id" db:"id"`
+	ConversationID string     `json:"conversationId,omitempty" db:"conversation_id"`
+	SenderID       string     `json:"senderId" db:"sender_id"`
+	ReceiverID     string     `json:"receiverId,omitempty" db:"receiver_id"` // Empty for group messages
+	Content        string     `json:"content" db:"content"`
+	IsRead         bool       `json:"isRead" db:"is_read"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" db:"updated_at"`
+	EditedAt       *time.Time `json:"editedAt,omitempty" db:"edited_at"`
+	DeletedAt      *time.Time `json:"deletedAt,omitempty" db:"deleted_at"` // Deleted messages keep their row with empty content
+	IsDeleted      bool       `json:"isDeleted,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty" db:"delivered_at"` // When a receiver session got the message
+	ReadAt         *time.Time `json:"readAt,omitempty" db:"read_at"`
+	Status         string     `json:"status"` // MessageStatusSent, MessageStatusDelivered or MessageStatusRead
 	// Additional fields for frontend display
 	SenderNickname   string  `json:"senderNickname,omitempty" db:"sender_nickname"`
 	SenderAvatarURL  *string `json:"senderAvatarUrl,omitempty" db:"sender_avatar_url"`
 	ReceiverNickname string  `json:"receiverNickname,omitempty" db:"receiver_nickname"`
 }
 
-// Conversation represents a conversation between two users
+// Delivery status of a one-to-one message. Group messages stay "sent"; how
+// far each member has read is tracked on ConversationParticipant.
+const (
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+)
+
+// Conversation represents a one-to-one or group conversation
 type Conversation struct {
 	ID              string    `json:"id" db:"id"`
-	User1ID         string    `json:"user1Id" db:"user1_id"`
-	User2ID         string    `json:"user2Id" db:"user2_id"`
+	User1ID         string    `json:"user1Id,omitempty" db:"user1_id"` // Only set for one-to-one conversations
+	User2ID         string    `json:"user2Id,omitempty" db:"user2_id"`
+	IsGroup         bool      `json:"isGroup" db:"is_group"`
+	Name            string    `json:"name,omitempty" db:"name"`
+	CreatedBy       *string   `json:"createdBy,omitempty" db:"created_by"`
 	LastMessageID   *string   `json:"lastMessageId,omitempty" db:"last_message_id"`
 	LastMessageTime time.Time `json:"lastMessageTime" db:"last_message_time"`
 	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt       time.Time `json:"updatedAt" db:"updated_at"`
 	// Additional fields for frontend display
-	OtherUserID       string  `json:"otherUserId,omitempty"`
-	OtherUserNickname string  `json:"otherUserNickname,omitempty"`
-	OtherUserAvatar   *string `json:"otherUserAvatar,omitempty"`
-	LastMessage       string  `json:"lastMessage,omitempty"`
-	UnreadCount       int     `json:"unreadCount,omitempty"`
-	IsOnline          bool    `json:"isOnline,omitempty"`
+	OtherUserID       string                    `json:"otherUserId,omitempty"`
+	OtherUserNickname string                    `json:"otherUserNickname,omitempty"`
+	OtherUserAvatar   *string                   `json:"otherUserAvatar,omitempty"`
+	LastMessage       string                    `json:"lastMessage,omitempty"`
+	UnreadCount       int                       `json:"unreadCount,omitempty"`
+	IsOnline          bool                      `json:"isOnline,omitempty"`
+	Participants      []ConversationParticipant `json:"participants,omitempty"`
+}
+
+// ConversationParticipant represents a member of a conversation and how far they have read
+type ConversationParticipant struct {
+	UserID     string     `json:"userId" db:"user_id"`
+	Nickname   string     `json:"nickname"`
+	AvatarURL  *string    `json:"avatarUrl,omitempty"`
+	JoinedAt   time.Time  `json:"joinedAt" db:"joined_at"`
+	LastReadAt *time.Time `json:"lastReadAt,omitempty" db:"last_read_at"`
 }
 
 // Category represents a post category
@@ -123,6 +198,50 @@ type Category struct {
 	PostCount int    `json:"postCount" db:"post_count"`
 }
 
+// PostSearchResult represents a post matched by a search query.
+// Snippets are HTML-escaped with matches wrapped in <mark> tags, and a
+// higher Rank means a more relevant match.
+type PostSearchResult struct {
+	ID             int       `json:"id"`
+	Title          string    `json:"title"`
+	TitleSnippet   string    `json:"titleSnippet"`
+	ContentSnippet string    `json:"contentSnippet"`
+	UserID         string    `json:"userId"`
+	Author         string    `json:"author"`
+	Categories     []string  `json:"categories"`
+	CreatedAt      time.Time `json:"createdAt"`
+	Rank           float64   `json:"rank"`
+}
+
+// CommentSearchResult represents a comment matched by a search query
+type CommentSearchResult struct {
+	ID        int       `json:"id"`
+	PostID    int       `json:"postId"`
+	PostTitle string    `json:"postTitle"`
+	Snippet   string    `json:"snippet"`
+	UserID    string    `json:"userId"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"createdAt"`
+	Rank      float64   `json:"rank"`
+}
+
+// UserSearchResult represents a user matched by a search query
+type UserSearchResult struct {
+	ID              string  `json:"id"`
+	Nickname        string  `json:"nickname"`
+	NicknameSnippet string  `json:"nicknameSnippet"`
+	AvatarURL       *string `json:"avatarUrl,omitempty"`
+	Rank            float64 `json:"rank"`
+}
+
+// SearchResults groups search matches by type
+type SearchResults struct {
+	Query    string                `json:"query"`
+	Posts    []PostSearchResult    `json:"posts"`
+	Comments []CommentSearchResult `json:"comments"`
+	Users    []UserSearchResult    `json:"users"`
+}
+
 // Request/Response models for API endpoints
 
 // RegisterRequest represents the registration request payload
@@ -142,6 +261,227 @@ type LoginRequest struct {
 	Password   string `json:"password"`
 }
 
+// ForgotPasswordRequest represents the request for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents the payload that sets a new password with
+// an emailed reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ChangePasswordRequest represents the payload that replaces the signed-in
+// user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// ChangeEmailRequest represents the payload that replaces the signed-in
+// user's email
+type ChangeEmailRequest struct {
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+// ChangeNicknameRequest represents the payload that replaces the signed-in
+// user's nickname
+type ChangeNicknameRequest struct {
+	Password string `json:"password"`
+	Nickname string `json:"nickname"`
+}
+
+// TwoFactorCodeRequest represents a payload carrying a code from the user's
+// authenticator app, or one of their recovery codes
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorConfirmRequest represents a payload that changes two-factor
+// settings, confirmed with both the password and a code
+type TwoFactorConfirmRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// TwoFactorChallenge tells the client that a login needs a second factor.
+// The challenge itself travels in an HttpOnly cookie.
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool      `json:"twoFactorRequired"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+}
+
+// TwoFactorSetup is what an authenticator app needs to enroll a user
+type TwoFactorSetup struct {
+	Secret string `json:"secret"` // base32, for typing in by hand
+	URI    string `json:"uri"`    // otpauth:// URI, usually shown as a QR code
+}
+
+// TwoFactorStatus describes the user's two-factor settings
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+// RecoveryCodes are one-time codes that stand in for an authenticator app.
+// They are only ever shown when generated.
+type RecoveryCodes struct {
+	Codes []string `json:"recoveryCodes"`
+}
+
+// StaffMember is a moderator or admin as listed to admins
+type StaffMember struct {
+	ID       string `json:"id"`
+	Nickname string `json:"nickname"`
+	Role     string `json:"role"`
+}
+
+// UserRoleRequest represents the payload that changes a user's role. User
+// is their nickname or email.
+type UserRoleRequest struct {
+	User string `json:"user"`
+	Role string `json:"role"`
+}
+
+// BlockedUser is a user someone has blocked, as listed to the blocker
+type BlockedUser struct {
+	ID        string    `json:"id"`
+	Nickname  string    `json:"nickname"`
+	AvatarURL *string   `json:"avatarUrl,omitempty"`
+	BlockedAt time.Time `json:"blockedAt"`
+}
+
+// BlockRequest represents the payload that blocks a user
+type BlockRequest struct {
+	UserID string `json:"userId"`
+}
+
+// AuditEvent records who did what to which target, and from where. Actor is
+// the actor's current nickname, unset for anonymous events and deleted
+// accounts.
+type AuditEvent struct {
+	ID         int64           `json:"id" db:"id"`
+	ActorID    *string         `json:"actorId,omitempty" db:"actor_id"`
+	Actor      *string         `json:"actor,omitempty" db:"-"`
+	Action     string          `json:"action" db:"action"`
+	TargetType string          `json:"targetType,omitempty" db:"target_type"`
+	TargetID   string          `json:"targetId,omitempty" db:"target_id"`
+	IPAddress  string          `json:"ipAddress,omitempty" db:"ip_address"`
+	Metadata   json.RawMessage `json:"metadata,omitempty" db:"metadata"` // JSON object of event details
+	CreatedAt  time.Time       `json:"createdAt" db:"created_at"`
+}
+
+// Suspension keeps a user from signing in until it expires or is lifted. A
+// suspension without expiry is a permanent ban.
+type Suspension struct {
+	ID        int64      `json:"id" db:"id"`
+	UserID    string     `json:"userId" db:"user_id"`
+	Nickname  string     `json:"nickname" db:"-"`
+	Reason    string     `json:"reason" db:"reason"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" db:"expires_at"`
+	CreatedBy *string    `json:"createdBy,omitempty" db:"-"` // moderator nickname
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// SuspendRequest represents the payload that suspends a user, named by
+// nickname or email. Zero days bans the user permanently.
+type SuspendRequest struct {
+	User   string `json:"user"`
+	Reason string `json:"reason"`
+	Days   int    `json:"days"`
+}
+
+// UserSuspendedData is sent to a user's connections before they are closed
+type UserSuspendedData struct {
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // nil for a permanent ban
+}
+
+// Report flags a post, comment, message or user for the moderators
+type Report struct {
+	ID           int64      `json:"id" db:"id"`
+	ReporterID   string     `json:"reporterId" db:"reporter_id"`
+	Reporter     string     `json:"reporter" db:"nickname"`
+	TargetType   string     `json:"targetType" db:"target_type"`
+	TargetID     string     `json:"targetId" db:"target_id"`
+	TargetUserID *string    `json:"targetUserId,omitempty" db:"target_user_id"` // author of the content, or the reported user
+	TargetUser   *string    `json:"targetUser,omitempty" db:"-"`
+	Snapshot     string     `json:"snapshot" db:"target_snapshot"` // the content when it was reported
+	Reason       string     `json:"reason" db:"reason"`
+	Status       string     `json:"status" db:"status"`
+	ClaimedBy    *string    `json:"claimedBy,omitempty" db:"-"` // moderator nickname
+	ClaimedAt    *time.Time `json:"claimedAt,omitempty" db:"claimed_at"`
+	ClosedBy     *string    `json:"closedBy,omitempty" db:"-"` // moderator nickname
+	ClosedAt     *time.Time `json:"closedAt,omitempty" db:"closed_at"`
+	Action       *string    `json:"action,omitempty" db:"action"`
+	Note         *string    `json:"note,omitempty" db:"note"`
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// ReportRequest represents the payload that reports a post, comment,
+// message or user
+type ReportRequest struct {
+	TargetType string `json:"targetType"`
+	TargetID   string `json:"targetId"`
+	Reason     string `json:"reason"`
+}
+
+// ResolveReportRequest represents the payload that closes a report with an
+// action against the reported content or user
+type ResolveReportRequest struct {
+	Action      string `json:"action"` // none, hide, warn or suspend
+	Note        string `json:"note"`
+	SuspendDays int    `json:"suspendDays"` // for suspend
+}
+
+// DismissReportRequest represents the payload that closes a report without
+// action
+type DismissReportRequest struct {
+	Note string `json:"note"`
+}
+
+// ModerationAction is one step a moderator took, kept as an audit trail
+type ModerationAction struct {
+	ID         int64     `json:"id" db:"id"`
+	ReportID   *int64    `json:"reportId,omitempty" db:"report_id"`
+	Moderator  *string   `json:"moderator,omitempty" db:"-"` // nickname
+	Action     string    `json:"action" db:"action"`
+	TargetType string    `json:"targetType" db:"target_type"`
+	TargetID   string    `json:"targetId" db:"target_id"`
+	Note       *string   `json:"note,omitempty" db:"note"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
+
+// ReportDetail is a report together with the moderation actions taken on it
+type ReportDetail struct {
+	*Report
+	Actions []ModerationAction `json:"actions"`
+}
+
+// OAuthPendingSignup represents an OAuth login waiting for the user to
+// complete the profile fields the provider does not supply
+type OAuthPendingSignup struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	Nickname  string    `json:"nickname"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"lastName"`
+	AvatarURL *string   `json:"avatarUrl,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// OAuthSignupRequest represents the profile completion payload for OAuth signups
+type OAuthSignupRequest struct {
+	Nickname  string `json:"nickname"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Age       int    `json:"age"`
+	Gender    string `json:"gender"`
+}
+
 // PostRequest represents the post creation request payload
 type PostRequest struct {
 	Title      string   `json:"title"`
@@ -150,6 +490,15 @@ type PostRequest struct {
 	ImagePath  *string  `json:"imagePath,omitempty"`
 }
 
+// PostUpdateRequest represents the post update request payload.
+// Nil fields are left unchanged, which lets PATCH send only what changed.
+type PostUpdateRequest struct {
+	Title      *string   `json:"title,omitempty"`
+	Content    *string   `json:"content,omitempty"`
+	Categories *[]string `json:"categories,omitempty"`
+	ImagePath  *string   `json:"imagePath,omitempty"`
+}
+
 // CommentRequest represents the comment creation request payload
 type CommentRequest struct {
 	PostID   int    `json:"postId"`
@@ -166,8 +515,14 @@ type LikeRequest struct {
 
 // MessageRequest represents the message sending request payload
 type MessageRequest struct {
-	ReceiverID string `json:"receiverId"`
-	Content    string `json:"content"`
+	ReceiverID     string `json:"receiverId"`
+	ConversationID string `json:"conversationId,omitempty"` // Used instead of ReceiverID for group messages
+	Content        string `json:"content"`
+}
+
+// MessageUpdateRequest represents the message editing request payload
+type MessageUpdateRequest struct {
+	Content string `json:"content"`
 }
 
 // ConversationRequest represents the conversation creation request payload
@@ -176,12 +531,24 @@ type ConversationRequest struct {
 	User2ID string `json:"user2Id"`
 }
 
+// GroupConversationRequest represents the group creation request payload
+type GroupConversationRequest struct {
+	Name           string   `json:"name"`
+	ParticipantIDs []string `json:"participantIds"`
+}
+
+// ConversationMembersRequest represents the add-members request payload
+type ConversationMembersRequest struct {
+	UserIDs []string `json:"userIds"`
+}
+
 // APIResponse represents a standard API response
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	NextCursor string      `json:"nextCursor,omitempty"` // Set on paginated lists when more rows exist
 }
 
 // WebSocketMessage represents a WebSocket message
@@ -193,10 +560,13 @@ type WebSocketMessage struct {
 
 // NotificationData represents notification data for WebSocket
 type NotificationData struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
-	UserID  string `json:"userId,omitempty"`
-	PostID  *int   `json:"postId,omitempty"`
+	ID        int64     `json:"id,omitempty"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	UserID    string    `json:"userId,omitempty"` // The user who triggered the notification
+	PostID    *int      `json:"postId,omitempty"`
+	CommentID *int      `json:"commentId,omitempty"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
 }
 
 // UserStatusData represents user online/offline status for WebSocket
@@ -212,6 +582,28 @@ type MessageData struct {
 	Type    string   `json:"type"` // "new_message", "message_read", etc.
 }
 
+// MessageAckData acknowledges a private_message frame to the sender's sessions.
+// TempID is the temporary ID the client attached to the frame.
+type MessageAckData struct {
+	TempID    string   `json:"tempId,omitempty"`
+	MessageID string   `json:"messageId,omitempty"`
+	Message   *Message `json:"message,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// MessageReceiptData tells a sender which of their messages were delivered
+// to or read by the receiver, and when. It is the data of message_delivered
+// and message_read events.
+type MessageReceiptData struct {
+	ReaderID       string     `json:"readerId,omitempty"`   // Set on message_read
+	ReceiverID     string     `json:"receiverId,omitempty"` // Set on message_delivered
+	SenderID       string     `json:"senderId"`
+	ConversationID string     `json:"conversationId,omitempty"`
+	MessageIDs     []string   `json:"messageIds"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	ReadAt         *time.Time `json:"readAt,omitempty"`
+}
+
 // TypingIndicatorData represents typing indicator data
 type TypingIndicatorData struct {
 	UserID     string `json:"userId"`
@@ -0,0 +1,629 @@
+package moderation
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"forum/internal/auth"
+	"forum/internal/database"
+	"forum/internal/messaging"
+	"forum/internal/models"
+)
+
+// Things that can be reported
+const (
+	TargetPost    = "post"
+	TargetComment = "comment"
+	TargetMessage = "message"
+	TargetUser    = "user"
+)
+
+// Report statuses. Open and claimed reports are pending.
+const (
+	StatusOpen      = "open"
+	StatusClaimed   = "claimed"
+	StatusResolved  = "resolved"
+	StatusDismissed = "dismissed"
+)
+
+// Actions a moderator can take when resolving a report
+const (
+	ActionNone    = "none"
+	ActionHide    = "hide"
+	ActionWarn    = "warn"
+	ActionSuspend = "suspend"
+)
+
+// Steps recorded in the audit trail besides the resolve actions
+const (
+	ActionClaim   = "claim"
+	ActionResolve = "resolve"
+	ActionDismiss = "dismiss"
+)
+
+const (
+	// MaxReasonLength is the maximum length of a report reason in characters
+	MaxReasonLength = 500
+
+	// MaxNoteLength is the maximum length of a moderator note in characters
+	MaxNoteLength = 1000
+
+	// MaxSuspendDays is the longest suspension a report can lead to
+	MaxSuspendDays = 365
+)
+
+// Errors returned by the moderation functions
+var (
+	ErrInvalidTarget     = errors.New("target type must be post, comment, message or user")
+	ErrTargetNotFound    = errors.New("reported content not found")
+	ErrCannotReportSelf  = errors.New("you cannot report yourself or your own content")
+	ErrReasonRequired    = errors.New("a reason is required")
+	ErrReasonTooLong     = fmt.Errorf("reason cannot exceed %d characters", MaxReasonLength)
+	ErrAlreadyReported   = errors.New("you have already reported this")
+	ErrInvalidStatus     = errors.New("status must be pending, open, claimed, resolved, dismissed or all")
+	ErrReportNotFound    = errors.New("report not found")
+	ErrReportClosed      = errors.New("report has already been closed")
+	ErrClaimedByOther    = errors.New("report is claimed by another moderator")
+	ErrInvalidAction     = errors.New("action does not apply to this report")
+	ErrNoteTooLong       = fmt.Errorf("note cannot exceed %d characters", MaxNoteLength)
+	ErrInvalidSuspension = fmt.Errorf("suspensions must last between 1 and %d days", MaxSuspendDays)
+)
+
+// IsValidationError reports whether err was caused by invalid input rather
+// than a storage failure
+func IsValidationError(err error) bool {
+	return errors.Is(err, ErrInvalidTarget) ||
+		errors.Is(err, ErrReasonRequired) ||
+		errors.Is(err, ErrReasonTooLong) ||
+		errors.Is(err, ErrInvalidStatus) ||
+		errors.Is(err, ErrInvalidAction) ||
+		errors.Is(err, ErrNoteTooLong) ||
+		errors.Is(err, ErrInvalidSuspension)
+}
+
+// Resolution is the outcome of closing a report
+type Resolution struct {
+	Report *models.Report
+
+	// ReporterIDs are the users whose reports were closed, to be told so
+	ReporterIDs []string
+
+	// RemovedMessage is the message taken down by a hide action, if any
+	RemovedMessage *models.Message
+
+	// Suspension is the suspension imposed by a suspend action, if any
+	Suspension *models.Suspension
+}
+
+// CreateReport files a report by reporterID. The content is copied into the
+// report so moderators see what was reported even after it is edited.
+func CreateReport(reporterID string, req models.ReportRequest) (*models.Report, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if utf8.RuneCountInString(reason) > MaxReasonLength {
+		return nil, ErrReasonTooLong
+	}
+
+	targetID := strings.TrimSpace(req.TargetID)
+	targetUserID, snapshot, err := lookupTarget(reporterID, req.TargetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if targetUserID == reporterID {
+		return nil, ErrCannotReportSelf
+	}
+
+	result, err := database.DB.Exec(`
+		INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, target_snapshot, reason, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, reporterID, req.TargetType, targetID, targetUserID, snapshot, reason, StatusOpen, time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, ErrAlreadyReported
+		}
+		return nil, fmt.Errorf("failed to create report: %v", err)
+	}
+
+	id, _ := result.LastInsertId()
+	log.Printf("🚩 User %s reported %s %s", reporterID, req.TargetType, targetID)
+	return getReport(database.DB, id)
+}
+
+// lookupTarget finds the author of the reported content, or the reported
+// user, and the text to keep as a snapshot. Messages can only be reported
+// by members of their conversation.
+func lookupTarget(reporterID, targetType, targetID string) (string, string, error) {
+	var userID, snapshot string
+	var err error
+
+	switch targetType {
+	case TargetPost:
+		var title, content string
+		err = database.DB.QueryRow(
+			"SELECT user_id, title, content FROM posts WHERE id = ? AND hidden_at IS NULL", numericID(targetID),
+		).Scan(&userID, &title, &content)
+		snapshot = title + "\n\n" + content
+	case TargetComment:
+		err = database.DB.QueryRow(
+			"SELECT user_id, content FROM comments WHERE id = ? AND hidden_at IS NULL", numericID(targetID),
+		).Scan(&userID, &snapshot)
+	case TargetMessage:
+		message, msgErr := messaging.GetMessageForUser(reporterID, targetID)
+		if errors.Is(msgErr, messaging.ErrMessageNotFound) || (msgErr == nil && message.IsDeleted) {
+			return "", "", ErrTargetNotFound
+		}
+		if msgErr != nil {
+			return "", "", msgErr
+		}
+		return message.SenderID, message.Content, nil
+	case TargetUser:
+		err = database.DB.QueryRow("SELECT id, nickname FROM users WHERE id = ?", targetID).Scan(&userID, &snapshot)
+	default:
+		return "", "", ErrInvalidTarget
+	}
+
+	if err == sql.ErrNoRows {
+		return "", "", ErrTargetNotFound
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to look up reported %s: %v", targetType, err)
+	}
+	return userID, snapshot, nil
+}
+
+// numericID parses the ID of a post or comment, mapping anything invalid to
+// an ID that matches no row
+func numericID(id string) int64 {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// ListReports returns reports with the given status, newest first. An empty
+// status or "pending" lists open and claimed reports; "all" lists every
+// report. When beforeID is set, only older reports are returned.
+func ListReports(status string, limit int, beforeID int64) ([]models.Report, error) {
+	query := reportSelect + ` WHERE 1 = 1`
+	args := []interface{}{}
+
+	switch status {
+	case "", "pending":
+		query += ` AND r.status IN (?, ?)`
+		args = append(args, StatusOpen, StatusClaimed)
+	case StatusOpen, StatusClaimed, StatusResolved, StatusDismissed:
+		query += ` AND r.status = ?`
+		args = append(args, status)
+	case "all":
+	default:
+		return nil, ErrInvalidStatus
+	}
+
+	if beforeID > 0 {
+		query += ` AND r.id < ?`
+		args = append(args, beforeID)
+	}
+
+	query += ` ORDER BY r.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reports: %v", err)
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+
+	return reports, rows.Err()
+}
+
+// GetReport retrieves a report with the moderation actions taken on it
+func GetReport(id int64) (*models.ReportDetail, error) {
+	report, err := getReport(database.DB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT a.id, a.report_id, u.nickname, a.action, a.target_type, a.target_id, a.note, a.created_at
+		FROM moderation_actions a
+		LEFT JOIN users u ON a.moderator_id = u.id
+		WHERE a.report_id = ?
+		ORDER BY a.id ASC
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query moderation actions: %v", err)
+	}
+	defer rows.Close()
+
+	detail := &models.ReportDetail{Report: report, Actions: []models.ModerationAction{}}
+	for rows.Next() {
+		var action models.ModerationAction
+		if err := rows.Scan(
+			&action.ID, &action.ReportID, &action.Moderator, &action.Action,
+			&action.TargetType, &action.TargetID, &action.Note, &action.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan moderation action: %v", err)
+		}
+		detail.Actions = append(detail.Actions, action)
+	}
+
+	return detail, rows.Err()
+}
+
+// ClaimReport assigns an open report to a moderator so others know it is
+// being handled. Claiming a report again is a no-op for the same moderator.
+func ClaimReport(id int64, moderatorID string) (*models.Report, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	report, claimedBy, err := getPendingReport(tx, id, moderatorID)
+	if err != nil {
+		return nil, err
+	}
+	if claimedBy == moderatorID {
+		return report, nil
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE reports SET status = ?, claimed_by = ?, claimed_at = ? WHERE id = ?
+	`, StatusClaimed, moderatorID, now, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim report: %v", err)
+	}
+	if err := recordAction(tx, &id, moderatorID, ActionClaim, report.TargetType, report.TargetID, "", now); err != nil {
+		return nil, err
+	}
+
+	report, err = getReport(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit claim: %v", err)
+	}
+
+	return report, nil
+}
+
+// ResolveReport closes a report with an action against the reported content
+// or user. Every other pending report on the same target is closed with it.
+func ResolveReport(id int64, moderatorID string, req models.ResolveReportRequest) (*Resolution, error) {
+	note, err := validateNote(req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	action := req.Action
+	if action == "" {
+		action = ActionNone
+	}
+
+	report, err := getReport(database.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAction(report, action, req.SuspendDays); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if report, _, err = getPendingReport(tx, id, moderatorID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	resolution := &Resolution{}
+	if resolution.Suspension, err = applyAction(tx, report, moderatorID, action, note, req.SuspendDays, now); err != nil {
+		return nil, err
+	}
+
+	// Close every pending report on the target, this one included
+	rows, err := tx.Query(`
+		SELECT id, reporter_id FROM reports
+		WHERE target_type = ? AND target_id = ? AND status IN (?, ?)
+	`, report.TargetType, report.TargetID, StatusOpen, StatusClaimed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find reports to close: %v", err)
+	}
+	var reportIDs []int64
+	for rows.Next() {
+		var reportID int64
+		var reporterID string
+		if err := rows.Scan(&reportID, &reporterID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan report: %v", err)
+		}
+		reportIDs = append(reportIDs, reportID)
+		resolution.ReporterIDs = append(resolution.ReporterIDs, reporterID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, reportID := range reportIDs {
+		if err := closeReport(tx, reportID, moderatorID, StatusResolved, action, note, now); err != nil {
+			return nil, err
+		}
+		if err := recordAction(tx, &reportID, moderatorID, ActionResolve, report.TargetType, report.TargetID, note, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// Messages are taken down through messaging so the conversation is
+	// fixed up like for any other deletion, in the same transaction so a
+	// failure leaves the report open
+	removed := false
+	if action == ActionHide && report.TargetType == TargetMessage {
+		err := messaging.RemoveTx(tx, report.TargetID)
+		switch {
+		case err == nil:
+			removed = true
+		case errors.Is(err, messaging.ErrMessageNotFound), errors.Is(err, messaging.ErrMessageDeleted):
+		default:
+			return nil, err
+		}
+	}
+
+	if resolution.Report, err = getReport(tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit resolution: %v", err)
+	}
+
+	if removed {
+		if resolution.RemovedMessage, err = messaging.GetMessage(report.TargetID); err != nil {
+			log.Printf("❌ Failed to load removed message %s: %v", report.TargetID, err)
+		}
+	}
+
+	log.Printf("🚩 Report %d resolved by %s with action %s", id, moderatorID, action)
+	return resolution, nil
+}
+
+// DismissReport closes a report without taking action
+func DismissReport(id int64, moderatorID, note string) (*Resolution, error) {
+	note, err := validateNote(note)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	report, _, err := getPendingReport(tx, id, moderatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := closeReport(tx, id, moderatorID, StatusDismissed, ActionNone, note, now); err != nil {
+		return nil, err
+	}
+	if err := recordAction(tx, &id, moderatorID, ActionDismiss, report.TargetType, report.TargetID, note, now); err != nil {
+		return nil, err
+	}
+
+	resolution := &Resolution{ReporterIDs: []string{report.ReporterID}}
+	if resolution.Report, err = getReport(tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit dismissal: %v", err)
+	}
+
+	log.Printf("🚩 Report %d dismissed by %s", id, moderatorID)
+	return resolution, nil
+}
+
+// checkAction validates a resolve action against the report's target
+func checkAction(report *models.Report, action string, suspendDays int) error {
+	switch action {
+	case ActionNone:
+		return nil
+	case ActionHide:
+		if report.TargetType == TargetUser {
+			return ErrInvalidAction
+		}
+		return nil
+	case ActionWarn, ActionSuspend:
+		if report.TargetUserID == nil {
+			return ErrTargetNotFound
+		}
+		if action == ActionSuspend && (suspendDays < 1 || suspendDays > MaxSuspendDays) {
+			return ErrInvalidSuspension
+		}
+		return nil
+	default:
+		return ErrInvalidAction
+	}
+}
+
+// applyAction carries out a resolve action and records it. Warnings are
+// only recorded here; the caller notifies the warned user. Suspensions are
+// returned for the caller to disconnect the user.
+func applyAction(tx *sql.Tx, report *models.Report, moderatorID, action, note string, suspendDays int, now time.Time) (*models.Suspension, error) {
+	targetType, targetID := report.TargetType, report.TargetID
+	var suspension *models.Suspension
+
+	switch action {
+	case ActionNone:
+		return nil, nil
+	case ActionHide:
+		switch report.TargetType {
+		case TargetPost:
+			_, err := tx.Exec("UPDATE posts SET hidden_at = ? WHERE id = ? AND hidden_at IS NULL", now, numericID(targetID))
+			if err != nil {
+				return nil, fmt.Errorf("failed to hide post: %v", err)
+			}
+		case TargetComment:
+			_, err := tx.Exec("UPDATE comments SET hidden_at = ? WHERE id = ? AND hidden_at IS NULL", now, numericID(targetID))
+			if err != nil {
+				return nil, fmt.Errorf("failed to hide comment: %v", err)
+			}
+		}
+	case ActionWarn:
+		targetType, targetID = TargetUser, *report.TargetUserID
+	case ActionSuspend:
+		targetType, targetID = TargetUser, *report.TargetUserID
+
+		reason := note
+		if reason == "" {
+			reason = report.Reason
+		}
+		var err error
+		suspension, err = auth.SuspendUserTx(tx, targetID, moderatorID, reason, time.Duration(suspendDays)*24*time.Hour)
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, ErrTargetNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return suspension, recordAction(tx, &report.ID, moderatorID, action, targetType, targetID, note, now)
+}
+
+// getPendingReport loads a report that is still open, or claimed by the
+// given moderator, along with who claimed it
+func getPendingReport(tx *sql.Tx, id int64, moderatorID string) (*models.Report, string, error) {
+	var claimedBy sql.NullString
+	err := tx.QueryRow("SELECT claimed_by FROM reports WHERE id = ?", id).Scan(&claimedBy)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrReportNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get report: %v", err)
+	}
+
+	report, err := getReport(tx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if report.Status != StatusOpen && report.Status != StatusClaimed {
+		return nil, "", ErrReportClosed
+	}
+	if claimedBy.Valid && claimedBy.String != moderatorID {
+		return nil, "", ErrClaimedByOther
+	}
+
+	return report, claimedBy.String, nil
+}
+
+// closeReport marks a report resolved or dismissed
+func closeReport(tx *sql.Tx, id int64, moderatorID, status, action, note string, now time.Time) error {
+	_, err := tx.Exec(`
+		UPDATE reports SET status = ?, closed_by = ?, closed_at = ?, action = ?, note = ? WHERE id = ?
+	`, status, moderatorID, now, action, nullIfEmpty(note), id)
+	if err != nil {
+		return fmt.Errorf("failed to close report: %v", err)
+	}
+	return nil
+}
+
+// recordAction adds a step to the moderation audit trail
+func recordAction(tx *sql.Tx, reportID *int64, moderatorID, action, targetType, targetID, note string, now time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO moderation_actions (report_id, moderator_id, action, target_type, target_id, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, reportID, moderatorID, action, targetType, targetID, nullIfEmpty(note), now)
+	if err != nil {
+		return fmt.Errorf("failed to record moderation action: %v", err)
+	}
+	return nil
+}
+
+func validateNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxNoteLength {
+		return "", ErrNoteTooLong
+	}
+	return note, nil
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// getReport retrieves a single report
+func getReport(q queryer, id int64) (*models.Report, error) {
+	report, err := scanReport(q.QueryRow(reportSelect+` WHERE r.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrReportNotFound
+	}
+	return report, err
+}
+
+// reportSelect selects the columns read by scanReport
+const reportSelect = `
+	SELECT r.id, r.reporter_id, reporter.nickname, r.target_type, r.target_id, r.target_user_id, target.nickname,
+	       r.target_snapshot, r.reason, r.status, claimer.nickname, r.claimed_at, closer.nickname, r.closed_at,
+	       r.action, r.note, r.created_at
+	FROM reports r
+	JOIN users reporter ON r.reporter_id = reporter.id
+	LEFT JOIN users target ON r.target_user_id = target.id
+	LEFT JOIN users claimer ON r.claimed_by = claimer.id
+	LEFT JOIN users closer ON r.closed_by = closer.id
+`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanReport scans a row selected with reportSelect
+func scanReport(row rowScanner) (*models.Report, error) {
+	var report models.Report
+	err := row.Scan(
+		&report.ID, &report.ReporterID, &report.Reporter, &report.TargetType, &report.TargetID,
+		&report.TargetUserID, &report.TargetUser, &report.Snapshot, &report.Reason, &report.Status,
+		&report.ClaimedBy, &report.ClaimedAt, &report.ClosedBy, &report.ClosedAt,
+		&report.Action, &report.Note, &report.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan report: %v", err)
+	}
+	return &report, nil
+}
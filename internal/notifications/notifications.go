@@ -0,0 +1,333 @@
+package notifications
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"forum/internal/blocks"
+	"forum/internal/database"
+	"forum/internal/models"
+	"forum/internal/websocket"
+)
+
+// Notification types
+const (
+	TypeCommentReply = "comment_reply"
+	TypePostComment  = "post_comment"
+	TypePostLike     = "post_like"
+	TypeCommentLike  = "comment_like"
+	TypeMention      = "mention"
+
+	TypeReportResolved    = "report_resolved"
+	TypeReportDismissed   = "report_dismissed"
+	TypeModerationWarning = "moderation_warning"
+)
+
+// MaxMarkRead is the most notification IDs one MarkRead call accepts
+const MaxMarkRead = 500
+
+// ErrTooManyIDs is returned when more than MaxMarkRead IDs are marked at once
+var ErrTooManyIDs = fmt.Errorf("cannot mark more than %d notifications at once", MaxMarkRead)
+
+// mentionPattern matches @nickname using the characters allowed in nicknames
+var mentionPattern = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_-])@([a-zA-Z0-9_-]+)`)
+
+// Notify stores a notification and pushes it to the recipient's open sessions.
+// Notifications about a user's own actions, or caused by someone the
+// recipient has blocked, are ignored.
+func Notify(n *models.Notification) error {
+	if n.ActorID != nil {
+		if *n.ActorID == n.UserID {
+			return nil
+		}
+		blocked, err := blocks.HasBlocked(n.UserID, *n.ActorID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return nil
+		}
+	}
+
+	n.CreatedAt = time.Now()
+	result, err := database.DB.Exec(`
+		INSERT INTO notifications (user_id, actor_id, type, message, post_id, comment_id, is_read, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, 0, ?)
+	`, n.UserID, n.ActorID, n.Type, n.Message, n.PostID, n.CommentID, n.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store notification: %v", err)
+	}
+	n.ID, _ = result.LastInsertId()
+
+	push(n)
+	return nil
+}
+
+// NotifyCommentCreated notifies the parent comment author (for replies) or
+// the post author (for top-level comments), plus anyone @mentioned
+func NotifyCommentCreated(actor *models.User, comment *models.Comment) {
+	notified := map[string]bool{actor.ID: true}
+
+	var recipientID, notificationType, message string
+	if comment.ParentID != nil {
+		database.DB.QueryRow("SELECT user_id FROM comments WHERE id = ?", *comment.ParentID).Scan(&recipientID)
+		notificationType = TypeCommentReply
+		message = fmt.Sprintf("%s replied to your comment", actor.Nickname)
+	} else {
+		database.DB.QueryRow("SELECT user_id FROM posts WHERE id = ?", comment.PostID).Scan(&recipientID)
+		notificationType = TypePostComment
+		message = fmt.Sprintf("%s commented on your post", actor.Nickname)
+	}
+
+	if recipientID != "" && !notified[recipientID] {
+		notified[recipientID] = true
+		postID, commentID := comment.PostID, comment.ID
+		logError(Notify(&models.Notification{
+			UserID:    recipientID,
+			ActorID:   &actor.ID,
+			Type:      notificationType,
+			Message:   message,
+			PostID:    &postID,
+			CommentID: &commentID,
+		}))
+	}
+
+	postID, commentID := comment.PostID, comment.ID
+	notifyMentions(actor, comment.Content, &postID, &commentID, "a comment", notified)
+}
+
+// NotifyPostCreated notifies users @mentioned in a new post
+func NotifyPostCreated(actor *models.User, post *models.Post) {
+	postID := post.ID
+	notifyMentions(actor, post.Title+"\n"+post.Content, &postID, nil, "a post", map[string]bool{actor.ID: true})
+}
+
+// NotifyLiked notifies the author of a liked post or comment
+func NotifyLiked(actor *models.User, postID, commentID *int) {
+	var recipientID, notificationType, message string
+	var targetPostID *int
+
+	if postID != nil {
+		database.DB.QueryRow("SELECT user_id FROM posts WHERE id = ?", *postID).Scan(&recipientID)
+		notificationType = TypePostLike
+		message = fmt.Sprintf("%s liked your post", actor.Nickname)
+		targetPostID = postID
+	} else if commentID != nil {
+		var commentPostID int
+		database.DB.QueryRow("SELECT user_id, post_id FROM comments WHERE id = ?", *commentID).Scan(&recipientID, &commentPostID)
+		notificationType = TypeCommentLike
+		message = fmt.Sprintf("%s liked your comment", actor.Nickname)
+		targetPostID = &commentPostID
+	}
+
+	if recipientID == "" {
+		return
+	}
+
+	logError(Notify(&models.Notification{
+		UserID:    recipientID,
+		ActorID:   &actor.ID,
+		Type:      notificationType,
+		Message:   message,
+		PostID:    targetPostID,
+		CommentID: commentID,
+	}))
+}
+
+// NotifyReportClosed tells reporters that the moderators have dealt with
+// their report. Moderators stay anonymous, so there is no actor.
+func NotifyReportClosed(reporterIDs []string, targetType string, dismissed bool) {
+	notificationType := TypeReportResolved
+	message := fmt.Sprintf("Thanks for your report. A moderator reviewed the %s and took action.", targetType)
+	if dismissed {
+		notificationType = TypeReportDismissed
+		message = fmt.Sprintf("Thanks for your report. A moderator reviewed the %s and found no problem.", targetType)
+	}
+
+	for _, reporterID := range reporterIDs {
+		logError(Notify(&models.Notification{
+			UserID:  reporterID,
+			Type:    notificationType,
+			Message: message,
+		}))
+	}
+}
+
+// NotifyWarning delivers a moderator's warning to a user
+func NotifyWarning(userID, note string) {
+	message := "A moderator warned you about content you posted."
+	if note != "" {
+		message = "A moderator warned you: " + note
+	}
+
+	logError(Notify(&models.Notification{
+		UserID:  userID,
+		Type:    TypeModerationWarning,
+		Message: message,
+	}))
+}
+
+// ExtractMentions returns the distinct nicknames @mentioned in text, in order of appearance
+func ExtractMentions(text string) []string {
+	seen := map[string]bool{}
+	var nicknames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		nickname := match[1]
+		if !seen[strings.ToLower(nickname)] {
+			seen[strings.ToLower(nickname)] = true
+			nicknames = append(nicknames, nickname)
+		}
+	}
+	return nicknames
+}
+
+// notifyMentions notifies every mentioned user not already in notified
+func notifyMentions(actor *models.User, text string, postID, commentID *int, where string, notified map[string]bool) {
+	for _, nickname := range ExtractMentions(text) {
+		// Mentions ignore case, preferring the exact nickname when several
+		// differ only in case
+		var userID string
+		err := database.DB.QueryRow(`
+			SELECT id FROM users WHERE nickname = ? COLLATE NOCASE
+			ORDER BY nickname = ? DESC LIMIT 1
+		`, nickname, nickname).Scan(&userID)
+		if err != nil || notified[userID] {
+			continue
+		}
+		notified[userID] = true
+
+		logError(Notify(&models.Notification{
+			UserID:    userID,
+			ActorID:   &actor.ID,
+			Type:      TypeMention,
+			Message:   fmt.Sprintf("%s mentioned you in %s", actor.Nickname, where),
+			PostID:    postID,
+			CommentID: commentID,
+		}))
+	}
+}
+
+// List returns a user's notifications, newest first. When beforeID is set,
+// only notifications older than that ID are returned.
+func List(userID string, limit int, beforeID int64, unreadOnly bool) ([]models.Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.actor_id, n.type, n.message, n.post_id, n.comment_id, n.is_read, n.created_at,
+		       u.nickname, u.avatar_url
+		FROM notifications n
+		LEFT JOIN users u ON n.actor_id = u.id
+		WHERE n.user_id = ?
+	`
+	args := []interface{}{userID}
+
+	if beforeID > 0 {
+		query += ` AND n.id < ?`
+		args = append(args, beforeID)
+	}
+	if unreadOnly {
+		query += ` AND n.is_read = 0`
+	}
+
+	query += ` ORDER BY n.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %v", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var actorNickname sql.NullString
+		if err := rows.Scan(
+			&n.ID, &n.UserID, &n.ActorID, &n.Type, &n.Message, &n.PostID, &n.CommentID, &n.IsRead, &n.CreatedAt,
+			&actorNickname, &n.ActorAvatar,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %v", err)
+		}
+		n.ActorNickname = actorNickname.String
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// MarkRead marks the given notifications of a user as read, or all of them
+// when ids is empty. At most MaxMarkRead IDs are accepted.
+func MarkRead(userID string, ids []int64) error {
+	if len(ids) > MaxMarkRead {
+		return ErrTooManyIDs
+	}
+	if len(ids) == 0 {
+		_, err := database.DB.Exec("UPDATE notifications SET is_read = 1 WHERE user_id = ? AND is_read = 0", userID)
+		if err != nil {
+			return fmt.Errorf("failed to mark notifications as read: %v", err)
+		}
+		return nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := []interface{}{userID}
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+
+	_, err := database.DB.Exec(`
+		UPDATE notifications SET is_read = 1
+		WHERE user_id = ? AND id IN (`+strings.Join(placeholders, ", ")+`)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to mark notifications as read: %v", err)
+	}
+	return nil
+}
+
+// UnreadCount returns the number of unread notifications of a user
+func UnreadCount(userID string) (int, error) {
+	var count int
+	err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = 0", userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %v", err)
+	}
+	return count, nil
+}
+
+// push delivers a stored notification to the recipient if they are online
+func push(n *models.Notification) {
+	hub := websocket.GetHub()
+	if hub == nil || !hub.IsUserOnline(n.UserID) {
+		return
+	}
+
+	data := models.NotificationData{
+		ID:        n.ID,
+		Type:      n.Type,
+		Message:   n.Message,
+		PostID:    n.PostID,
+		CommentID: n.CommentID,
+		CreatedAt: n.CreatedAt,
+	}
+	if n.ActorID != nil {
+		data.UserID = *n.ActorID
+	}
+
+	hub.BroadcastToUser(n.UserID, models.WebSocketMessage{
+		Type:      "notification",
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+func logError(err error) {
+	if err != nil {
+		log.Printf("❌ Notification error: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"forum/internal/config"
+)
+
+// Policy names. Each one has its own limits in config.RateLimitConfig.
+const (
+	Login     = "login"
+	Register  = "register"
+	Post      = "post"
+	Comment   = "comment"
+	Like      = "like"
+	Message   = "message"
+	WebSocket = "websocket"
+	Email     = "email"
+	Report    = "report"
+)
+
+// Limiter decides whether the caller identified by key may act now. When it
+// may not, Allow returns how long to wait before trying again.
+type Limiter interface {
+	Allow(key string) (bool, time.Duration)
+}
+
+var (
+	limiters = map[string]Limiter{}
+	mutex    sync.RWMutex
+)
+
+// Initialize creates an in-memory token bucket for every configured policy
+func Initialize(cfg config.RateLimitConfig) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	limiters = map[string]Limiter{}
+	if !cfg.Enabled {
+		log.Println("⚠️ Rate limiting disabled")
+		return
+	}
+
+	for name, policy := range cfg.Policies() {
+		limiters[name] = NewTokenBucket(policy.Requests, policy.Per.Duration, policy.Burst)
+	}
+	log.Println("✅ Rate limiting enabled")
+}
+
+// SetLimiter replaces the limiter used for a policy, for example with one
+// shared between instances. A nil limiter removes the limit.
+func SetLimiter(policy string, limiter Limiter) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if limiter == nil {
+		delete(limiters, policy)
+		return
+	}
+	limiters[policy] = limiter
+}
+
+// Allow checks key against the named policy. Policies without a limiter
+// always allow.
+func Allow(policy, key string) (bool, time.Duration) {
+	mutex.RLock()
+	limiter := limiters[policy]
+	mutex.RUnlock()
+
+	if limiter == nil {
+		return true, 0
+	}
+	return limiter.Allow(key)
+}
+
+// UserKey is the limiter key for an authenticated user
+func UserKey(userID string) string {
+	return "user:" + userID
+}
+
+// IPKey is the limiter key for an anonymous client
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds for the Retry-After header
+func RetryAfterSeconds(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// sweepInterval is how often idle buckets are dropped from memory
+const sweepInterval = time.Minute
+
+// TokenBucket is an in-memory Limiter. Each key gets a bucket of burst
+// tokens that refills at requests per period; every allowed call takes one.
+type TokenBucket struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewTokenBucket creates a limiter allowing requests per period with bursts
+// of up to burst. A burst of zero or less defaults to requests.
+func NewTokenBucket(requests int, per time.Duration, burst int) *TokenBucket {
+	if burst <= 0 {
+		burst = requests
+	}
+	return &TokenBucket{
+		rate:      float64(requests) / per.Seconds(),
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from key's bucket if one is available
+func (tb *TokenBucket) Allow(key string) (bool, time.Duration) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(tb.lastSweep) >= sweepInterval {
+		tb.sweep(now)
+	}
+
+	b, exists := tb.buckets[key]
+	if !exists {
+		b = &bucket{tokens: tb.burst, updated: now}
+		tb.buckets[key] = b
+	} else {
+		b.tokens = math.Min(tb.burst, b.tokens+now.Sub(b.updated).Seconds()*tb.rate)
+		b.updated = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / tb.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// starts full anyway
+func (tb *TokenBucket) sweep(now time.Time) {
+	for key, b := range tb.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*tb.rate >= tb.burst {
+			delete(tb.buckets, key)
+		}
+	}
+	tb.lastSweep = now
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"forum/internal/messaging"
+	"forum/internal/models"
+)
+
+// typingState tracks a client that is typing to one receiver
+type typingState struct {
+	// Whether isTyping:true was relayed this session, so that isTyping:false
+	// is only relayed after it
+	relayed bool
+	timer   *time.Timer
+}
+
+// handleTypingIndicator relays typing indicators to the receiver's sessions
+func (c *Client) handleTypingIndicator(data interface{}) {
+	typingData, ok := data.(map[string]interface{})
+	if !ok {
+		log.Printf("Invalid typing indicator data format from user %s", c.UserID)
+		return
+	}
+
+	receiverID, _ := typingData["receiverId"].(string)
+	isTyping, _ := typingData["isTyping"].(bool)
+
+	if !isTyping {
+		c.stopTyping(receiverID)
+		return
+	}
+
+	c.typingMutex.Lock()
+	state, exists := c.typing[receiverID]
+	c.typingMutex.Unlock()
+
+	// Only check permissions when a typing session starts
+	if !exists {
+		if err := messaging.CanMessage(c.UserID, receiverID); err != nil {
+			log.Printf("Dropped typing indicator from user %s: %v", c.UserID, err)
+			return
+		}
+	}
+
+	c.typingMutex.Lock()
+	if c.typing == nil {
+		c.typing = make(map[string]*typingState)
+		c.typingRelayed = make(map[string]time.Time)
+	}
+	state, exists = c.typing[receiverID]
+	if !exists {
+		state = &typingState{
+			timer: time.AfterFunc(settings.TypingTimeout.Duration, func() { c.stopTyping(receiverID) }),
+		}
+		c.typing[receiverID] = state
+	} else {
+		state.timer.Reset(settings.TypingTimeout.Duration)
+	}
+
+	// At most one isTyping:true per throttle interval, even across sessions
+	// stopped and started again; the timeout sends isTyping:false on the
+	// client's behalf once refreshes stop
+	relay := time.Since(c.typingRelayed[receiverID]) >= settings.TypingThrottle.Duration
+	if relay {
+		c.typingRelayed[receiverID] = time.Now()
+		state.relayed = true
+	}
+	c.typingMutex.Unlock()
+
+	if relay {
+		c.relayTyping(receiverID, true)
+	}
+}
+
+// stopTyping ends a typing session and tells the receiver
+func (c *Client) stopTyping(receiverID string) {
+	c.typingMutex.Lock()
+	state, exists := c.typing[receiverID]
+	if exists {
+		state.timer.Stop()
+		delete(c.typing, receiverID)
+	}
+	// Relay times older than the throttle interval no longer matter
+	if time.Since(c.typingRelayed[receiverID]) >= settings.TypingThrottle.Duration {
+		delete(c.typingRelayed, receiverID)
+	}
+	c.typingMutex.Unlock()
+
+	if exists && state.relayed {
+		c.relayTyping(receiverID, false)
+	}
+}
+
+// clearTyping ends every typing session of a disconnecting client
+func (c *Client) clearTyping() {
+	c.typingMutex.Lock()
+	receivers := make([]string, 0, len(c.typing))
+	for receiverID := range c.typing {
+		receivers = append(receivers, receiverID)
+	}
+	c.typingMutex.Unlock()
+
+	for _, receiverID := range receivers {
+		c.stopTyping(receiverID)
+	}
+}
+
+// relayTyping sends a typing_indicator event to the receiver if they are online
+func (c *Client) relayTyping(receiverID string, isTyping bool) {
+	if !c.Hub.IsUserOnline(receiverID) {
+		return
+	}
+
+	c.Hub.BroadcastToUser(receiverID, models.WebSocketMessage{
+		Type: "typing_indicator",
+		Data: models.TypingIndicatorData{
+			UserID:     c.UserID,
+			ReceiverID: receiverID,
+			IsTyping:   isTyping,
+		},
+		Timestamp: time.Now(),
+	})
+}
@@ -1,35 +1,54 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	"forum/internal/auth"
+	"forum/internal/config"
 	"forum/internal/database"
+	"forum/internal/messaging"
 	"forum/internal/models"
+	"forum/internal/ratelimit"
 
 	"github.com/gorilla/websocket"
 )
 
 var (
 	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true // Allow all origins in development
-		},
+		// Only the forum itself and the configured origins may connect
+		CheckOrigin: auth.OriginAllowed,
 	}
 	hub *Hub
+
+	// settings holds the connection timeouts and limits passed to InitializeHub
+	settings = config.Default().WebSocket
 )
 
 // Client represents a WebSocket client
 type Client struct {
-	ID     string
-	UserID string
-	Conn   *websocket.Conn
-	Send   chan []byte
-	Hub    *Hub
+	ID        string
+	UserID    string
+	SessionID string // public ID of the login session the connection was opened with; stable across rotation
+	Conn      *websocket.Conn
+	Send      chan []byte
+	Hub       *Hub
+
+	// Close frame to send once the queued messages are written
+	disconnect chan []byte
+
+	// Receivers this client is currently typing to, and when isTyping:true
+	// was last relayed to each. The latter outlives typing sessions so
+	// stopping and starting again cannot get around the throttle.
+	typing        map[string]*typingState
+	typingRelayed map[string]time.Time
+	typingMutex   sync.Mutex
 }
 
 // Hub maintains the set of active clients and broadcasts messages to the clients
@@ -51,6 +70,16 @@ type Hub struct {
 
 	// Mutex for thread-safe operations
 	mutex sync.RWMutex
+
+	// Set once Shutdown starts; no new clients are accepted after that
+	closing bool
+
+	// Running read and write pumps, so Shutdown can wait for queues to flush
+	pumps sync.WaitGroup
+
+	// Held while Run handles an event, so Shutdown can wait for the one in
+	// progress to finish
+	handling sync.Mutex
 }
 
 // NewHub creates a new Hub
@@ -69,103 +98,139 @@ func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.register:
-			h.mutex.Lock()
+			h.handling.Lock()
+			h.registerClient(client)
+			h.handling.Unlock()
 
-			// Add client to general clients map
-			h.clients[client] = true
+		case client := <-h.unregister:
+			h.handling.Lock()
+			h.unregisterClient(client)
+			h.handling.Unlock()
 
-			// Add client to user-specific clients list (supports multiple sessions per user)
-			if _, exists := h.userClients[client.UserID]; !exists {
-				h.userClients[client.UserID] = []*Client{}
-			}
-			h.userClients[client.UserID] = append(h.userClients[client.UserID], client)
+		case message := <-h.broadcast:
+			h.handling.Lock()
+			h.broadcastToClients(message)
+			h.handling.Unlock()
+		}
+	}
+}
 
-			totalClients := len(h.clients)
-			totalUsers := len(h.userClients)
-			userSessions := len(h.userClients[client.UserID])
-			h.mutex.Unlock()
+// registerClient adds a connected client and announces its user as online
+func (h *Hub) registerClient(client *Client) {
+	h.mutex.Lock()
+	if h.closing {
+		// Shutdown started after this client was accepted
+		h.mutex.Unlock()
+		close(client.Send)
+		return
+	}
 
-			log.Printf("✅ Client %s (User: %s) connected. Total clients: %d, Total unique users: %d, User sessions: %d",
-				client.ID, client.UserID, totalClients, totalUsers, userSessions)
+	// Add client to general clients map
+	h.clients[client] = true
 
-			// Update database with user online status (using client ID as session ID)
-			h.updateUserOnlineStatus(client.UserID, client.ID, true)
+	// Add client to user-specific clients list (supports multiple sessions per user)
+	if _, exists := h.userClients[client.UserID]; !exists {
+		h.userClients[client.UserID] = []*Client{}
+	}
+	h.userClients[client.UserID] = append(h.userClients[client.UserID], client)
 
-			// Only broadcast user online status if this is their first session
-			if userSessions == 1 {
-				h.broadcastUserStatus(client.UserID, "online")
-			}
+	totalClients := len(h.clients)
+	totalUsers := len(h.userClients)
+	userSessions := len(h.userClients[client.UserID])
+	h.mutex.Unlock()
 
-		case client := <-h.unregister:
-			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.Send)
+	log.Printf("✅ Client %s (User: %s) connected. Total clients: %d, Total unique users: %d, User sessions: %d",
+		client.ID, client.UserID, totalClients, totalUsers, userSessions)
 
-				// Remove client from user's client list
-				if userClients, exists := h.userClients[client.UserID]; exists {
-					// Find and remove this specific client
-					for i, c := range userClients {
-						if c == client {
-							h.userClients[client.UserID] = append(userClients[:i], userClients[i+1:]...)
-							break
-						}
-					}
-					// If no more clients for this user, remove the user entry
-					if len(h.userClients[client.UserID]) == 0 {
-						delete(h.userClients, client.UserID)
-					}
+	// Update database with user online status (using client ID as session ID)
+	h.updateUserOnlineStatus(client.UserID, client.ID, true)
+
+	// Only broadcast user online status if this is their first session
+	if userSessions == 1 {
+		h.broadcastUserStatus(client.UserID, "online")
+	}
+}
+
+// unregisterClient removes a disconnected client and announces its user as
+// offline once their last session is gone
+func (h *Hub) unregisterClient(client *Client) {
+	h.mutex.Lock()
+	if h.closing {
+		// Shutdown already removed the client and cleared its session
+		h.mutex.Unlock()
+		return
+	}
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.Send)
+
+		// Remove client from user's client list
+		if userClients, exists := h.userClients[client.UserID]; exists {
+			// Find and remove this specific client
+			for i, c := range userClients {
+				if c == client {
+					h.userClients[client.UserID] = append(userClients[:i], userClients[i+1:]...)
+					break
 				}
 			}
-
-			totalClients := len(h.clients)
-			totalUsers := len(h.userClients)
-			userSessions := 0
-			if userClients, exists := h.userClients[client.UserID]; exists {
-				userSessions = len(userClients)
+			// If no more clients for this user, remove the user entry
+			if len(h.userClients[client.UserID]) == 0 {
+				delete(h.userClients, client.UserID)
 			}
-			h.mutex.Unlock()
+		}
+	}
 
-			log.Printf("❌ Client %s (User: %s) disconnected. Total clients: %d, Total unique users: %d, Remaining user sessions: %d",
-				client.ID, client.UserID, totalClients, totalUsers, userSessions)
+	totalClients := len(h.clients)
+	totalUsers := len(h.userClients)
+	userSessions := 0
+	if userClients, exists := h.userClients[client.UserID]; exists {
+		userSessions = len(userClients)
+	}
+	h.mutex.Unlock()
 
-			// Update database with user offline status (remove this specific session)
-			h.updateUserOnlineStatus(client.UserID, client.ID, false)
+	log.Printf("❌ Client %s (User: %s) disconnected. Total clients: %d, Total unique users: %d, Remaining user sessions: %d",
+		client.ID, client.UserID, totalClients, totalUsers, userSessions)
 
-			// Only broadcast user offline status if this was their last session
-			if userSessions == 0 {
-				h.broadcastUserStatus(client.UserID, "offline")
-			}
+	// Tell anyone this client was typing to that it stopped
+	client.clearTyping()
 
-		case message := <-h.broadcast:
-			h.mutex.RLock()
-			for client := range h.clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.clients, client)
-					delete(h.userClients, client.UserID)
-				}
-			}
-			h.mutex.RUnlock()
+	// Update database with user offline status (remove this specific session)
+	h.updateUserOnlineStatus(client.UserID, client.ID, false)
+
+	// Only broadcast user offline status if this was their last session
+	if userSessions == 0 {
+		h.broadcastUserStatus(client.UserID, "offline")
+	}
+}
+
+// broadcastToClients queues a message for every connected client
+func (h *Hub) broadcastToClients(message []byte) {
+	// Full queues remove their client, so this needs the write lock
+	h.mutex.Lock()
+	for client := range h.clients {
+		select {
+		case client.Send <- message:
+		default:
+			close(client.Send)
+			delete(h.clients, client)
+			delete(h.userClients, client.UserID)
 		}
 	}
+	h.mutex.Unlock()
 }
 
 // SendToUser sends a message to all sessions of a specific user
 func (h *Hub) SendToUser(userID string, message []byte) {
 	h.mutex.RLock()
-	clients, exists := h.userClients[userID]
+	clients := append([]*Client(nil), h.userClients[userID]...)
 	h.mutex.RUnlock()
 
-	if exists {
-		for _, client := range clients {
-			select {
-			case client.Send <- message:
-			default:
-				// Client's send channel is full, remove this client
-				h.mutex.Lock()
+	for _, client := range clients {
+		if !client.queue(message) {
+			// Client's send channel is full, remove this client unless the
+			// hub already has
+			h.mutex.Lock()
+			if h.clients[client] {
 				close(client.Send)
 				delete(h.clients, client)
 
@@ -182,8 +247,8 @@ func (h *Hub) SendToUser(userID string, message []byte) {
 						delete(h.userClients, userID)
 					}
 				}
-				h.mutex.Unlock()
 			}
+			h.mutex.Unlock()
 		}
 	}
 }
@@ -194,31 +259,36 @@ func (h *Hub) BroadcastMessage(message []byte) {
 }
 
 // BroadcastToUser sends a message to a specific user (all their sessions)
-func (h *Hub) BroadcastToUser(userID string, wsMessage models.WebSocketMessage) {
+// and returns the number of sessions it was queued for
+func (h *Hub) BroadcastToUser(userID string, wsMessage models.WebSocketMessage) int {
+	data, err := json.Marshal(wsMessage)
+	if err != nil {
+		log.Printf("Error marshaling message for user %s: %v", userID, err)
+		return 0
+	}
+
+	// Queued under the read lock so unregister cannot close Send meanwhile
 	h.mutex.RLock()
-	clients := h.userClients[userID]
-	h.mutex.RUnlock()
+	defer h.mutex.RUnlock()
 
+	clients := h.userClients[userID]
 	if len(clients) == 0 {
 		log.Printf("No active sessions found for user %s", userID)
-		return
-	}
-
-	data, err := json.Marshal(wsMessage)
-	if err != nil {
-		log.Printf("Error marshaling message for user %s: %v", userID, err)
-		return
+		return 0
 	}
 
+	sent := 0
 	for _, client := range clients {
 		select {
 		case client.Send <- data:
+			sent++
 		default:
 			log.Printf("Failed to send message to client %s", client.ID)
 		}
 	}
 
 	log.Printf("Broadcasted message to user %s (%d sessions)", userID, len(clients))
+	return sent
 }
 
 // GetOnlineUsers returns a list of online user IDs
@@ -310,6 +380,17 @@ func (h *Hub) debugOnlineUsersTable() {
 
 // broadcastUserStatus broadcasts user online/offline status
 func (h *Hub) broadcastUserStatus(userID, status string) {
+	data, err := userStatusMessage(userID, status)
+	if err != nil {
+		log.Printf("Error marshaling user status message: %v", err)
+		return
+	}
+
+	h.BroadcastMessage(data)
+}
+
+// userStatusMessage builds a user_status event for a user
+func userStatusMessage(userID, status string) ([]byte, error) {
 	// Get user nickname from database
 	var nickname string
 	err := database.DB.QueryRow("SELECT nickname FROM users WHERE id = ?", userID).Scan(&nickname)
@@ -328,17 +409,249 @@ func (h *Hub) broadcastUserStatus(userID, status string) {
 		Timestamp: time.Now(),
 	}
 
-	data, err := json.Marshal(message)
+	return json.Marshal(message)
+}
+
+// Shutdown disconnects every client. Each client's queued messages are
+// flushed before a going-away close frame is sent. This instance's
+// online_users sessions are cleared and users left without any session are
+// announced as offline. Frames clients send during the drain are still
+// handled, but replies to them are dropped since the clients are no longer
+// registered. Shutdown returns once all connections have closed and the hub
+// no longer touches the database; connections still open when ctx expires
+// are closed without waiting.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mutex.Lock()
+	if h.closing {
+		h.mutex.Unlock()
+		return nil
+	}
+	h.closing = true
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.clients = make(map[*Client]bool)
+	h.userClients = make(map[string][]*Client)
+	h.mutex.Unlock()
+
+	log.Printf("🛑 Shutting down WebSocket hub, disconnecting %d clients", len(clients))
+
+	for _, userID := range clearOnlineSessions(clients) {
+		data, err := userStatusMessage(userID, "offline")
+		if err != nil {
+			log.Printf("Error marshaling user status message: %v", err)
+			continue
+		}
+		for _, client := range clients {
+			select {
+			case client.Send <- data:
+			default:
+			}
+		}
+	}
+
+	// Closing Send makes writePump flush the queue and then send the close frame
+	for _, client := range clients {
+		client.clearTyping()
+		close(client.Send)
+	}
+
+	// Once the pumps have exited, wait for Run to finish the event it is
+	// handling; events after that see closing and leave the database alone
+	done := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		h.handling.Lock()
+		h.handling.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("✅ WebSocket hub shut down")
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Out of time: close the remaining connections so their pumps exit
+	for _, client := range clients {
+		client.Conn.Close()
+	}
+	<-done
+
+	if len(clients) > 0 {
+		return fmt.Errorf("forced %d websocket connections closed: %w", len(clients), ctx.Err())
+	}
+	return nil
+}
+
+// CloseSessions disconnects the clients opened with any of the login sessions
+// with the given public IDs, for example after the sessions were revoked. The connections get
+// a policy violation close frame and then unregister as usual, so users left
+// without a connection are announced as offline. Returns the number of
+// clients closed.
+func (h *Hub) CloseSessions(publicIDs ...string) int {
+	revoked := make(map[string]bool, len(publicIDs))
+	for _, publicID := range publicIDs {
+		revoked[publicID] = true
+	}
+
+	h.mutex.RLock()
+	var clients []*Client
+	for client := range h.clients {
+		if revoked[client.SessionID] {
+			clients = append(clients, client)
+		}
+	}
+	h.mutex.RUnlock()
+
+	closeFrame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked")
+	for _, client := range clients {
+		// Close frames may be written concurrently with writePump
+		client.Conn.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(settings.WriteWait.Duration))
+		client.Conn.Close()
+	}
+
+	if len(clients) > 0 {
+		log.Printf("🔒 Closed %d WebSocket clients of revoked sessions", len(clients))
+	}
+	return len(clients)
+}
+
+// DisconnectUser sends wsMessage to every connection of the user and then
+// closes them with a policy violation close frame giving reason, for example
+// after the user was suspended. Returns the number of clients closed.
+func (h *Hub) DisconnectUser(userID string, wsMessage models.WebSocketMessage, reason string) int {
+	data, err := json.Marshal(wsMessage)
 	if err != nil {
-		log.Printf("Error marshaling user status message: %v", err)
-		return
+		log.Printf("Error marshaling message for user %s: %v", userID, err)
+		return 0
 	}
+	closeFrame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
 
-	h.BroadcastMessage(data)
+	// Queued under the read lock so unregister cannot close Send meanwhile
+	h.mutex.RLock()
+	clients := h.userClients[userID]
+	for _, client := range clients {
+		select {
+		case client.Send <- data:
+		default:
+		}
+		select {
+		case client.disconnect <- closeFrame:
+		default:
+		}
+	}
+	h.mutex.RUnlock()
+
+	if len(clients) > 0 {
+		log.Printf("⛔ Closed %d WebSocket clients of user %s: %s", len(clients), userID, reason)
+	}
+	return len(clients)
+}
+
+// PurgeStaleOnline refreshes the online_users rows of this hub's clients and
+// removes rows not refreshed within staleAfter, which were left behind by an
+// instance that stopped without clearing them. Users left without a session
+// are announced as offline. Returns the number of rows removed.
+func (h *Hub) PurgeStaleOnline(staleAfter time.Duration) (int, error) {
+	h.mutex.RLock()
+	clientIDs := make([]string, 0, len(h.clients))
+	for client := range h.clients {
+		clientIDs = append(clientIDs, client.ID)
+	}
+	h.mutex.RUnlock()
+
+	for _, clientID := range clientIDs {
+		_, err := database.DB.Exec("UPDATE online_users SET last_seen = CURRENT_TIMESTAMP WHERE session_id = ?", clientID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to refresh online session: %v", err)
+		}
+	}
+
+	// last_seen is written by SQLite, so compare it with SQLite's clock
+	rows, err := database.DB.Query(`
+		DELETE FROM online_users WHERE last_seen < datetime('now', ?)
+		RETURNING user_id
+	`, fmt.Sprintf("-%d seconds", int(staleAfter.Seconds())))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge stale online sessions: %v", err)
+	}
+
+	users := make(map[string]bool)
+	removed := 0
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return removed, fmt.Errorf("failed to scan stale online session: %v", err)
+		}
+		users[userID] = true
+		removed++
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return removed, fmt.Errorf("failed to purge stale online sessions: %v", err)
+	}
+
+	for userID := range users {
+		var remaining int
+		err := database.DB.QueryRow("SELECT COUNT(*) FROM online_users WHERE user_id = ?", userID).Scan(&remaining)
+		if err == nil && remaining == 0 {
+			h.broadcastUserStatus(userID, "offline")
+		}
+	}
+
+	return removed, nil
+}
+
+// clearOnlineSessions removes the online_users rows of the given clients and
+// returns the users left without a session on any instance
+func clearOnlineSessions(clients []*Client) []string {
+	users := make(map[string]bool)
+	for _, client := range clients {
+		_, err := database.DB.Exec(`
+			DELETE FROM online_users WHERE user_id = ? AND session_id = ?
+		`, client.UserID, client.ID)
+		if err != nil {
+			log.Printf("❌ Error removing user session from online status: %v", err)
+		}
+		users[client.UserID] = true
+	}
+
+	offline := make([]string, 0, len(users))
+	for userID := range users {
+		var remaining int
+		err := database.DB.QueryRow("SELECT COUNT(*) FROM online_users WHERE user_id = ?", userID).Scan(&remaining)
+		if err != nil {
+			log.Printf("❌ Error counting remaining sessions for user %s: %v", userID, err)
+			continue
+		}
+		if remaining == 0 {
+			offline = append(offline, userID)
+		}
+	}
+
+	return offline
+}
+
+// track registers a client's pumps with the hub unless it is shutting down
+func (h *Hub) track() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closing {
+		return false
+	}
+	h.pumps.Add(2)
+	return true
 }
 
 // InitializeHub initializes the global hub
-func InitializeHub() {
+func InitializeHub(cfg config.WebSocketConfig) {
+	settings = cfg
 	hub = NewHub()
 	go hub.Run()
 	log.Println("✅ WebSocket hub initialized")
@@ -352,29 +665,49 @@ func GetHub() *Hub {
 // HandleWebSocket handles WebSocket connections
 func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Get user from session
-	user := auth.GetUserFromSession(r)
-	if user == nil {
+	session, err := auth.GetSessionFromRequest(r)
+	if err != nil || session == nil {
+		log.Printf("❌ WebSocket: No user in session")
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	user, err := auth.GetUserByID(session.UserID)
+	if err != nil || user == nil {
 		log.Printf("❌ WebSocket: No user in session")
 		http.Error(w, "Authentication required", http.StatusUnauthorized)
 		return
 	}
+	if suspension, err := auth.GetActiveSuspension(user.ID); err != nil || suspension != nil {
+		log.Printf("⛔ WebSocket: Refused suspended user %s", user.ID)
+		http.Error(w, "Account suspended", http.StatusForbidden)
+		return
+	}
 
 	log.Printf("🔌 WebSocket: User %s (%s) connecting", user.Nickname, user.ID)
 
+	if !hub.track() {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
 	// Upgrade connection to WebSocket
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("❌ WebSocket upgrade error: %v", err)
+		hub.pumps.Add(-2)
 		return
 	}
 
 	// Create client
 	client := &Client{
-		ID:     user.ID + "_" + time.Now().Format("20060102150405"),
-		UserID: user.ID,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-		Hub:    hub,
+		ID:        user.ID + "_" + time.Now().Format("20060102150405"),
+		UserID:    user.ID,
+		SessionID: session.PublicID,
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		Hub:       hub,
+
+		disconnect: make(chan []byte, 1),
 	}
 
 	// Register client
@@ -390,12 +723,13 @@ func (c *Client) readPump() {
 	defer func() {
 		c.Hub.unregister <- c
 		c.Conn.Close()
+		c.Hub.pumps.Done()
 	}()
 
-	c.Conn.SetReadLimit(512)
-	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	c.Conn.SetReadLimit(settings.MaxFrameSize)
+	c.Conn.SetReadDeadline(time.Now().Add(settings.PongWait.Duration))
 	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.Conn.SetReadDeadline(time.Now().Add(settings.PongWait.Duration))
 		return nil
 	})
 
@@ -415,18 +749,19 @@ func (c *Client) readPump() {
 
 // writePump pumps messages from the hub to the websocket connection
 func (c *Client) writePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	ticker := time.NewTicker(settings.PingPeriod.Duration)
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
+		c.Hub.pumps.Done()
 	}()
 
 	for {
 		select {
 		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(settings.WriteWait.Duration))
 			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.Conn.WriteMessage(websocket.CloseMessage, c.Hub.closeMessage())
 				return
 			}
 
@@ -447,8 +782,20 @@ func (c *Client) writePump() {
 				return
 			}
 
+		case closeFrame := <-c.disconnect:
+			c.Conn.SetWriteDeadline(time.Now().Add(settings.WriteWait.Duration))
+			for n := len(c.Send); n > 0; n-- {
+				message, ok := <-c.Send
+				if !ok {
+					break
+				}
+				c.Conn.WriteMessage(websocket.TextMessage, message)
+			}
+			c.Conn.WriteMessage(websocket.CloseMessage, closeFrame)
+			return
+
 		case <-ticker.C:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(settings.WriteWait.Duration))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -456,6 +803,17 @@ func (c *Client) writePump() {
 	}
 }
 
+// closeMessage is the payload of the close frame sent when a client's queue is closed
+func (h *Hub) closeMessage() []byte {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.closing {
+		return websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	}
+	return []byte{}
+}
+
 // handleMessage handles incoming WebSocket messages
 func (c *Client) handleMessage(message []byte) {
 	var wsMessage models.WebSocketMessage
@@ -464,6 +822,14 @@ func (c *Client) handleMessage(message []byte) {
 		return
 	}
 
+	if allowed, _ := ratelimit.Allow(ratelimit.WebSocket, ratelimit.UserKey(c.UserID)); !allowed {
+		log.Printf("Dropped %s frame from user %s: rate limited", wsMessage.Type, c.UserID)
+		if wsMessage.Type == "private_message" {
+			c.sendMessageError(frameTempID(wsMessage.Data), "Too many messages, please slow down")
+		}
+		return
+	}
+
 	switch wsMessage.Type {
 	case "typing_indicator":
 		c.handleTypingIndicator(wsMessage.Data)
@@ -478,12 +844,6 @@ func (c *Client) handleMessage(message []byte) {
 	}
 }
 
-// handleTypingIndicator handles typing indicators
-func (c *Client) handleTypingIndicator(data interface{}) {
-	// Implementation for typing indicators
-	log.Printf("Typing indicator from user %s: %v", c.UserID, data)
-}
-
 // handlePing handles ping messages
 func (c *Client) handlePing() {
 	response := models.WebSocketMessage{
@@ -498,40 +858,125 @@ func (c *Client) handlePing() {
 		return
 	}
 
-	select {
-	case c.Send <- data:
-	default:
-		close(c.Send)
+	if !c.queue(data) {
+		log.Printf("Dropped pong for client %s: disconnected or send queue full", c.ID)
 	}
 }
 
-// handlePrivateMessage handles private message sending
+// handlePrivateMessage stores a private message sent over the socket, acks it
+// to the sender's sessions and delivers it to the receiver's sessions
 func (c *Client) handlePrivateMessage(data interface{}) {
 	// Parse the message data
 	messageData, ok := data.(map[string]interface{})
 	if !ok {
 		log.Printf("Invalid private message data format from user %s", c.UserID)
+		c.sendMessageError("", "Invalid message format")
 		return
 	}
 
-	receiverID, ok := messageData["receiverId"].(string)
-	if !ok || receiverID == "" {
-		log.Printf("Invalid receiver ID in private message from user %s", c.UserID)
+	// Optional client-side ID used to match the ack with the pending message
+	tempID, _ := messageData["tempId"].(string)
+
+	// Frames share the message budget of the HTTP send endpoints
+	if allowed, _ := ratelimit.Allow(ratelimit.Message, ratelimit.UserKey(c.UserID)); !allowed {
+		log.Printf("Rejected private message from user %s: rate limited", c.UserID)
+		c.sendMessageError(tempID, "Too many messages, please slow down")
 		return
 	}
 
-	content, ok := messageData["content"].(string)
-	if !ok || content == "" {
-		log.Printf("Invalid content in private message from user %s", c.UserID)
+	conversationID, _ := messageData["conversationId"].(string)
+	receiverID, _ := messageData["receiverId"].(string)
+	content, _ := messageData["content"].(string)
+
+	// Group messages are addressed to a conversation, direct ones to either
+	var message *models.Message
+	var err error
+	if conversationID != "" {
+		message, err = messaging.SendToConversation(c.UserID, conversationID, content)
+	} else {
+		message, err = messaging.Send(c.UserID, receiverID, content)
+	}
+	if err != nil {
+		if errors.Is(err, messaging.ErrReceiverNotFound) ||
+			errors.Is(err, messaging.ErrConversationNotFound) ||
+			errors.Is(err, messaging.ErrBlocked) ||
+			messaging.IsValidationError(err) {
+			log.Printf("Rejected private message from user %s: %v", c.UserID, err)
+			c.sendMessageError(tempID, err.Error())
+		} else {
+			log.Printf("Error storing private message from user %s: %v", c.UserID, err)
+			c.sendMessageError(tempID, "Failed to send message")
+		}
+		return
+	}
+
+	// Ack to every session of the sender so they all show the message
+	c.Hub.BroadcastToUser(c.UserID, models.WebSocketMessage{
+		Type: "message_sent",
+		Data: models.MessageAckData{
+			TempID:    tempID,
+			MessageID: message.ID,
+			Message:   message,
+		},
+		Timestamp: time.Now(),
+	})
+
+	BroadcastNewMessage(message)
+
+	// Sending a message ends the typing indicator
+	if message.ReceiverID != "" {
+		c.stopTyping(message.ReceiverID)
+	}
+}
+
+// frameTempID returns the client-side tempId of a private_message frame, if any
+func frameTempID(data interface{}) string {
+	messageData, _ := data.(map[string]interface{})
+	tempID, _ := messageData["tempId"].(string)
+	return tempID
+}
+
+// sendMessageError reports a rejected private_message frame to this client only
+func (c *Client) sendMessageError(tempID, reason string) {
+	response := models.WebSocketMessage{
+		Type:      "message_error",
+		Data:      models.MessageAckData{TempID: tempID, Error: reason},
+		Timestamp: time.Now(),
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Error marshaling message error response: %v", err)
 		return
 	}
 
-	// Create and send the message through the messaging system
-	// This will be handled by the messaging handlers
-	log.Printf("Private message from %s to %s: %s", c.UserID, receiverID, content)
+	if !c.queue(data) {
+		log.Printf("Failed to send message error to client %s", c.ID)
+	}
 }
 
-// handleMessageRead handles message read notifications
+// queue adds data to this client's send queue. The hub closes Send when it
+// removes a client, which can happen while readPump is still handling
+// frames, so data is only queued while the client is registered. Returns
+// false when the client is gone or its queue is full.
+func (c *Client) queue(data []byte) bool {
+	c.Hub.mutex.RLock()
+	defer c.Hub.mutex.RUnlock()
+	if !c.Hub.clients[c] {
+		return false
+	}
+
+	select {
+	case c.Send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// handleMessageRead stores read receipts sent by a client and tells the
+// senders. The frame names either the messages read ({"messageIds": [...]})
+// or a sender whose messages were all read ({"senderId"}).
 func (c *Client) handleMessageRead(data interface{}) {
 	// Parse the message read data
 	readData, ok := data.(map[string]interface{})
@@ -540,22 +985,28 @@ func (c *Client) handleMessageRead(data interface{}) {
 		return
 	}
 
-	senderID, ok := readData["senderId"].(string)
-	if !ok || senderID == "" {
-		log.Printf("Invalid sender ID in message read from user %s", c.UserID)
+	var receipts []models.MessageReceiptData
+	var err error
+	if rawIDs, ok := readData["messageIds"].([]interface{}); ok {
+		messageIDs := make([]string, 0, len(rawIDs))
+		for _, rawID := range rawIDs {
+			if id, ok := rawID.(string); ok && id != "" {
+				messageIDs = append(messageIDs, id)
+			}
+		}
+		receipts, err = messaging.MarkRead(c.UserID, messageIDs)
+	} else if senderID, ok := readData["senderId"].(string); ok && senderID != "" {
+		receipts, err = messaging.MarkAsRead(c.UserID, senderID)
+	} else {
+		log.Printf("Invalid message read data from user %s", c.UserID)
+		return
+	}
+	if err != nil {
+		log.Printf("Error storing read receipts from user %s: %v", c.UserID, err)
 		return
 	}
 
-	// Broadcast read notification to the sender
-	c.Hub.BroadcastToUser(senderID, models.WebSocketMessage{
-		Type: "message_read",
-		Data: map[string]interface{}{
-			"readerId": c.UserID,
-		},
-		Timestamp: time.Now(),
-	})
-
-	log.Printf("Message read notification from %s for messages from %s", c.UserID, senderID)
+	BroadcastMessagesRead(receipts)
 }
 
 // BroadcastNewPost broadcasts a new post to all connected clients
@@ -579,7 +1030,49 @@ func BroadcastNewPost(post *models.Post) {
 	hub.BroadcastMessage(data)
 }
 
-// BroadcastNewMessage broadcasts a new private message to the receiver
+// BroadcastPostUpdated broadcasts an edited post to all connected clients
+func BroadcastPostUpdated(post *models.Post) {
+	if hub == nil {
+		return
+	}
+
+	message := models.WebSocketMessage{
+		Type:      "post_updated",
+		Data:      post,
+		Timestamp: time.Now(),
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling post updated message: %v", err)
+		return
+	}
+
+	hub.BroadcastMessage(data)
+}
+
+// BroadcastPostDeleted broadcasts the removal of a post to all connected clients
+func BroadcastPostDeleted(postID int) {
+	if hub == nil {
+		return
+	}
+
+	message := models.WebSocketMessage{
+		Type:      "post_deleted",
+		Data:      map[string]interface{}{"postId": postID},
+		Timestamp: time.Now(),
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling post deleted message: %v", err)
+		return
+	}
+
+	hub.BroadcastMessage(data)
+}
+
+// BroadcastNewMessage delivers a new message to every other member of its conversation
 func BroadcastNewMessage(message *models.Message) {
 	if hub == nil {
 		return
@@ -591,8 +1084,140 @@ func BroadcastNewMessage(message *models.Message) {
 		Timestamp: time.Now(),
 	}
 
-	hub.BroadcastToUser(message.ReceiverID, wsMessage)
-	log.Printf("Broadcasted new message from %s to %s", message.SenderID, message.ReceiverID)
+	for _, userID := range conversationMembers(message) {
+		if userID == message.SenderID {
+			continue
+		}
+
+		// A one-to-one message is delivered once a receiver session has it
+		if hub.BroadcastToUser(userID, wsMessage) > 0 && userID == message.ReceiverID {
+			receipts, err := messaging.MarkDelivered(userID, []string{message.ID})
+			if err != nil {
+				log.Printf("Error marking message %s delivered: %v", message.ID, err)
+				continue
+			}
+			BroadcastMessagesDelivered(receipts)
+		}
+	}
+	log.Printf("Broadcasted new message from %s to conversation %s", message.SenderID, message.ConversationID)
+}
+
+// BroadcastMessagesDelivered sends message_delivered receipts to the senders' sessions
+func BroadcastMessagesDelivered(receipts []models.MessageReceiptData) {
+	if hub == nil {
+		return
+	}
+
+	for _, receipt := range receipts {
+		hub.BroadcastToUser(receipt.SenderID, models.WebSocketMessage{
+			Type:      "message_delivered",
+			Data:      receipt,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// BroadcastMessagesRead sends message_read receipts to the senders' sessions
+// and to the reader's sessions so their other tabs clear unread messages
+func BroadcastMessagesRead(receipts []models.MessageReceiptData) {
+	if hub == nil {
+		return
+	}
+
+	for _, receipt := range receipts {
+		wsMessage := models.WebSocketMessage{
+			Type:      "message_read",
+			Data:      receipt,
+			Timestamp: time.Now(),
+		}
+		hub.BroadcastToUser(receipt.SenderID, wsMessage)
+		hub.BroadcastToUser(receipt.ReaderID, wsMessage)
+	}
+}
+
+// BroadcastMessageEdited sends an edited message to every member of its conversation
+func BroadcastMessageEdited(message *models.Message) {
+	broadcastToParticipants(message, "message_edited")
+}
+
+// BroadcastMessageDeleted sends the tombstone of a deleted message to every member of its conversation
+func BroadcastMessageDeleted(message *models.Message) {
+	broadcastToParticipants(message, "message_deleted")
+}
+
+// broadcastToParticipants sends a message event to all sessions of every conversation member
+func broadcastToParticipants(message *models.Message, eventType string) {
+	if hub == nil {
+		return
+	}
+
+	wsMessage := models.WebSocketMessage{
+		Type:      eventType,
+		Data:      message,
+		Timestamp: time.Now(),
+	}
+
+	for _, userID := range conversationMembers(message) {
+		hub.BroadcastToUser(userID, wsMessage)
+	}
+}
+
+// conversationMembers returns the users a message event should reach,
+// falling back to the sender and receiver if the members can't be loaded
+func conversationMembers(message *models.Message) []string {
+	if message.ConversationID != "" {
+		userIDs, err := messaging.ParticipantIDs(message.ConversationID)
+		if err == nil {
+			return userIDs
+		}
+		log.Printf("Error loading members of conversation %s: %v", message.ConversationID, err)
+	}
+
+	userIDs := []string{message.SenderID}
+	if message.ReceiverID != "" {
+		userIDs = append(userIDs, message.ReceiverID)
+	}
+	return userIDs
+}
+
+// BroadcastConversationUpdated sends the current state of a conversation to
+// its members and to any users who were just removed from it
+func BroadcastConversationUpdated(conversation *models.Conversation, removedUserIDs ...string) {
+	if hub == nil {
+		return
+	}
+
+	wsMessage := models.WebSocketMessage{
+		Type:      "conversation_updated",
+		Data:      conversation,
+		Timestamp: time.Now(),
+	}
+
+	for _, participant := range conversation.Participants {
+		hub.BroadcastToUser(participant.UserID, wsMessage)
+	}
+	for _, userID := range removedUserIDs {
+		hub.BroadcastToUser(userID, wsMessage)
+	}
+}
+
+// DisconnectSuspendedUser tells the user's clients why they were suspended
+// and closes their connections
+func DisconnectSuspendedUser(suspension *models.Suspension) {
+	if hub == nil {
+		return
+	}
+
+	message := models.WebSocketMessage{
+		Type: "user_suspended",
+		Data: models.UserSuspendedData{
+			Reason:    suspension.Reason,
+			ExpiresAt: suspension.ExpiresAt,
+		},
+		Timestamp: time.Now(),
+	}
+
+	hub.DisconnectUser(suspension.UserID, message, "account suspended")
 }
 
 // BroadcastUserOffline broadcasts that a user has gone offline
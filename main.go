@@ -1,15 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 
 	"forum/internal/auth"
+	"forum/internal/config"
 	"forum/internal/database"
 	"forum/internal/handlers"
+	"forum/internal/janitor"
+	"forum/internal/mail"
+	"forum/internal/messaging"
+	"forum/internal/ratelimit"
 	"forum/internal/websocket"
 )
 
@@ -28,61 +39,148 @@ import (
 // }
 
 func main() {
+	configPath := flag.String("config", os.Getenv("FORUM_CONFIG"), "path to a JSON config file")
+	flag.Parse()
+
+	// Load configuration: defaults, then the config file, then environment overrides
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	// Schema migrations can be managed without starting the server
+	if flag.Arg(0) == "migrate" {
+		os.Exit(runMigrate(cfg, flag.Args()[1:]))
+	}
+
+	// Roles can be assigned before anyone is an admin
+	if flag.Arg(0) == "role" {
+		os.Exit(runRole(cfg, flag.Args()[1:]))
+	}
+
 	// Initialize database
-	if err := database.Initialize(); err != nil {
+	if err := database.Initialize(cfg.Database); err != nil {
 		log.Fatal(" Failed to initialize database:", err)
 	}
 	defer database.Close()
 
-	// Initialize OAuth providers
-	auth.InitializeOAuthProviders()
+	// Apply session settings and initialize OAuth providers
+	auth.Initialize(cfg.Auth)
+	auth.InitializeOAuthProviders(cfg.OAuth)
+
+	// Select how account emails are delivered
+	mail.Initialize(cfg.Mail, cfg.Server.PublicURL)
+
+	// Load messaging settings
+	messaging.Initialize(cfg.Messaging)
+
+	// Apply upload settings and rate limits
+	handlers.Initialize(cfg.Uploads)
+	handlers.InitializeRateLimits(cfg.RateLimit)
 
 	// Initialize WebSocket hub
-	websocket.InitializeHub()
+	websocket.InitializeHub(cfg.WebSocket)
+
+	// Purge expired sessions, account tokens and stale online users in the background
+	cleanup := janitor.New(cfg.Auth.CleanupInterval.Duration, websocket.GetHub())
+	go cleanup.Run()
 
 	// Setup routes
-	setupRoutes()
+	setupRoutes(cfg)
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	port := cfg.Server.Port
 
 	// Display startup information
 	fmt.Println("Database initialized successfully")
 	fmt.Println("✅WebSocket hub initialized")
 	fmt.Printf("Server starting on port %s\n", port)
 	fmt.Printf("🌐 Access the forum at: http://localhost:%s\n", port)
-	fmt.Println("📊 Database: forum.db")
+	fmt.Printf("📊 Database: %s\n", cfg.Database.Path)
 	fmt.Println("🌐 Frontend: Modern SPA with real-time features")
 	fmt.Println("💬 Real-time messaging enabled")
 	fmt.Println("👥 Online user tracking active")
 	fmt.Println("")
 
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	server := &http.Server{Addr: ":" + port, Handler: handlers.CSRFProtect(http.DefaultServeMux)}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// Wait for SIGINT or SIGTERM, then drain before the database is closed
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	sig := <-stop
+	log.Printf("🛑 Received %s, shutting down (timeout %s)", sig, cfg.Server.ShutdownTimeout)
+
+	// Stop accepting connections and let in-flight requests finish
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("⚠️ HTTP server shutdown: %v", err)
+	}
+	cleanup.Stop()
+
+	// Flush and close websocket clients and clear their online status. This
+	// gets its own deadline so clients still receive close frames when slow
+	// requests used up the first one.
+	wsCtx, wsCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+	defer wsCancel()
+	if err := websocket.GetHub().Shutdown(wsCtx); err != nil {
+		log.Printf("⚠️ WebSocket hub shutdown: %v", err)
+	}
+
+	log.Println("👋 Server stopped")
 }
 
-func setupRoutes() {
-	// Static files
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("frontend/static/"))))
+func setupRoutes(cfg *config.Config) {
+	// Static files; uploads may live outside the frontend directory
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(cfg.Server.StaticDir))))
+	http.Handle("/static/uploads/", http.StripPrefix("/static/uploads/", http.FileServer(http.Dir(cfg.Uploads.Dir))))
 
 	// API routes
-	http.HandleFunc("/api/register", handlers.RegisterHandler)
-	http.HandleFunc("/api/login", handlers.LoginHandler)
+	http.HandleFunc("/api/register", handlers.RateLimit(ratelimit.Register, handlers.RegisterHandler))
+	http.HandleFunc("/api/login", handlers.RateLimit(ratelimit.Login, handlers.LoginHandler))
+	http.HandleFunc("/api/login/2fa", handlers.RateLimit(ratelimit.Login, handlers.LoginTwoFactorHandler))
 	http.HandleFunc("/api/logout", handlers.LogoutHandler)
 	http.HandleFunc("/api/user", handlers.CurrentUserHandler)
-	http.HandleFunc("/api/posts", handlers.PostsHandler)
+	http.HandleFunc("/api/sessions", handlers.SessionsHandler)
+	http.HandleFunc("/api/sessions/", handlers.SessionHandler)
+	http.HandleFunc("/api/password/forgot", handlers.RateLimit(ratelimit.Email, handlers.ForgotPasswordHandler))
+	http.HandleFunc("/api/password/reset", handlers.RateLimit(ratelimit.Login, handlers.ResetPasswordHandler))
+	http.HandleFunc("/api/verify-email", handlers.VerifyEmailHandler)
+	http.HandleFunc("/api/verify-email/resend", handlers.RateLimit(ratelimit.Email, handlers.ResendVerificationHandler))
+	http.HandleFunc("/api/account/password", handlers.RateLimit(ratelimit.Login, handlers.ChangePasswordHandler))
+	http.HandleFunc("/api/account/email", handlers.RateLimit(ratelimit.Login, handlers.ChangeEmailHandler))
+	http.HandleFunc("/api/account/nickname", handlers.RateLimit(ratelimit.Login, handlers.ChangeNicknameHandler))
+	http.HandleFunc("/api/2fa", handlers.TwoFactorStatusHandler)
+	http.HandleFunc("/api/2fa/setup", handlers.RateLimit(ratelimit.Login, handlers.TwoFactorSetupHandler))
+	http.HandleFunc("/api/2fa/enable", handlers.RateLimit(ratelimit.Login, handlers.TwoFactorEnableHandler))
+	http.HandleFunc("/api/2fa/disable", handlers.RateLimit(ratelimit.Login, handlers.TwoFactorDisableHandler))
+	http.HandleFunc("/api/2fa/recovery-codes", handlers.RateLimit(ratelimit.Login, handlers.RecoveryCodesHandler))
+	http.HandleFunc("/api/admin/staff", handlers.RequirePermission(auth.PermUserRoleAssign, handlers.StaffHandler))
+	http.HandleFunc("/api/admin/roles", handlers.RequirePermission(auth.PermUserRoleAssign, handlers.UserRoleHandler))
+	http.HandleFunc("/api/admin/audit", handlers.RequirePermission(auth.PermAuditView, handlers.AuditEventsHandler))
+	http.HandleFunc("/api/admin/audit/export", handlers.RequirePermission(auth.PermAuditView, handlers.AuditExportHandler))
+	http.HandleFunc("/api/blocks", handlers.BlocksHandler)
+	http.HandleFunc("/api/blocks/", handlers.BlockHandler)
+	http.HandleFunc("/api/reports", handlers.RateLimit(ratelimit.Report, handlers.ReportHandler))
+	http.HandleFunc("/api/moderation/reports", handlers.RequirePermission(auth.PermReportReview, handlers.ModerationReportsHandler))
+	http.HandleFunc("/api/moderation/reports/", handlers.RequirePermission(auth.PermReportReview, handlers.ModerationReportHandler))
+	http.HandleFunc("/api/moderation/suspensions", handlers.RequirePermission(auth.PermUserSuspend, handlers.SuspensionsHandler))
+	http.HandleFunc("/api/moderation/suspensions/", handlers.RequirePermission(auth.PermUserSuspend, handlers.SuspensionHandler))
+	http.HandleFunc("/api/posts", handlers.RateLimit(ratelimit.Post, handlers.PostsHandler))
 	http.HandleFunc("/api/posts/", handlers.PostHandler)
 	// Comment routes - use a custom handler to catch all comment requests
-	http.HandleFunc("/api/comment", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/api/comment", handlers.RateLimit(ratelimit.Comment, func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("🔀 Comment router - Method: %s, URL: %s", r.Method, r.URL.Path)
 		handlers.CommentHandler(w, r)
-	})
-	http.HandleFunc("/api/comment/", func(w http.ResponseWriter, r *http.Request) {
+	}))
+	http.HandleFunc("/api/comment/", handlers.RateLimit(ratelimit.Comment, func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("🔀 Comment router with slash - Method: %s, URL: %s", r.Method, r.URL.Path)
 		handlers.CommentHandler(w, r)
-	})
+	}))
 	http.HandleFunc("/api/comments/", handlers.CommentsHandler)
 	// Also handle without trailing slash for better compatibility
 	http.HandleFunc("/api/comments", handlers.CommentsHandler)
@@ -94,18 +192,24 @@ func setupRoutes() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"success": true, "message": "Test endpoint working"}`))
 	})
-	http.HandleFunc("/api/like", handlers.LikeHandler)
+	http.HandleFunc("/api/like", handlers.RateLimit(ratelimit.Like, handlers.LikeHandler))
 
 	http.HandleFunc("/api/categories", handlers.CategoriesHandler)
+	http.HandleFunc("/api/search", handlers.SearchHandler)
+	http.HandleFunc("/api/notifications", handlers.NotificationsHandler)
+	http.HandleFunc("/api/notifications/read", handlers.MarkNotificationsReadHandler)
+	http.HandleFunc("/api/notifications/unread-count", handlers.UnreadNotificationsCountHandler)
 	http.HandleFunc("/api/profile", handlers.ProfileHandler)
 
 	http.HandleFunc("/api/online-users", handlers.OnlineUsersHandler)
 
 	// Messaging endpoints
 	http.HandleFunc("/api/conversations", handlers.ConversationsHandler)
+	http.HandleFunc("/api/conversations/", handlers.ConversationHandler)
 	http.HandleFunc("/api/messages", handlers.MessagesHandler)
-	http.HandleFunc("/api/messages/send", handlers.SendMessageHandler)
+	http.HandleFunc("/api/messages/send", handlers.RateLimit(ratelimit.Message, handlers.SendMessageHandler))
 	http.HandleFunc("/api/messages/read", handlers.MarkMessageReadHandler)
+	http.HandleFunc("/api/messages/", handlers.MessageHandler)
 
 	// Avatar upload routes
 	http.HandleFunc("/api/upload/avatar", handlers.AvatarUploadHandler)
@@ -116,6 +220,8 @@ func setupRoutes() {
 	http.HandleFunc("/auth/google/callback", handlers.GoogleCallbackHandler)
 	http.HandleFunc("/auth/github/login", handlers.GithubLoginHandler)
 	http.HandleFunc("/auth/github/callback", handlers.GithubCallbackHandler)
+	http.HandleFunc("/api/oauth/providers", handlers.OAuthProvidersHandler)
+	http.HandleFunc("/api/oauth/signup", handlers.OAuthSignupHandler)
 
 	// WebSocket endpoint
 	http.HandleFunc("/ws", websocket.HandleWebSocket)
@@ -132,7 +238,7 @@ func setupRoutes() {
 
 		// For all non-API routes, serve index.html and let frontend router handle routing
 		// This allows the frontend to show custom 404 pages
-		http.ServeFile(w, r, "frontend/static/index.html")
+		http.ServeFile(w, r, filepath.Join(cfg.Server.StaticDir, "index.html"))
 	})
 
 }
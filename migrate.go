@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"forum/internal/config"
+	"forum/internal/database"
+)
+
+const migrateUsage = `Usage: forum [-config file] migrate <command>
+
+Commands:
+  up          Apply all pending migrations
+  down [n]    Revert the newest n applied migrations (default 1)
+  status      List migrations and whether they are applied`
+
+// runMigrate runs the "forum migrate" command and returns the exit code
+func runMigrate(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err := database.Open(cfg.Database.Path); err != nil {
+		fmt.Fprintln(os.Stderr, "❌", err)
+		return 1
+	}
+	defer database.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❌", err)
+			return 1
+		}
+		fmt.Printf("✅ Applied %d migrations\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				fmt.Fprintln(os.Stderr, "❌ The number of migrations to revert must be a positive integer")
+				return 2
+			}
+			steps = n
+		}
+
+		reverted, err := database.MigrateDown(steps)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❌", err)
+			return 1
+		}
+		fmt.Printf("✅ Reverted %d migrations\n", reverted)
+
+	case "status":
+		statuses, err := database.MigrationStatuses()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❌", err)
+			return 1
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tAPPLIED AT\tDESCRIPTION")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, appliedAt, status.Description)
+		}
+		w.Flush()
+
+		if err := database.CheckSchemaVersion(); err != nil {
+			fmt.Fprintln(os.Stderr, "⚠️", err)
+			return 1
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
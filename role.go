@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+	"forum/internal/config"
+	"forum/internal/database"
+)
+
+const roleUsage = `Usage: forum [-config file] role <nickname or email> <user|moderator|admin>
+
+Gives a user a role. Use it to appoint the first admin, who can then
+manage roles from the admin page.`
+
+// runRole runs the "forum role" command and returns the exit code
+func runRole(cfg *config.Config, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, roleUsage)
+		return 2
+	}
+
+	if err := database.Open(cfg.Database.Path); err != nil {
+		fmt.Fprintln(os.Stderr, "❌", err)
+		return 1
+	}
+	defer database.Close()
+
+	version, err := database.SchemaVersion()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌", err)
+		return 1
+	}
+	if version != database.LatestVersion() {
+		fmt.Fprintln(os.Stderr, "❌ The database schema is not up to date, run \"forum migrate up\" first")
+		return 1
+	}
+
+	user, err := auth.GetUserByEmailOrNickname(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌", err)
+		return 1
+	}
+	if user == nil {
+		fmt.Fprintf(os.Stderr, "❌ No user with nickname or email %q\n", args[0])
+		return 1
+	}
+
+	if err := auth.SetUserRole(user.ID, args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, "❌", err)
+		if errors.Is(err, auth.ErrInvalidRole) {
+			return 2
+		}
+		return 1
+	}
+
+	audit.Record(audit.Event{
+		Action:     audit.ActionRoleChanged,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Metadata:   map[string]interface{}{"from": user.Role, "to": args[1], "via": "cli"},
+	})
+
+	fmt.Printf("✅ %s is now %s\n", user.Nickname, args[1])
+	return 0
+}
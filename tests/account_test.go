@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/database"
+	"forum/internal/handlers"
+)
+
+// Test changing the password, email and nickname of an account
+func TestAccountChanges(t *testing.T) {
+	openTestDatabase(t)
+	user := createTestUser(t, "changer")
+	createTestUser(t, "taken")
+
+	t.Run("Change Password", func(t *testing.T) {
+		if err := auth.ChangePassword(user.ID, "wrong-password", "new-password"); !errors.Is(err, auth.ErrWrongPassword) {
+			t.Errorf("Expected ErrWrongPassword, got: %v", err)
+		}
+		if err := auth.ChangePassword(user.ID, "password123", "short"); !errors.Is(err, auth.ErrPasswordTooShort) {
+			t.Errorf("Expected ErrPasswordTooShort, got: %v", err)
+		}
+		if err := auth.ChangePassword(user.ID, "password123", "new-password"); err != nil {
+			t.Fatalf("ChangePassword should not return error, got: %v", err)
+		}
+		if _, err := auth.AuthenticateUser(user.Nickname, "new-password"); err != nil {
+			t.Errorf("New password should work, got: %v", err)
+		}
+	})
+
+	t.Run("Change Email", func(t *testing.T) {
+		database.DB.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ?", user.ID)
+		auth.RequestPasswordReset(user.Email)
+
+		cases := map[string]struct {
+			email string
+			want  error
+		}{
+			"Invalid":   {"not-an-email", auth.ErrInvalidEmail},
+			"Display":   {"Changer <changer@example.org>", auth.ErrInvalidEmail},
+			"Unchanged": {user.Email, auth.ErrEmailUnchanged},
+			"Taken":     {"taken@example.com", auth.ErrEmailTaken},
+		}
+		for name, tc := range cases {
+			if err := auth.ChangeEmail(user.ID, "new-password", tc.email); !errors.Is(err, tc.want) {
+				t.Errorf("%s: expected %v, got: %v", name, tc.want, err)
+			}
+		}
+		if err := auth.ChangeEmail(user.ID, "password123", "changer@example.org"); !errors.Is(err, auth.ErrWrongPassword) {
+			t.Errorf("Expected ErrWrongPassword, got: %v", err)
+		}
+
+		if err := auth.ChangeEmail(user.ID, "new-password", "changer@example.org"); err != nil {
+			t.Fatalf("ChangeEmail should not return error, got: %v", err)
+		}
+
+		updated, _ := auth.GetUserByID(user.ID)
+		if updated.Email != "changer@example.org" {
+			t.Errorf("Expected new email, got %s", updated.Email)
+		}
+		if updated.EmailVerified {
+			t.Error("A new email should need verifying again")
+		}
+
+		var tokens int
+		database.DB.QueryRow("SELECT COUNT(*) FROM user_tokens WHERE user_id = ?", user.ID).Scan(&tokens)
+		if tokens != 0 {
+			t.Errorf("Links sent to the old email should stop working, %d tokens left", tokens)
+		}
+	})
+
+	t.Run("Change Nickname", func(t *testing.T) {
+		if err := auth.ChangeNickname(user.ID, "new-password", "no spaces"); !errors.Is(err, auth.ErrInvalidNickname) {
+			t.Errorf("Expected ErrInvalidNickname, got: %v", err)
+		}
+		if err := auth.ChangeNickname(user.ID, "new-password", "taken"); !errors.Is(err, auth.ErrNicknameTaken) {
+			t.Errorf("Expected ErrNicknameTaken, got: %v", err)
+		}
+		if err := auth.ChangeNickname(user.ID, "new-password", "renamed"); err != nil {
+			t.Fatalf("ChangeNickname should not return error, got: %v", err)
+		}
+
+		updated, _ := auth.GetUserByID(user.ID)
+		if updated.Nickname != "renamed" {
+			t.Errorf("Expected nickname renamed, got %s", updated.Nickname)
+		}
+	})
+
+	t.Run("Account Without Password", func(t *testing.T) {
+		oauthUser := createTestUser(t, "oauthonly")
+		database.DB.Exec("UPDATE users SET password = '', github_id = '4242' WHERE id = ?", oauthUser.ID)
+
+		// A session alone must not be enough to take the account over
+		if err := auth.ChangeEmail(oauthUser.ID, "", "attacker@example.com"); !errors.Is(err, auth.ErrReauthRequired) {
+			t.Errorf("Expected ErrReauthRequired without a provider sign-in, got: %v", err)
+		}
+		if _, err := auth.BeginTwoFactorSetup(oauthUser, ""); !errors.Is(err, auth.ErrReauthRequired) {
+			t.Errorf("Expected ErrReauthRequired for two-factor setup, got: %v", err)
+		}
+
+		session, _ := auth.CreateSession(oauthUser.ID, "agent", "127.0.0.1")
+		r := httptest.NewRequest(http.MethodPut, "/api/account/email", strings.NewReader(`{"email": "attacker@example.com"}`))
+		r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		w := httptest.NewRecorder()
+		handlers.ChangeEmailHandler(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for an email change with only a session, got %d", w.Code)
+		}
+
+		database.DB.Exec("UPDATE users SET oauth_login_at = ? WHERE id = ?", time.Now().Add(-time.Hour), oauthUser.ID)
+		if err := auth.ChangeNickname(oauthUser.ID, "", "oauthrenamed"); !errors.Is(err, auth.ErrReauthRequired) {
+			t.Errorf("Expected ErrReauthRequired after an old provider sign-in, got: %v", err)
+		}
+
+		// Signing in with the provider again confirms the change
+		auth.RegisterOAuthProvider(&auth.OAuthProvider{Name: "github", ClientID: "client", ClientSecret: "secret"})
+		linked, _, err := auth.CompleteOAuthLogin(auth.GetOAuthProvider("github"),
+			&auth.OAuthProfile{ProviderUserID: "4242", Email: oauthUser.Email, EmailVerified: true},
+			&auth.OAuthToken{AccessToken: "token"})
+		if err != nil || linked == nil || linked.ID != oauthUser.ID {
+			t.Fatalf("Expected the provider account to sign in as %s, got %v, %v", oauthUser.ID, linked, err)
+		}
+		if err := auth.ChangePassword(oauthUser.ID, "", "first-password"); err != nil {
+			t.Fatalf("A fresh provider sign-in should allow setting a password, got: %v", err)
+		}
+		if err := auth.ChangeNickname(oauthUser.ID, "", "oauthrenamed"); !errors.Is(err, auth.ErrWrongPassword) {
+			t.Errorf("Once set, the password should be required, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/audit"
+	"forum/internal/handlers"
+	"forum/internal/models"
+)
+
+// Test recording, filtering and exporting audit events
+func TestAudit(t *testing.T) {
+	openTestDatabase(t)
+	user := createTestUser(t, "auditee")
+	moderator := createTestUser(t, "watcher")
+
+	// list returns the events matching filter, failing the test on error
+	list := func(filter audit.Filter) []models.AuditEvent {
+		events, err := audit.List(filter, 100, 0)
+		if err != nil {
+			t.Fatalf("List should not return error, got: %v", err)
+		}
+		return events
+	}
+
+	t.Run("Record", func(t *testing.T) {
+		audit.Record(audit.Event{
+			ActorID: moderator.ID, Action: audit.ActionUserSuspended, TargetType: audit.TargetUser, TargetID: user.ID,
+			IPAddress: "10.0.0.2", Metadata: map[string]interface{}{"days": 7},
+		})
+		audit.Record(audit.Event{ActorID: user.ID, Action: audit.ActionPasswordChanged, TargetType: audit.TargetUser, TargetID: user.ID})
+
+		events := list(audit.Filter{})
+		if len(events) != 2 {
+			t.Fatalf("Expected 2 events, got %d", len(events))
+		}
+		if events[0].Action != audit.ActionPasswordChanged || events[0].Metadata != nil {
+			t.Errorf("Expected the newest event first without metadata, got %+v", events[0])
+		}
+
+		suspended := events[1]
+		if suspended.Actor == nil || *suspended.Actor != moderator.Nickname || suspended.IPAddress != "10.0.0.2" {
+			t.Errorf("Expected the suspension by %s from 10.0.0.2, got %+v", moderator.Nickname, suspended)
+		}
+		var metadata map[string]int
+		if err := json.Unmarshal(suspended.Metadata, &metadata); err != nil || metadata["days"] != 7 {
+			t.Errorf("Expected metadata {\"days\": 7}, got %s", suspended.Metadata)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		if n := len(list(audit.Filter{ActorID: moderator.ID})); n != 1 {
+			t.Errorf("Expected 1 event by the moderator, got %d", n)
+		}
+		if n := len(list(audit.Filter{Action: "moderation"})); n != 1 {
+			t.Errorf("Expected a category to match its actions, got %d", n)
+		}
+		if n := len(list(audit.Filter{Action: "moderation.user"})); n != 0 {
+			t.Errorf("Expected a partial action to match nothing, got %d", n)
+		}
+		if n := len(list(audit.Filter{Action: audit.ActionPasswordChanged, TargetID: user.ID})); n != 1 {
+			t.Errorf("Expected 1 password change, got %d", n)
+		}
+		if n := len(list(audit.Filter{IPAddress: "10.0.0.2"})); n != 1 {
+			t.Errorf("Expected 1 event from 10.0.0.2, got %d", n)
+		}
+		if n := len(list(audit.Filter{Since: time.Now().Add(time.Hour)})); n != 0 {
+			t.Errorf("Expected no events in the future, got %d", n)
+		}
+		if n := len(list(audit.Filter{Since: time.Now().Add(-time.Hour), Until: time.Now().Add(time.Hour)})); n != 2 {
+			t.Errorf("Expected both events in the last hour, got %d", n)
+		}
+
+		_, err := audit.List(audit.Filter{Since: time.Now(), Until: time.Now().Add(-time.Hour)}, 10, 0)
+		if !errors.Is(err, audit.ErrInvalidRange) {
+			t.Errorf("Expected ErrInvalidRange, got: %v", err)
+		}
+
+		events := list(audit.Filter{})
+		older, _ := audit.List(audit.Filter{}, 10, events[0].ID)
+		if len(older) != 1 || older[0].ID != events[1].ID {
+			t.Errorf("Expected the page before %d to hold only %d, got %+v", events[0].ID, events[1].ID, older)
+		}
+	})
+
+	t.Run("Failed Sign In", func(t *testing.T) {
+		body := strings.NewReader(`{"identifier": "auditee", "password": "wrong"}`)
+		w := httptest.NewRecorder()
+		handlers.LoginHandler(w, httptest.NewRequest(http.MethodPost, "/api/login", body))
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected 401, got %d", w.Code)
+		}
+
+		events := list(audit.Filter{Action: audit.ActionLoginFailed})
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 failed sign-in, got %d", len(events))
+		}
+		failed := events[0]
+		if failed.ActorID != nil || failed.TargetID != user.ID || failed.IPAddress != "192.0.2.1" {
+			t.Errorf("Expected an anonymous attempt on %s from 192.0.2.1, got %+v", user.ID, failed)
+		}
+		if !strings.Contains(string(failed.Metadata), `"reason":"invalid_credentials"`) {
+			t.Errorf("Expected the reason in the metadata, got %s", failed.Metadata)
+		}
+	})
+
+	t.Run("Sign In", func(t *testing.T) {
+		body := strings.NewReader(`{"identifier": "auditee", "password": "password123"}`)
+		w := httptest.NewRecorder()
+		handlers.LoginHandler(w, httptest.NewRequest(http.MethodPost, "/api/login", body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", w.Code)
+		}
+
+		events := list(audit.Filter{Action: audit.ActionLogin, ActorID: user.ID})
+		if len(events) != 1 || events[0].TargetType != audit.TargetSession || events[0].TargetID == "" {
+			t.Errorf("Expected a sign-in to a new session, got %+v", events)
+		}
+	})
+
+	// export downloads the audit log in format
+	export := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handlers.AuditExportHandler(w, httptest.NewRequest(http.MethodGet, "/api/admin/audit/export?"+query, nil))
+		return w
+	}
+
+	t.Run("Export CSV", func(t *testing.T) {
+		w := export("actor=watcher")
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;") {
+			t.Fatalf("Expected a CSV attachment, got %d %q", w.Code, w.Header().Get("Content-Disposition"))
+		}
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Export should be valid CSV, got: %v", err)
+		}
+		if len(records) != 2 || records[0][4] != "action" || records[1][4] != audit.ActionUserSuspended {
+			t.Errorf("Expected a header and the suspension, got %v", records)
+		}
+	})
+
+	t.Run("Export JSON", func(t *testing.T) {
+		w := export("format=json&action=auth")
+		var events []models.AuditEvent
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatalf("Export should be a JSON array, got: %v", err)
+		}
+		if len(events) != 2 {
+			t.Errorf("Expected the 2 sign-in events, got %d", len(events))
+		}
+
+		if w := export("format=xml"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an unknown format, got %d", w.Code)
+		}
+		if w := export("from=2026-02-01&to=2026-01-01"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a backwards range, got %d", w.Code)
+		}
+		if w := export("actor=nobody"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for an unknown actor, got %d", w.Code)
+		}
+	})
+}
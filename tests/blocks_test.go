@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/blocks"
+	"forum/internal/database"
+	"forum/internal/handlers"
+	"forum/internal/messaging"
+	"forum/internal/models"
+	"forum/internal/notifications"
+)
+
+// Test blocking users and what it hides from the blocker
+func TestBlocks(t *testing.T) {
+	openTestDatabase(t)
+	blocker := createTestUser(t, "blocker")
+	pest := createTestUser(t, "pest")
+	friend := createTestUser(t, "friend")
+
+	t.Run("Block", func(t *testing.T) {
+		if err := blocks.Block(blocker.ID, blocker.ID); !errors.Is(err, blocks.ErrCannotBlockSelf) {
+			t.Errorf("Expected ErrCannotBlockSelf, got: %v", err)
+		}
+		if err := blocks.Block(blocker.ID, "no-such-user"); !errors.Is(err, blocks.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got: %v", err)
+		}
+		if err := blocks.Block(blocker.ID, pest.ID); err != nil {
+			t.Fatalf("Block should not return error, got: %v", err)
+		}
+		if err := blocks.Block(blocker.ID, pest.ID); err != nil {
+			t.Errorf("Blocking again should be a no-op, got: %v", err)
+		}
+
+		blocked, err := blocks.List(blocker.ID)
+		if err != nil {
+			t.Fatalf("List should not return error, got: %v", err)
+		}
+		if len(blocked) != 1 || blocked[0].ID != pest.ID || blocked[0].Nickname != pest.Nickname {
+			t.Errorf("Expected only %s blocked, got %+v", pest.Nickname, blocked)
+		}
+	})
+
+	t.Run("Messaging", func(t *testing.T) {
+		if _, err := messaging.Send(pest.ID, blocker.ID, "hello?"); !errors.Is(err, messaging.ErrBlocked) {
+			t.Errorf("Blocked users should not reach the blocker, got: %v", err)
+		}
+		if err := messaging.CanMessage(blocker.ID, pest.ID); !errors.Is(err, messaging.ErrBlocked) {
+			t.Errorf("The blocker should not message the blocked user either, got: %v", err)
+		}
+		if err := messaging.CanMessage(friend.ID, blocker.ID); err != nil {
+			t.Errorf("Other users should be unaffected, got: %v", err)
+		}
+	})
+
+	t.Run("Notifications", func(t *testing.T) {
+		for _, actor := range []*models.User{pest, friend} {
+			if err := notifications.Notify(&models.Notification{
+				UserID: blocker.ID, ActorID: &actor.ID, Type: notifications.TypeMention, Message: actor.Nickname + " mentioned you",
+			}); err != nil {
+				t.Fatalf("Notify should not return error, got: %v", err)
+			}
+		}
+
+		list, err := notifications.List(blocker.ID, 10, 0, false)
+		if err != nil {
+			t.Fatalf("List should not return error, got: %v", err)
+		}
+		if len(list) != 1 || *list[0].ActorID != friend.ID {
+			t.Errorf("Expected only the notification from %s, got %+v", friend.Nickname, list)
+		}
+	})
+
+	t.Run("Feeds", func(t *testing.T) {
+		var postID int64
+		for _, author := range []*models.User{pest, friend} {
+			result, err := database.DB.Exec(`
+				INSERT INTO posts (user_id, title, content, created_at, updated_at) VALUES (?, ?, 'Body', ?, ?)
+			`, author.ID, "By "+author.Nickname, time.Now(), time.Now())
+			if err != nil {
+				t.Fatalf("Failed to create post: %v", err)
+			}
+			postID, _ = result.LastInsertId()
+		}
+		for _, author := range []*models.User{pest, friend} {
+			if _, err := database.DB.Exec(`
+				INSERT INTO comments (post_id, user_id, content, created_at, updated_at) VALUES (?, ?, 'Reply', ?, ?)
+			`, postID, author.ID, time.Now(), time.Now()); err != nil {
+				t.Fatalf("Failed to create comment: %v", err)
+			}
+		}
+
+		// get requests a path as the user and returns how many items it lists
+		get := func(handler http.HandlerFunc, path string, user *models.User) int {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			if user != nil {
+				session, err := auth.CreateSession(user.ID, "agent", "127.0.0.1")
+				if err != nil {
+					t.Fatalf("CreateSession should not return error, got: %v", err)
+				}
+				r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			var response struct{ Data []json.RawMessage }
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("GET %s: status %d, %v", path, w.Code, err)
+			}
+			return len(response.Data)
+		}
+
+		if n := get(handlers.PostsHandler, "/api/posts", blocker); n != 1 {
+			t.Errorf("Expected the blocked user's post hidden from the feed, got %d posts", n)
+		}
+		if n := get(handlers.PostsHandler, "/api/posts", friend); n != 2 {
+			t.Errorf("Other users should see every post, got %d", n)
+		}
+
+		commentsPath := fmt.Sprintf("/api/comments/%d", postID)
+		if n := get(handlers.CommentsHandler, commentsPath, blocker); n != 1 {
+			t.Errorf("Expected the blocked user's comment hidden, got %d comments", n)
+		}
+		if n := get(handlers.CommentsHandler, commentsPath, nil); n != 2 {
+			t.Errorf("Anonymous visitors should see every comment, got %d", n)
+		}
+	})
+
+	t.Run("Unblock", func(t *testing.T) {
+		if err := blocks.Unblock(blocker.ID, pest.ID); err != nil {
+			t.Fatalf("Unblock should not return error, got: %v", err)
+		}
+		if err := blocks.Unblock(blocker.ID, pest.ID); !errors.Is(err, blocks.ErrNotBlocked) {
+			t.Errorf("Expected ErrNotBlocked, got: %v", err)
+		}
+		if err := messaging.CanMessage(pest.ID, blocker.ID); err != nil {
+			t.Errorf("Unblocked users should be able to message again, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/config"
+)
+
+// writeConfigFile writes a config file into a temporary directory
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+// Test loading configuration from defaults, a file and the environment
+func TestConfigLoad(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		cfg, err := config.Load("")
+		if err != nil {
+			t.Fatalf("Load should not return error, got: %v", err)
+		}
+
+		if cfg.Database.Path != "forum.db" {
+			t.Errorf("Expected default database path forum.db, got %s", cfg.Database.Path)
+		}
+		if cfg.Auth.SessionDuration.Duration != 7*24*time.Hour {
+			t.Errorf("Expected default session duration of 7 days, got %s", cfg.Auth.SessionDuration)
+		}
+		if cfg.OAuth.Google.RedirectURL != "http://localhost:"+cfg.Server.Port+"/auth/google/callback" {
+			t.Errorf("Unexpected default Google redirect URL: %s", cfg.OAuth.Google.RedirectURL)
+		}
+	})
+
+	t.Run("File Values", func(t *testing.T) {
+		path := writeConfigFile(t, `{
+			"database": {"path": "staging.db"},
+			"auth": {"sessionDuration": "12h", "cookieSecure": true},
+			"websocket": {"pongWait": "30s", "pingPeriod": "25s"}
+		}`)
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load should not return error, got: %v", err)
+		}
+
+		if cfg.Database.Path != "staging.db" {
+			t.Errorf("Expected database path staging.db, got %s", cfg.Database.Path)
+		}
+		if cfg.Auth.SessionDuration.Duration != 12*time.Hour {
+			t.Errorf("Expected session duration 12h, got %s", cfg.Auth.SessionDuration)
+		}
+		if !cfg.Auth.CookieSecure {
+			t.Error("Expected secure cookies")
+		}
+		if cfg.WebSocket.WriteWait.Duration != 10*time.Second {
+			t.Errorf("Settings missing from the file should keep their defaults, got writeWait %s", cfg.WebSocket.WriteWait)
+		}
+	})
+
+	t.Run("Environment Overrides File", func(t *testing.T) {
+		path := writeConfigFile(t, `{"server": {"port": "9000"}, "database": {"path": "staging.db"}}`)
+		t.Setenv("DATABASE_PATH", "override.db")
+		t.Setenv("MESSAGE_EDIT_WINDOW", "0")
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load should not return error, got: %v", err)
+		}
+
+		if cfg.Database.Path != "override.db" {
+			t.Errorf("Expected database path override.db, got %s", cfg.Database.Path)
+		}
+		if cfg.Server.Port != "9000" {
+			t.Errorf("Expected port 9000 from the file, got %s", cfg.Server.Port)
+		}
+		if cfg.Messaging.EditWindow.Duration != 0 {
+			t.Errorf("Expected no edit window, got %s", cfg.Messaging.EditWindow)
+		}
+	})
+}
+
+// Test that invalid configuration is rejected at startup
+func TestConfigValidation(t *testing.T) {
+	invalidFiles := map[string]string{
+		"Unknown Field":        `{"databse": {"path": "forum.db"}}`,
+		"Bad Duration":         `{"auth": {"sessionDuration": "a week"}}`,
+		"Bad Port":             `{"server": {"port": "http"}}`,
+		"Zero Shutdown":        `{"server": {"shutdownTimeout": "0s"}}`,
+		"Ping After Pong":      `{"websocket": {"pingPeriod": "2m"}}`,
+		"Negative Edit":        `{"messaging": {"editWindow": "-1m"}}`,
+		"Half OAuth Client":    `{"oauth": {"github": {"clientId": "id"}}}`,
+		"Relative Token URL":   `{"oauth": {"google": {"tokenUrl": "/token"}}}`,
+		"Zero Avatar Size":     `{"uploads": {"maxAvatarSize": 0}}`,
+		"Empty Database Path":  `{"database": {"path": ""}}`,
+		"Zero Rate Limit":      `{"rateLimit": {"login": {"requests": 0}}}`,
+		"Short Max Lockout":    `{"auth": {"lockoutDuration": "2h", "maxLockoutDuration": "1h"}}`,
+		"Origin With Path":     `{"auth": {"allowedOrigins": ["https://forum.example.com/app"]}}`,
+		"Short Idle Timeout":   `{"auth": {"sessionIdleTimeout": "30s"}}`,
+		"Relative Public URL":  `{"server": {"publicUrl": "/forum"}}`,
+		"Unknown Mail Driver":  `{"mail": {"driver": "sendmail"}}`,
+		"SMTP Without Host":    `{"mail": {"driver": "smtp"}}`,
+		"Bad Sender":           `{"mail": {"from": "no-reply"}}`,
+		"Short Reset TTL":      `{"auth": {"passwordResetTtl": "10s"}}`,
+		"Colon In Issuer":      `{"auth": {"twoFactorIssuer": "Forum: Staging"}}`,
+		"Slow Typing Throttle": `{"websocket": {"typingThrottle": "10s"}}`,
+	}
+
+	for name, content := range invalidFiles {
+		t.Run(name, func(t *testing.T) {
+			if _, err := config.Load(writeConfigFile(t, content)); err == nil {
+				t.Error("Load should reject invalid configuration")
+			}
+		})
+	}
+
+	t.Run("Invalid Environment Value", func(t *testing.T) {
+		t.Setenv("COOKIE_SECURE", "maybe")
+		_, err := config.Load("")
+		if err == nil || !strings.Contains(err.Error(), "COOKIE_SECURE") {
+			t.Errorf("Expected an error naming COOKIE_SECURE, got: %v", err)
+		}
+	})
+
+	t.Run("Missing File", func(t *testing.T) {
+		if _, err := config.Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+			t.Error("Load should fail when the config file does not exist")
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"forum/internal/auth"
+	"forum/internal/blocks"
+	"forum/internal/database"
+	"forum/internal/handlers"
+	"forum/internal/messaging"
+	"forum/internal/models"
+)
+
+// Test creating, renaming, joining and leaving group conversations
+func TestGroupConversations(t *testing.T) {
+	openTestDatabase(t)
+	owner := createTestUser(t, "groupowner")
+	member := createTestUser(t, "groupmember")
+	newcomer := createTestUser(t, "groupnewcomer")
+	outsider := createTestUser(t, "groupoutsider")
+
+	// memberIDs returns the participant IDs of a conversation in a set
+	memberIDs := func(conversation *models.Conversation) map[string]bool {
+		ids := make(map[string]bool, len(conversation.Participants))
+		for _, participant := range conversation.Participants {
+			ids[participant.UserID] = true
+		}
+		return ids
+	}
+
+	// createGroup creates a group of owner and member
+	createGroup := func(t *testing.T) *models.Conversation {
+		group, err := messaging.CreateGroup(owner.ID, "Book club", []string{member.ID})
+		if err != nil {
+			t.Fatalf("CreateGroup should not return error, got: %v", err)
+		}
+		return group
+	}
+
+	t.Run("Create Group", func(t *testing.T) {
+		group, err := messaging.CreateGroup(owner.ID, "  Book club  ", []string{member.ID, member.ID, owner.ID, " "})
+		if err != nil {
+			t.Fatalf("CreateGroup should not return error, got: %v", err)
+		}
+		if !group.IsGroup || group.Name != "Book club" || group.CreatedBy == nil || *group.CreatedBy != owner.ID {
+			t.Errorf("Expected a group named %q created by %s, got %+v", "Book club", owner.ID, group)
+		}
+		if ids := memberIDs(group); len(ids) != 2 || !ids[owner.ID] || !ids[member.ID] {
+			t.Errorf("Expected the creator and one member once each, got %+v", group.Participants)
+		}
+
+		tooMany := make([]string, messaging.MaxGroupSize)
+		for i := range tooMany {
+			tooMany[i] = fmt.Sprintf("user-%d", i)
+		}
+		cases := map[string]struct {
+			name    string
+			members []string
+			want    error
+		}{
+			"Blank Name":     {"   ", []string{member.ID}, messaging.ErrGroupNameRequired},
+			"Long Name":      {strings.Repeat("x", messaging.MaxGroupNameLength+1), []string{member.ID}, messaging.ErrGroupNameTooLong},
+			"Only Creator":   {"Alone", []string{owner.ID, ""}, messaging.ErrNoParticipants},
+			"Too Large":      {"Crowd", tooMany, messaging.ErrGroupTooLarge},
+			"Unknown Member": {"Ghosts", []string{member.ID, "no-such-user"}, messaging.ErrReceiverNotFound},
+		}
+		for name, tc := range cases {
+			if _, err := messaging.CreateGroup(owner.ID, tc.name, tc.members); !errors.Is(err, tc.want) {
+				t.Errorf("%s: expected %v, got: %v", name, tc.want, err)
+			}
+		}
+
+		if err := blocks.Block(outsider.ID, owner.ID); err != nil {
+			t.Fatalf("Block should not return error, got: %v", err)
+		}
+		defer blocks.Unblock(outsider.ID, owner.ID)
+		if _, err := messaging.CreateGroup(owner.ID, "Uninvited", []string{member.ID, outsider.ID}); !errors.Is(err, messaging.ErrBlocked) {
+			t.Errorf("Expected ErrBlocked when adding someone who blocked the creator, got: %v", err)
+		}
+	})
+
+	t.Run("Rename Group", func(t *testing.T) {
+		group := createGroup(t)
+
+		renamed, err := messaging.RenameGroup(member.ID, group.ID, "Poetry club")
+		if err != nil {
+			t.Fatalf("RenameGroup should not return error, got: %v", err)
+		}
+		if renamed.Name != "Poetry club" {
+			t.Errorf("Expected any member to rename the group, got %q", renamed.Name)
+		}
+
+		if _, err := messaging.RenameGroup(member.ID, group.ID, ""); !errors.Is(err, messaging.ErrGroupNameRequired) {
+			t.Errorf("Expected ErrGroupNameRequired, got: %v", err)
+		}
+		if _, err := messaging.RenameGroup(outsider.ID, group.ID, "Mine now"); !errors.Is(err, messaging.ErrConversationNotFound) {
+			t.Errorf("Expected ErrConversationNotFound for a non-member, got: %v", err)
+		}
+
+		direct, err := messaging.Send(owner.ID, member.ID, "hi")
+		if err != nil {
+			t.Fatalf("Send should not return error, got: %v", err)
+		}
+		if _, err := messaging.RenameGroup(owner.ID, direct.ConversationID, "Us"); !errors.Is(err, messaging.ErrNotGroup) {
+			t.Errorf("Expected ErrNotGroup for a one-to-one conversation, got: %v", err)
+		}
+	})
+
+	t.Run("Add Members", func(t *testing.T) {
+		group := createGroup(t)
+		if _, err := messaging.SendToConversation(owner.ID, group.ID, "before you joined"); err != nil {
+			t.Fatalf("SendToConversation should not return error, got: %v", err)
+		}
+
+		if _, err := messaging.AddMembers(outsider.ID, group.ID, []string{outsider.ID}); !errors.Is(err, messaging.ErrConversationNotFound) {
+			t.Errorf("Expected non-members unable to add anyone, got: %v", err)
+		}
+
+		updated, err := messaging.AddMembers(member.ID, group.ID, []string{owner.ID, newcomer.ID, newcomer.ID})
+		if err != nil {
+			t.Fatalf("AddMembers should not return error, got: %v", err)
+		}
+		if ids := memberIDs(updated); len(ids) != 3 || !ids[newcomer.ID] {
+			t.Errorf("Expected the newcomer added once and existing members ignored, got %+v", updated.Participants)
+		}
+		for _, participant := range updated.Participants {
+			if participant.UserID == newcomer.ID && participant.LastReadAt == nil {
+				t.Error("Expected earlier history marked as read for the newcomer")
+			}
+		}
+
+		// History from before joining is readable
+		history, err := messaging.GetMessagesInConversation(newcomer.ID, group.ID, 10, 0)
+		if err != nil || len(history) != 1 {
+			t.Errorf("Expected the newcomer to read 1 earlier message, got %d, %v", len(history), err)
+		}
+
+		if unchanged, err := messaging.AddMembers(owner.ID, group.ID, []string{member.ID}); err != nil || len(unchanged.Participants) != 3 {
+			t.Errorf("Expected adding existing members to be a no-op, got %v", err)
+		}
+		tooMany := make([]string, messaging.MaxGroupSize-2)
+		for i := range tooMany {
+			tooMany[i] = fmt.Sprintf("user-%d", i)
+		}
+		if _, err := messaging.AddMembers(owner.ID, group.ID, tooMany); !errors.Is(err, messaging.ErrGroupTooLarge) {
+			t.Errorf("Expected ErrGroupTooLarge, got: %v", err)
+		}
+	})
+
+	t.Run("Non-members Cannot Send", func(t *testing.T) {
+		group := createGroup(t)
+
+		if _, err := messaging.SendToConversation(outsider.ID, group.ID, "let me in"); !errors.Is(err, messaging.ErrConversationNotFound) {
+			t.Errorf("Expected ErrConversationNotFound, got: %v", err)
+		}
+		if _, err := messaging.GetMessagesInConversation(outsider.ID, group.ID, 10, 0); !errors.Is(err, messaging.ErrConversationNotFound) {
+			t.Errorf("Expected non-members unable to read, got: %v", err)
+		}
+
+		session, err := auth.CreateSession(outsider.ID, "agent", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("CreateSession should not return error, got: %v", err)
+		}
+		r := httptest.NewRequest(http.MethodPost, "/api/conversations/"+group.ID+"/messages", strings.NewReader(`{"content": "let me in"}`))
+		r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		w := httptest.NewRecorder()
+		handlers.ConversationHandler(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", w.Code)
+		}
+
+		var stored int
+		database.DB.QueryRow("SELECT COUNT(*) FROM messages WHERE conversation_id = ?", group.ID).Scan(&stored)
+		if stored != 0 {
+			t.Errorf("Expected nothing stored, got %d messages", stored)
+		}
+	})
+
+	t.Run("Leave Conversation", func(t *testing.T) {
+		group := createGroup(t)
+		if _, err := messaging.SendToConversation(member.ID, group.ID, "see you"); err != nil {
+			t.Fatalf("SendToConversation should not return error, got: %v", err)
+		}
+
+		remaining, err := messaging.LeaveConversation(member.ID, group.ID)
+		if err != nil {
+			t.Fatalf("LeaveConversation should not return error, got: %v", err)
+		}
+		if ids := memberIDs(remaining); len(ids) != 1 || !ids[owner.ID] {
+			t.Errorf("Expected only the owner left, got %+v", remaining.Participants)
+		}
+		if _, err := messaging.SendToConversation(member.ID, group.ID, "one more thing"); !errors.Is(err, messaging.ErrConversationNotFound) {
+			t.Errorf("Expected former members unable to send, got: %v", err)
+		}
+		if _, err := messaging.LeaveConversation(member.ID, group.ID); !errors.Is(err, messaging.ErrConversationNotFound) {
+			t.Errorf("Expected leaving twice to fail, got: %v", err)
+		}
+
+		// The last member leaving deletes the group and its messages
+		deleted, err := messaging.LeaveConversation(owner.ID, group.ID)
+		if err != nil || deleted != nil {
+			t.Fatalf("Expected the group deleted, got %+v, %v", deleted, err)
+		}
+		var conversations, messages int
+		database.DB.QueryRow("SELECT COUNT(*) FROM conversations WHERE id = ?", group.ID).Scan(&conversations)
+		database.DB.QueryRow("SELECT COUNT(*) FROM messages WHERE conversation_id = ?", group.ID).Scan(&messages)
+		if conversations != 0 || messages != 0 {
+			t.Errorf("Expected no conversation or messages left, got %d and %d", conversations, messages)
+		}
+
+		direct, err := messaging.Send(owner.ID, member.ID, "hi")
+		if err != nil {
+			t.Fatalf("Send should not return error, got: %v", err)
+		}
+		if _, err := messaging.LeaveConversation(owner.ID, direct.ConversationID); !errors.Is(err, messaging.ErrNotGroup) {
+			t.Errorf("Expected ErrNotGroup for a one-to-one conversation, got: %v", err)
+		}
+	})
+}
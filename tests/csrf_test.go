@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forum/internal/auth"
+	"forum/internal/config"
+	"forum/internal/handlers"
+)
+
+// Test which request origins may write to the API and open websockets
+func TestOriginAllowed(t *testing.T) {
+	cfg := config.Default().Auth
+	cfg.AllowedOrigins = []string{"https://app.example.com"}
+	auth.Initialize(cfg)
+	defer auth.Initialize(config.Default().Auth)
+
+	cases := map[string]bool{
+		"":                         true,
+		"http://forum.test:8080":   true,
+		"HTTP://FORUM.TEST:8080":   true,
+		"https://app.example.com":  true,
+		"https://evil.example.com": false,
+		"http://forum.test:9090":   false,
+		"null":                     false,
+	}
+
+	for origin, expected := range cases {
+		r := httptest.NewRequest(http.MethodPost, "http://forum.test:8080/api/posts", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if got := auth.OriginAllowed(r); got != expected {
+			t.Errorf("OriginAllowed(%q) = %v, expected %v", origin, got, expected)
+		}
+	}
+}
+
+// Test that API writes need the session's CSRF token
+func TestCSRFProtect(t *testing.T) {
+	openTestDatabase(t)
+	user := createTestUser(t, "csrf")
+
+	session, err := auth.CreateSession(user.ID, "test-agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("CreateSession should not return error, got: %v", err)
+	}
+	if len(session.CSRFToken) != 64 {
+		t.Fatalf("Expected a 64 character CSRF token, got %q", session.CSRFToken)
+	}
+
+	stored, err := auth.GetSessionByID(session.ID)
+	if err != nil || stored == nil || stored.CSRFToken != session.CSRFToken {
+		t.Fatalf("Stored session should keep its CSRF token, got %+v (%v)", stored, err)
+	}
+
+	protected := handlers.CSRFProtect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(method, path, origin, token string, withSession bool) int {
+		r := httptest.NewRequest(method, "http://forum.test"+path, nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if token != "" {
+			r.Header.Set(auth.CSRFHeaderName, token)
+		}
+		if withSession {
+			r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		}
+		w := httptest.NewRecorder()
+		protected.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		origin      string
+		token       string
+		withSession bool
+		expected    int
+	}{
+		{"Write With Token", http.MethodPost, "/api/posts", "http://forum.test", session.CSRFToken, true, http.StatusOK},
+		{"Write Without Token", http.MethodPost, "/api/posts", "", "", true, http.StatusForbidden},
+		{"Write With Wrong Token", http.MethodDelete, "/api/posts/1", "", "not-the-token", true, http.StatusForbidden},
+		{"Read Without Token", http.MethodGet, "/api/posts", "", "", true, http.StatusOK},
+		{"Anonymous Login", http.MethodPost, "/api/login", "", "", false, http.StatusOK},
+		{"Cross Origin Login", http.MethodPost, "/api/login", "https://evil.example.com", "", false, http.StatusForbidden},
+		{"Cross Origin With Token", http.MethodPost, "/api/posts", "https://evil.example.com", session.CSRFToken, true, http.StatusForbidden},
+		{"Outside The API", http.MethodPost, "/auth/github/callback", "", "", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := send(tt.method, tt.path, tt.origin, tt.token, tt.withSession); got != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/config"
+	"forum/internal/database"
+	"forum/internal/handlers"
+	"forum/internal/messaging"
+	"forum/internal/models"
+)
+
+// Test editing and deleting private messages
+func TestMessageEditing(t *testing.T) {
+	openTestDatabase(t)
+	alice := createTestUser(t, "editalice")
+	bob := createTestUser(t, "editbob")
+
+	// send stores a message from alice to bob
+	send := func(content string) *models.Message {
+		message, err := messaging.Send(alice.ID, bob.ID, content)
+		if err != nil {
+			t.Fatalf("Send should not return error, got: %v", err)
+		}
+		return message
+	}
+
+	// request calls the message endpoint as user and returns the status
+	request := func(method, messageID, body string, user *models.User) int {
+		r := httptest.NewRequest(method, "/api/messages/"+messageID, strings.NewReader(body))
+		session, err := auth.CreateSession(user.ID, "agent", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("CreateSession should not return error, got: %v", err)
+		}
+		r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		w := httptest.NewRecorder()
+		handlers.MessageHandler(w, r)
+		return w.Code
+	}
+
+	// lastMessageID returns the conversation's last_message_id, or "" when cleared
+	lastMessageID := func(conversationID string) string {
+		var id *string
+		if err := database.DB.QueryRow("SELECT last_message_id FROM conversations WHERE id = ?", conversationID).Scan(&id); err != nil {
+			t.Fatalf("Failed to load conversation: %v", err)
+		}
+		if id == nil {
+			return ""
+		}
+		return *id
+	}
+
+	t.Run("Edit", func(t *testing.T) {
+		message := send("hello")
+
+		edited, err := messaging.Edit(alice.ID, message.ID, "  hello again  ")
+		if err != nil {
+			t.Fatalf("Edit should not return error, got: %v", err)
+		}
+		if edited.Content != "hello again" || edited.EditedAt == nil || edited.IsDeleted {
+			t.Errorf("Expected the trimmed content with an edit time, got %+v", edited)
+		}
+
+		if _, err := messaging.Edit(alice.ID, message.ID, "   "); !errors.Is(err, messaging.ErrEmptyMessage) {
+			t.Errorf("Expected ErrEmptyMessage, got: %v", err)
+		}
+		if _, err := messaging.Edit(alice.ID, message.ID, strings.Repeat("x", messaging.MaxMessageLength+1)); !errors.Is(err, messaging.ErrMessageTooLong) {
+			t.Errorf("Expected ErrMessageTooLong, got: %v", err)
+		}
+		if _, err := messaging.Edit(alice.ID, "no-such-message", "hi"); !errors.Is(err, messaging.ErrMessageNotFound) {
+			t.Errorf("Expected ErrMessageNotFound, got: %v", err)
+		}
+
+		if code := request(http.MethodPut, message.ID, `{"content": "over http"}`, alice); code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", code)
+		}
+		if code := request(http.MethodPut, message.ID, `{"content": ""}`, alice); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for empty content, got %d", code)
+		}
+		if code := request(http.MethodPut, "no-such-message", `{"content": "hi"}`, alice); code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", code)
+		}
+	})
+
+	t.Run("Sender Only", func(t *testing.T) {
+		message := send("mine")
+
+		if _, err := messaging.Edit(bob.ID, message.ID, "yours now"); !errors.Is(err, messaging.ErrNotMessageSender) {
+			t.Errorf("Expected ErrNotMessageSender for an edit, got: %v", err)
+		}
+		if _, err := messaging.Delete(bob.ID, message.ID); !errors.Is(err, messaging.ErrNotMessageSender) {
+			t.Errorf("Expected ErrNotMessageSender for a delete, got: %v", err)
+		}
+		if code := request(http.MethodPut, message.ID, `{"content": "yours now"}`, bob); code != http.StatusForbidden {
+			t.Errorf("Expected 403 for an edit by the receiver, got %d", code)
+		}
+		if code := request(http.MethodDelete, message.ID, "", bob); code != http.StatusForbidden {
+			t.Errorf("Expected 403 for a delete by the receiver, got %d", code)
+		}
+
+		if unchanged, _ := messaging.GetMessage(message.ID); unchanged.Content != "mine" || unchanged.IsDeleted {
+			t.Errorf("Expected the message untouched, got %+v", unchanged)
+		}
+	})
+
+	t.Run("Edit Window", func(t *testing.T) {
+		window := messaging.EditWindow
+		defer func() { messaging.EditWindow = window }()
+		messaging.EditWindow = 15 * time.Minute
+
+		message := send("too late")
+		database.DB.Exec("UPDATE messages SET created_at = ? WHERE id = ?", time.Now().Add(-16*time.Minute), message.ID)
+
+		if _, err := messaging.Edit(alice.ID, message.ID, "changed"); !errors.Is(err, messaging.ErrEditWindowExpired) {
+			t.Errorf("Expected ErrEditWindowExpired for an edit, got: %v", err)
+		}
+		if _, err := messaging.Delete(alice.ID, message.ID); !errors.Is(err, messaging.ErrEditWindowExpired) {
+			t.Errorf("Expected ErrEditWindowExpired for a delete, got: %v", err)
+		}
+		if code := request(http.MethodPut, message.ID, `{"content": "changed"}`, alice); code != http.StatusForbidden {
+			t.Errorf("Expected 403 after the window, got %d", code)
+		}
+
+		// Zero removes the limit
+		messaging.EditWindow = 0
+		if _, err := messaging.Edit(alice.ID, message.ID, "changed"); err != nil {
+			t.Errorf("Expected no limit with a zero window, got: %v", err)
+		}
+	})
+
+	t.Run("Tombstone", func(t *testing.T) {
+		message := send("regrettable")
+
+		deleted, err := messaging.Delete(alice.ID, message.ID)
+		if err != nil {
+			t.Fatalf("Delete should not return error, got: %v", err)
+		}
+		if !deleted.IsDeleted || deleted.DeletedAt == nil || deleted.Content != "" {
+			t.Errorf("Expected a tombstone without content, got %+v", deleted)
+		}
+
+		var content string
+		var count int
+		database.DB.QueryRow("SELECT content, COUNT(*) FROM messages WHERE id = ?", message.ID).Scan(&content, &count)
+		if count != 1 || content != "" {
+			t.Errorf("Expected the row kept with empty content, got %d rows with %q", count, content)
+		}
+
+		if _, err := messaging.Edit(alice.ID, message.ID, "undo"); !errors.Is(err, messaging.ErrMessageDeleted) {
+			t.Errorf("Expected ErrMessageDeleted for an edit, got: %v", err)
+		}
+		if _, err := messaging.Delete(alice.ID, message.ID); !errors.Is(err, messaging.ErrMessageDeleted) {
+			t.Errorf("Expected ErrMessageDeleted for a second delete, got: %v", err)
+		}
+		if code := request(http.MethodDelete, message.ID, "", alice); code != http.StatusGone {
+			t.Errorf("Expected 410 for a deleted message, got %d", code)
+		}
+	})
+
+	t.Run("Last Message Is Fixed", func(t *testing.T) {
+		// A fresh pair so the conversation holds only these messages
+		carol := createTestUser(t, "editcarol")
+		var sent []*models.Message
+		for i, content := range []string{"first", "second", "third"} {
+			message, err := messaging.Send(carol.ID, bob.ID, content)
+			if err != nil {
+				t.Fatalf("Send should not return error, got: %v", err)
+			}
+			database.DB.Exec("UPDATE messages SET created_at = ? WHERE id = ?", time.Now().Add(time.Duration(i-3)*time.Minute), message.ID)
+			sent = append(sent, message)
+		}
+		conversationID := sent[0].ConversationID
+
+		steps := []struct {
+			delete *models.Message
+			want   string
+		}{
+			{sent[2], sent[1].ID}, // The last message falls back to the newest remaining one
+			{sent[0], sent[1].ID}, // Deleting an older message leaves it alone
+			{sent[1], ""},         // Nothing remains
+		}
+		for _, step := range steps {
+			if _, err := messaging.Delete(carol.ID, step.delete.ID); err != nil {
+				t.Fatalf("Delete should not return error, got: %v", err)
+			}
+			if got := lastMessageID(conversationID); got != step.want {
+				t.Errorf("After deleting %q: expected last message %q, got %q", step.delete.Content, step.want, got)
+			}
+		}
+	})
+
+	t.Run("Moderator Removal", func(t *testing.T) {
+		message := send("reported")
+		database.DB.Exec("UPDATE messages SET created_at = ? WHERE id = ?", time.Now().Add(-24*time.Hour), message.ID)
+
+		removed, err := messaging.Remove(message.ID)
+		if err != nil {
+			t.Fatalf("Remove should not return error, got: %v", err)
+		}
+		if !removed.IsDeleted || removed.Content != "" {
+			t.Errorf("Expected a tombstone regardless of sender and age, got %+v", removed)
+		}
+		if _, err := messaging.Remove(message.ID); !errors.Is(err, messaging.ErrMessageDeleted) {
+			t.Errorf("Expected ErrMessageDeleted, got: %v", err)
+		}
+	})
+
+	t.Run("Events Reach Both Participants", func(t *testing.T) {
+		server := startSocketServer(t, config.Default().WebSocket)
+		otherSession := dialSocket(t, server, alice.ID)
+		receiver := dialSocket(t, server, bob.ID)
+		message := send("watch this")
+
+		if code := request(http.MethodPut, message.ID, `{"content": "watch this, edited"}`, alice); code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+		for _, socket := range []*testSocket{otherSession, receiver} {
+			if edited := socket.expect("message_edited"); edited["id"] != message.ID || edited["content"] != "watch this, edited" {
+				t.Errorf("Expected the edited message, got %v", edited)
+			}
+		}
+
+		if code := request(http.MethodDelete, message.ID, "", alice); code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+		for _, socket := range []*testSocket{otherSession, receiver} {
+			deleted := socket.expect("message_deleted")
+			if deleted["id"] != message.ID || deleted["isDeleted"] != true || deleted["content"] != "" {
+				t.Errorf("Expected the tombstone, got %v", deleted)
+			}
+		}
+
+		// The tombstone is what both sides read back
+		var response struct{ Data models.Message }
+		r := httptest.NewRequest(http.MethodGet, "/api/messages/"+message.ID, nil)
+		session, _ := auth.CreateSession(bob.ID, "agent", "127.0.0.1")
+		r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		w := httptest.NewRecorder()
+		handlers.MessageHandler(w, r)
+		json.NewDecoder(w.Body).Decode(&response)
+		if !response.Data.IsDeleted || response.Data.Content != "" {
+			t.Errorf("Expected the receiver to read the tombstone, got %+v", response.Data)
+		}
+	})
+}
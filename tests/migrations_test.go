@@ -0,0 +1,113 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/database"
+)
+
+// Test that every migration can be applied and reverted
+func TestMigrations(t *testing.T) {
+	if err := database.Open(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Open should not return error, got: %v", err)
+	}
+	defer database.DB.Close()
+
+	t.Run("Migrate Up", func(t *testing.T) {
+		applied, err := database.MigrateUp()
+		if err != nil {
+			t.Fatalf("MigrateUp should not return error, got: %v", err)
+		}
+		if applied != database.LatestVersion() {
+			t.Errorf("Expected %d migrations applied, got %d", database.LatestVersion(), applied)
+		}
+
+		version, err := database.SchemaVersion()
+		if err != nil {
+			t.Fatalf("SchemaVersion should not return error, got: %v", err)
+		}
+		if version != database.LatestVersion() {
+			t.Errorf("Expected schema version %d, got %d", database.LatestVersion(), version)
+		}
+	})
+
+	t.Run("Migrate Up Is Idempotent", func(t *testing.T) {
+		applied, err := database.MigrateUp()
+		if err != nil {
+			t.Fatalf("MigrateUp should not return error, got: %v", err)
+		}
+		if applied != 0 {
+			t.Errorf("Expected no migrations applied, got %d", applied)
+		}
+	})
+
+	t.Run("Migrate Down And Up Again", func(t *testing.T) {
+		reverted, err := database.MigrateDown(database.LatestVersion())
+		if err != nil {
+			t.Fatalf("MigrateDown should not return error, got: %v", err)
+		}
+		if reverted != database.LatestVersion() {
+			t.Errorf("Expected %d migrations reverted, got %d", database.LatestVersion(), reverted)
+		}
+
+		version, err := database.SchemaVersion()
+		if err != nil {
+			t.Fatalf("SchemaVersion should not return error, got: %v", err)
+		}
+		if version != 0 {
+			t.Errorf("Expected schema version 0, got %d", version)
+		}
+
+		if _, err := database.MigrateUp(); err != nil {
+			t.Fatalf("MigrateUp after MigrateDown should not return error, got: %v", err)
+		}
+	})
+
+	t.Run("Statuses", func(t *testing.T) {
+		statuses, err := database.MigrationStatuses()
+		if err != nil {
+			t.Fatalf("MigrationStatuses should not return error, got: %v", err)
+		}
+		if len(statuses) != database.LatestVersion() {
+			t.Errorf("Expected %d statuses, got %d", database.LatestVersion(), len(statuses))
+		}
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				t.Errorf("Migration %d should be applied", status.Version)
+			}
+		}
+	})
+
+	t.Run("Post Times Move To UTC", func(t *testing.T) {
+		if _, err := database.MigrateDown(1); err != nil {
+			t.Fatalf("MigrateDown should not return error, got: %v", err)
+		}
+		author := createTestUser(t, "utcauthor")
+		written := time.Date(2026, 3, 10, 14, 0, 0, 500, time.FixedZone("CEST", 2*60*60))
+		database.DB.Exec(`
+			INSERT INTO posts (user_id, title, content, created_at) VALUES (?, 'Title', 'Body', ?), (?, 'Title', 'Body', CURRENT_TIMESTAMP)
+		`, author.ID, written, author.ID)
+
+		if _, err := database.MigrateUp(); err != nil {
+			t.Fatalf("MigrateUp should not return error, got: %v", err)
+		}
+
+		rows, err := database.DB.Query("SELECT CAST(created_at AS TEXT) FROM posts ORDER BY id")
+		if err != nil {
+			t.Fatalf("Failed to query posts: %v", err)
+		}
+		defer rows.Close()
+		var stored []string
+		for rows.Next() {
+			var createdAt string
+			rows.Scan(&createdAt)
+			stored = append(stored, createdAt)
+		}
+		if len(stored) != 2 || stored[0] != "2026-03-10 12:00:00.0000005+00:00" || !strings.HasSuffix(stored[1], "+00:00") {
+			t.Errorf("Expected both times rewritten in UTC, got %v", stored)
+		}
+	})
+}
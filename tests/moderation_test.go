@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/database"
+	"forum/internal/handlers"
+	"forum/internal/messaging"
+	"forum/internal/models"
+	"forum/internal/moderation"
+)
+
+// Test filing reports and working through them as a moderator
+func TestModeration(t *testing.T) {
+	openTestDatabase(t)
+	author := createTestUser(t, "author")
+	reporter := createTestUser(t, "reporter")
+	second := createTestUser(t, "second")
+	moderator := createTestUser(t, "moderator")
+	other := createTestUser(t, "othermod")
+	auth.SetUserRole(moderator.ID, auth.RoleModerator)
+	auth.SetUserRole(other.ID, auth.RoleModerator)
+
+	result, err := database.DB.Exec(`
+		INSERT INTO posts (user_id, title, content, created_at, updated_at) VALUES (?, 'Spam', 'Buy now', ?, ?)
+	`, author.ID, time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+	postID, _ := result.LastInsertId()
+	postTarget := strconv.FormatInt(postID, 10)
+
+	var report *models.Report
+
+	t.Run("Create Report", func(t *testing.T) {
+		report, err = moderation.CreateReport(reporter.ID, models.ReportRequest{
+			TargetType: moderation.TargetPost, TargetID: postTarget, Reason: "  spam  ",
+		})
+		if err != nil {
+			t.Fatalf("CreateReport should not return error, got: %v", err)
+		}
+		if report.Status != moderation.StatusOpen || report.Reason != "spam" {
+			t.Errorf("Expected an open report with a trimmed reason, got %q %q", report.Status, report.Reason)
+		}
+		if report.TargetUserID == nil || *report.TargetUserID != author.ID {
+			t.Errorf("Expected the post author as target user, got %v", report.TargetUserID)
+		}
+		if report.Snapshot != "Spam\n\nBuy now" {
+			t.Errorf("Expected the post as snapshot, got %q", report.Snapshot)
+		}
+	})
+
+	t.Run("Invalid Reports", func(t *testing.T) {
+		cases := []struct {
+			name       string
+			reporterID string
+			req        models.ReportRequest
+			want       error
+		}{
+			{"Duplicate", reporter.ID, models.ReportRequest{TargetType: moderation.TargetPost, TargetID: postTarget, Reason: "again"}, moderation.ErrAlreadyReported},
+			{"Own Content", author.ID, models.ReportRequest{TargetType: moderation.TargetPost, TargetID: postTarget, Reason: "oops"}, moderation.ErrCannotReportSelf},
+			{"No Reason", second.ID, models.ReportRequest{TargetType: moderation.TargetPost, TargetID: postTarget, Reason: " "}, moderation.ErrReasonRequired},
+			{"Unknown Type", second.ID, models.ReportRequest{TargetType: "poll", TargetID: "1", Reason: "spam"}, moderation.ErrInvalidTarget},
+			{"Missing Post", second.ID, models.ReportRequest{TargetType: moderation.TargetPost, TargetID: "999", Reason: "spam"}, moderation.ErrTargetNotFound},
+		}
+		for _, tc := range cases {
+			if _, err := moderation.CreateReport(tc.reporterID, tc.req); !errors.Is(err, tc.want) {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+			}
+		}
+	})
+
+	t.Run("Report Message", func(t *testing.T) {
+		message, err := messaging.Send(author.ID, reporter.ID, "rude words")
+		if err != nil {
+			t.Fatalf("Send should not return error, got: %v", err)
+		}
+
+		req := models.ReportRequest{TargetType: moderation.TargetMessage, TargetID: message.ID, Reason: "abuse"}
+		if _, err := moderation.CreateReport(second.ID, req); !errors.Is(err, moderation.ErrTargetNotFound) {
+			t.Errorf("Only conversation members should be able to report a message, got: %v", err)
+		}
+
+		report, err := moderation.CreateReport(reporter.ID, req)
+		if err != nil {
+			t.Fatalf("CreateReport should not return error, got: %v", err)
+		}
+
+		resolution, err := moderation.ResolveReport(report.ID, moderator.ID, models.ResolveReportRequest{Action: moderation.ActionHide})
+		if err != nil {
+			t.Fatalf("ResolveReport should not return error, got: %v", err)
+		}
+		if resolution.RemovedMessage == nil || !resolution.RemovedMessage.IsDeleted {
+			t.Error("Hiding a message should delete it")
+		}
+	})
+
+	t.Run("Message Removal Rolls Back", func(t *testing.T) {
+		message, err := messaging.Send(author.ID, reporter.ID, "more rude words")
+		if err != nil {
+			t.Fatalf("Send should not return error, got: %v", err)
+		}
+		messageReport, err := moderation.CreateReport(reporter.ID, models.ReportRequest{
+			TargetType: moderation.TargetMessage, TargetID: message.ID, Reason: "abuse",
+		})
+		if err != nil {
+			t.Fatalf("CreateReport should not return error, got: %v", err)
+		}
+
+		// Make taking the message down fail after the report is closed
+		if _, err := database.DB.Exec(`
+			CREATE TRIGGER fail_message_removal BEFORE UPDATE OF deleted_at ON messages
+			BEGIN SELECT RAISE(ABORT, 'removal failed'); END
+		`); err != nil {
+			t.Fatalf("Failed to create trigger: %v", err)
+		}
+		_, err = moderation.ResolveReport(messageReport.ID, moderator.ID, models.ResolveReportRequest{Action: moderation.ActionHide})
+		database.DB.Exec("DROP TRIGGER fail_message_removal")
+		if err == nil {
+			t.Fatal("Expected ResolveReport to fail")
+		}
+
+		detail, err := moderation.GetReport(messageReport.ID)
+		if err != nil {
+			t.Fatalf("GetReport should not return error, got: %v", err)
+		}
+		if detail.Status != moderation.StatusOpen {
+			t.Errorf("Expected the report left open, got %q", detail.Status)
+		}
+		var actions int
+		database.DB.QueryRow("SELECT COUNT(*) FROM moderation_actions WHERE report_id = ?", messageReport.ID).Scan(&actions)
+		if actions != 0 {
+			t.Errorf("Expected no moderation actions recorded, got %d", actions)
+		}
+
+		resolution, err := moderation.ResolveReport(messageReport.ID, moderator.ID, models.ResolveReportRequest{Action: moderation.ActionHide})
+		if err != nil {
+			t.Fatalf("ResolveReport should not return error on retry, got: %v", err)
+		}
+		if resolution.RemovedMessage == nil || resolution.RemovedMessage.ID != message.ID || !resolution.RemovedMessage.IsDeleted {
+			t.Errorf("Expected the message removed on retry, got %+v", resolution.RemovedMessage)
+		}
+	})
+
+	t.Run("Claim", func(t *testing.T) {
+		claimed, err := moderation.ClaimReport(report.ID, moderator.ID)
+		if err != nil {
+			t.Fatalf("ClaimReport should not return error, got: %v", err)
+		}
+		if claimed.Status != moderation.StatusClaimed || claimed.ClaimedBy == nil || *claimed.ClaimedBy != moderator.Nickname {
+			t.Errorf("Expected the report claimed by %s, got %q %v", moderator.Nickname, claimed.Status, claimed.ClaimedBy)
+		}
+
+		if _, err := moderation.ClaimReport(report.ID, moderator.ID); err != nil {
+			t.Errorf("Claiming again should be a no-op, got: %v", err)
+		}
+		if _, err := moderation.ClaimReport(report.ID, other.ID); !errors.Is(err, moderation.ErrClaimedByOther) {
+			t.Errorf("Expected ErrClaimedByOther, got: %v", err)
+		}
+		if _, err := moderation.DismissReport(report.ID, other.ID, ""); !errors.Is(err, moderation.ErrClaimedByOther) {
+			t.Errorf("Expected ErrClaimedByOther, got: %v", err)
+		}
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		if _, err := moderation.CreateReport(second.ID, models.ReportRequest{
+			TargetType: moderation.TargetPost, TargetID: postTarget, Reason: "also spam",
+		}); err != nil {
+			t.Fatalf("CreateReport should not return error, got: %v", err)
+		}
+
+		if _, err := moderation.ResolveReport(report.ID, moderator.ID, models.ResolveReportRequest{
+			Action: moderation.ActionSuspend, SuspendDays: 0,
+		}); !errors.Is(err, moderation.ErrInvalidSuspension) {
+			t.Errorf("Expected ErrInvalidSuspension, got: %v", err)
+		}
+
+		resolution, err := moderation.ResolveReport(report.ID, moderator.ID, models.ResolveReportRequest{
+			Action: moderation.ActionHide, Note: "advertising",
+		})
+		if err != nil {
+			t.Fatalf("ResolveReport should not return error, got: %v", err)
+		}
+		if resolution.Report.Status != moderation.StatusResolved || *resolution.Report.Action != moderation.ActionHide {
+			t.Errorf("Expected a report resolved with hide, got %q %v", resolution.Report.Status, resolution.Report.Action)
+		}
+		if len(resolution.ReporterIDs) != 2 {
+			t.Errorf("Expected both reports on the post to be closed, got %v", resolution.ReporterIDs)
+		}
+
+		var hidden bool
+		database.DB.QueryRow("SELECT hidden_at IS NOT NULL FROM posts WHERE id = ?", postID).Scan(&hidden)
+		if !hidden {
+			t.Error("Expected the post to be hidden")
+		}
+
+		pending, err := moderation.ListReports("", 10, 0)
+		if err != nil {
+			t.Fatalf("ListReports should not return error, got: %v", err)
+		}
+		if len(pending) != 0 {
+			t.Errorf("Expected no pending reports, got %d", len(pending))
+		}
+
+		if _, err := moderation.DismissReport(report.ID, moderator.ID, ""); !errors.Is(err, moderation.ErrReportClosed) {
+			t.Errorf("Expected ErrReportClosed, got: %v", err)
+		}
+	})
+
+	t.Run("Hidden Content Takes No Interaction", func(t *testing.T) {
+		// send posts body to handler as the second user
+		send := func(handler http.HandlerFunc, path, body string) int {
+			r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			session, err := auth.CreateSession(second.ID, "agent", "127.0.0.1")
+			if err != nil {
+				t.Fatalf("CreateSession should not return error, got: %v", err)
+			}
+			r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+			w := httptest.NewRecorder()
+			handler(w, r)
+			return w.Code
+		}
+		comment := func(postID int64) int64 {
+			result, err := database.DB.Exec(`
+				INSERT INTO comments (post_id, user_id, content, created_at, updated_at) VALUES (?, ?, 'A comment', ?, ?)
+			`, postID, author.ID, time.Now(), time.Now())
+			if err != nil {
+				t.Fatalf("Failed to create comment: %v", err)
+			}
+			id, _ := result.LastInsertId()
+			return id
+		}
+
+		// The post was hidden by the resolution above
+		onHiddenPost := comment(postID)
+
+		result, err := database.DB.Exec(`
+			INSERT INTO posts (user_id, title, content, created_at, updated_at) VALUES (?, 'Fine', 'Fine', ?, ?)
+		`, author.ID, time.Now(), time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		visiblePostID, _ := result.LastInsertId()
+		hiddenComment := comment(visiblePostID)
+		visibleComment := comment(visiblePostID)
+		database.DB.Exec("UPDATE comments SET hidden_at = ? WHERE id = ?", time.Now(), hiddenComment)
+
+		rejected := map[string]struct {
+			handler    http.HandlerFunc
+			path, body string
+		}{
+			"Comment On Hidden Post":      {handlers.CommentHandler, "/api/comment", fmt.Sprintf(`{"postId": %d, "content": "hi"}`, postID)},
+			"Reply To Hidden Comment":     {handlers.CommentHandler, "/api/comment", fmt.Sprintf(`{"postId": %d, "parentId": %d, "content": "hi"}`, visiblePostID, hiddenComment)},
+			"Reply Across Posts":          {handlers.CommentHandler, "/api/comment", fmt.Sprintf(`{"postId": %d, "parentId": %d, "content": "hi"}`, postID, visibleComment)},
+			"Like Hidden Post":            {handlers.LikeHandler, "/api/like", fmt.Sprintf(`{"postId": %d, "isLike": true}`, postID)},
+			"Like Hidden Comment":         {handlers.LikeHandler, "/api/like", fmt.Sprintf(`{"commentId": %d, "isLike": true}`, hiddenComment)},
+			"Like Comment On Hidden Post": {handlers.LikeHandler, "/api/like", fmt.Sprintf(`{"commentId": %d, "isLike": true}`, onHiddenPost)},
+		}
+		for name, tc := range rejected {
+			if code := send(tc.handler, tc.path, tc.body); code != http.StatusNotFound {
+				t.Errorf("%s: expected 404, got %d", name, code)
+			}
+		}
+
+		var comments, likes int
+		database.DB.QueryRow("SELECT COUNT(*) FROM comments WHERE user_id = ?", second.ID).Scan(&comments)
+		database.DB.QueryRow("SELECT COUNT(*) FROM likes WHERE user_id = ?", second.ID).Scan(&likes)
+		if comments != 0 || likes != 0 {
+			t.Errorf("Expected nothing stored, got %d comments and %d likes", comments, likes)
+		}
+
+		// Visible content is unaffected
+		if code := send(handlers.CommentHandler, "/api/comment", fmt.Sprintf(`{"postId": %d, "parentId": %d, "content": "hi"}`, visiblePostID, visibleComment)); code != http.StatusOK {
+			t.Errorf("Expected a reply to a visible comment to succeed, got %d", code)
+		}
+		if code := send(handlers.LikeHandler, "/api/like", fmt.Sprintf(`{"commentId": %d, "isLike": true}`, visibleComment)); code != http.StatusOK {
+			t.Errorf("Expected liking a visible comment to succeed, got %d", code)
+		}
+	})
+
+	t.Run("Audit Trail", func(t *testing.T) {
+		detail, err := moderation.GetReport(report.ID)
+		if err != nil {
+			t.Fatalf("GetReport should not return error, got: %v", err)
+		}
+
+		var actions []string
+		for _, action := range detail.Actions {
+			actions = append(actions, action.Action)
+			if action.Moderator == nil || *action.Moderator != moderator.Nickname {
+				t.Errorf("Expected every action by %s, got %v", moderator.Nickname, action.Moderator)
+			}
+		}
+		want := []string{moderation.ActionClaim, moderation.ActionHide, moderation.ActionResolve}
+		if len(actions) != len(want) {
+			t.Fatalf("Expected actions %v, got %v", want, actions)
+		}
+		for i := range want {
+			if actions[i] != want[i] {
+				t.Errorf("Expected actions %v, got %v", want, actions)
+				break
+			}
+		}
+	})
+
+	t.Run("Suspend", func(t *testing.T) {
+		userReport, err := moderation.CreateReport(reporter.ID, models.ReportRequest{
+			TargetType: moderation.TargetUser, TargetID: author.ID, Reason: "spammer",
+		})
+		if err != nil {
+			t.Fatalf("CreateReport should not return error, got: %v", err)
+		}
+
+		if _, err := moderation.ResolveReport(userReport.ID, moderator.ID, models.ResolveReportRequest{
+			Action: moderation.ActionHide,
+		}); !errors.Is(err, moderation.ErrInvalidAction) {
+			t.Errorf("Users cannot be hidden, expected ErrInvalidAction, got: %v", err)
+		}
+
+		if _, err := moderation.ResolveReport(userReport.ID, moderator.ID, models.ResolveReportRequest{
+			Action: moderation.ActionSuspend, SuspendDays: 7, Note: "repeated spam",
+		}); err != nil {
+			t.Fatalf("ResolveReport should not return error, got: %v", err)
+		}
+
+		var reason string
+		var expiresAt time.Time
+		err = database.DB.QueryRow(
+			"SELECT reason, expires_at FROM user_suspensions WHERE user_id = ?", author.ID,
+		).Scan(&reason, &expiresAt)
+		if err != nil {
+			t.Fatalf("Expected a suspension, got: %v", err)
+		}
+		if reason != "repeated spam" || time.Until(expiresAt) < 6*24*time.Hour {
+			t.Errorf("Expected a 7 day suspension for repeated spam, got %q until %v", reason, expiresAt)
+		}
+	})
+
+	t.Run("Dismiss", func(t *testing.T) {
+		staffReport, err := moderation.CreateReport(reporter.ID, models.ReportRequest{
+			TargetType: moderation.TargetUser, TargetID: other.ID, Reason: "mean mod",
+		})
+		if err != nil {
+			t.Fatalf("CreateReport should not return error, got: %v", err)
+		}
+
+		if _, err := moderation.ResolveReport(staffReport.ID, moderator.ID, models.ResolveReportRequest{
+			Action: moderation.ActionSuspend, SuspendDays: 1,
+		}); !errors.Is(err, auth.ErrCannotSuspendStaff) {
+			t.Errorf("Expected ErrCannotSuspendStaff, got: %v", err)
+		}
+
+		resolution, err := moderation.DismissReport(staffReport.ID, moderator.ID, "no issue")
+		if err != nil {
+			t.Fatalf("DismissReport should not return error, got: %v", err)
+		}
+		if resolution.Report.Status != moderation.StatusDismissed || len(resolution.ReporterIDs) != 1 {
+			t.Errorf("Expected a dismissed report for one reporter, got %q %v", resolution.Report.Status, resolution.ReporterIDs)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"forum/internal/auth"
+	"forum/internal/config"
+	"forum/internal/database"
+	"forum/internal/handlers"
+	"forum/internal/models"
+	"forum/internal/notifications"
+)
+
+// Test @mention extraction
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"No Mentions", "just a regular comment", nil},
+		{"Single Mention", "thanks @alice!", []string{"alice"}},
+		{"Start Of Text", "@bob what do you think?", []string{"bob"}},
+		{"Multiple Mentions", "@alice and @bob_99 agree", []string{"alice", "bob_99"}},
+		{"Duplicates Removed", "@alice @Alice @alice", []string{"alice"}},
+		{"Email Is Not A Mention", "mail me at me@example.com", nil},
+		{"Nickname Characters", "ping @jean-luc.", []string{"jean-luc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := notifications.ExtractMentions(tt.text)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// Test storing, listing, reading and pushing notifications
+func TestNotifications(t *testing.T) {
+	openTestDatabase(t)
+	author := createTestUser(t, "notifyauthor")
+	reader := createTestUser(t, "NotifyReader")
+	other := createTestUser(t, "notifyother")
+
+	// list returns every notification of a user, newest first
+	list := func(user *models.User, unreadOnly bool) []models.Notification {
+		items, err := notifications.List(user.ID, 100, 0, unreadOnly)
+		if err != nil {
+			t.Fatalf("List should not return error, got: %v", err)
+		}
+		return items
+	}
+
+	// unread returns a user's unread count
+	unread := func(user *models.User) int {
+		count, err := notifications.UnreadCount(user.ID)
+		if err != nil {
+			t.Fatalf("UnreadCount should not return error, got: %v", err)
+		}
+		return count
+	}
+
+	// clear deletes every stored notification
+	clear := func() {
+		database.DB.Exec("DELETE FROM notifications")
+	}
+
+	var postID int
+	database.DB.QueryRow(`
+		INSERT INTO posts (user_id, title, content, created_at, updated_at)
+		VALUES (?, 'Notified', 'Body', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id
+	`, author.ID).Scan(&postID)
+
+	t.Run("Notify", func(t *testing.T) {
+		defer clear()
+
+		n := &models.Notification{UserID: author.ID, ActorID: &reader.ID, Type: notifications.TypePostLike, Message: "liked", PostID: &postID}
+		if err := notifications.Notify(n); err != nil {
+			t.Fatalf("Notify should not return error, got: %v", err)
+		}
+		if n.ID == 0 || n.CreatedAt.IsZero() {
+			t.Errorf("Expected the stored ID and time set, got %+v", n)
+		}
+
+		items := list(author, false)
+		if len(items) != 1 {
+			t.Fatalf("Expected 1 notification, got %d", len(items))
+		}
+		stored := items[0]
+		if stored.ID != n.ID || stored.Type != notifications.TypePostLike || stored.ActorNickname != reader.Nickname ||
+			stored.PostID == nil || *stored.PostID != postID || stored.IsRead {
+			t.Errorf("Expected the unread like by %s on post %d, got %+v", reader.Nickname, postID, stored)
+		}
+	})
+
+	t.Run("Own Actions Are Skipped", func(t *testing.T) {
+		defer clear()
+
+		if err := notifications.Notify(&models.Notification{UserID: author.ID, ActorID: &author.ID, Type: notifications.TypePostLike, Message: "liked"}); err != nil {
+			t.Fatalf("Notify should not return error, got: %v", err)
+		}
+		notifications.NotifyLiked(author, &postID, nil)
+		notifications.NotifyPostCreated(author, &models.Post{ID: postID, Title: "Note to self", Content: "@notifyauthor remember"})
+
+		if n := len(list(author, false)); n != 0 {
+			t.Errorf("Expected no notifications about the user's own actions, got %d", n)
+		}
+
+		// Notifications without an actor, such as warnings, are always stored
+		notifications.NotifyWarning(author.ID, "")
+		if n := len(list(author, false)); n != 1 {
+			t.Errorf("Expected the warning stored, got %d", n)
+		}
+	})
+
+	t.Run("Mentions", func(t *testing.T) {
+		defer clear()
+
+		notifications.NotifyPostCreated(author, &models.Post{
+			ID: postID, Title: "Hello @notifyreader", Content: "Also @NOTIFYREADER, @notifyother and @nobody",
+		})
+
+		items := list(reader, false)
+		if len(items) != 1 || items[0].Type != notifications.TypeMention {
+			t.Fatalf("Expected one mention whatever the letter case, got %+v", items)
+		}
+		if n := len(list(other, false)); n != 1 {
+			t.Errorf("Expected %s mentioned once, got %d", other.Nickname, n)
+		}
+
+		// Nicknames differing only in case each get their own mentions
+		namesake := createTestUser(t, "notifyreader")
+		notifications.NotifyPostCreated(author, &models.Post{ID: postID, Title: "Hi @notifyreader", Content: ""})
+		if n := len(list(namesake, false)); n != 1 {
+			t.Errorf("Expected the exact nickname mentioned, got %d", n)
+		}
+		if n := len(list(reader, false)); n != 1 {
+			t.Errorf("Expected no second mention for %s, got %d", reader.Nickname, n)
+		}
+	})
+
+	t.Run("List And Mark Read", func(t *testing.T) {
+		defer clear()
+
+		var ids []int64
+		for i := 0; i < 3; i++ {
+			n := &models.Notification{UserID: reader.ID, ActorID: &author.ID, Type: notifications.TypePostComment, Message: "commented"}
+			if err := notifications.Notify(n); err != nil {
+				t.Fatalf("Notify should not return error, got: %v", err)
+			}
+			ids = append(ids, n.ID)
+		}
+		notifications.NotifyWarning(other.ID, "")
+
+		page, err := notifications.List(reader.ID, 2, ids[2], false)
+		if err != nil || len(page) != 2 || page[0].ID != ids[1] || page[1].ID != ids[0] {
+			t.Errorf("Expected the two notifications before %d, newest first, got %+v, %v", ids[2], page, err)
+		}
+
+		// Other users' IDs are ignored
+		otherIDs := list(other, false)
+		if err := notifications.MarkRead(reader.ID, []int64{ids[0], otherIDs[0].ID}); err != nil {
+			t.Fatalf("MarkRead should not return error, got: %v", err)
+		}
+		if n := unread(reader); n != 2 {
+			t.Errorf("Expected 2 unread, got %d", n)
+		}
+		if n := len(list(reader, true)); n != 2 {
+			t.Errorf("Expected 2 notifications listed as unread, got %d", n)
+		}
+		if n := unread(other); n != 1 {
+			t.Errorf("Expected the other user's notification untouched, got %d unread", n)
+		}
+
+		if err := notifications.MarkRead(reader.ID, nil); err != nil {
+			t.Fatalf("MarkRead should not return error, got: %v", err)
+		}
+		if n := unread(reader); n != 0 {
+			t.Errorf("Expected everything read, got %d unread", n)
+		}
+	})
+
+	t.Run("Mark Read Limit", func(t *testing.T) {
+		ids := make([]int64, notifications.MaxMarkRead+1)
+		for i := range ids {
+			ids[i] = int64(i + 1)
+		}
+		if err := notifications.MarkRead(reader.ID, ids); !errors.Is(err, notifications.ErrTooManyIDs) {
+			t.Errorf("Expected ErrTooManyIDs, got: %v", err)
+		}
+		if err := notifications.MarkRead(reader.ID, ids[:notifications.MaxMarkRead]); err != nil {
+			t.Errorf("Expected a full batch accepted, got: %v", err)
+		}
+
+		body, _ := json.Marshal(map[string][]int64{"ids": ids})
+		r := httptest.NewRequest(http.MethodPost, "/api/notifications/read", strings.NewReader(string(body)))
+		session, err := auth.CreateSession(reader.ID, "agent", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("CreateSession should not return error, got: %v", err)
+		}
+		r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		w := httptest.NewRecorder()
+		handlers.MarkNotificationsReadHandler(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for too many IDs, got %d", w.Code)
+		}
+	})
+
+	t.Run("Push", func(t *testing.T) {
+		defer clear()
+		server := startSocketServer(t, config.Default().WebSocket)
+		socket := dialSocket(t, server, author.ID)
+
+		notifications.NotifyLiked(reader, &postID, nil)
+
+		pushed := socket.expect("notification")
+		stored := list(author, false)
+		if len(stored) != 1 {
+			t.Fatalf("Expected the notification stored, got %d", len(stored))
+		}
+		if pushed["id"] != float64(stored[0].ID) || pushed["type"] != notifications.TypePostLike ||
+			pushed["userId"] != reader.ID || pushed["postId"] != float64(postID) {
+			t.Errorf("Expected the stored like pushed, got %v", pushed)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"forum/internal/auth"
+	"forum/internal/database"
+)
+
+// Test PKCE verifier/challenge generation
+func TestPKCEGeneration(t *testing.T) {
+	t.Run("Challenge Is S256 Of Verifier", func(t *testing.T) {
+		verifier, challenge := auth.GeneratePKCE()
+
+		if len(verifier) < 43 {
+			t.Errorf("Verifier should be at least 43 characters, got %d", len(verifier))
+		}
+
+		sum := sha256.Sum256([]byte(verifier))
+		expected := base64.RawURLEncoding.EncodeToString(sum[:])
+		if challenge != expected {
+			t.Errorf("Expected challenge %s, got %s", expected, challenge)
+		}
+	})
+
+	t.Run("Verifiers Are Unique", func(t *testing.T) {
+		first, _ := auth.GeneratePKCE()
+		second, _ := auth.GeneratePKCE()
+		if first == second {
+			t.Error("PKCE verifiers should be unique")
+		}
+	})
+}
+
+// Test the authorization-code flow against a stub provider
+func TestOAuthProviderFlow(t *testing.T) {
+	var receivedVerifier string
+
+	stub := http.NewServeMux()
+	stub.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" {
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		receivedVerifier = r.Form.Get("code_verifier")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "stub-token",
+			"token_type":   "bearer",
+		})
+	})
+	stub.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer stub-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":         42,
+			"login":      "octo.cat",
+			"name":       "Octo Cat",
+			"avatar_url": "https://example.com/a.png",
+		})
+	})
+	stub.HandleFunc("/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"email": "other@example.com", "primary": false, "verified": true},
+			{"email": "octo@example.com", "primary": true, "verified": true},
+		})
+	})
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider := &auth.OAuthProvider{
+		Name:         "github",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/auth/github/callback",
+		AuthURL:      server.URL + "/authorize",
+		TokenURL:     server.URL + "/token",
+		UserInfoURL:  server.URL + "/user",
+		EmailsURL:    server.URL + "/emails",
+		Scopes:       []string{"read:user", "user:email"},
+	}
+
+	t.Run("Authorization URL", func(t *testing.T) {
+		authURL, err := url.Parse(provider.AuthCodeURL("state123", "challenge456"))
+		if err != nil {
+			t.Fatalf("Authorization URL should parse: %v", err)
+		}
+
+		params := authURL.Query()
+		expected := map[string]string{
+			"response_type":         "code",
+			"client_id":             "client",
+			"state":                 "state123",
+			"code_challenge":        "challenge456",
+			"code_challenge_method": "S256",
+			"scope":                 "read:user user:email",
+		}
+		for key, value := range expected {
+			if params.Get(key) != value {
+				t.Errorf("Expected %s=%s, got %s", key, value, params.Get(key))
+			}
+		}
+	})
+
+	t.Run("Exchange Sends Verifier", func(t *testing.T) {
+		token, err := provider.Exchange("good-code", "my-verifier")
+		if err != nil {
+			t.Fatalf("Exchange should succeed: %v", err)
+		}
+		if token.AccessToken != "stub-token" {
+			t.Errorf("Expected access token 'stub-token', got '%s'", token.AccessToken)
+		}
+		if receivedVerifier != "my-verifier" {
+			t.Errorf("Expected code_verifier to be forwarded, got '%s'", receivedVerifier)
+		}
+	})
+
+	t.Run("Exchange Rejects Provider Errors", func(t *testing.T) {
+		if _, err := provider.Exchange("bad-code", "my-verifier"); err == nil {
+			t.Error("Exchange should fail when the provider returns an error")
+		}
+	})
+
+	t.Run("Fetch Profile Uses Primary Email", func(t *testing.T) {
+		profile, err := provider.FetchProfile("stub-token")
+		if err != nil {
+			t.Fatalf("FetchProfile should succeed: %v", err)
+		}
+
+		if profile.ProviderUserID != "42" {
+			t.Errorf("Expected provider user ID '42', got '%s'", profile.ProviderUserID)
+		}
+		if profile.Email != "octo@example.com" || !profile.EmailVerified {
+			t.Errorf("Expected verified primary email, got '%s' (verified=%v)", profile.Email, profile.EmailVerified)
+		}
+		if profile.FirstName != "Octo" || profile.LastName != "Cat" {
+			t.Errorf("Expected name 'Octo Cat', got '%s %s'", profile.FirstName, profile.LastName)
+		}
+	})
+}
+
+// Test linking provider sign-ins to existing accounts by email
+func TestOAuthAccountLinking(t *testing.T) {
+	openTestDatabase(t)
+	auth.RegisterOAuthProvider(&auth.OAuthProvider{Name: "github", ClientID: "client", ClientSecret: "secret"})
+	provider := auth.GetOAuthProvider("github")
+	token := &auth.OAuthToken{AccessToken: "token"}
+
+	// linkedID returns the github_id stored for a user, or "" when unlinked
+	linkedID := func(userID string) string {
+		var githubID sql.NullString
+		database.DB.QueryRow("SELECT github_id FROM users WHERE id = ?", userID).Scan(&githubID)
+		return githubID.String
+	}
+
+	t.Run("Unverified Account Is Not Linked", func(t *testing.T) {
+		victim := createTestUser(t, "unverifiedowner")
+
+		user, pendingToken, err := auth.CompleteOAuthLogin(provider,
+			&auth.OAuthProfile{ProviderUserID: "1001", Email: victim.Email, EmailVerified: true}, token)
+		if !errors.Is(err, auth.ErrOAuthEmailUnverified) || user != nil || pendingToken != "" {
+			t.Fatalf("Expected ErrOAuthEmailUnverified, got %v, %q, %v", user, pendingToken, err)
+		}
+		if linkedID(victim.ID) != "" {
+			t.Error("Expected the provider account left unlinked")
+		}
+
+		var verified bool
+		database.DB.QueryRow("SELECT email_verified_at IS NOT NULL FROM users WHERE id = ?", victim.ID).Scan(&verified)
+		if verified {
+			t.Error("Expected the provider's verification not copied to the account")
+		}
+	})
+
+	t.Run("Verified Account Is Linked", func(t *testing.T) {
+		owner := createTestUser(t, "verifiedowner")
+		database.DB.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ?", owner.ID)
+
+		user, pendingToken, err := auth.CompleteOAuthLogin(provider,
+			&auth.OAuthProfile{ProviderUserID: "1002", Email: owner.Email, EmailVerified: true}, token)
+		if err != nil || user == nil || user.ID != owner.ID || pendingToken != "" {
+			t.Fatalf("Expected to sign in as %s, got %v, %q, %v", owner.ID, user, pendingToken, err)
+		}
+		if got := linkedID(owner.ID); got != "1002" {
+			t.Errorf("Expected github_id 1002 stored, got %q", got)
+		}
+	})
+
+	t.Run("Unverified Provider Email", func(t *testing.T) {
+		owner := createTestUser(t, "providerunverified")
+		database.DB.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ?", owner.ID)
+
+		if _, _, err := auth.CompleteOAuthLogin(provider,
+			&auth.OAuthProfile{ProviderUserID: "1003", Email: owner.Email}, token); err == nil {
+			t.Error("Expected an error for an email the provider has not verified")
+		}
+		if linkedID(owner.ID) != "" {
+			t.Error("Expected the provider account left unlinked")
+		}
+	})
+
+	t.Run("New Email Becomes Pending Signup", func(t *testing.T) {
+		user, pendingToken, err := auth.CompleteOAuthLogin(provider,
+			&auth.OAuthProfile{ProviderUserID: "1004", Email: "newcomer@example.com", EmailVerified: true, Login: "newcomer"}, token)
+		if err != nil || user != nil || pendingToken == "" {
+			t.Fatalf("Expected a pending signup, got %v, %q, %v", user, pendingToken, err)
+		}
+	})
+}
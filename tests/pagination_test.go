@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"forum/internal/database"
+	"forum/internal/handlers"
+)
+
+// Test paging through the post feed with cursors
+func TestPostsPagination(t *testing.T) {
+	openTestDatabase(t)
+	author := createTestUser(t, "pager")
+
+	// insertPost stores a post created at the given time and returns its ID
+	insertPost := func(createdAt time.Time) int {
+		result, err := database.DB.Exec(`
+			INSERT INTO posts (user_id, title, content, created_at, updated_at) VALUES (?, 'Title', 'Body', ?, ?)
+		`, author.ID, createdAt.UTC(), createdAt.UTC())
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return int(id)
+	}
+
+	// Oldest first; four posts share one timestamp so a page boundary falls
+	// between them
+	base := time.Now().Add(-time.Hour)
+	shared := base.Add(2 * time.Minute)
+	ids := []int{
+		insertPost(base),
+		insertPost(base.Add(time.Minute)),
+		insertPost(shared),
+		insertPost(shared),
+		insertPost(shared),
+		insertPost(shared),
+		insertPost(base.Add(3 * time.Minute)),
+	}
+
+	// The feed lists the newest first, and the newest ID first among equal times
+	var want []int
+	for i := len(ids) - 1; i >= 0; i-- {
+		want = append(want, ids[i])
+	}
+
+	// page requests the feed and returns the post IDs and the next cursor
+	page := func(query url.Values) (int, []int, string) {
+		w := httptest.NewRecorder()
+		handlers.PostsHandler(w, httptest.NewRequest(http.MethodGet, "/api/posts?"+query.Encode(), nil))
+
+		var response struct {
+			Data       []struct{ ID int }
+			NextCursor string
+		}
+		json.NewDecoder(w.Body).Decode(&response)
+
+		var got []int
+		for _, post := range response.Data {
+			got = append(got, post.ID)
+		}
+		return w.Code, got, response.NextCursor
+	}
+
+	t.Run("Cursor Round Trip", func(t *testing.T) {
+		var got []int
+		query := url.Values{"limit": {"3"}}
+		pages := 0
+		for {
+			code, ids, next := page(query)
+			if code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", code)
+			}
+			got = append(got, ids...)
+			pages++
+			if next == "" {
+				break
+			}
+			if pages > len(want) {
+				t.Fatal("Pagination did not terminate")
+			}
+			query.Set("cursor", next)
+		}
+
+		if pages != 3 {
+			t.Errorf("Expected 3 pages of up to 3 posts, got %d", pages)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("Expected every post exactly once, got %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Expected posts in order %v, got %v", want, got)
+			}
+		}
+	})
+
+	t.Run("Exact Last Page", func(t *testing.T) {
+		_, ids, next := page(url.Values{"limit": {"7"}})
+		if len(ids) != 7 || next != "" {
+			t.Errorf("Expected all 7 posts and no cursor, got %d posts and cursor %q", len(ids), next)
+		}
+	})
+
+	t.Run("Invalid Parameters", func(t *testing.T) {
+		encode := func(payload string) string {
+			return base64.RawURLEncoding.EncodeToString([]byte(payload))
+		}
+		cases := map[string]url.Values{
+			"Zero Limit":        {"limit": {"0"}},
+			"Negative Limit":    {"limit": {"-5"}},
+			"Non-numeric Limit": {"limit": {"ten"}},
+			"Not Base64":        {"cursor": {"!!!"}},
+			"Not JSON":          {"cursor": {encode("not json")}},
+			"Missing Timestamp": {"cursor": {encode(`{"id": 3}`)}},
+			"Missing ID":        {"cursor": {encode(`{"t": "2026-01-01T00:00:00Z"}`)}},
+			"Non-positive ID":   {"cursor": {encode(`{"t": "2026-01-01T00:00:00Z", "id": -1}`)}},
+			"Bad Timestamp":     {"cursor": {encode(`{"t": "2026-01-01 00:00:00", "id": 3}`)}},
+		}
+		for name, query := range cases {
+			if code, _, _ := page(query); code != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", name, code)
+			}
+		}
+	})
+
+	t.Run("Limit Is Clamped", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			insertPost(base.Add(-time.Duration(i+1) * time.Second))
+		}
+
+		_, ids, next := page(url.Values{"limit": {"1000"}})
+		if len(ids) != 100 || next == "" {
+			t.Errorf("Expected a page of 100 posts with a cursor, got %d posts and cursor %q", len(ids), next)
+		}
+
+		_, ids, _ = page(url.Values{})
+		if len(ids) != 20 {
+			t.Errorf("Expected the default page of 20 posts, got %d", len(ids))
+		}
+	})
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/audit"
+	"forum/internal/auth"
+	"forum/internal/config"
+	"forum/internal/database"
+	"forum/internal/handlers"
+	"forum/internal/models"
+)
+
+// Test editing and deleting posts through the post endpoint
+func TestPostEditing(t *testing.T) {
+	openTestDatabase(t)
+	author := createTestUser(t, "postauthor")
+	stranger := createTestUser(t, "poststranger")
+	moderator := createTestUser(t, "postmoderator")
+	if err := auth.SetUserRole(moderator.ID, auth.RoleModerator); err != nil {
+		t.Fatalf("SetUserRole should not return error, got: %v", err)
+	}
+
+	server := startSocketServer(t, config.Default().WebSocket)
+	watcher := dialSocket(t, server, stranger.ID)
+
+	// insertPost stores a post by author in the given categories
+	insertPost := func(categories ...string) int {
+		written := time.Now().Add(-time.Hour)
+		result, err := database.DB.Exec(`
+			INSERT INTO posts (user_id, title, content, created_at, updated_at) VALUES (?, 'Original title', 'Original body', ?, ?)
+		`, author.ID, written, written)
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		for _, category := range categories {
+			database.DB.Exec("INSERT INTO post_categories (post_id, category) VALUES (?, ?)", id, category)
+		}
+		return int(id)
+	}
+
+	// request calls the post endpoint, as user when set, and returns the
+	// status and the post in the response
+	request := func(method string, postID int, body string, user *models.User) (int, models.Post) {
+		r := httptest.NewRequest(method, fmt.Sprintf("/api/posts/%d", postID), strings.NewReader(body))
+		if user != nil {
+			session, err := auth.CreateSession(user.ID, "agent", "127.0.0.1")
+			if err != nil {
+				t.Fatalf("CreateSession should not return error, got: %v", err)
+			}
+			r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		}
+		w := httptest.NewRecorder()
+		handlers.PostHandler(w, r)
+
+		var response struct{ Data models.Post }
+		json.NewDecoder(w.Body).Decode(&response)
+		return w.Code, response.Data
+	}
+
+	// categories returns the stored categories of a post
+	categories := func(postID int) []string {
+		rows, err := database.DB.Query("SELECT category FROM post_categories WHERE post_id = ? ORDER BY category", postID)
+		if err != nil {
+			t.Fatalf("Failed to query categories: %v", err)
+		}
+		defer rows.Close()
+		var names []string
+		for rows.Next() {
+			var name string
+			rows.Scan(&name)
+			names = append(names, name)
+		}
+		return names
+	}
+
+	// count returns the number of rows in table matching where
+	count := func(table, where string, args ...interface{}) int {
+		var n int
+		database.DB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE "+where, args...).Scan(&n)
+		return n
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		postID := insertPost("general", "help")
+		var before time.Time
+		database.DB.QueryRow("SELECT updated_at FROM posts WHERE id = ?", postID).Scan(&before)
+
+		code, post := request(http.MethodPut, postID, `{"title": " New title ", "content": "New body", "categories": ["go", "", "news"]}`, author)
+		if code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+		if post.Title != "New title" || post.Content != "New body" {
+			t.Errorf("Expected the trimmed new title and body, got %q and %q", post.Title, post.Content)
+		}
+		if got := categories(postID); strings.Join(got, ",") != "go,news" {
+			t.Errorf("Expected the categories rewritten to go and news, got %v", got)
+		}
+
+		var after time.Time
+		database.DB.QueryRow("SELECT updated_at FROM posts WHERE id = ?", postID).Scan(&after)
+		if !after.After(before) {
+			t.Errorf("Expected updated_at bumped past %v, got %v", before, after)
+		}
+
+		updated := watcher.expect("post_updated")
+		if updated["id"] != float64(postID) || updated["title"] != "New title" {
+			t.Errorf("Expected the edited post broadcast, got %v", updated)
+		}
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		postID := insertPost("general")
+
+		code, post := request(http.MethodPatch, postID, `{"title": "Only the title"}`, author)
+		if code != http.StatusOK || post.Title != "Only the title" || post.Content != "Original body" {
+			t.Errorf("Expected only the title changed, got %d %q %q", code, post.Title, post.Content)
+		}
+		if got := categories(postID); len(got) != 1 || got[0] != "general" {
+			t.Errorf("Expected categories kept when not sent, got %v", got)
+		}
+
+		if code, _ := request(http.MethodPatch, postID, `{"categories": []}`, author); code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+		if got := categories(postID); len(got) != 0 {
+			t.Errorf("Expected an empty list to clear the categories, got %v", got)
+		}
+	})
+
+	t.Run("Invalid Edits", func(t *testing.T) {
+		postID := insertPost()
+
+		cases := map[string]struct {
+			method, body string
+		}{
+			"Put Without Content": {http.MethodPut, `{"title": "Title only"}`},
+			"Blank Title":         {http.MethodPatch, `{"title": "   "}`},
+			"Blank Content":       {http.MethodPut, `{"title": "Title", "content": ""}`},
+			"Not JSON":            {http.MethodPatch, `title=x`},
+		}
+		for name, tc := range cases {
+			if code, _ := request(tc.method, postID, tc.body, author); code != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", name, code)
+			}
+		}
+
+		var title string
+		database.DB.QueryRow("SELECT title FROM posts WHERE id = ?", postID).Scan(&title)
+		if title != "Original title" {
+			t.Errorf("Expected the post unchanged, got title %q", title)
+		}
+	})
+
+	t.Run("Only The Author", func(t *testing.T) {
+		postID := insertPost("general")
+
+		for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			body := `{"title": "Hijacked", "content": "Hijacked", "categories": []}`
+			if code, _ := request(method, postID, body, stranger); code != http.StatusForbidden {
+				t.Errorf("%s by another user: expected 403, got %d", method, code)
+			}
+			if code, _ := request(method, postID, body, nil); code != http.StatusUnauthorized {
+				t.Errorf("%s without a session: expected 401, got %d", method, code)
+			}
+			if code, _ := request(method, 999999, body, author); code != http.StatusNotFound {
+				t.Errorf("%s of a missing post: expected 404, got %d", method, code)
+			}
+		}
+
+		if count("posts", "id = ? AND title = 'Original title'", postID) != 1 || len(categories(postID)) != 1 {
+			t.Error("Expected the post and its categories untouched")
+		}
+	})
+
+	t.Run("Moderators", func(t *testing.T) {
+		postID := insertPost()
+
+		if code, _ := request(http.MethodPatch, postID, `{"content": "Edited by a moderator"}`, moderator); code != http.StatusOK {
+			t.Fatalf("Expected moderators to edit any post, got %d", code)
+		}
+		events, err := audit.List(audit.Filter{Action: audit.ActionPostEdited, TargetID: fmt.Sprint(postID)}, 10, 0)
+		if err != nil || len(events) != 1 {
+			t.Errorf("Expected the moderator's edit audited, got %d events, %v", len(events), err)
+		}
+
+		if code, _ := request(http.MethodDelete, postID, "", moderator); code != http.StatusOK {
+			t.Errorf("Expected moderators to delete any post, got %d", code)
+		}
+	})
+
+	t.Run("Delete Cascades", func(t *testing.T) {
+		postID := insertPost("general", "help")
+		keptID := insertPost("general")
+
+		for _, id := range []int{postID, keptID} {
+			result, err := database.DB.Exec(`
+				INSERT INTO comments (post_id, user_id, content, created_at, updated_at) VALUES (?, ?, 'A comment', ?, ?)
+			`, id, stranger.ID, time.Now(), time.Now())
+			if err != nil {
+				t.Fatalf("Failed to create comment: %v", err)
+			}
+			commentID, _ := result.LastInsertId()
+			database.DB.Exec("INSERT INTO likes (user_id, post_id, is_like, created_at) VALUES (?, ?, 1, ?)", stranger.ID, id, time.Now())
+			database.DB.Exec("INSERT INTO likes (user_id, comment_id, is_like, created_at) VALUES (?, ?, 1, ?)", author.ID, commentID, time.Now())
+			database.DB.Exec(`
+				INSERT INTO notifications (user_id, actor_id, type, message, post_id, is_read, created_at)
+				VALUES (?, ?, 'post_comment', 'commented', ?, 0, ?)
+			`, author.ID, stranger.ID, id, time.Now())
+		}
+		if n := count("likes", "comment_id IS NOT NULL"); n != 2 {
+			t.Fatalf("Expected 2 comment likes before deleting, got %d", n)
+		}
+
+		if code, _ := request(http.MethodDelete, postID, "", author); code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+
+		remaining := map[string]int{
+			"posts":           count("posts", "id = ?", postID),
+			"comments":        count("comments", "post_id = ?", postID),
+			"post likes":      count("likes", "post_id = ?", postID),
+			"comment likes":   count("likes", "comment_id IS NOT NULL AND comment_id NOT IN (SELECT id FROM comments)"),
+			"post_categories": count("post_categories", "post_id = ?", postID),
+			"notifications":   count("notifications", "post_id = ?", postID),
+		}
+		for table, n := range remaining {
+			if n != 0 {
+				t.Errorf("Expected no %s left for the deleted post, got %d", table, n)
+			}
+		}
+
+		kept := count("posts", "id = ?", keptID) + count("comments", "post_id = ?", keptID) +
+			count("likes", "post_id = ?", keptID) + count("post_categories", "post_id = ?", keptID) +
+			count("notifications", "post_id = ?", keptID)
+		if kept != 5 {
+			t.Errorf("Expected the other post and its rows untouched, got %d rows", kept)
+		}
+
+		for {
+			frame, err := watcher.next(2 * time.Second)
+			if err != nil {
+				t.Fatalf("Expected a post_deleted frame, got: %v", err)
+			}
+			if frame.Type == "post_deleted" && frame.Data["postId"] == float64(postID) {
+				break
+			}
+		}
+
+		if code, _ := request(http.MethodGet, postID, "", author); code != http.StatusNotFound {
+			t.Errorf("Expected the deleted post gone, got %d", code)
+		}
+	})
+
+	t.Run("Comment Delete Removes Replies", func(t *testing.T) {
+		// Pooled connections may not enforce foreign keys, so the delete
+		// must not rely on ON DELETE CASCADE
+		database.DB.SetMaxOpenConns(1)
+		database.DB.Exec("PRAGMA foreign_keys = OFF")
+		defer func() {
+			database.DB.Exec("PRAGMA foreign_keys = ON")
+			database.DB.SetMaxOpenConns(0)
+		}()
+
+		postID := insertPost()
+
+		// insertComment stores a comment by stranger, liked by author and
+		// notified to author
+		insertComment := func(parentID interface{}) int64 {
+			result, err := database.DB.Exec(`
+				INSERT INTO comments (post_id, user_id, parent_id, content, created_at, updated_at) VALUES (?, ?, ?, 'A reply', ?, ?)
+			`, postID, stranger.ID, parentID, time.Now(), time.Now())
+			if err != nil {
+				t.Fatalf("Failed to create comment: %v", err)
+			}
+			id, _ := result.LastInsertId()
+			database.DB.Exec("INSERT INTO likes (user_id, comment_id, is_like, created_at) VALUES (?, ?, 1, ?)", author.ID, id, time.Now())
+			database.DB.Exec(`
+				INSERT INTO notifications (user_id, actor_id, type, message, post_id, comment_id, is_read, created_at)
+				VALUES (?, ?, 'post_comment', 'commented', ?, ?, 0, ?)
+			`, author.ID, stranger.ID, postID, id, time.Now())
+			return id
+		}
+		top := insertComment(nil)
+		reply := insertComment(top)
+		nested := insertComment(reply)
+		sibling := insertComment(nil)
+
+		r := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/comment/%d", top), nil)
+		session, _ := auth.CreateSession(stranger.ID, "agent", "127.0.0.1")
+		r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		w := httptest.NewRecorder()
+		handlers.CommentHandler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", w.Code)
+		}
+
+		for _, id := range []int64{top, reply, nested} {
+			if n := count("comments", "id = ?", id) + count("likes", "comment_id = ?", id) + count("notifications", "comment_id = ?", id); n != 0 {
+				t.Errorf("Expected comment %d and its likes and notifications deleted, got %d rows", id, n)
+			}
+		}
+		if n := count("comments", "id = ?", sibling) + count("likes", "comment_id = ?", sibling) + count("notifications", "comment_id = ?", sibling); n != 3 {
+			t.Errorf("Expected the other comment and its rows untouched, got %d rows", n)
+		}
+	})
+}
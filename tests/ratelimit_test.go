@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/handlers"
+	"forum/internal/models"
+	"forum/internal/ratelimit"
+)
+
+// Test the in-memory token bucket limiter
+func TestTokenBucket(t *testing.T) {
+	t.Run("Allows Burst Then Denies", func(t *testing.T) {
+		limiter := ratelimit.NewTokenBucket(1, time.Minute, 3)
+
+		for i := 0; i < 3; i++ {
+			if allowed, _ := limiter.Allow("user:a"); !allowed {
+				t.Fatalf("Request %d should be allowed within the burst", i+1)
+			}
+		}
+
+		allowed, retryAfter := limiter.Allow("user:a")
+		if allowed {
+			t.Fatal("Request past the burst should be denied")
+		}
+		if retryAfter <= 0 || retryAfter > time.Minute {
+			t.Errorf("Expected retry after within a minute, got %s", retryAfter)
+		}
+	})
+
+	t.Run("Keys Are Independent", func(t *testing.T) {
+		limiter := ratelimit.NewTokenBucket(1, time.Minute, 1)
+
+		limiter.Allow("ip:10.0.0.1")
+		if allowed, _ := limiter.Allow("ip:10.0.0.2"); !allowed {
+			t.Error("A different key should have its own bucket")
+		}
+	})
+
+	t.Run("Refills Over Time", func(t *testing.T) {
+		limiter := ratelimit.NewTokenBucket(100, time.Second, 1)
+
+		limiter.Allow("user:b")
+		if allowed, _ := limiter.Allow("user:b"); allowed {
+			t.Fatal("Second request should be denied before the bucket refills")
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		if allowed, _ := limiter.Allow("user:b"); !allowed {
+			t.Error("Request should be allowed after the bucket refills")
+		}
+	})
+
+	t.Run("Burst Defaults To Requests", func(t *testing.T) {
+		limiter := ratelimit.NewTokenBucket(2, time.Minute, 0)
+
+		limiter.Allow("user:c")
+		limiter.Allow("user:c")
+		if allowed, _ := limiter.Allow("user:c"); allowed {
+			t.Error("Burst should default to the number of requests")
+		}
+	})
+}
+
+// Test rounding of Retry-After values
+func TestRetryAfterSeconds(t *testing.T) {
+	cases := map[time.Duration]int{
+		0:                       1,
+		300 * time.Millisecond:  1,
+		time.Second:             1,
+		1500 * time.Millisecond: 2,
+		time.Minute:             60,
+	}
+
+	for wait, expected := range cases {
+		if got := ratelimit.RetryAfterSeconds(wait); got != expected {
+			t.Errorf("RetryAfterSeconds(%s) = %d, expected %d", wait, got, expected)
+		}
+	}
+}
+
+// Test that requests count against both their client IP and their user
+func TestRateLimitKeys(t *testing.T) {
+	openTestDatabase(t)
+	alice := createTestUser(t, "limitalice")
+	bob := createTestUser(t, "limitbob")
+
+	ratelimit.SetLimiter(ratelimit.Post, ratelimit.NewTokenBucket(2, time.Hour, 2))
+	t.Cleanup(func() { ratelimit.SetLimiter(ratelimit.Post, nil) })
+
+	limited := handlers.RateLimit(ratelimit.Post, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// request posts from ip, as user when set, and returns the status
+	request := func(ip string, user *models.User) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+		r.RemoteAddr = ip + ":1234"
+		if user != nil {
+			session, err := auth.CreateSession(user.ID, "agent", ip)
+			if err != nil {
+				t.Fatalf("CreateSession should not return error, got: %v", err)
+			}
+			r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		}
+		w := httptest.NewRecorder()
+		limited(w, r)
+		return w.Code
+	}
+
+	t.Run("Accounts Share Their IP's Limit", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if code := request("198.51.100.1", alice); code != http.StatusOK {
+				t.Fatalf("Request %d: expected 200, got %d", i+1, code)
+			}
+		}
+		if code := request("198.51.100.1", bob); code != http.StatusTooManyRequests {
+			t.Errorf("Expected another account on the same IP limited, got %d", code)
+		}
+		if code := request("198.51.100.1", nil); code != http.StatusTooManyRequests {
+			t.Errorf("Expected anonymous requests from the IP limited, got %d", code)
+		}
+	})
+
+	t.Run("Users Keep Their Limit Across IPs", func(t *testing.T) {
+		if code := request("198.51.100.2", alice); code != http.StatusTooManyRequests {
+			t.Errorf("Expected the user limited from a new IP, got %d", code)
+		}
+		if code := request("198.51.100.3", bob); code != http.StatusOK {
+			t.Errorf("Expected another user on a fresh IP allowed, got %d", code)
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/database"
+	"forum/internal/handlers"
+	"forum/internal/messaging"
+	"forum/internal/models"
+)
+
+// Test delivery and read receipts and read positions
+func TestReadReceipts(t *testing.T) {
+	openTestDatabase(t)
+	alice := createTestUser(t, "receiptalice")
+	bob := createTestUser(t, "receiptbob")
+	carol := createTestUser(t, "receiptcarol")
+
+	// send stores a message and backdates it so messages sort predictably
+	send := func(sender, receiver *models.User, content string, age time.Duration) *models.Message {
+		message, err := messaging.Send(sender.ID, receiver.ID, content)
+		if err != nil {
+			t.Fatalf("Send should not return error, got: %v", err)
+		}
+		database.DB.Exec("UPDATE messages SET created_at = ? WHERE id = ?", time.Now().Add(-age), message.ID)
+		return message
+	}
+
+	// unread returns userID's unread count in a conversation
+	unread := func(userID, conversationID string) int {
+		conversations, err := messaging.GetUserConversations(userID)
+		if err != nil {
+			t.Fatalf("GetUserConversations should not return error, got: %v", err)
+		}
+		for _, conversation := range conversations {
+			if conversation.ID == conversationID {
+				return conversation.UnreadCount
+			}
+		}
+		t.Fatalf("Conversation %s not listed for %s", conversationID, userID)
+		return 0
+	}
+
+	t.Run("Delivered Then Read", func(t *testing.T) {
+		first := send(alice, bob, "one", 2*time.Minute)
+		second := send(alice, bob, "two", time.Minute)
+		ids := []string{first.ID, second.ID}
+
+		delivered, err := messaging.MarkDelivered(bob.ID, ids)
+		if err != nil {
+			t.Fatalf("MarkDelivered should not return error, got: %v", err)
+		}
+		if len(delivered) != 1 || delivered[0].SenderID != alice.ID || delivered[0].ReceiverID != bob.ID ||
+			len(delivered[0].MessageIDs) != 2 || delivered[0].MessageIDs[0] != first.ID || delivered[0].DeliveredAt == nil {
+			t.Fatalf("Expected one receipt to %s for both messages in order, got %+v", alice.ID, delivered)
+		}
+		if again, _ := messaging.MarkDelivered(bob.ID, ids); again != nil {
+			t.Errorf("Expected no receipts for messages already delivered, got %+v", again)
+		}
+		if message, _ := messaging.GetMessage(first.ID); message.Status != models.MessageStatusDelivered {
+			t.Errorf("Expected status %q, got %q", models.MessageStatusDelivered, message.Status)
+		}
+
+		read, err := messaging.MarkRead(bob.ID, ids)
+		if err != nil {
+			t.Fatalf("MarkRead should not return error, got: %v", err)
+		}
+		if len(read) != 1 || read[0].ReaderID != bob.ID || len(read[0].MessageIDs) != 2 || read[0].ReadAt == nil {
+			t.Fatalf("Expected one read receipt for both messages, got %+v", read)
+		}
+		if again, _ := messaging.MarkRead(bob.ID, ids); again != nil {
+			t.Errorf("Expected no receipts for messages already read, got %+v", again)
+		}
+
+		message, _ := messaging.GetMessage(second.ID)
+		if message.Status != models.MessageStatusRead || message.DeliveredAt == nil || message.ReadAt == nil {
+			t.Errorf("Expected a read message keeping its delivery time, got %+v", message)
+		}
+		if n := unread(bob.ID, first.ConversationID); n != 0 {
+			t.Errorf("Expected nothing unread, got %d", n)
+		}
+	})
+
+	t.Run("Only The Receiver", func(t *testing.T) {
+		message := send(alice, bob, "for bob", time.Second)
+
+		for _, user := range []*models.User{alice, carol} {
+			receipts, err := messaging.MarkRead(user.ID, []string{message.ID})
+			if err != nil || receipts != nil {
+				t.Errorf("Expected %s unable to read bob's message, got %+v, %v", user.Nickname, receipts, err)
+			}
+		}
+		if stored, _ := messaging.GetMessage(message.ID); stored.Status != models.MessageStatusSent {
+			t.Errorf("Expected the message still unread, got %q", stored.Status)
+		}
+	})
+
+	t.Run("Read Position Only Moves Forward", func(t *testing.T) {
+		older := send(carol, bob, "older", 2*time.Hour)
+		newer := send(carol, bob, "newer", time.Hour)
+		send(carol, bob, "newest", time.Minute)
+
+		messaging.MarkRead(bob.ID, []string{newer.ID})
+		if n := unread(bob.ID, newer.ConversationID); n != 1 {
+			t.Errorf("Expected only the newest message unread, got %d", n)
+		}
+
+		// Reading an older message later leaves the position alone
+		messaging.MarkRead(bob.ID, []string{older.ID})
+		if n := unread(bob.ID, newer.ConversationID); n != 1 {
+			t.Errorf("Expected the read position kept, got %d unread", n)
+		}
+	})
+
+	t.Run("Group Messages", func(t *testing.T) {
+		group, err := messaging.CreateGroup(alice.ID, "Receipts", []string{bob.ID, carol.ID})
+		if err != nil {
+			t.Fatalf("CreateGroup should not return error, got: %v", err)
+		}
+		var ids []string
+		for _, content := range []string{"hello group", "anyone?"} {
+			message, err := messaging.SendToConversation(alice.ID, group.ID, content)
+			if err != nil {
+				t.Fatalf("SendToConversation should not return error, got: %v", err)
+			}
+			ids = append(ids, message.ID)
+		}
+		if n := unread(bob.ID, group.ID); n != 2 {
+			t.Fatalf("Expected 2 unread group messages, got %d", n)
+		}
+
+		receipts, err := messaging.MarkRead(bob.ID, ids)
+		if err != nil {
+			t.Fatalf("MarkRead should not return error, got: %v", err)
+		}
+		if receipts != nil {
+			t.Errorf("Expected no receipts for group messages, got %+v", receipts)
+		}
+		if n := unread(bob.ID, group.ID); n != 0 {
+			t.Errorf("Expected reading to move bob's read position, got %d unread", n)
+		}
+		if n := unread(carol.ID, group.ID); n != 2 {
+			t.Errorf("Expected other members unaffected, got %d unread", n)
+		}
+		if message, _ := messaging.GetMessage(ids[0]); message.Status != models.MessageStatusSent {
+			t.Errorf("Expected group messages to stay %q, got %q", models.MessageStatusSent, message.Status)
+		}
+	})
+
+	t.Run("Long Backlog", func(t *testing.T) {
+		// More unread messages than SQLite allows bound variables, so
+		// marking them must not list their IDs in the query
+		dave := createTestUser(t, "receiptdave")
+		first := send(dave, bob, "first of many", time.Hour)
+		const backlog = 40000
+		_, err := database.DB.Exec(`
+			WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+			INSERT INTO messages (id, conversation_id, sender_id, receiver_id, content, is_read, created_at, updated_at)
+			SELECT 'backlog-' || i, ?, ?, ?, 'again', 0, ?, ? FROM n
+		`, backlog, first.ConversationID, dave.ID, bob.ID, time.Now(), time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create backlog: %v", err)
+		}
+
+		receipts, err := messaging.MarkAsRead(bob.ID, dave.ID)
+		if err != nil {
+			t.Fatalf("MarkAsRead should not return error, got: %v", err)
+		}
+		if len(receipts) != 1 || len(receipts[0].MessageIDs) != backlog+1 || receipts[0].MessageIDs[0] != first.ID {
+			t.Errorf("Expected one receipt for all %d messages, oldest first, got %d receipts", backlog+1, len(receipts))
+		}
+
+		var unreadMessages int
+		database.DB.QueryRow("SELECT COUNT(*) FROM messages WHERE sender_id = ? AND read_at IS NULL", dave.ID).Scan(&unreadMessages)
+		if unreadMessages != 0 {
+			t.Errorf("Expected every message marked read, got %d unread", unreadMessages)
+		}
+	})
+
+	t.Run("Batch Limit", func(t *testing.T) {
+		ids := make([]string, messaging.MaxReceiptBatch+1)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("message-%d", i)
+		}
+
+		if _, err := messaging.MarkRead(bob.ID, ids); !errors.Is(err, messaging.ErrTooManyMessages) {
+			t.Errorf("Expected ErrTooManyMessages from MarkRead, got: %v", err)
+		}
+		if _, err := messaging.MarkDelivered(bob.ID, ids); !errors.Is(err, messaging.ErrTooManyMessages) {
+			t.Errorf("Expected ErrTooManyMessages from MarkDelivered, got: %v", err)
+		}
+		if _, err := messaging.MarkRead(bob.ID, ids[:messaging.MaxReceiptBatch]); err != nil {
+			t.Errorf("Expected a full batch accepted, got: %v", err)
+		}
+
+		body, _ := json.Marshal(map[string][]string{"messageIds": ids})
+		r := httptest.NewRequest(http.MethodPost, "/api/messages/read", strings.NewReader(string(body)))
+		session, err := auth.CreateSession(bob.ID, "agent", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("CreateSession should not return error, got: %v", err)
+		}
+		r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		w := httptest.NewRecorder()
+		handlers.MarkMessageReadHandler(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for too many IDs, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/database"
+	"forum/internal/mail"
+)
+
+// recordingMailer keeps sent emails instead of delivering them
+type recordingMailer struct {
+	mutex    sync.Mutex
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(msg mail.Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// last returns the most recent email, failing the test when none was sent
+func (m *recordingMailer) last(t *testing.T) mail.Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.messages) == 0 {
+		t.Fatal("Expected an email to be sent")
+	}
+	return m.messages[len(m.messages)-1]
+}
+
+func (m *recordingMailer) count() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.messages)
+}
+
+var emailTokenPattern = regexp.MustCompile(`token=(\S+)`)
+
+// emailToken extracts the account token from the link in an email
+func emailToken(t *testing.T, msg mail.Message) string {
+	match := emailTokenPattern.FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("Email has no token link: %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("Token link is not escaped properly: %v", err)
+	}
+	return token
+}
+
+// Test password reset and email verification tokens
+func TestAccountRecovery(t *testing.T) {
+	openTestDatabase(t)
+	user := createTestUser(t, "forgetful")
+
+	mailer := &recordingMailer{}
+	mail.SetMailer(mailer)
+	defer mail.SetMailer(&mail.LogMailer{})
+
+	t.Run("Unknown Email", func(t *testing.T) {
+		if err := auth.RequestPasswordReset("nobody@example.com"); err != nil {
+			t.Fatalf("RequestPasswordReset should not return error, got: %v", err)
+		}
+		if mailer.count() != 0 {
+			t.Error("No email should be sent for an unknown address")
+		}
+	})
+
+	t.Run("Reset Password", func(t *testing.T) {
+		session, _ := auth.CreateSession(user.ID, "Firefox on Linux", "10.0.0.1")
+
+		if err := auth.RequestPasswordReset(user.Email); err != nil {
+			t.Fatalf("RequestPasswordReset should not return error, got: %v", err)
+		}
+		msg := mailer.last(t)
+		if msg.To != user.Email {
+			t.Errorf("Expected reset email to %s, got %s", user.Email, msg.To)
+		}
+		token := emailToken(t, msg)
+
+		if _, _, err := auth.ResetPassword(token, "short"); !errors.Is(err, auth.ErrPasswordTooShort) {
+			t.Errorf("Expected ErrPasswordTooShort, got: %v", err)
+		}
+
+		userID, revoked, err := auth.ResetPassword(token, "new-password")
+		if err != nil {
+			t.Fatalf("ResetPassword should not return error, got: %v", err)
+		}
+		if userID != user.ID {
+			t.Errorf("Expected user %s, got %s", user.ID, userID)
+		}
+		if len(revoked) != 1 || revoked[0] != session.PublicID {
+			t.Errorf("Expected the user's session to be ended, got %v", revoked)
+		}
+		if s, _ := auth.GetSessionByID(session.ID); s != nil {
+			t.Error("Sessions should not survive a password reset")
+		}
+
+		if _, err := auth.AuthenticateUser(user.Nickname, "new-password"); err != nil {
+			t.Errorf("New password should work, got: %v", err)
+		}
+		if _, err := auth.AuthenticateUser(user.Nickname, "password123"); err == nil {
+			t.Error("Old password should no longer work")
+		}
+
+		if _, _, err := auth.ResetPassword(token, "another-password"); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("A reset token should only work once, got: %v", err)
+		}
+	})
+
+	t.Run("Newer Token Replaces Older", func(t *testing.T) {
+		auth.RequestPasswordReset(user.Email)
+		first := emailToken(t, mailer.last(t))
+		auth.RequestPasswordReset(user.Email)
+		second := emailToken(t, mailer.last(t))
+
+		if _, _, err := auth.ResetPassword(first, "new-password"); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("An older reset token should stop working, got: %v", err)
+		}
+		if _, _, err := auth.ResetPassword(second, "new-password"); err != nil {
+			t.Errorf("The newest reset token should work, got: %v", err)
+		}
+	})
+
+	t.Run("Verify Email", func(t *testing.T) {
+		fresh := createTestUser(t, "newcomer")
+		if fresh.EmailVerified {
+			t.Fatal("New users should start unverified")
+		}
+
+		if err := auth.SendEmailVerification(fresh); err != nil {
+			t.Fatalf("SendEmailVerification should not return error, got: %v", err)
+		}
+		token := emailToken(t, mailer.last(t))
+
+		if _, _, err := auth.ResetPassword(token, "new-password"); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("A verification token should not reset passwords, got: %v", err)
+		}
+		if _, err := auth.VerifyEmail(token); err != nil {
+			t.Fatalf("VerifyEmail should not return error, got: %v", err)
+		}
+
+		verified, _ := auth.GetUserByID(fresh.ID)
+		if !verified.EmailVerified {
+			t.Error("Email should be verified")
+		}
+	})
+
+	t.Run("Expired Token", func(t *testing.T) {
+		fresh := createTestUser(t, "latecomer")
+		auth.SendEmailVerification(fresh)
+		token := emailToken(t, mailer.last(t))
+
+		database.DB.Exec("UPDATE user_tokens SET expires_at = ?", time.Now().Add(-time.Minute))
+		if _, err := auth.VerifyEmail(token); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("Expired tokens should be rejected, got: %v", err)
+		}
+
+		removed, err := auth.CleanupUserTokens()
+		if err != nil {
+			t.Fatalf("CleanupUserTokens should not return error, got: %v", err)
+		}
+		if removed == 0 {
+			t.Error("Expected used and expired tokens to be removed")
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forum/internal/auth"
+	"forum/internal/handlers"
+)
+
+// Test the permissions granted by each role and the middleware enforcing them
+func TestRoles(t *testing.T) {
+	openTestDatabase(t)
+	author := createTestUser(t, "author")
+	moderator := createTestUser(t, "moderator")
+	admin := createTestUser(t, "admin")
+
+	if author.Role != auth.RoleUser {
+		t.Errorf("New users should have the user role, got %q", author.Role)
+	}
+
+	t.Run("Set Role", func(t *testing.T) {
+		if err := auth.SetUserRole(moderator.ID, "owner"); !errors.Is(err, auth.ErrInvalidRole) {
+			t.Errorf("Expected ErrInvalidRole, got: %v", err)
+		}
+		if err := auth.SetUserRole("no-such-user", auth.RoleAdmin); !errors.Is(err, auth.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got: %v", err)
+		}
+		if err := auth.SetUserRole(moderator.ID, auth.RoleModerator); err != nil {
+			t.Fatalf("SetUserRole should not return error, got: %v", err)
+		}
+		if err := auth.SetUserRole(admin.ID, auth.RoleAdmin); err != nil {
+			t.Fatalf("SetUserRole should not return error, got: %v", err)
+		}
+
+		moderator, _ = auth.GetUserByID(moderator.ID)
+		admin, _ = auth.GetUserByID(admin.ID)
+		if moderator.Role != auth.RoleModerator || admin.Role != auth.RoleAdmin {
+			t.Errorf("Expected moderator and admin roles, got %q and %q", moderator.Role, admin.Role)
+		}
+	})
+
+	t.Run("Permissions", func(t *testing.T) {
+		cases := []struct {
+			name       string
+			user       string
+			permission auth.Permission
+			want       bool
+		}{
+			{"User Deletes Any Post", author.ID, auth.PermPostDeleteAny, false},
+			{"Moderator Deletes Any Post", moderator.ID, auth.PermPostDeleteAny, true},
+			{"Moderator Edits Any Comment", moderator.ID, auth.PermCommentEditAny, true},
+			{"Moderator Assigns Roles", moderator.ID, auth.PermUserRoleAssign, false},
+			{"Moderator Suspends Users", moderator.ID, auth.PermUserSuspend, true},
+			{"Moderator Bans Users", moderator.ID, auth.PermUserBan, false},
+			{"Admin Bans Users", admin.ID, auth.PermUserBan, true},
+			{"Admin Deletes Any Comment", admin.ID, auth.PermCommentDeleteAny, true},
+			{"Admin Assigns Roles", admin.ID, auth.PermUserRoleAssign, true},
+			{"Moderator Views Audit Log", moderator.ID, auth.PermAuditView, false},
+			{"Admin Views Audit Log", admin.ID, auth.PermAuditView, true},
+		}
+		for _, tc := range cases {
+			user, _ := auth.GetUserByID(tc.user)
+			if got := auth.HasPermission(user, tc.permission); got != tc.want {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+			}
+		}
+
+		if auth.HasPermission(nil, auth.PermPostEditAny) {
+			t.Error("Anonymous users should have no permissions")
+		}
+	})
+
+	t.Run("Modify Content", func(t *testing.T) {
+		other := createTestUser(t, "other")
+		if !auth.CanModify(author, author.ID, auth.PermPostEditAny) {
+			t.Error("Users should be able to edit their own posts")
+		}
+		if auth.CanModify(other, author.ID, auth.PermPostEditAny) {
+			t.Error("Users should not be able to edit other users' posts")
+		}
+		if !auth.CanModify(moderator, author.ID, auth.PermPostEditAny) {
+			t.Error("Moderators should be able to edit any post")
+		}
+	})
+
+	t.Run("Require Permission", func(t *testing.T) {
+		protected := handlers.RequirePermission(auth.PermUserRoleAssign, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		cases := map[string]struct {
+			userID string
+			want   int
+		}{
+			"Anonymous": {"", http.StatusUnauthorized},
+			"Moderator": {moderator.ID, http.StatusForbidden},
+			"Admin":     {admin.ID, http.StatusNoContent},
+		}
+		for name, tc := range cases {
+			r := httptest.NewRequest(http.MethodGet, "/api/admin/staff", nil)
+			if tc.userID != "" {
+				session, err := auth.CreateSession(tc.userID, "agent", "127.0.0.1")
+				if err != nil {
+					t.Fatalf("CreateSession should not return error, got: %v", err)
+				}
+				r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+			}
+
+			w := httptest.NewRecorder()
+			protected(w, r)
+			if w.Code != tc.want {
+				t.Errorf("%s: expected status %d, got %d", name, tc.want, w.Code)
+			}
+		}
+	})
+}
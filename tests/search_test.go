@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/blocks"
+	"forum/internal/config"
+	"forum/internal/database"
+	"forum/internal/handlers"
+	"forum/internal/models"
+)
+
+// Test full-text search and its filters
+func TestSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := database.Initialize(config.DatabaseConfig{Path: path}); err != nil {
+		t.Fatalf("Initialize should not return error, got: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Close()
+		database.SearchEnabled = false
+	})
+
+	// search requests the search endpoint, as viewer when set
+	search := func(query url.Values, viewer *models.User) (int, models.SearchResults) {
+		r := httptest.NewRequest(http.MethodGet, "/api/search?"+query.Encode(), nil)
+		if viewer != nil {
+			session, err := auth.CreateSession(viewer.ID, "agent", "127.0.0.1")
+			if err != nil {
+				t.Fatalf("CreateSession should not return error, got: %v", err)
+			}
+			r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+		}
+		w := httptest.NewRecorder()
+		handlers.SearchHandler(w, r)
+
+		var response struct{ Data models.SearchResults }
+		json.NewDecoder(w.Body).Decode(&response)
+		return w.Code, response.Data
+	}
+
+	t.Run("Unavailable Without FTS5", func(t *testing.T) {
+		enabled := database.SearchEnabled
+		database.SearchEnabled = false
+		defer func() { database.SearchEnabled = enabled }()
+
+		if code, _ := search(url.Values{"q": {"anything"}}, nil); code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503, got %d", code)
+		}
+	})
+
+	if !database.SearchEnabled {
+		t.Skip("Full-text search needs a build with -tags sqlite_fts5")
+	}
+
+	early := createTestUser(t, "earlybird")
+	viewer := createTestUser(t, "searcher")
+	writer := createTestUser(t, "gardenwriter")
+	pest := createTestUser(t, "gardenpest")
+	if err := blocks.Block(viewer.ID, pest.ID); err != nil {
+		t.Fatalf("Block should not return error, got: %v", err)
+	}
+
+	written := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	insertPost := func(author *models.User, title string) int64 {
+		result, err := database.DB.Exec(`
+			INSERT INTO posts (user_id, title, content, created_at, updated_at) VALUES (?, ?, 'Tomatoes need <b>sun</b>', ?, ?)
+		`, author.ID, title, written, written)
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return id
+	}
+	postID := insertPost(writer, "Gardening tips")
+	insertPost(pest, "Gardening secrets")
+	hiddenID := insertPost(writer, "Gardening mistakes")
+	database.DB.Exec("UPDATE posts SET hidden_at = ? WHERE id = ?", time.Now(), hiddenID)
+
+	for _, author := range []*models.User{writer, pest} {
+		if _, err := database.DB.Exec(`
+			INSERT INTO comments (post_id, user_id, content, created_at, updated_at) VALUES (?, ?, 'More compost please', ?, ?)
+		`, postID, author.ID, written, written); err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+	}
+
+	t.Run("Prefix Match", func(t *testing.T) {
+		code, results := search(url.Values{"q": {"garden"}, "type": {"posts"}}, viewer)
+		if code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+		if len(results.Posts) != 1 || results.Posts[0].ID != int(postID) {
+			t.Fatalf("Expected only post %d, got %+v", postID, results.Posts)
+		}
+		if results.Posts[0].TitleSnippet != "<mark>Gardening</mark> tips" {
+			t.Errorf("Expected the match highlighted, got %q", results.Posts[0].TitleSnippet)
+		}
+		if len(results.Comments) != 0 || len(results.Users) != 0 {
+			t.Errorf("Expected only posts for type=posts, got %+v", results)
+		}
+	})
+
+	t.Run("Snippets Are Escaped", func(t *testing.T) {
+		_, results := search(url.Values{"q": {"tomatoes"}, "type": {"posts"}}, viewer)
+		if len(results.Posts) != 1 || strings.Contains(results.Posts[0].ContentSnippet, "<b>") {
+			t.Errorf("Expected the post body HTML-escaped, got %+v", results.Posts)
+		}
+	})
+
+	t.Run("Query Sanitisation", func(t *testing.T) {
+		for _, q := range []string{`garden" OR "secrets`, `NOT garden`, `garden*`, `(garden`, `title:garden`, `"`, `^`} {
+			if code, _ := search(url.Values{"q": {q}}, viewer); code != http.StatusOK {
+				t.Errorf("%q: expected 200, got %d", q, code)
+			}
+		}
+
+		// Operators are taken literally, so both words must be present
+		_, results := search(url.Values{"q": {"gardening OR secrets"}, "type": {"posts"}}, nil)
+		if len(results.Posts) != 0 {
+			t.Errorf("Expected OR to be a search word, got %+v", results.Posts)
+		}
+
+		if code, _ := search(url.Values{"q": {"   "}}, nil); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a blank query, got %d", code)
+		}
+		if code, _ := search(url.Values{"q": {"garden"}, "limit": {"0"}}, nil); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a zero limit, got %d", code)
+		}
+	})
+
+	t.Run("Hidden And Blocked Content", func(t *testing.T) {
+		_, results := search(url.Values{"q": {"gardening"}, "type": {"posts"}}, nil)
+		if len(results.Posts) != 2 {
+			t.Errorf("Expected anonymous visitors to see 2 posts, got %d", len(results.Posts))
+		}
+		for _, post := range results.Posts {
+			if post.ID == int(hiddenID) {
+				t.Error("Expected the hidden post left out")
+			}
+		}
+		if _, results := search(url.Values{"q": {"gardening"}, "type": {"posts"}}, viewer); len(results.Posts) != 1 {
+			t.Errorf("Expected the blocked user's post left out, got %+v", results.Posts)
+		}
+
+		if _, results := search(url.Values{"q": {"compost"}, "type": {"comments"}}, nil); len(results.Comments) != 2 {
+			t.Errorf("Expected anonymous visitors to see 2 comments, got %d", len(results.Comments))
+		}
+		_, results = search(url.Values{"q": {"compost"}, "type": {"comments"}}, viewer)
+		if len(results.Comments) != 1 || results.Comments[0].UserID != writer.ID {
+			t.Errorf("Expected the blocked user's comment left out, got %+v", results.Comments)
+		}
+	})
+
+	t.Run("Dates", func(t *testing.T) {
+		cases := []struct {
+			from, to string
+			want     int
+		}{
+			{"2026-03-10", "2026-03-10", 1},
+			{"2026-03-11", "", 0},
+			{"", "2026-03-09", 0},
+			{"2026-03-10T11:00:00Z", "2026-03-10T13:00:00Z", 1},
+			{"2026-03-10T12:30:00Z", "", 0},
+			{"2026-03-10T13:30:00+02:00", "", 1},
+		}
+		for _, tc := range cases {
+			query := url.Values{"q": {"gardening"}, "type": {"posts"}, "author": {writer.Nickname}}
+			if tc.from != "" {
+				query.Set("from", tc.from)
+			}
+			if tc.to != "" {
+				query.Set("to", tc.to)
+			}
+			if _, results := search(query, viewer); len(results.Posts) != tc.want {
+				t.Errorf("from %q to %q: expected %d posts, got %d", tc.from, tc.to, tc.want, len(results.Posts))
+			}
+		}
+
+		for _, bad := range []url.Values{{"from": {"10/03/2026"}}, {"to": {"yesterday"}}} {
+			bad.Set("q", "gardening")
+			if code, _ := search(bad, viewer); code != http.StatusBadRequest {
+				t.Errorf("%v: expected 400, got %d", bad, code)
+			}
+		}
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		_, results := search(url.Values{"q": {"gardenw"}, "type": {"users"}}, nil)
+		if len(results.Users) != 1 || results.Users[0].ID != writer.ID {
+			t.Fatalf("Expected %s, got %+v", writer.Nickname, results.Users)
+		}
+		if results.Users[0].NicknameSnippet != "<mark>gardenwriter</mark>" {
+			t.Errorf("Expected the nickname highlighted, got %q", results.Users[0].NicknameSnippet)
+		}
+
+		if _, results := search(url.Values{"q": {"gardenpest"}, "type": {"users"}}, nil); len(results.Users) != 1 {
+			t.Errorf("Expected anonymous visitors to find %s, got %+v", pest.Nickname, results.Users)
+		}
+		if _, results := search(url.Values{"q": {"gardenpest"}, "type": {"users"}}, viewer); len(results.Users) != 0 {
+			t.Errorf("Expected the blocked user left out, got %+v", results.Users)
+		}
+	})
+
+	t.Run("Users With New Rowids", func(t *testing.T) {
+		// VACUUM may renumber the implicit rowids of users, which must not
+		// point the index at other accounts. Renumber one directly, since
+		// whether VACUUM does depends on the SQLite version.
+		if _, err := database.DB.Exec("DELETE FROM users WHERE id = ?", early.ID); err != nil {
+			t.Fatalf("Failed to delete user: %v", err)
+		}
+		if _, err := database.DB.Exec("UPDATE users SET rowid = rowid + 1000 WHERE id = ?", writer.ID); err != nil {
+			t.Fatalf("Failed to renumber user: %v", err)
+		}
+		if _, err := database.DB.Exec("VACUUM"); err != nil {
+			t.Fatalf("VACUUM should not return error, got: %v", err)
+		}
+
+		_, results := search(url.Values{"q": {"gardenwriter"}, "type": {"users"}}, nil)
+		if len(results.Users) != 1 || results.Users[0].ID != writer.ID {
+			t.Errorf("Expected %s after renumbering, got %+v", writer.ID, results.Users)
+		}
+		_, results = search(url.Values{"q": {"earlybird"}, "type": {"users"}}, nil)
+		if len(results.Users) != 0 {
+			t.Errorf("Expected the deleted user gone from the index, got %+v", results.Users)
+		}
+	})
+
+	t.Run("Renamed Users", func(t *testing.T) {
+		if err := auth.ChangeNickname(writer.ID, "password123", "plantlover"); err != nil {
+			t.Fatalf("ChangeNickname should not return error, got: %v", err)
+		}
+
+		if _, results := search(url.Values{"q": {"plantlover"}, "type": {"users"}}, nil); len(results.Users) != 1 {
+			t.Errorf("Expected the new nickname indexed, got %+v", results.Users)
+		}
+		if _, results := search(url.Values{"q": {"gardenwriter"}, "type": {"users"}}, nil); len(results.Users) != 0 {
+			t.Errorf("Expected the old nickname gone, got %+v", results.Users)
+		}
+	})
+
+	t.Run("Old User Index Is Rebuilt", func(t *testing.T) {
+		// Databases indexed before users_fts was keyed by user ID
+		database.DB.Exec("DROP TRIGGER users_fts_insert")
+		database.DB.Exec("DROP TRIGGER users_fts_delete")
+		database.DB.Exec("DROP TRIGGER users_fts_update")
+		database.DB.Exec("DROP TABLE users_fts")
+		if _, err := database.DB.Exec(`
+			CREATE VIRTUAL TABLE users_fts USING fts5(nickname, content='users', content_rowid='rowid')
+		`); err != nil {
+			t.Fatalf("Failed to create the old index: %v", err)
+		}
+		database.DB.Close()
+
+		if err := database.Initialize(config.DatabaseConfig{Path: path}); err != nil {
+			t.Fatalf("Initialize should not return error, got: %v", err)
+		}
+		_, results := search(url.Values{"q": {"plantlover"}, "type": {"users"}}, nil)
+		if len(results.Users) != 1 || results.Users[0].ID != writer.ID {
+			t.Errorf("Expected the index rebuilt with %s, got %+v", writer.ID, results.Users)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/config"
+	"forum/internal/database"
+	"forum/internal/models"
+)
+
+// openTestDatabase opens a migrated database in a temporary directory
+func openTestDatabase(t *testing.T) {
+	if err := database.Open(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Open should not return error, got: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+
+	if _, err := database.MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp should not return error, got: %v", err)
+	}
+}
+
+// createTestUser registers a user with the given nickname
+func createTestUser(t *testing.T, nickname string) *models.User {
+	user, err := auth.CreateUser(&models.RegisterRequest{
+		Email: nickname + "@example.com", Nickname: nickname, Password: "password123",
+		FirstName: "Test", LastName: "User", Age: 30, Gender: "female",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser should not return error, got: %v", err)
+	}
+	return user
+}
+
+// Test listing and revoking a user's sessions
+func TestSessionManagement(t *testing.T) {
+	openTestDatabase(t)
+	user := createTestUser(t, "devices")
+	other := createTestUser(t, "someone")
+
+	laptop, err := auth.CreateSession(user.ID, "Firefox on Linux", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("CreateSession should not return error, got: %v", err)
+	}
+	phone, _ := auth.CreateSession(user.ID, "Safari on iPhone", "10.0.0.2")
+	tablet, _ := auth.CreateSession(user.ID, "Chrome on Android", "10.0.0.3")
+	othersSession, _ := auth.CreateSession(other.ID, "Edge on Windows", "10.0.0.4")
+
+	t.Run("List Sessions", func(t *testing.T) {
+		sessions, err := auth.ListSessions(user.ID, laptop.ID)
+		if err != nil {
+			t.Fatalf("ListSessions should not return error, got: %v", err)
+		}
+		if len(sessions) != 3 {
+			t.Fatalf("Expected 3 sessions, got %d", len(sessions))
+		}
+
+		current := 0
+		for _, session := range sessions {
+			if session.ID == laptop.ID || session.ID == phone.ID {
+				t.Error("Listed sessions must not expose session IDs")
+			}
+			if session.Current {
+				current++
+				if session.UserAgent != "Firefox on Linux" || session.IPAddress != "10.0.0.1" {
+					t.Errorf("Current session has wrong device details: %+v", session)
+				}
+			}
+		}
+		if current != 1 {
+			t.Errorf("Expected exactly one current session, got %d", current)
+		}
+	})
+
+	t.Run("Revoke Another User's Session", func(t *testing.T) {
+		if err := auth.RevokeSession(user.ID, othersSession.PublicID); !errors.Is(err, auth.ErrSessionNotFound) {
+			t.Errorf("Expected ErrSessionNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("Revoke One Session", func(t *testing.T) {
+		if err := auth.RevokeSession(user.ID, phone.PublicID); err != nil {
+			t.Fatalf("RevokeSession should not return error, got: %v", err)
+		}
+		if session, _ := auth.GetSessionByID(phone.ID); session != nil {
+			t.Error("Revoked session should no longer be valid")
+		}
+	})
+
+	t.Run("Revoke Other Sessions", func(t *testing.T) {
+		revoked, err := auth.RevokeOtherSessions(user.ID, laptop.ID)
+		if err != nil {
+			t.Fatalf("RevokeOtherSessions should not return error, got: %v", err)
+		}
+		if len(revoked) != 1 || revoked[0] != tablet.PublicID {
+			t.Errorf("Expected only the tablet session revoked, got %v", revoked)
+		}
+		if session, _ := auth.GetSessionByID(laptop.ID); session == nil {
+			t.Error("Current session should survive signing out elsewhere")
+		}
+		if session, _ := auth.GetSessionByID(othersSession.ID); session == nil {
+			t.Error("Other users' sessions should not be revoked")
+		}
+	})
+}
+
+// Test idle and absolute session expiry, renewal and ID rotation
+func TestSessionExpiry(t *testing.T) {
+	cfg := config.Default().Auth
+	cfg.SessionDuration = config.Duration{Duration: 2 * time.Hour}
+	cfg.SessionIdleTimeout = config.Duration{Duration: 30 * time.Minute}
+	auth.Initialize(cfg)
+	defer auth.Initialize(config.Default().Auth)
+
+	openTestDatabase(t)
+	user := createTestUser(t, "expiry")
+
+	// requestWith builds a request carrying the session cookie
+	requestWith := func(sessionID string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/user", nil)
+		r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: sessionID})
+		return r
+	}
+
+	t.Run("New Session Expires After Idle Timeout", func(t *testing.T) {
+		session, _ := auth.CreateSession(user.ID, "agent", "127.0.0.1")
+		if expected := session.CreatedAt.Add(30 * time.Minute); !session.ExpiresAt.Equal(expected) {
+			t.Errorf("Expected expiry %s, got %s", expected, session.ExpiresAt)
+		}
+	})
+
+	t.Run("Activity Renews Session", func(t *testing.T) {
+		session, _ := auth.CreateSession(user.ID, "agent", "127.0.0.1")
+		lastActive := time.Now().Add(-20 * time.Minute)
+		database.DB.Exec("UPDATE sessions SET last_active_at = ?, expires_at = ? WHERE id = ?",
+			lastActive, lastActive.Add(30*time.Minute), session.ID)
+
+		if renewed, err := auth.GetSessionFromRequest(requestWith(session.ID)); err != nil || renewed == nil {
+			t.Fatalf("Session should still be valid, got %v (%v)", renewed, err)
+		}
+
+		stored, _ := auth.GetSessionByID(session.ID)
+		if remaining := time.Until(stored.ExpiresAt); remaining < 29*time.Minute {
+			t.Errorf("Activity should extend the session by the idle timeout, %s left", remaining)
+		}
+	})
+
+	t.Run("Renewal Stops At Absolute Lifetime", func(t *testing.T) {
+		session, _ := auth.CreateSession(user.ID, "agent", "127.0.0.1")
+		createdAt := time.Now().Add(-110 * time.Minute)
+		lastActive := time.Now().Add(-5 * time.Minute)
+		database.DB.Exec("UPDATE sessions SET created_at = ?, last_active_at = ?, expires_at = ? WHERE id = ?",
+			createdAt, lastActive, lastActive.Add(30*time.Minute), session.ID)
+
+		auth.GetSessionFromRequest(requestWith(session.ID))
+
+		stored, _ := auth.GetSessionByID(session.ID)
+		if stored.ExpiresAt.After(createdAt.Add(2 * time.Hour)) {
+			t.Errorf("Session should not outlive its absolute lifetime, expires %s", stored.ExpiresAt)
+		}
+	})
+
+	t.Run("Idle Session Is Cleaned Up", func(t *testing.T) {
+		session, _ := auth.CreateSession(user.ID, "agent", "127.0.0.1")
+		database.DB.Exec("UPDATE sessions SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Second), session.ID)
+
+		if expired, _ := auth.GetSessionFromRequest(requestWith(session.ID)); expired != nil {
+			t.Error("Expired session should not be valid")
+		}
+
+		removed, err := auth.CleanupExpiredSessions()
+		if err != nil {
+			t.Fatalf("CleanupExpiredSessions should not return error, got: %v", err)
+		}
+		if removed != 1 {
+			t.Errorf("Expected 1 expired session removed, got %d", removed)
+		}
+	})
+
+	t.Run("Rotation Replaces Session ID", func(t *testing.T) {
+		session, _ := auth.CreateSession(user.ID, "agent", "127.0.0.1")
+		oldID, publicID, token := session.ID, session.PublicID, session.CSRFToken
+
+		w := httptest.NewRecorder()
+		if err := auth.RotateSession(w, session); err != nil {
+			t.Fatalf("RotateSession should not return error, got: %v", err)
+		}
+
+		if old, _ := auth.GetSessionByID(oldID); old != nil {
+			t.Error("The old session ID should stop working")
+		}
+		rotated, _ := auth.GetSessionByID(session.ID)
+		if rotated == nil || rotated.PublicID != publicID || rotated.CSRFToken != token {
+			t.Errorf("Rotated session should keep its public ID and CSRF token, got %+v", rotated)
+		}
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Value != session.ID {
+			t.Errorf("Expected the new session ID in the cookie, got %v", cookies)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/config"
+	"forum/internal/database"
+	"forum/internal/models"
+	"forum/internal/websocket"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// Test draining the websocket hub on shutdown
+func TestHubShutdown(t *testing.T) {
+	openTestDatabase(t)
+	alice := createTestUser(t, "drainalice")
+	bob := createTestUser(t, "drainbob")
+
+	t.Run("Flush Before Going Away", func(t *testing.T) {
+		server := startSocketServer(t, config.Default().WebSocket)
+		reader := dialSocket(t, server, alice.ID)
+		sender := dialSocket(t, server, bob.ID)
+		hub := websocket.GetHub()
+
+		for i := 0; i < 50; i++ {
+			hub.BroadcastToUser(alice.ID, models.WebSocketMessage{Type: "notification", Data: map[string]int{"n": i}})
+		}
+
+		// Frames keep arriving while the hub drains; they must not crash it
+		sending := make(chan struct{})
+		go func() {
+			defer close(sending)
+			for i := 0; i < 500; i++ {
+				frame := `{"type": "private_message", "data": {"receiverId": "no-such-user", "content": "x"}}`
+				if sender.conn.WriteMessage(gorilla.TextMessage, []byte(frame)) != nil {
+					return
+				}
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := hub.Shutdown(ctx); err != nil {
+			t.Fatalf("Shutdown should not return error, got: %v", err)
+		}
+		<-sending
+
+		notifications := 0
+		for {
+			frame, err := reader.next(2 * time.Second)
+			if err != nil {
+				if !gorilla.IsCloseError(err, gorilla.CloseGoingAway) {
+					t.Errorf("Expected a going-away close frame, got: %v", err)
+				}
+				break
+			}
+			if frame.Type == "notification" {
+				notifications++
+			}
+		}
+		if notifications != 50 {
+			t.Errorf("Expected the 50 queued notifications before the close frame, got %d", notifications)
+		}
+
+		var online int
+		database.DB.QueryRow("SELECT COUNT(*) FROM online_users").Scan(&online)
+		if online != 0 {
+			t.Errorf("Expected the online sessions to be cleared, got %d", online)
+		}
+		if users := hub.GetOnlineUsers(); len(users) != 0 {
+			t.Errorf("Expected no online users, got %v", users)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		server := startSocketServer(t, config.Default().WebSocket)
+		// A client that stops reading leaves writePump blocked on a full
+		// connection, so the drain cannot finish
+		dialSocketConn(t, server, alice.ID)
+		hub := websocket.GetHub()
+		for !hub.IsUserOnline(alice.ID) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		payload := strings.Repeat("x", 128*1024)
+		for i := 0; i < 250; i++ {
+			hub.BroadcastToUser(alice.ID, models.WebSocketMessage{Type: "notification", Data: map[string]string{"padding": payload}})
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		started := time.Now()
+		err := hub.Shutdown(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the deadline to be exceeded, got: %v", err)
+		}
+		if elapsed := time.Since(started); elapsed > 2*time.Second {
+			t.Errorf("Expected Shutdown to return soon after the deadline, took %v", elapsed)
+		}
+	})
+
+	t.Run("Refuse New Clients", func(t *testing.T) {
+		server := startSocketServer(t, config.Default().WebSocket)
+		websocket.GetHub().Shutdown(context.Background())
+
+		_, resp, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err == nil || resp == nil || resp.StatusCode != 401 && resp.StatusCode != 503 {
+			t.Errorf("Expected the connection to be refused, got: %v", err)
+		}
+	})
+}
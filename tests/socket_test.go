@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/config"
+	"forum/internal/websocket"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// testSocket is a websocket client connected to a test server. Frames are
+// read in the background so that waiting for one never breaks the
+// connection.
+type testSocket struct {
+	t      *testing.T
+	conn   *gorilla.Conn
+	frames chan testFrame
+
+	// Why frames was closed; only read once it is
+	err error
+}
+
+// testFrame is a decoded server frame
+type testFrame struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// startSocketServer starts a fresh hub with cfg behind a test server. The
+// hub is shut down when the test ends, so its pumps never outlive the
+// test's database.
+func startSocketServer(t *testing.T, cfg config.WebSocketConfig) *httptest.Server {
+	websocket.InitializeHub(cfg)
+	hub := websocket.GetHub()
+	server := httptest.NewServer(http.HandlerFunc(websocket.HandleWebSocket))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		hub.Shutdown(ctx)
+	})
+	return server
+}
+
+// dialSocketConn opens a websocket to server with a new session of the user
+func dialSocketConn(t *testing.T, server *httptest.Server, userID string) *gorilla.Conn {
+	session, err := auth.CreateSession(userID, "agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("CreateSession should not return error, got: %v", err)
+	}
+
+	header := http.Header{}
+	header.Add("Cookie", (&http.Cookie{Name: auth.SessionCookieName, Value: session.ID}).String())
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("Dial should not return error, got: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// dialSocket opens a websocket like dialSocketConn and waits until the hub
+// has registered it
+func dialSocket(t *testing.T, server *httptest.Server, userID string) *testSocket {
+	socket := &testSocket{t: t, conn: dialSocketConn(t, server, userID), frames: make(chan testFrame, 64)}
+	go socket.read()
+
+	// Registration is asynchronous and pings are only answered once the
+	// client is registered, so ping until a pong arrives
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			socket.conn.WriteMessage(gorilla.TextMessage, []byte(`{"type": "ping"}`))
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	socket.expect("pong")
+	close(stop)
+	<-stopped
+
+	return socket
+}
+
+// read decodes frames until the connection fails. The server batches
+// queued frames into one message separated by newlines.
+func (s *testSocket) read() {
+	defer close(s.frames)
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			s.err = err
+			return
+		}
+		for _, raw := range bytes.Split(message, []byte{'\n'}) {
+			var frame testFrame
+			if err := json.Unmarshal(raw, &frame); err != nil {
+				s.err = err
+				return
+			}
+			s.frames <- frame
+		}
+	}
+}
+
+// send writes a frame
+func (s *testSocket) send(frameType string, data interface{}) {
+	frame, _ := json.Marshal(map[string]interface{}{"type": frameType, "data": data})
+	if err := s.conn.WriteMessage(gorilla.TextMessage, frame); err != nil {
+		s.t.Fatalf("WriteMessage should not return error, got: %v", err)
+	}
+}
+
+// errNoFrame is returned by next when no frame arrives in time
+var errNoFrame = errors.New("no frame received")
+
+// next returns the next frame, errNoFrame once wait passes without one, or
+// the read error once the connection is closed
+func (s *testSocket) next(wait time.Duration) (testFrame, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case frame, ok := <-s.frames:
+		if !ok {
+			return frame, s.err
+		}
+		return frame, nil
+	case <-timer.C:
+		return testFrame{}, errNoFrame
+	}
+}
+
+// expect skips frames until one of frameType arrives and returns its data
+func (s *testSocket) expect(frameType string) map[string]interface{} {
+	s.t.Helper()
+	for {
+		frame, err := s.next(2 * time.Second)
+		if err != nil {
+			s.t.Fatalf("Expected a %s frame, got: %v", frameType, err)
+		}
+		if frame.Type == frameType {
+			return frame.Data
+		}
+	}
+}
+
+// Test delivering, acknowledging and rejecting private_message frames
+func TestWebSocketDelivery(t *testing.T) {
+	openTestDatabase(t)
+	alice := createTestUser(t, "socketalice")
+	bob := createTestUser(t, "socketbob")
+
+	server := startSocketServer(t, config.Default().WebSocket)
+	laptop := dialSocket(t, server, alice.ID)
+	phone := dialSocket(t, server, alice.ID)
+	receiver := dialSocket(t, server, bob.ID)
+
+	t.Run("Ack To Every Session", func(t *testing.T) {
+		laptop.send("private_message", map[string]interface{}{
+			"receiverId": bob.ID, "content": "hello over the socket", "tempId": "temp-1",
+		})
+
+		ack := laptop.expect("message_sent")
+		if ack["tempId"] != "temp-1" || ack["messageId"] == "" || ack["messageId"] == nil {
+			t.Fatalf("Expected an ack of temp-1 with the stored ID, got %v", ack)
+		}
+		if other := phone.expect("message_sent"); other["messageId"] != ack["messageId"] {
+			t.Errorf("Expected the other session to get the same ack, got %v", other)
+		}
+
+		delivered := receiver.expect("new_message")
+		if delivered["id"] != ack["messageId"] || delivered["content"] != "hello over the socket" {
+			t.Errorf("Expected the receiver to get the message, got %v", delivered)
+		}
+	})
+
+	t.Run("Unknown Receiver", func(t *testing.T) {
+		laptop.send("private_message", map[string]interface{}{
+			"receiverId": "no-such-user", "content": "hello?", "tempId": "temp-2",
+		})
+
+		rejected := laptop.expect("message_error")
+		if rejected["tempId"] != "temp-2" || rejected["error"] == "" || rejected["error"] == nil {
+			t.Errorf("Expected an error for temp-2, got %v", rejected)
+		}
+	})
+
+	t.Run("Empty Content", func(t *testing.T) {
+		laptop.send("private_message", map[string]interface{}{
+			"receiverId": bob.ID, "content": "   ", "tempId": "temp-3",
+		})
+
+		if rejected := laptop.expect("message_error"); rejected["tempId"] != "temp-3" {
+			t.Errorf("Expected an error for temp-3, got %v", rejected)
+		}
+	})
+
+	t.Run("Invalid Frame", func(t *testing.T) {
+		laptop.send("private_message", "not an object")
+
+		if rejected := laptop.expect("message_error"); rejected["error"] != "Invalid message format" {
+			t.Errorf("Expected an invalid format error, got %v", rejected)
+		}
+	})
+
+	t.Run("Errors Go To The Sending Session Only", func(t *testing.T) {
+		// The phone already saw the first ack; anything else it gets now
+		// must not be one of the laptop's errors
+		for {
+			frame, err := phone.next(200 * time.Millisecond)
+			if err != nil {
+				break
+			}
+			if frame.Type == "message_error" {
+				t.Errorf("Expected errors to reach the sending session only, got %v", frame.Data)
+			}
+		}
+	})
+}
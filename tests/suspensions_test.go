@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/database"
+)
+
+// Test suspending, banning and reinstating users
+func TestSuspensions(t *testing.T) {
+	openTestDatabase(t)
+	user := createTestUser(t, "troll")
+	moderator := createTestUser(t, "moderator")
+	auth.SetUserRole(moderator.ID, auth.RoleModerator)
+
+	session, err := auth.CreateSession(user.ID, "agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("CreateSession should not return error, got: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/user", nil)
+	r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session.ID})
+
+	t.Run("Invalid Suspensions", func(t *testing.T) {
+		if _, err := auth.SuspendUser(user.ID, moderator.ID, "  ", time.Hour); !errors.Is(err, auth.ErrSuspensionReason) {
+			t.Errorf("Expected ErrSuspensionReason, got: %v", err)
+		}
+		if _, err := auth.SuspendUser(moderator.ID, moderator.ID, "abuse", time.Hour); !errors.Is(err, auth.ErrCannotSuspendStaff) {
+			t.Errorf("Expected ErrCannotSuspendStaff, got: %v", err)
+		}
+		if _, err := auth.SuspendUser("no-such-user", moderator.ID, "abuse", time.Hour); !errors.Is(err, auth.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("Suspend", func(t *testing.T) {
+		suspension, err := auth.SuspendUser(user.ID, moderator.ID, "flaming", 24*time.Hour)
+		if err != nil {
+			t.Fatalf("SuspendUser should not return error, got: %v", err)
+		}
+		if suspension.ExpiresAt == nil || suspension.Nickname != user.Nickname ||
+			suspension.CreatedBy == nil || *suspension.CreatedBy != moderator.Nickname {
+			t.Errorf("Expected a suspension of %s by %s, got %+v", user.Nickname, moderator.Nickname, suspension)
+		}
+
+		if stored, _ := auth.GetSessionByID(session.ID); stored != nil {
+			t.Error("Suspending a user should end their sessions")
+		}
+		if auth.GetUserFromSession(r) != nil {
+			t.Error("Suspended users should be signed out")
+		}
+	})
+
+	t.Run("Sign In While Suspended", func(t *testing.T) {
+		var suspended *auth.SuspendedError
+		if _, err := auth.AuthenticateUser(user.Nickname, "password123"); !errors.As(err, &suspended) {
+			t.Fatalf("Expected a SuspendedError, got: %v", err)
+		}
+		if suspended.Suspension.Reason != "flaming" {
+			t.Errorf("Expected the suspension reason, got %q", suspended.Suspension.Reason)
+		}
+		if _, err := auth.AuthenticateUser(user.Nickname, "wrong"); errors.As(err, &suspended) {
+			t.Error("A wrong password should not reveal the suspension")
+		}
+		if _, err := auth.CreateSession(user.ID, "agent", "127.0.0.1"); !errors.As(err, &suspended) {
+			t.Errorf("Suspended users should not get new sessions, got: %v", err)
+		}
+	})
+
+	t.Run("Ban Outranks Suspension", func(t *testing.T) {
+		if _, err := auth.SuspendUser(user.ID, moderator.ID, "ban evasion", 0); err != nil {
+			t.Fatalf("SuspendUser should not return error, got: %v", err)
+		}
+		if _, err := auth.SuspendUser(user.ID, moderator.ID, "more flaming", 48*time.Hour); err != nil {
+			t.Fatalf("SuspendUser should not return error, got: %v", err)
+		}
+
+		active, err := auth.GetActiveSuspension(user.ID)
+		if err != nil {
+			t.Fatalf("GetActiveSuspension should not return error, got: %v", err)
+		}
+		if active == nil || active.ExpiresAt != nil || active.Reason != "ban evasion" {
+			t.Errorf("Expected the ban to be in force, got %+v", active)
+		}
+
+		suspensions, _ := auth.ListSuspensions()
+		if len(suspensions) != 3 {
+			t.Errorf("Expected 3 suspensions in force, got %d", len(suspensions))
+		}
+	})
+
+	t.Run("Lift", func(t *testing.T) {
+		if err := auth.LiftSuspension(user.ID, moderator.ID); err != nil {
+			t.Fatalf("LiftSuspension should not return error, got: %v", err)
+		}
+		if err := auth.LiftSuspension(user.ID, moderator.ID); !errors.Is(err, auth.ErrNotSuspended) {
+			t.Errorf("Expected ErrNotSuspended, got: %v", err)
+		}
+		if _, err := auth.AuthenticateUser(user.Nickname, "password123"); err != nil {
+			t.Errorf("Reinstated users should be able to sign in, got: %v", err)
+		}
+	})
+
+	t.Run("Expired Suspension", func(t *testing.T) {
+		if _, err := auth.SuspendUser(user.ID, moderator.ID, "cool off", time.Hour); err != nil {
+			t.Fatalf("SuspendUser should not return error, got: %v", err)
+		}
+		database.DB.Exec("UPDATE user_suspensions SET expires_at = ? WHERE lifted_at IS NULL", time.Now().Add(-time.Second))
+
+		if active, _ := auth.GetActiveSuspension(user.ID); active != nil {
+			t.Errorf("Expired suspensions should not be in force, got %+v", active)
+		}
+		if _, err := auth.CreateSession(user.ID, "agent", "127.0.0.1"); err != nil {
+			t.Errorf("Users should sign in again once the suspension expires, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/database"
+)
+
+// totpNow returns the current authenticator code for secret, offset by a
+// number of 30 second steps
+func totpNow(t *testing.T, secret string, steps int) string {
+	code, err := auth.TOTPCode(secret, time.Now().Add(time.Duration(steps)*30*time.Second))
+	if err != nil {
+		t.Fatalf("TOTPCode should not return error, got: %v", err)
+	}
+	return code
+}
+
+// Test the TOTP algorithm against the RFC 6238 test vector
+func TestTOTPCode(t *testing.T) {
+	// "12345678901234567890" in base32, which gives 94287082 at T=59
+	code, err := auth.TOTPCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", time.Unix(59, 0))
+	if err != nil {
+		t.Fatalf("TOTPCode should not return error, got: %v", err)
+	}
+	if code != "287082" {
+		t.Errorf("Expected code 287082, got %s", code)
+	}
+}
+
+// Test enrolling in two-factor authentication and signing in with it
+func TestTwoFactorAuthentication(t *testing.T) {
+	openTestDatabase(t)
+	user := createTestUser(t, "twofactor")
+
+	var secret string
+	var recoveryCodes []string
+
+	t.Run("Setup", func(t *testing.T) {
+		if _, err := auth.EnableTwoFactor(user.ID, "123456"); !errors.Is(err, auth.ErrTwoFactorNotStarted) {
+			t.Errorf("Expected ErrTwoFactorNotStarted, got: %v", err)
+		}
+		if _, err := auth.BeginTwoFactorSetup(user, "wrong-password"); !errors.Is(err, auth.ErrWrongPassword) {
+			t.Errorf("Expected ErrWrongPassword, got: %v", err)
+		}
+
+		setup, err := auth.BeginTwoFactorSetup(user, "password123")
+		if err != nil {
+			t.Fatalf("BeginTwoFactorSetup should not return error, got: %v", err)
+		}
+		secret = setup.Secret
+
+		uri, err := url.Parse(setup.URI)
+		if err != nil {
+			t.Fatalf("Setup URI should parse, got: %v", err)
+		}
+		if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Forum:twofactor" {
+			t.Errorf("Unexpected setup URI: %s", setup.URI)
+		}
+		if uri.Query().Get("secret") != secret {
+			t.Errorf("Setup URI should carry the secret, got: %s", setup.URI)
+		}
+
+		if _, err := auth.EnableTwoFactor(user.ID, "000000"); !errors.Is(err, auth.ErrInvalidCode) {
+			t.Errorf("Expected ErrInvalidCode, got: %v", err)
+		}
+
+		recoveryCodes, err = auth.EnableTwoFactor(user.ID, totpNow(t, secret, 0))
+		if err != nil {
+			t.Fatalf("EnableTwoFactor should not return error, got: %v", err)
+		}
+		if len(recoveryCodes) != 10 {
+			t.Errorf("Expected 10 recovery codes, got %d", len(recoveryCodes))
+		}
+
+		updated, _ := auth.GetUserByID(user.ID)
+		if !updated.TwoFactorEnabled {
+			t.Error("Two-factor authentication should be enabled")
+		}
+		user = updated
+
+		if _, err := auth.BeginTwoFactorSetup(user, "password123"); !errors.Is(err, auth.ErrTwoFactorEnabled) {
+			t.Errorf("Expected ErrTwoFactorEnabled, got: %v", err)
+		}
+	})
+
+	t.Run("Login Challenge", func(t *testing.T) {
+		challenge, err := auth.BeginTwoFactorLogin(user.ID)
+		if err != nil {
+			t.Fatalf("BeginTwoFactorLogin should not return error, got: %v", err)
+		}
+
+		if _, err := auth.CompleteTwoFactorLogin(challenge, totpNow(t, secret, 0)); !errors.Is(err, auth.ErrInvalidCode) {
+			t.Errorf("A code should not be accepted twice, got: %v", err)
+		}
+		if _, err := auth.CompleteTwoFactorLogin("not-a-challenge", totpNow(t, secret, 1)); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("Expected ErrInvalidToken, got: %v", err)
+		}
+
+		signedIn, err := auth.CompleteTwoFactorLogin(challenge, totpNow(t, secret, 1))
+		if err != nil {
+			t.Fatalf("CompleteTwoFactorLogin should not return error, got: %v", err)
+		}
+		if signedIn.ID != user.ID {
+			t.Errorf("Expected user %s, got %s", user.ID, signedIn.ID)
+		}
+
+		if _, err := auth.CompleteTwoFactorLogin(challenge, recoveryCodes[0]); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("A challenge should only sign in once, got: %v", err)
+		}
+	})
+
+	t.Run("Recovery Codes", func(t *testing.T) {
+		challenge, _ := auth.BeginTwoFactorLogin(user.ID)
+		if _, err := auth.CompleteTwoFactorLogin(challenge, "  "+recoveryCodes[0]+" "); err != nil {
+			t.Fatalf("A recovery code should sign in, got: %v", err)
+		}
+
+		challenge, _ = auth.BeginTwoFactorLogin(user.ID)
+		if _, err := auth.CompleteTwoFactorLogin(challenge, recoveryCodes[0]); !errors.Is(err, auth.ErrInvalidCode) {
+			t.Errorf("A recovery code should only work once, got: %v", err)
+		}
+
+		status, err := auth.GetTwoFactorStatus(user.ID)
+		if err != nil {
+			t.Fatalf("GetTwoFactorStatus should not return error, got: %v", err)
+		}
+		if !status.Enabled || status.RecoveryCodesLeft != 9 {
+			t.Errorf("Expected 9 recovery codes left, got %+v", status)
+		}
+
+		fresh, err := auth.RegenerateRecoveryCodes(user.ID, "password123", recoveryCodes[1])
+		if err != nil {
+			t.Fatalf("RegenerateRecoveryCodes should not return error, got: %v", err)
+		}
+		if _, err := auth.CompleteTwoFactorLogin(challenge, recoveryCodes[2]); !errors.Is(err, auth.ErrInvalidCode) {
+			t.Errorf("Old recovery codes should stop working, got: %v", err)
+		}
+		recoveryCodes = fresh
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		if err := auth.DisableTwoFactor(user.ID, "password123", "000000"); !errors.Is(err, auth.ErrInvalidCode) {
+			t.Errorf("Expected ErrInvalidCode, got: %v", err)
+		}
+		if err := auth.DisableTwoFactor(user.ID, "password123", "000000"); !errors.Is(err, auth.ErrInvalidCode) {
+			t.Errorf("Expected ErrInvalidCode, got: %v", err)
+		}
+
+		var failed int
+		database.DB.QueryRow("SELECT failed_logins FROM users WHERE id = ?", user.ID).Scan(&failed)
+		if failed < 2 {
+			t.Errorf("Wrong codes should count towards the login lockout even after a correct password, got %d", failed)
+		}
+
+		if err := auth.DisableTwoFactor(user.ID, "password123", recoveryCodes[0]); err != nil {
+			t.Fatalf("DisableTwoFactor should not return error, got: %v", err)
+		}
+
+		status, _ := auth.GetTwoFactorStatus(user.ID)
+		if status.Enabled || status.RecoveryCodesLeft != 0 {
+			t.Errorf("Expected two-factor authentication to be off, got %+v", status)
+		}
+	})
+}
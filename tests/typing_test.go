@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"forum/internal/config"
+)
+
+// Test throttling and stopping typing indicators
+func TestTypingIndicators(t *testing.T) {
+	openTestDatabase(t)
+	alice := createTestUser(t, "typingalice")
+	bob := createTestUser(t, "typingbob")
+
+	// connect starts a hub with the given intervals and connects both users
+	connect := func(t *testing.T, throttle, timeout time.Duration) (*testSocket, *testSocket) {
+		cfg := config.Default().WebSocket
+		cfg.TypingThrottle.Duration = throttle
+		cfg.TypingTimeout.Duration = timeout
+		server := startSocketServer(t, cfg)
+		return dialSocket(t, server, alice.ID), dialSocket(t, server, bob.ID)
+	}
+
+	// typing sends a typing indicator from alice to bob
+	typing := func(socket *testSocket, isTyping bool) {
+		socket.send("typing_indicator", map[string]interface{}{"receiverId": bob.ID, "isTyping": isTyping})
+	}
+
+	// expectTyping waits for the next indicator bob receives and checks it
+	expectTyping := func(t *testing.T, socket *testSocket, isTyping bool) {
+		t.Helper()
+		indicator := socket.expect("typing_indicator")
+		if indicator["userId"] != alice.ID || indicator["isTyping"] != isTyping {
+			t.Fatalf("Expected isTyping %v from %s, got %v", isTyping, alice.ID, indicator)
+		}
+	}
+
+	// expectNoTyping checks that bob receives no indicator within wait
+	expectNoTyping := func(t *testing.T, socket *testSocket, wait time.Duration) {
+		t.Helper()
+		deadline := time.Now().Add(wait)
+		for remaining := wait; remaining > 0; remaining = time.Until(deadline) {
+			frame, err := socket.next(remaining)
+			if err != nil {
+				return
+			}
+			if frame.Type == "typing_indicator" {
+				t.Fatalf("Expected no typing indicator, got %v", frame.Data)
+			}
+		}
+	}
+
+	t.Run("Throttle", func(t *testing.T) {
+		sender, receiver := connect(t, 300*time.Millisecond, time.Minute)
+
+		for i := 0; i < 5; i++ {
+			typing(sender, true)
+		}
+		expectTyping(t, receiver, true)
+		expectNoTyping(t, receiver, 150*time.Millisecond)
+
+		// Once the throttle interval has passed the next refresh is relayed
+		time.Sleep(200 * time.Millisecond)
+		typing(sender, true)
+		expectTyping(t, receiver, true)
+
+		typing(sender, false)
+		expectTyping(t, receiver, false)
+	})
+
+	t.Run("Stop And Start Stays Throttled", func(t *testing.T) {
+		sender, receiver := connect(t, 300*time.Millisecond, time.Minute)
+
+		typing(sender, true)
+		expectTyping(t, receiver, true)
+		for i := 0; i < 5; i++ {
+			typing(sender, false)
+			typing(sender, true)
+		}
+		expectTyping(t, receiver, false)
+		expectNoTyping(t, receiver, 150*time.Millisecond)
+
+		time.Sleep(200 * time.Millisecond)
+		typing(sender, true)
+		expectTyping(t, receiver, true)
+	})
+
+	t.Run("Auto Stop", func(t *testing.T) {
+		timeout := 300 * time.Millisecond
+		sender, receiver := connect(t, 50*time.Millisecond, timeout)
+
+		typing(sender, true)
+		started := time.Now()
+		expectTyping(t, receiver, true)
+		expectTyping(t, receiver, false)
+		if elapsed := time.Since(started); elapsed < timeout-50*time.Millisecond {
+			t.Errorf("Expected isTyping false after about %v, got it after %v", timeout, elapsed)
+		}
+	})
+
+	t.Run("Refresh Postpones Stop", func(t *testing.T) {
+		sender, receiver := connect(t, 50*time.Millisecond, 400*time.Millisecond)
+
+		typing(sender, true)
+		expectTyping(t, receiver, true)
+
+		// Refreshed for longer than the timeout; nothing but isTyping true
+		for i := 0; i < 4; i++ {
+			time.Sleep(150 * time.Millisecond)
+			typing(sender, true)
+		}
+		for {
+			frame, err := receiver.next(100 * time.Millisecond)
+			if err != nil {
+				break
+			}
+			if frame.Type == "typing_indicator" && frame.Data["isTyping"] != true {
+				t.Fatalf("Expected refreshes to keep the indicator on, got %v", frame.Data)
+			}
+		}
+
+		expectTyping(t, receiver, false)
+	})
+
+	t.Run("Stop On Send", func(t *testing.T) {
+		sender, receiver := connect(t, 50*time.Millisecond, time.Minute)
+
+		typing(sender, true)
+		expectTyping(t, receiver, true)
+
+		sender.send("private_message", map[string]interface{}{"receiverId": bob.ID, "content": "done typing"})
+		expectTyping(t, receiver, false)
+
+		// The session ended, so a later explicit stop relays nothing
+		typing(sender, false)
+		expectNoTyping(t, receiver, 200*time.Millisecond)
+	})
+}